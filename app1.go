@@ -0,0 +1,160 @@
+package jpegstructure
+
+import (
+	"bytes"
+
+	"encoding/binary"
+
+	"github.com/dsoprea/go-logging"
+)
+
+var (
+	exifPrefix = []byte{'E', 'x', 'i', 'f', 0x00, 0x00}
+	xmpPrefix = []byte("http://ns.adobe.com/xap/1.0/\x00")
+)
+
+// isExifPayload indicates whether an APP1 payload carries EXIF data.
+func isExifPayload(data []byte) bool {
+	return bytes.HasPrefix(data, exifPrefix) == true
+}
+
+// isXmpPayload indicates whether an APP1 payload carries a (standard) XMP
+// packet.
+func isXmpPayload(data []byte) bool {
+	return bytes.HasPrefix(data, xmpPrefix) == true
+}
+
+// SegmentKind distinguishes segments that share a marker by the signature at
+// the front of their payloads.
+type SegmentKind int
+
+const (
+	// SegmentKindOther is any segment that isn't APP1.
+	SegmentKindOther SegmentKind = iota
+	SegmentKindExifApp1
+	SegmentKindXmpApp1
+	SegmentKindExtendedXmpApp1
+
+	// SegmentKindUnknownApp1 is an APP1 segment with an unrecognized
+	// signature.
+	SegmentKindUnknownApp1
+)
+
+func (sk SegmentKind) String() string {
+	switch sk {
+	case SegmentKindExifApp1:
+		return "exif"
+	case SegmentKindXmpApp1:
+		return "xmp"
+	case SegmentKindExtendedXmpApp1:
+		return "extended-xmp"
+	case SegmentKindUnknownApp1:
+		return "unknown-app1"
+	}
+
+	return "other"
+}
+
+// Kind classifies the segment by its payload signature so that EXIF, XMP,
+// and Extended XMP APP1 segments can be told apart without checking the
+// preambles again.
+func (s Segment) Kind() SegmentKind {
+	if s.MarkerId != MARKER_APP1 {
+		return SegmentKindOther
+	} else if isExifPayload(s.Data) == true {
+		return SegmentKindExifApp1
+	} else if isXmpPayload(s.Data) == true {
+		return SegmentKindXmpApp1
+	} else if bytes.HasPrefix(s.Data, extendedXmpPrefix) == true {
+		return SegmentKindExtendedXmpApp1
+	}
+
+	return SegmentKindUnknownApp1
+}
+
+// ExifData returns the EXIF data (beginning with the TIFF header) from the
+// first EXIF APP1 segment.
+func (sl SegmentList) ExifData() (data []byte, err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	s, err := sl.App1Exif()
+	log.PanicIf(err)
+
+	return s.Data[len(exifPrefix):], nil
+}
+
+// XmpData returns the XMP packet from the first XMP APP1 segment.
+func (sl SegmentList) XmpData() (data []byte, err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	s, err := sl.App1Xmp()
+	log.PanicIf(err)
+
+	return s.Data[len(xmpPrefix):], nil
+}
+
+// ExtendedXmpData reassembles the Extended XMP packet that the standard packet
+// refers to (by its xmpNote:HasExtendedXMP GUID). ErrSegmentNotFound is
+// returned if there's no reference or if a chunk is missing.
+func (sl SegmentList) ExtendedXmpData() (data []byte, err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	packet, err := sl.XmpData()
+	log.PanicIf(err)
+
+	properties, err := ParseXmpProperties(packet)
+	log.PanicIf(err)
+
+	guid := ""
+	for _, xp := range properties {
+		if xp.Namespace == xmpNoteNamespace && xp.Name == "HasExtendedXMP" {
+			guid = xp.Value
+		}
+	}
+
+	if len(guid) != extendedXmpGuidSize {
+		log.Panic(ErrSegmentNotFound)
+	}
+
+	prefix := append(append([]byte{}, extendedXmpPrefix...), guid...)
+	received := 0
+
+	for _, s := range sl.FindWithPrefix(MARKER_APP1, prefix) {
+		if len(s.Data) < extendedXmpHeaderSize {
+			continue
+		}
+
+		fullLength := int(binary.BigEndian.Uint32(s.Data[len(prefix):]))
+		offset := int(binary.BigEndian.Uint32(s.Data[len(prefix) + 4:]))
+		chunk := s.Data[extendedXmpHeaderSize:]
+
+		if data == nil {
+			data = make([]byte, fullLength)
+		}
+
+		if fullLength != len(data) || offset + len(chunk) > len(data) {
+			log.Panicf("extended XMP chunk not valid: LENGTH=(%d) OFFSET=(%d) SIZE=(%d)", fullLength, offset, len(chunk))
+		}
+
+		copy(data[offset:], chunk)
+		received += len(chunk)
+	}
+
+	if data == nil || received < len(data) {
+		log.Panic(ErrSegmentNotFound)
+	}
+
+	return data, nil
+}
@@ -0,0 +1,42 @@
+package jpegstructure
+
+import (
+	"path"
+	"testing"
+
+	"github.com/dsoprea/go-logging"
+)
+
+func TestSegment_Kind(t *testing.T) {
+	filepath := path.Join(assetsPath, testImageRelFilepath)
+
+	sl, err := ParseFileStructure(filepath)
+	log.PanicIf(err)
+
+	kinds := make([]SegmentKind, 3)
+	for i := range kinds {
+		kinds[i] = sl[i].Kind()
+	}
+
+	if kinds[0] != SegmentKindOther || kinds[1] != SegmentKindExifApp1 || kinds[2] != SegmentKindXmpApp1 {
+		t.Fatalf("Kinds not correct: %v", kinds)
+	}
+
+	extended := Segment{
+		MarkerId: MARKER_APP1,
+		Data: append(append([]byte{}, extendedXmpPrefix...), 0x00),
+	}
+
+	if extended.Kind() != SegmentKindExtendedXmpApp1 {
+		t.Fatalf("Extended XMP kind not correct: [%s]", extended.Kind())
+	}
+
+	unknown := Segment{
+		MarkerId: MARKER_APP1,
+		Data: []byte("unknown"),
+	}
+
+	if unknown.Kind() != SegmentKindUnknownApp1 {
+		t.Fatalf("Unknown kind not correct: [%s]", unknown.Kind())
+	}
+}
@@ -0,0 +1,95 @@
+package jpegstructure
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+)
+
+const (
+	// appSignaturePreviewSize is the number of payload bytes that
+	// AppSignaturePreview() shows.
+	appSignaturePreviewSize = 16
+)
+
+// AppSignature is a known APPn payload prefix.
+type AppSignature struct {
+	MarkerId byte
+	Prefix []byte
+
+	// Name is a short label (e.g. "Exif").
+	Name string
+
+	Description string
+}
+
+func (as AppSignature) String() string {
+	return fmt.Sprintf("AppSignature<MARKER=[%s] NAME=[%s] PREFIX=[%q]>", markerNames[as.MarkerId], as.Name, as.Prefix)
+}
+
+var (
+	appSignaturesLock sync.RWMutex
+
+	// appSignatures are checked in order, so a longer prefix must come before
+	// any shorter prefix that it begins with.
+	appSignatures = []AppSignature{
+		{MARKER_APP0, jfifPrefix, "JFIF", "JFIF header"},
+		{MARKER_APP0, jfxxPrefix, "JFXX", "JFIF extension (thumbnail)"},
+		{MARKER_APP1, exifPrefix, "Exif", "EXIF metadata"},
+		{MARKER_APP1, xmpPrefix, "XMP", "XMP packet"},
+		{MARKER_APP1, extendedXmpPrefix, "ExtendedXMP", "Extended XMP chunk"},
+		{MARKER_APP2, iccPrefix, "ICC_PROFILE", "ICC profile chunk"},
+		{MARKER_APP2, mpfPrefix, "MPF", "Multi-Picture Format index"},
+		{MARKER_APP2, []byte("FPXR\x00"), "FPXR", "FlashPix ready (Kodak, Samsung)"},
+		{MARKER_APP2, []byte("urn:iso:std:iso:ts:21496:-1\x00"), "HDRGainMap", "ISO 21496-1 HDR gain-map metadata"},
+		{MARKER_APP3, jpsPrefix, "JPS", "JPEG stereo"},
+		{MARKER_APP7, []byte("PENTAX \x00"), "PentaxDebug", "Pentax debug block"},
+		{MARKER_APP8, spiffPrefix, "SPIFF", "SPIFF header"},
+		{MARKER_APP12, duckyPrefix, "Ducky", "Photoshop \"Save for Web\" settings"},
+		{MARKER_APP12, []byte("[picture info]"), "PictureInfo", "Olympus/Agfa picture info"},
+		{MARKER_APP13, []byte("Photoshop 3.0\x00"), "Photoshop", "Photoshop image resources (IPTC)"},
+		{MARKER_APP13, []byte("Adobe_CM"), "AdobeCM", "Adobe color management"},
+		{MARKER_APP14, adobePrefix, "Adobe", "Adobe color transform"},
+	}
+)
+
+// RegisterAppSignature adds to the signatures that Identify() recognizes.
+// Registered signatures are checked before the built-in ones. They don't
+// change what the strict lint profile accepts.
+func RegisterAppSignature(as AppSignature) {
+	appSignaturesLock.Lock()
+	defer appSignaturesLock.Unlock()
+
+	appSignatures = append([]AppSignature{as}, appSignatures...)
+}
+
+// Identify returns the known signature that the payload of an APPn segment
+// begins with. `found` is false for other segments and for APPn segments
+// with an unknown signature.
+func Identify(s Segment) (as AppSignature, found bool) {
+	if IsAppMarker(s.MarkerId) == false {
+		return as, false
+	}
+
+	appSignaturesLock.RLock()
+	defer appSignaturesLock.RUnlock()
+
+	for _, as := range appSignatures {
+		if as.MarkerId == s.MarkerId && bytes.HasPrefix(s.Data, as.Prefix) == true {
+			return as, true
+		}
+	}
+
+	return as, false
+}
+
+// AppSignaturePreview returns the start of the payload, quoted, to help
+// identify APPn segments that Identify() doesn't know.
+func AppSignaturePreview(s Segment) string {
+	preview := s.Data
+	if len(preview) > appSignaturePreviewSize {
+		preview = preview[:appSignaturePreviewSize]
+	}
+
+	return fmt.Sprintf("%q", preview)
+}
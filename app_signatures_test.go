@@ -0,0 +1,90 @@
+package jpegstructure
+
+import (
+	"bytes"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/dsoprea/go-logging"
+)
+
+func TestIdentify(t *testing.T) {
+	filepath := path.Join(assetsPath, "20180428_212314.jpg")
+
+	sl, err := ParseFileStructure(filepath)
+	log.PanicIf(err)
+
+	names := make([]string, 0)
+	for _, s := range sl {
+		if as, found := Identify(s); found == true {
+			names = append(names, as.Name)
+		}
+	}
+
+	if strings.Join(names, ",") != "JFIF,Exif" {
+		t.Fatalf("Signatures not correct: %v", names)
+	}
+
+	// The signature must be on the right marker.
+	misplaced := Segment{
+		MarkerId: MARKER_APP3,
+		Data: append([]byte{}, exifPrefix...),
+	}
+
+	if _, found := Identify(misplaced); found == true {
+		t.Fatalf("Signature identified on the wrong marker.")
+	}
+
+	nonApp := Segment{
+		MarkerId: MARKER_COM,
+		Data: append([]byte{}, exifPrefix...),
+	}
+
+	if _, found := Identify(nonApp); found == true {
+		t.Fatalf("Non-APPn segment identified.")
+	}
+}
+
+func TestRegisterAppSignature(t *testing.T) {
+	s := Segment{
+		MarkerId: MARKER_APP9,
+		Data: []byte("TestVendor\x00payload"),
+	}
+
+	if _, found := Identify(s); found == true {
+		t.Fatalf("Signature identified before registration.")
+	}
+
+	original := appSignatures
+	defer func() {
+		appSignatures = original
+	}()
+
+	RegisterAppSignature(AppSignature{MARKER_APP9, []byte("TestVendor\x00"), "TestVendor", "test"})
+
+	as, found := Identify(s)
+	if found == false || as.Name != "TestVendor" {
+		t.Fatalf("Registered signature not identified: %v", as)
+	}
+}
+
+func TestSegmentList_DumpText_Signatures(t *testing.T) {
+	sl := SegmentList{
+		{MarkerId: MARKER_SOI, MarkerName: "SOI"},
+		{MarkerId: MARKER_APP14, MarkerName: "APP14", Data: []byte("Adobe\x00\x64\x00\x00\x00\x00\x01")},
+		{MarkerId: MARKER_APP9, MarkerName: "APP9", Data: []byte("Mystery\x00")},
+		{MarkerId: MARKER_EOI, MarkerName: "EOI"},
+	}
+
+	b := new(bytes.Buffer)
+
+	err := sl.DumpText(b, true)
+	log.PanicIf(err)
+
+	if strings.Contains(b.String(), "|- SIGNATURE: NAME=[Adobe] DESCRIPTION=[Adobe color transform]\n") == false {
+		t.Fatalf("Known signature not labeled:\n%s", b.String())
+	} else if strings.Contains(b.String(), "|- SIGNATURE: (unknown) PREFIX=[\"Mystery\\x00\"]\n") == false {
+		t.Fatalf("Unknown signature not previewed:\n%s", b.String())
+	}
+}
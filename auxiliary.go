@@ -0,0 +1,487 @@
+package jpegstructure
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/xml"
+
+	"github.com/dsoprea/go-logging"
+)
+
+const (
+	AUXILIARY_KIND_DEPTH = "depth"
+	AUXILIARY_KIND_CONFIDENCE = "confidence"
+	AUXILIARY_KIND_GAIN_MAP = "gain-map"
+
+	// AUXILIARY_KIND_OTHER is anything else listed in a container (e.g. the
+	// video of a motion photo).
+	AUXILIARY_KIND_OTHER = "other"
+)
+
+const (
+	// AUXILIARY_SOURCE_GDEPTH is data embedded (base64) in the GDepth XMP
+	// properties, which are usually in the Extended XMP.
+	AUXILIARY_SOURCE_GDEPTH = "gdepth"
+
+	// AUXILIARY_SOURCE_GCONTAINER is an item appended after the primary image
+	// and listed in the Container:Directory XMP property.
+	AUXILIARY_SOURCE_GCONTAINER = "gcontainer"
+
+	// AUXILIARY_SOURCE_MPF is an image indexed by an MPF segment whose own
+	// XMP identifies it as a gain map (Apple and Ultra HDR).
+	AUXILIARY_SOURCE_MPF = "mpf"
+
+	// AUXILIARY_SOURCE_SAMSUNG is an entry of the Samsung trailer (SEFT).
+	AUXILIARY_SOURCE_SAMSUNG = "samsung"
+)
+
+const (
+	gdepthNamespace = "http://ns.google.com/photos/1.0/depthmap/"
+	containerNamespace = "http://ns.google.com/photos/1.0/container/"
+	containerItemNamespace = "http://ns.google.com/photos/1.0/container/item/"
+	appleGainMapNamespace = "http://ns.apple.com/HDRGainMap/1.0/"
+	hdrGainMapNamespace = "http://ns.adobe.com/hdr-gain-map/1.0/"
+)
+
+var (
+	samsungTrailerSignature = []byte("SEFT")
+	samsungDirectorySignature = []byte("SEFH")
+
+	containerSemanticKinds = map[string]string{
+		"Depth": AUXILIARY_KIND_DEPTH,
+		"Confidence": AUXILIARY_KIND_CONFIDENCE,
+		"GainMap": AUXILIARY_KIND_GAIN_MAP,
+	}
+)
+
+// AuxiliaryImage is a depth map, confidence map, or gain map (or another item
+// of a container) that accompanies the primary image.
+type AuxiliaryImage struct {
+	// Kind is AUXILIARY_KIND_*.
+	Kind string
+
+	// Source is where the image was found (AUXILIARY_SOURCE_*).
+	Source string
+
+	// Mime is the media type, if known. Samsung depth maps are raw and have
+	// none.
+	Mime string
+
+	// Name is the semantic of a container item or the name of a Samsung
+	// entry.
+	Name string
+
+	// Offset is the position of the data in the file or -1 if it was decoded
+	// from the XMP.
+	Offset int
+
+	// Properties are the XMP properties that describe the image (e.g. the
+	// GDepth Format, Near, and Far, or the hdrgm gain-map parameters), by
+	// local name.
+	Properties map[string]string
+
+	Data []byte
+}
+
+func (ai AuxiliaryImage) String() string {
+	return fmt.Sprintf("AuxiliaryImage<KIND=[%s] SOURCE=[%s] MIME=[%s] NAME=[%s] OFFSET=(%d) SIZE=(%d)>", ai.Kind, ai.Source, ai.Mime, ai.Name, ai.Offset, len(ai.Data))
+}
+
+// primaryEnd returns the position after the EOI of the primary image. The
+// list may also have the segments of images that were appended to it.
+func (sl SegmentList) primaryEnd() int {
+	for _, s := range sl {
+		if s.MarkerId == MARKER_EOI {
+			return s.EndOffset()
+		}
+	}
+
+	return -1
+}
+
+// xmpPackets returns the standard XMP packet and the Extended XMP packet, if
+// present.
+func (sl SegmentList) xmpPackets() [][]byte {
+	packets := make([][]byte, 0, 2)
+
+	packet, err := sl.XmpData()
+	if err != nil {
+		return packets
+	}
+
+	packets = append(packets, packet)
+
+	extended, err := sl.ExtendedXmpData()
+	if err == nil {
+		packets = append(packets, extended)
+	}
+
+	return packets
+}
+
+// gdepthImages decodes the depth and confidence maps embedded in the GDepth
+// properties.
+func gdepthImages(properties []XmpProperty) (images []AuxiliaryImage) {
+	values := make(map[string]string)
+	for _, xp := range properties {
+		if xp.Namespace == gdepthNamespace {
+			values[xp.Name] = xp.Value
+		}
+	}
+
+	images = make([]AuxiliaryImage, 0)
+
+	describe := make(map[string]string)
+	for name, value := range values {
+		if name != "Data" && name != "Confidence" {
+			describe[name] = value
+		}
+	}
+
+	maps := []struct {
+		kind string
+		dataName string
+		mimeName string
+	}{
+		{AUXILIARY_KIND_DEPTH, "Data", "Mime"},
+		{AUXILIARY_KIND_CONFIDENCE, "Confidence", "ConfidenceMime"},
+	}
+
+	for _, m := range maps {
+		encoded, found := values[m.dataName]
+		if found == false {
+			continue
+		}
+
+		data, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(encoded), ""))
+		if err != nil {
+			jpegLogger.Debugf(nil, "GDepth %s not valid base64: %v", m.dataName, err)
+			continue
+		}
+
+		ai := AuxiliaryImage{
+			Kind: m.kind,
+			Source: AUXILIARY_SOURCE_GDEPTH,
+			Mime: values[m.mimeName],
+			Offset: -1,
+			Properties: describe,
+			Data: data,
+		}
+
+		images = append(images, ai)
+	}
+
+	return images
+}
+
+// containerItem is one entry of a Container:Directory.
+type containerItem struct {
+	mime string
+	semantic string
+	length int
+	padding int
+}
+
+// set records one of the Item properties.
+func (ci *containerItem) set(name, value string) {
+	value = strings.TrimSpace(value)
+
+	switch name {
+	case "Mime":
+		ci.mime = value
+	case "Semantic":
+		ci.semantic = value
+	case "Length":
+		ci.length, _ = strconv.Atoi(value)
+	case "Padding":
+		ci.padding, _ = strconv.Atoi(value)
+	}
+}
+
+// parseContainerDirectory returns the items of the Container:Directory in
+// the packet, in order. The Item properties may be given as attributes or as
+// elements.
+func parseContainerDirectory(packet []byte) (items []containerItem, err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	decoder := xml.NewDecoder(bytes.NewReader(packet))
+
+	items = make([]containerItem, 0)
+
+	var current *containerItem
+	text := new(bytes.Buffer)
+
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+
+		log.PanicIf(err)
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			if t.Name.Space == containerNamespace && t.Name.Local == "Item" {
+				current = new(containerItem)
+
+				for _, attr := range t.Attr {
+					if attr.Name.Space == containerItemNamespace {
+						current.set(attr.Name.Local, attr.Value)
+					}
+				}
+			}
+
+			text.Reset()
+		case xml.CharData:
+			text.Write(t)
+		case xml.EndElement:
+			if current == nil {
+				continue
+			}
+
+			if t.Name.Space == containerItemNamespace {
+				current.set(t.Name.Local, text.String())
+			} else if t.Name.Space == containerNamespace && t.Name.Local == "Item" {
+				items = append(items, *current)
+				current = nil
+			}
+		}
+	}
+
+	return items, nil
+}
+
+// containerImages returns the items that follow the primary image, as listed
+// by the container directory. The first item is the primary image itself.
+func containerImages(items []containerItem, primaryEnd int, data []byte) (images []AuxiliaryImage) {
+	images = make([]AuxiliaryImage, 0)
+
+	if len(items) == 0 {
+		return images
+	}
+
+	position := primaryEnd + items[0].padding
+
+	for _, ci := range items[1:] {
+		start := position
+		position += ci.length + ci.padding
+
+		if ci.length <= 0 || start + ci.length > len(data) {
+			jpegLogger.Debugf(nil, "Container item [%s] out of bounds: (%d) (%d)", ci.semantic, start, ci.length)
+			break
+		}
+
+		kind, found := containerSemanticKinds[ci.semantic]
+		if found == false {
+			kind = AUXILIARY_KIND_OTHER
+		}
+
+		ai := AuxiliaryImage{
+			Kind: kind,
+			Source: AUXILIARY_SOURCE_GCONTAINER,
+			Mime: ci.mime,
+			Name: ci.semantic,
+			Offset: start,
+			Data: data[start:start + ci.length],
+		}
+
+		images = append(images, ai)
+	}
+
+	return images
+}
+
+// gainMapProperties returns the gain-map properties from the XMP of an image
+// or nil if the XMP doesn't describe a gain map.
+func gainMapProperties(sl SegmentList) map[string]string {
+	packet, err := sl.XmpData()
+	if err != nil {
+		return nil
+	}
+
+	properties, err := ParseXmpProperties(packet)
+	if err != nil {
+		return nil
+	}
+
+	var values map[string]string
+	for _, xp := range properties {
+		if xp.Namespace == appleGainMapNamespace || xp.Namespace == hdrGainMapNamespace {
+			if values == nil {
+				values = make(map[string]string)
+			}
+
+			values[xp.Name] = xp.Value
+		}
+	}
+
+	return values
+}
+
+// samsungImages returns the depth maps from the trailer that Samsung cameras
+// append to the file. The trailer ends with a (little-endian) four-byte
+// directory size and "SEFT". The directory starts with "SEFH", a version,
+// and a count, followed by 12-byte entries: two reserved bytes, a type, the
+// distance back from the directory to the entry's data, and its size. The
+// data starts with two reserved bytes, the type, and the length of a name
+// that follows.
+func samsungImages(data []byte) (images []AuxiliaryImage) {
+	images = make([]AuxiliaryImage, 0)
+
+	if len(data) < 8 || bytes.HasSuffix(data, samsungTrailerSignature) == false {
+		return images
+	}
+
+	directorySize := int(binary.LittleEndian.Uint32(data[len(data) - 8:]))
+	directoryStart := len(data) - 8 - directorySize
+
+	if directorySize < 12 || directoryStart < 0 || bytes.HasPrefix(data[directoryStart:], samsungDirectorySignature) == false {
+		return images
+	}
+
+	directory := data[directoryStart:len(data) - 8]
+	count := int(binary.LittleEndian.Uint32(directory[8:]))
+
+	for i := 0; i < count && 12 + i * 12 + 12 <= len(directory); i++ {
+		entry := directory[12 + i * 12:]
+
+		distance := int(binary.LittleEndian.Uint32(entry[4:]))
+		size := int(binary.LittleEndian.Uint32(entry[8:]))
+
+		start := directoryStart - distance
+		if distance <= 0 || start < 0 || size < 8 || start + size > directoryStart {
+			continue
+		}
+
+		block := data[start:start + size]
+
+		nameLength := int(binary.LittleEndian.Uint32(block[4:]))
+		if 8 + nameLength > len(block) {
+			continue
+		}
+
+		name := string(block[8:8 + nameLength])
+		if strings.Contains(name, "DepthMap") == false {
+			continue
+		}
+
+		ai := AuxiliaryImage{
+			Kind: AUXILIARY_KIND_DEPTH,
+			Source: AUXILIARY_SOURCE_SAMSUNG,
+			Name: name,
+			Offset: start + 8 + nameLength,
+			Data: block[8 + nameLength:],
+		}
+
+		images = append(images, ai)
+	}
+
+	return images
+}
+
+// AuxiliaryImages returns the depth, confidence, and gain maps that accompany
+// the primary image: those embedded in the GDepth XMP properties, the items
+// of a Google container (Container:Directory) appended after the image, the
+// gain maps indexed by MPF (Apple and Ultra HDR), and the depth maps of a
+// Samsung trailer. Everything but the GDepth maps is stored after the
+// primary image, so it's only found if `data` (the whole file) is given; it
+// may be nil. An image that's both in a container and indexed by MPF is only
+// returned once.
+func (sl SegmentList) AuxiliaryImages(data []byte) (images []AuxiliaryImage, err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	images = make([]AuxiliaryImage, 0)
+	seen := make(map[int]bool)
+
+	primaryEnd := sl.primaryEnd()
+
+	for _, packet := range sl.xmpPackets() {
+		properties, err := ParseXmpProperties(packet)
+		log.PanicIf(err)
+
+		images = append(images, gdepthImages(properties)...)
+
+		if data == nil || primaryEnd < 0 {
+			continue
+		}
+
+		items, err := parseContainerDirectory(packet)
+		log.PanicIf(err)
+
+		for _, ai := range containerImages(items, primaryEnd, data) {
+			// The container doesn't describe the gain map itself; its own
+			// XMP does.
+			if ai.Kind == AUXILIARY_KIND_GAIN_MAP {
+				if gainMap, err := ParseBytesStructure(ai.Data); err == nil {
+					ai.Properties = gainMapProperties(gainMap)
+				}
+			}
+
+			images = append(images, ai)
+			seen[ai.Offset] = true
+		}
+	}
+
+	if data == nil || primaryEnd < 0 {
+		return images, nil
+	}
+
+	for i, s := range sl {
+		if s.Offset >= primaryEnd {
+			break
+		} else if s.MarkerId != MARKER_APP2 || bytes.HasPrefix(s.Data, mpfPrefix) == false {
+			continue
+		}
+
+		mpfImages, err := sl.mpfImages(i, data)
+		log.PanicIf(err)
+
+		for _, mi := range mpfImages {
+			if seen[mi.start] == true {
+				continue
+			}
+
+			imageData := data[mi.start:mi.start + mi.size]
+
+			image, err := ParseBytesStructure(imageData)
+			if err != nil {
+				continue
+			}
+
+			properties := gainMapProperties(image)
+			if properties == nil {
+				continue
+			}
+
+			ai := AuxiliaryImage{
+				Kind: AUXILIARY_KIND_GAIN_MAP,
+				Source: AUXILIARY_SOURCE_MPF,
+				Mime: "image/jpeg",
+				Offset: mi.start,
+				Properties: properties,
+				Data: imageData,
+			}
+
+			images = append(images, ai)
+			seen[mi.start] = true
+		}
+	}
+
+	images = append(images, samsungImages(data)...)
+
+	return images, nil
+}
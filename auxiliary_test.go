@@ -0,0 +1,205 @@
+package jpegstructure
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"encoding/base64"
+	"encoding/binary"
+
+	"github.com/dsoprea/go-logging"
+)
+
+func getAuxiliaryXmp(namespaces string, description string) []byte {
+	return []byte(fmt.Sprintf(
+		`<x:xmpmeta xmlns:x="adobe:ns:meta/">`+
+			`<rdf:RDF xmlns:rdf="%s">`+
+			`<rdf:Description rdf:about="" %s>%s</rdf:Description>`+
+			`</rdf:RDF>`+
+			`</x:xmpmeta>`,
+		rdfNamespace, namespaces, description))
+}
+
+func TestSegmentList_AuxiliaryImages_GDepth(t *testing.T) {
+	depth := getTransformTestImage()
+
+	sl, err := ParseBytesStructure(getTransformTestImage())
+	log.PanicIf(err)
+
+	description := fmt.Sprintf(
+		`<GDepth:Format>RangeInverse</GDepth:Format>`+
+			`<GDepth:Near>0.5</GDepth:Near>`+
+			`<GDepth:Far>10</GDepth:Far>`+
+			`<GDepth:Mime>image/jpeg</GDepth:Mime>`+
+			`<GDepth:Data>%s</GDepth:Data>`,
+		base64.StdEncoding.EncodeToString(depth))
+
+	err = sl.SetXmp(getAuxiliaryXmp(`xmlns:GDepth="`+gdepthNamespace+`"`, description))
+	log.PanicIf(err)
+
+	images, err := sl.AuxiliaryImages(nil)
+	log.PanicIf(err)
+
+	if len(images) != 1 {
+		t.Fatalf("Auxiliary image count not correct: (%d)", len(images))
+	}
+
+	ai := images[0]
+	if ai.Kind != AUXILIARY_KIND_DEPTH || ai.Source != AUXILIARY_SOURCE_GDEPTH || ai.Mime != "image/jpeg" || ai.Offset != -1 || bytes.Equal(ai.Data, depth) == false {
+		t.Fatalf("Depth map not correct: %s", ai)
+	} else if ai.Properties["Format"] != "RangeInverse" || ai.Properties["Near"] != "0.5" || ai.Properties["Far"] != "10" {
+		t.Fatalf("Depth map properties not correct: %v", ai.Properties)
+	} else if _, found := ai.Properties["Data"]; found == true {
+		t.Fatalf("Depth map data included in properties.")
+	}
+}
+
+func TestSegmentList_AuxiliaryImages_UltraHdr(t *testing.T) {
+	primary, err := ParseBytesStructure(getTransformTestImage())
+	log.PanicIf(err)
+
+	gainMap, err := ParseBytesStructure(getTransformTestImage())
+	log.PanicIf(err)
+
+	err = gainMap.SetXmp(getAuxiliaryXmp(`xmlns:hdrgm="`+hdrGainMapNamespace+`"`, `<hdrgm:Version>1.0</hdrgm:Version><hdrgm:GainMapMax>2.3</hdrgm:GainMapMax>`))
+	log.PanicIf(err)
+
+	writeMpo := func() []byte {
+		images := []MpoImage{
+			{Segments: primary, Type: MPO_TYPE_BASELINE_PRIMARY},
+			{Segments: gainMap, Type: MPO_TYPE_UNDEFINED},
+		}
+
+		b := new(bytes.Buffer)
+
+		err := WriteMpo(b, images)
+		log.PanicIf(err)
+
+		return b.Bytes()
+	}
+
+	// The size of the gain map (with the MPF segment that the writer adds to
+	// it) doesn't depend on the primary image.
+	data := writeMpo()
+
+	sl, err := ParseBytesStructure(data)
+	log.PanicIf(err)
+
+	gainMapSize := len(data) - sl.primaryEnd()
+
+	// Only indexed by MPF.
+	images, err := sl.AuxiliaryImages(data)
+	log.PanicIf(err)
+
+	if len(images) != 1 || images[0].Source != AUXILIARY_SOURCE_MPF || images[0].Kind != AUXILIARY_KIND_GAIN_MAP || len(images[0].Data) != gainMapSize {
+		t.Fatalf("MPF gain map not correct: %v", images)
+	} else if images[0].Properties["GainMapMax"] != "2.3" {
+		t.Fatalf("MPF gain map properties not correct: %v", images[0].Properties)
+	}
+
+	// Also listed by the container.
+	directory := fmt.Sprintf(
+		`<Container:Directory><rdf:Seq>`+
+			`<rdf:li rdf:parseType="Resource"><Container:Item Item:Semantic="Primary" Item:Mime="image/jpeg"/></rdf:li>`+
+			`<rdf:li rdf:parseType="Resource"><Container:Item Item:Semantic="GainMap" Item:Mime="image/jpeg" Item:Length="%d"/></rdf:li>`+
+			`</rdf:Seq></Container:Directory>`,
+		gainMapSize)
+
+	namespaces := `xmlns:Container="` + containerNamespace + `" xmlns:Item="` + containerItemNamespace + `"`
+
+	err = primary.SetXmp(getAuxiliaryXmp(namespaces, directory))
+	log.PanicIf(err)
+
+	data = writeMpo()
+
+	sl, err = ParseBytesStructure(data)
+	log.PanicIf(err)
+
+	images, err = sl.AuxiliaryImages(data)
+	log.PanicIf(err)
+
+	if len(images) != 1 {
+		t.Fatalf("Gain map not returned once: %v", images)
+	}
+
+	ai := images[0]
+	if ai.Source != AUXILIARY_SOURCE_GCONTAINER || ai.Kind != AUXILIARY_KIND_GAIN_MAP || ai.Name != "GainMap" || ai.Offset != sl.primaryEnd() || bytes.Equal(ai.Data, data[sl.primaryEnd():]) == false {
+		t.Fatalf("Container gain map not correct: %s", ai)
+	} else if ai.Properties["Version"] != "1.0" {
+		t.Fatalf("Container gain map properties not correct: %v", ai.Properties)
+	}
+
+	// Without the file data, nothing after the primary image is found.
+	images, err = sl.AuxiliaryImages(nil)
+	log.PanicIf(err)
+
+	if len(images) != 0 {
+		t.Fatalf("Expected no images without the data: %v", images)
+	}
+}
+
+func TestSegmentList_AuxiliaryImages_Samsung(t *testing.T) {
+	primary := getTransformTestImage()
+
+	sl, err := ParseBytesStructure(primary)
+	log.PanicIf(err)
+
+	depth := []byte{0x01, 0x02, 0x03, 0x04}
+
+	b := bytes.NewBuffer(append([]byte{}, primary...))
+
+	entries := make([]byte, 0)
+	for _, name := range []string{"DualShot_Extra_Info", "DualShot_DepthMap_1"} {
+		blockStart := b.Len()
+
+		header := make([]byte, 8)
+		binary.LittleEndian.PutUint16(header[2:], 0x0a30)
+		binary.LittleEndian.PutUint32(header[4:], uint32(len(name)))
+
+		b.Write(header)
+		b.WriteString(name)
+		b.Write(depth)
+
+		entry := make([]byte, 12)
+		binary.LittleEndian.PutUint16(entry[2:], 0x0a30)
+		binary.LittleEndian.PutUint32(entry[8:], uint32(b.Len() - blockStart))
+
+		// The distance is filled in once the directory's position is known.
+		binary.LittleEndian.PutUint32(entry[4:], uint32(blockStart))
+
+		entries = append(entries, entry...)
+	}
+
+	directoryStart := b.Len()
+	for i := 0; i < len(entries); i += 12 {
+		blockStart := binary.LittleEndian.Uint32(entries[i + 4:])
+		binary.LittleEndian.PutUint32(entries[i + 4:], uint32(directoryStart) - blockStart)
+	}
+
+	directory := make([]byte, 12)
+	copy(directory, samsungDirectorySignature)
+	binary.LittleEndian.PutUint32(directory[4:], 107)
+	binary.LittleEndian.PutUint32(directory[8:], 2)
+	directory = append(directory, entries...)
+
+	b.Write(directory)
+
+	trailer := make([]byte, 4)
+	binary.LittleEndian.PutUint32(trailer, uint32(len(directory)))
+
+	b.Write(trailer)
+	b.Write(samsungTrailerSignature)
+
+	images, err := sl.AuxiliaryImages(b.Bytes())
+	log.PanicIf(err)
+
+	if len(images) != 1 {
+		t.Fatalf("Auxiliary image count not correct: (%d)", len(images))
+	}
+
+	ai := images[0]
+	if ai.Kind != AUXILIARY_KIND_DEPTH || ai.Source != AUXILIARY_SOURCE_SAMSUNG || ai.Name != "DualShot_DepthMap_1" || bytes.Equal(ai.Data, depth) == false {
+		t.Fatalf("Samsung depth map not correct: %s", ai)
+	}
+}
@@ -0,0 +1,236 @@
+package jpegstructure
+
+import (
+	"github.com/dsoprea/go-logging"
+)
+
+// SegmentBuilder assembles a well-formed image from its parts. The SOI and
+// EOI are added by Build(). Errors are deferred until Build() so that calls
+// can be chained:
+//
+//	sl, err := NewBuilder().AddJfif(jfif).AddQuantTables(qt0, qt1).AddFrame(MARKER_SOF0, sof, components).AddHuffmanTables(tables...).AddScanData(data).Build()
+type SegmentBuilder struct {
+	segments SegmentList
+	err error
+
+	frameComponents []SofComponent
+}
+
+// NewBuilder returns an empty builder.
+func NewBuilder() *SegmentBuilder {
+	return &SegmentBuilder{
+		segments: make(SegmentList, 0),
+	}
+}
+
+// AddSegment adds an arbitrary segment.
+func (sb *SegmentBuilder) AddSegment(markerId byte, data []byte) *SegmentBuilder {
+	if sb.err != nil {
+		return sb
+	}
+
+	if markerId == MARKER_SOI || markerId == MARKER_EOI || markerId == MARKER_SOS || markerId == 0x0 {
+		sb.err = log.Errorf("marker can not be added directly: (0x%02x)", markerId)
+		return sb
+	} else if len(data) > maxSegmentPayloadSize {
+		jpegLogger.Debugf(nil, "Segment payload too large: MARKER=(0x%02x) SIZE=(%d)", markerId, len(data))
+		sb.err = log.Wrap(ErrSegmentTooLarge)
+		return sb
+	}
+
+	s := Segment{
+		MarkerId: markerId,
+		MarkerName: markerNames[markerId],
+		Data: data,
+	}
+
+	sb.segments = append(sb.segments, s)
+	return sb
+}
+
+// AddSegmentFrom adds a segment taken from another image, recording that it
+// came from `source` (see Segment.Source).
+func (sb *SegmentBuilder) AddSegmentFrom(s Segment, source string) *SegmentBuilder {
+	if sb.err != nil {
+		return sb
+	}
+
+	sb.AddSegment(s.MarkerId, s.Data)
+	if sb.err != nil {
+		return sb
+	}
+
+	attributed := s.attributed(source)
+
+	last := &sb.segments[len(sb.segments) - 1]
+	last.Source = attributed.Source
+	last.SourceOffset = attributed.SourceOffset
+
+	return sb
+}
+
+// AddJfif adds a JFIF APP0 segment.
+func (sb *SegmentBuilder) AddJfif(jfif JfifSegment) *SegmentBuilder {
+	return sb.AddSegment(MARKER_APP0, jfif.Encode())
+}
+
+// AddExif adds an EXIF APP1 segment.
+func (sb *SegmentBuilder) AddExif(ed *ExifDocument) *SegmentBuilder {
+	if sb.err != nil {
+		return sb
+	}
+
+	exifData, err := ed.Encode()
+	if err != nil {
+		sb.err = err
+		return sb
+	}
+
+	payload := make([]byte, len(exifPrefix) + len(exifData))
+	copy(payload, exifPrefix)
+	copy(payload[len(exifPrefix):], exifData)
+
+	return sb.AddSegment(MARKER_APP1, payload)
+}
+
+// AddComment adds a COM segment.
+func (sb *SegmentBuilder) AddComment(comment string) *SegmentBuilder {
+	return sb.AddSegment(MARKER_COM, []byte(comment))
+}
+
+// AddQuantTables adds a DQT segment with the given tables.
+func (sb *SegmentBuilder) AddQuantTables(tables ...QuantizationTable) *SegmentBuilder {
+	return sb.AddSegment(MARKER_DQT, EncodeQuantizationTables(tables))
+}
+
+// AddHuffmanTables adds a DHT segment with the given tables.
+func (sb *SegmentBuilder) AddHuffmanTables(tables ...HuffmanTable) *SegmentBuilder {
+	return sb.AddSegment(MARKER_DHT, EncodeHuffmanTables(tables))
+}
+
+// AddFrame adds a frame header. `markerId` selects the process (e.g.
+// MARKER_SOF0 for baseline).
+func (sb *SegmentBuilder) AddFrame(markerId byte, sof SofSegment, components []SofComponent) *SegmentBuilder {
+	if sb.err != nil {
+		return sb
+	}
+
+	if IsSofMarker(markerId) == false {
+		sb.err = log.Errorf("not a SOF marker: (0x%02x)", markerId)
+		return sb
+	} else if sb.frameComponents != nil {
+		sb.err = log.Errorf("frame already added")
+		return sb
+	}
+
+	sb.frameComponents = components
+	return sb.AddSegment(markerId, EncodeSof(sof, components))
+}
+
+// AddScan adds a scan with the given header and entropy-coded data.
+func (sb *SegmentBuilder) AddScan(header SosHeader, data []byte) *SegmentBuilder {
+	if sb.err != nil {
+		return sb
+	}
+
+	if sb.frameComponents == nil {
+		sb.err = log.Errorf("scan added before frame")
+		return sb
+	}
+
+	for _, scanComponent := range header.Components {
+		found := false
+		for _, frameComponent := range sb.frameComponents {
+			if frameComponent.ComponentId == scanComponent.ComponentId {
+				found = true
+				break
+			}
+		}
+
+		if found == false {
+			sb.err = log.Errorf("scan references component not in frame: (%d)", scanComponent.ComponentId)
+			return sb
+		}
+	}
+
+	sos := Segment{
+		MarkerId: MARKER_SOS,
+		MarkerName: markerNames[MARKER_SOS],
+		Data: []byte{},
+	}
+
+	scanData := Segment{
+		MarkerId: 0x0,
+		MarkerName: "!SCANDATA",
+		Data: joinScanData(header.Encode(), data),
+	}
+
+	sb.segments = append(sb.segments, sos, scanData)
+	return sb
+}
+
+// AddScanData adds a single interleaved scan over every frame component, as
+// used by baseline images. The first component uses tables zero and the rest
+// use tables one.
+func (sb *SegmentBuilder) AddScanData(data []byte) *SegmentBuilder {
+	if sb.err != nil {
+		return sb
+	}
+
+	header := SosHeader{
+		Components: make([]SosComponent, len(sb.frameComponents)),
+		SpectralEnd: 63,
+	}
+
+	for i, fc := range sb.frameComponents {
+		tableId := byte(0)
+		if i > 0 {
+			tableId = 1
+		}
+
+		header.Components[i] = SosComponent{
+			ComponentId: fc.ComponentId,
+			DcTableId: tableId,
+			AcTableId: tableId,
+		}
+	}
+
+	return sb.AddScan(header, data)
+}
+
+// Build returns the assembled image with offsets as they'd be written.
+func (sb *SegmentBuilder) Build() (sl SegmentList, err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	log.PanicIf(sb.err)
+
+	hasDqt := false
+	hasScan := false
+	for _, s := range sb.segments {
+		if s.MarkerId == MARKER_DQT {
+			hasDqt = true
+		} else if s.MarkerId == MARKER_SOS {
+			hasScan = true
+		}
+	}
+
+	if hasDqt == false {
+		log.Panicf("no quantization tables added")
+	} else if hasScan == false {
+		log.Panicf("no scans added")
+	}
+
+	sl = make(SegmentList, 0, len(sb.segments) + 2)
+
+	sl = append(sl, Segment{MarkerId: MARKER_SOI, MarkerName: markerNames[MARKER_SOI], Data: []byte{}})
+	sl = append(sl, sb.segments...)
+	sl = append(sl, Segment{MarkerId: MARKER_EOI, MarkerName: markerNames[MARKER_EOI], Data: []byte{}})
+
+	sl.updateOffsets()
+
+	return sl, nil
+}
@@ -0,0 +1,101 @@
+package jpegstructure
+
+import (
+	"bytes"
+	"path"
+	"testing"
+
+	"github.com/dsoprea/go-logging"
+)
+
+func TestSegmentBuilder_Build(t *testing.T) {
+	filepath := path.Join(assetsPath, testImageRelFilepath)
+
+	original, err := ParseFileStructure(filepath)
+	log.PanicIf(err)
+
+	var quantTables []QuantizationTable
+	var huffmanTables []HuffmanTable
+	var sof *SofSegment
+	var components []SofComponent
+	var scanHeader *SosHeader
+	var entropyData []byte
+
+	for _, s := range original {
+		if s.MarkerId == MARKER_DQT {
+			quantTables, err = ParseQuantizationTables(s.Data)
+			log.PanicIf(err)
+		} else if s.MarkerId == MARKER_DHT {
+			huffmanTables, err = ParseHuffmanTables(s.Data)
+			log.PanicIf(err)
+		} else if s.MarkerId == MARKER_SOF0 {
+			sof, err = new(JpegSplitter).parseSof(s.Data)
+			log.PanicIf(err)
+
+			components, err = ParseSofComponents(s.Data)
+			log.PanicIf(err)
+		} else if s.MarkerId == 0x0 {
+			header, data, err := splitScanData(s.Data)
+			log.PanicIf(err)
+
+			scanHeader, err = ParseSosHeader(header)
+			log.PanicIf(err)
+
+			entropyData = data
+		}
+	}
+
+	sl, err := NewBuilder().
+		AddQuantTables(quantTables...).
+		AddFrame(MARKER_SOF0, *sof, components).
+		AddHuffmanTables(huffmanTables...).
+		AddScan(*scanHeader, entropyData).
+		Build()
+
+	log.PanicIf(err)
+
+	built := new(bytes.Buffer)
+
+	err = sl.Write(built)
+	log.PanicIf(err)
+
+	expected := new(bytes.Buffer)
+
+	err = original.StripMetadata(false).Write(expected)
+	log.PanicIf(err)
+
+	if bytes.Compare(built.Bytes(), expected.Bytes()) != 0 {
+		t.Fatalf("Built image does not match the original image data.")
+	}
+
+	reparsed, err := ParseBytesStructure(built.Bytes())
+	log.PanicIf(err)
+
+	for i, s := range reparsed {
+		if sl[i].Offset != s.Offset {
+			t.Fatalf("Offset of segment (%d) not correct: (%d) != (%d)", i, sl[i].Offset, s.Offset)
+		}
+	}
+}
+
+func TestSegmentBuilder_Build_Errors(t *testing.T) {
+	_, err := NewBuilder().AddScanData([]byte { 0x00 }).Build()
+	if err == nil || err.Error() != "scan added before frame" {
+		t.Fatalf("Expected error for scan before frame: %v", err)
+	}
+
+	sof := SofSegment{
+		BitsPerSample: 8,
+		Width: 8,
+		Height: 8,
+	}
+
+	components := []SofComponent {
+		{ComponentId: 1, HorizontalSamplingFactor: 1, VerticalSamplingFactor: 1},
+	}
+
+	_, err = NewBuilder().AddFrame(MARKER_SOF0, sof, components).AddScanData([]byte { 0x00 }).Build()
+	if err == nil || err.Error() != "no quantization tables added" {
+		t.Fatalf("Expected error for missing tables: %v", err)
+	}
+}
@@ -0,0 +1,177 @@
+package jpegstructure
+
+import (
+	"sort"
+
+	"github.com/dsoprea/go-logging"
+)
+
+var (
+	extendedXmpPrefix = []byte("http://ns.adobe.com/xmp/extension/\x00")
+)
+
+// Rank of each category of header segment in the canonical layout.
+const (
+	canonicalRankSoi = iota
+	canonicalRankJfif
+	canonicalRankExif
+	canonicalRankXmp
+	canonicalRankExtendedXmp
+	canonicalRankIcc
+	canonicalRankOtherApp
+	canonicalRankComment
+	canonicalRankTables
+	canonicalRankFrame
+)
+
+// canonicalRank returns the category of a segment that precedes the first
+// scan.
+func canonicalRank(s Segment) int {
+	switch {
+	case s.MarkerId == MARKER_SOI:
+		return canonicalRankSoi
+	case s.MarkerId == MARKER_APP0 && isJfifPayload(s.Data) == true:
+		return canonicalRankJfif
+	case s.Kind() == SegmentKindExifApp1:
+		return canonicalRankExif
+	case s.Kind() == SegmentKindXmpApp1:
+		return canonicalRankXmp
+	case s.Kind() == SegmentKindExtendedXmpApp1:
+		return canonicalRankExtendedXmp
+	case s.MarkerId == MARKER_APP2 && isIccPayload(s.Data) == true:
+		return canonicalRankIcc
+	case IsAppMarker(s.MarkerId) == true:
+		return canonicalRankOtherApp
+	case s.MarkerId == MARKER_COM:
+		return canonicalRankComment
+	case IsSofMarker(s.MarkerId) == true:
+		return canonicalRankFrame
+	}
+
+	return canonicalRankTables
+}
+
+// mergeTableSegments combines consecutive DQT segments into one and
+// consecutive DHT segments into one (a single segment may carry any number of
+// tables). Definition order is preserved, so redefinitions still win.
+func mergeTableSegments(tables SegmentList) SegmentList {
+	merged := make(SegmentList, 0, len(tables))
+
+	for _, s := range tables {
+		if s.MarkerId == MARKER_DQT || s.MarkerId == MARKER_DHT {
+			last := len(merged) - 1
+			if last >= 0 && merged[last].MarkerId == s.MarkerId && len(merged[last].Data) + len(s.Data) <= maxSegmentPayloadSize {
+				data := make([]byte, 0, len(merged[last].Data) + len(s.Data))
+				data = append(data, merged[last].Data...)
+				data = append(data, s.Data...)
+
+				merged[last].setData(data)
+				continue
+			}
+		}
+
+		merged = append(merged, s)
+	}
+
+	return merged
+}
+
+// Canonicalize returns a copy of the list with the segments before the first
+// scan reordered into the conventional layout: SOI, JFIF APP0, EXIF APP1, XMP
+// APP1 (standard then extended), ICC APP2 (in chunk order), other APPn, COM,
+// tables (DQT before DHT), and the frame. Repeated JFIF and EXIF segments are
+// dropped (only the first is kept), and the DQT and DHT segments are merged.
+// Everything from the first SOS onward is left alone.
+func (sl SegmentList) Canonicalize() (canonical SegmentList, err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	if len(sl) == 0 || sl[0].MarkerId != MARKER_SOI {
+		log.Panicf("first segment not SOI")
+	}
+
+	scanAt := len(sl)
+	for i, s := range sl {
+		if s.MarkerId == MARKER_SOS {
+			scanAt = i
+			break
+		}
+	}
+
+	header := make(SegmentList, 0, scanAt)
+	seenRanks := make(map[int]bool)
+
+	for _, s := range sl[:scanAt] {
+		rank := canonicalRank(s)
+
+		if rank == canonicalRankSoi || rank == canonicalRankJfif || rank == canonicalRankExif {
+			if seenRanks[rank] == true {
+				continue
+			}
+
+			seenRanks[rank] = true
+		}
+
+		header = append(header, s)
+	}
+
+	sort.SliceStable(header, func(i, j int) bool {
+		ri := canonicalRank(header[i])
+		rj := canonicalRank(header[j])
+
+		if ri != rj {
+			return ri < rj
+		}
+
+		if ri == canonicalRankIcc {
+			return header[i].Data[len(iccPrefix)] < header[j].Data[len(iccPrefix)]
+		} else if ri == canonicalRankTables {
+			// Quantization tables first, then Huffman tables, then anything
+			// else (DRI, DAC), preserving relative order.
+			return tableOrder(header[i].MarkerId) < tableOrder(header[j].MarkerId)
+		}
+
+		return false
+	})
+
+	canonical = make(SegmentList, 0, len(sl))
+
+	tablesAt := -1
+	for i, s := range header {
+		if canonicalRank(s) == canonicalRankTables {
+			tablesAt = i
+			break
+		}
+	}
+
+	if tablesAt == -1 {
+		canonical = append(canonical, header...)
+	} else {
+		tablesEnd := tablesAt
+		for tablesEnd < len(header) && canonicalRank(header[tablesEnd]) == canonicalRankTables {
+			tablesEnd++
+		}
+
+		canonical = append(canonical, header[:tablesAt]...)
+		canonical = append(canonical, mergeTableSegments(header[tablesAt:tablesEnd])...)
+		canonical = append(canonical, header[tablesEnd:]...)
+	}
+
+	canonical = append(canonical, sl[scanAt:]...)
+	canonical.updateOffsets()
+
+	return canonical, nil
+}
+
+func tableOrder(markerId byte) int {
+	if markerId == MARKER_DQT {
+		return 0
+	} else if markerId == MARKER_DHT {
+		return 1
+	}
+
+	return 2
+}
@@ -0,0 +1,63 @@
+package jpegstructure
+
+import (
+	"bytes"
+	"path"
+	"testing"
+
+	"github.com/dsoprea/go-logging"
+)
+
+func TestSegmentList_Canonicalize(t *testing.T) {
+	filepath := path.Join(assetsPath, "20180428_212314.jpg")
+
+	sl, err := ParseFileStructure(filepath)
+	log.PanicIf(err)
+
+	// Swap the JFIF and EXIF segments and add a duplicate JFIF.
+	shuffled := SegmentList { sl[0], sl[2], sl[1], sl[1] }
+	shuffled = append(shuffled, sl[3:]...)
+
+	canonical, err := shuffled.Canonicalize()
+	log.PanicIf(err)
+
+	markers := make([]byte, len(canonical))
+	for i, s := range canonical {
+		markers[i] = s.MarkerId
+	}
+
+	// The four DHT segments are merged and moved before the SOF.
+	expected := []byte { 0xd8, 0xe0, 0xe1, 0xdb, 0xc4, 0xc0, 0xda, 0x00, 0xd9 }
+	if bytes.Compare(markers, expected) != 0 {
+		t.Fatalf("Canonical markers not correct: %v", DumpBytesToString(markers))
+	}
+
+	tables, err := ParseHuffmanTables(canonical[4].Data)
+	log.PanicIf(err)
+
+	if len(tables) != 4 {
+		t.Fatalf("Merged DHT should have four tables: (%d)", len(tables))
+	}
+
+	b := new(bytes.Buffer)
+
+	err = canonical.Write(b)
+	log.PanicIf(err)
+
+	reparsed, err := ParseBytesStructure(b.Bytes())
+	log.PanicIf(err)
+
+	err = reparsed.Validate(b.Bytes())
+	log.PanicIf(err)
+
+	for i, s := range reparsed {
+		if s.Offset != canonical[i].Offset {
+			t.Fatalf("Offset (%d) not correct: (%d) != (%d)", i, canonical[i].Offset, s.Offset)
+		}
+	}
+
+	// The original is not modified.
+	if sl[1].MarkerId != MARKER_APP0 || sl[5].MarkerId != MARKER_DHT {
+		t.Fatalf("Original list was modified.")
+	}
+}
@@ -0,0 +1,157 @@
+package jpegstructure
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	"github.com/dsoprea/go-logging"
+)
+
+const (
+	// defaultCarveMaxImageSize is the default limit on the size of the images
+	// that Carve looks for.
+	defaultCarveMaxImageSize = 64 * 1024 * 1024
+
+	// carveChunkSize is the size of the reads made while looking for the
+	// start of an image.
+	carveChunkSize = 1024 * 1024
+)
+
+// CarveOptions controls Carve.
+type CarveOptions struct {
+	// MaxImageSize is the size of the largest image looked for. A candidate
+	// without an EOI within this many bytes is rejected. Defaults to 64M.
+	MaxImageSize int64
+
+	// Nested also returns the images found inside carved images (e.g. their
+	// thumbnails). Otherwise, the search resumes after each carved image.
+	Nested bool
+}
+
+// CarvedImage is an image recovered by Carve.
+type CarvedImage struct {
+	// Offset is the position of the SOI in the stream.
+	Offset int64
+
+	// Size is the length of the image, up to and including its EOI.
+	Size int64
+
+	// Segments have offsets relative to the start of the image.
+	Segments SegmentList
+}
+
+func (ci CarvedImage) String() string {
+	return fmt.Sprintf("CarvedImage<OFFSET=(0x%08x) SIZE=(%d) SEGMENTS=(%d)>", ci.Offset, ci.Size, len(ci.Segments))
+}
+
+// CarveCallback receives each image recovered by Carve, in the order that
+// they appear in the stream. Returning an error stops the search and Carve
+// returns it.
+type CarveCallback func(ci CarvedImage) error
+
+// isPlausibleImage indicates whether a candidate has what a viewable image
+// needs: a frame with a width and a scan.
+func isPlausibleImage(sl SegmentList) bool {
+	// The height may be zero if it's given by a DNL segment.
+	width, _, err := sl.Dimensions()
+	if err != nil || width == 0 {
+		return false
+	}
+
+	return sl.Index(MARKER_SOS) != -1 && sl.Index(0x0) != -1
+}
+
+// Carve searches the first `size` bytes of the reader (e.g. a disk image or
+// a memory dump) for JPEGs: each SOI is a candidate, which is parsed up to
+// its EOI and kept if it has a frame and a scan. Candidates are read on
+// demand, so memory use is bounded by the largest image. Only contiguous
+// images are recovered; an image whose blocks were scattered is rejected or,
+// if a stray EOI happens to follow its start, truncated.
+func Carve(r io.ReaderAt, size int64, options CarveOptions, cb CarveCallback) (err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	maxImageSize := options.MaxImageSize
+	if maxImageSize <= 0 {
+		maxImageSize = defaultCarveMaxImageSize
+	}
+
+	sr := io.NewSectionReader(r, 0, size)
+	chunk := make([]byte, carveChunkSize)
+	position := int64(0)
+
+	for position < size {
+		n, err := sr.ReadAt(chunk, position)
+		if err != nil && err != io.EOF {
+			log.Panic(err)
+		}
+
+		found := bytes.Index(chunk[:n], embeddedSignature)
+		if found == -1 {
+			if n < len(chunk) {
+				break
+			}
+
+			// The signature may straddle the chunks.
+			position += int64(n - (len(embeddedSignature) - 1))
+			continue
+		}
+
+		candidate := position + int64(found)
+
+		limit := size - candidate
+		if limit > maxImageSize {
+			limit = maxImageSize
+		}
+
+		sl, err := ParseAt(sr, candidate, limit)
+		if err != nil || isPlausibleImage(sl) == false {
+			jpegLogger.Debugf(nil, "Candidate at (0x%08x) rejected: %v", candidate, err)
+
+			position = candidate + 1
+			continue
+		}
+
+		ci := CarvedImage{
+			Offset: candidate,
+			Size: int64(sl[len(sl) - 1].EndOffset()),
+			Segments: sl,
+		}
+
+		err = cb(ci)
+		log.PanicIf(err)
+
+		if options.Nested == true {
+			position = candidate + 1
+		} else {
+			position = candidate + ci.Size
+		}
+	}
+
+	return nil
+}
+
+// CarveBytes is Carve for data in memory. It returns all of the images.
+func CarveBytes(data []byte, options CarveOptions) (images []CarvedImage, err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	images = make([]CarvedImage, 0)
+
+	cb := func(ci CarvedImage) error {
+		images = append(images, ci)
+		return nil
+	}
+
+	err = Carve(bytes.NewReader(data), int64(len(data)), options, cb)
+	log.PanicIf(err)
+
+	return images, nil
+}
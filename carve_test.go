@@ -0,0 +1,95 @@
+package jpegstructure
+
+import (
+	"bytes"
+	"path"
+	"testing"
+
+	"io/ioutil"
+	"math/rand"
+
+	"github.com/dsoprea/go-logging"
+)
+
+func TestCarveBytes(t *testing.T) {
+	filepath := path.Join(assetsPath, testImageRelFilepath)
+
+	photo, err := ioutil.ReadFile(filepath)
+	log.PanicIf(err)
+
+	small := getTransformTestImage()
+
+	garbage := func(size int) []byte {
+		data := make([]byte, size)
+		rand.New(rand.NewSource(int64(size))).Read(data)
+
+		return data
+	}
+
+	// A truncated image (no EOI within the blob) and a stray signature are
+	// rejected.
+	b := new(bytes.Buffer)
+	b.Write(garbage(1000))
+
+	smallOffset := b.Len()
+	b.Write(small)
+
+	b.Write(garbage(333))
+	b.Write(embeddedSignature)
+	b.Write(garbage(4000))
+
+	photoOffset := b.Len()
+	b.Write(photo)
+
+	b.Write(garbage(200))
+	b.Write(small[:len(small) / 2])
+
+	data := b.Bytes()
+
+	images, err := CarveBytes(data, CarveOptions{})
+	log.PanicIf(err)
+
+	if len(images) != 2 {
+		t.Fatalf("Carved image count not correct: (%d)", len(images))
+	}
+
+	expected := []struct {
+		offset int
+		data []byte
+	}{
+		{smallOffset, small},
+		{photoOffset, photo},
+	}
+
+	for i, ci := range images {
+		if ci.Offset != int64(expected[i].offset) || ci.Size != int64(len(expected[i].data)) {
+			t.Fatalf("Carved image (%d) not correct: %s", i, ci)
+		}
+
+		width, _, err := ci.Segments.Dimensions()
+		log.PanicIf(err)
+
+		if width == 0 {
+			t.Fatalf("Carved image (%d) has no frame.", i)
+		}
+	}
+
+	// The thumbnail inside the photo is found as well.
+	images, err = CarveBytes(data, CarveOptions{Nested: true})
+	log.PanicIf(err)
+
+	if len(images) != 3 {
+		t.Fatalf("Nested carved image count not correct: (%d)", len(images))
+	} else if images[2].Offset <= images[1].Offset || images[2].Offset >= images[1].Offset + images[1].Size {
+		t.Fatalf("Nested image not inside the photo: %s", images[2])
+	}
+
+	// Images larger than the limit aren't found (but the thumbnail inside
+	// the photo then is).
+	images, err = CarveBytes(data, CarveOptions{MaxImageSize: int64(len(photo) - 1)})
+	log.PanicIf(err)
+
+	if len(images) != 2 || images[0].Offset != int64(smallOffset) || images[1].Offset <= int64(photoOffset) || images[1].Size >= int64(len(photo)) {
+		t.Fatalf("Limited carve not correct: %v", images)
+	}
+}
@@ -0,0 +1,91 @@
+package jpegstructure
+
+import (
+	"bytes"
+)
+
+// cloneBytes returns a copy of the slice (nil stays nil).
+func cloneBytes(data []byte) []byte {
+	if data == nil {
+		return nil
+	}
+
+	cloned := make([]byte, len(data))
+	copy(cloned, data)
+
+	return cloned
+}
+
+// Clone returns a deep copy of the segment. Nothing is shared with the
+// original.
+func (s Segment) Clone() Segment {
+	cloned := s
+	cloned.Data = cloneBytes(s.Data)
+	cloned.Digest = cloneBytes(s.Digest)
+
+	if s.Warnings != nil {
+		cloned.Warnings = make([]ParseWarning, len(s.Warnings))
+		copy(cloned.Warnings, s.Warnings)
+	}
+
+	return cloned
+}
+
+// Clone returns a deep copy of the list so that speculative edits can be made
+// (and compared or discarded) without affecting the original.
+func (sl SegmentList) Clone() SegmentList {
+	if sl == nil {
+		return nil
+	}
+
+	cloned := make(SegmentList, len(sl))
+	for i, s := range sl {
+		cloned[i] = s.Clone()
+	}
+
+	return cloned
+}
+
+// Fork returns a copy of the list that shares the payloads with the original
+// until they're replaced (copy-on-write). Segments can be added, removed, and
+// reordered, and payloads replaced or appended to, without affecting the
+// original. The payload bytes themselves are shared, however, so use Clone()
+// to modify them in place.
+func (sl SegmentList) Fork() SegmentList {
+	if sl == nil {
+		return nil
+	}
+
+	forked := make(SegmentList, len(sl))
+	copy(forked, sl)
+
+	for i, s := range forked {
+		// Limit the capacity so that appending reallocates rather than
+		// writing into the original's backing array.
+		if s.Data != nil {
+			forked[i].Data = s.Data[:len(s.Data):len(s.Data)]
+		}
+
+		if s.Warnings != nil {
+			forked[i].Warnings = s.Warnings[:len(s.Warnings):len(s.Warnings)]
+		}
+	}
+
+	return forked
+}
+
+// Equal indicates whether the lists have the same segments with the same
+// payloads (regardless of offsets and of anything recorded while parsing).
+func (sl SegmentList) Equal(other SegmentList) bool {
+	if len(sl) != len(other) {
+		return false
+	}
+
+	for i, s := range sl {
+		if s.MarkerId != other[i].MarkerId || bytes.Equal(s.Data, other[i].Data) == false {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,64 @@
+package jpegstructure
+
+import (
+	"path"
+	"testing"
+
+	"github.com/dsoprea/go-logging"
+)
+
+func TestSegmentList_Clone(t *testing.T) {
+	filepath := path.Join(assetsPath, testImageRelFilepath)
+
+	options := ParseOptions{
+		ComputeDigests: true,
+	}
+
+	sl, err := ParseFileStructureWithOptions(filepath, options)
+	log.PanicIf(err)
+
+	original := sl[1].Data[0]
+
+	cloned := sl.Clone()
+	if cloned.Equal(sl) != true {
+		t.Fatalf("Clone not equal to the original.")
+	}
+
+	cloned[1].Data[0] ^= 0xff
+	cloned[1].Digest[0] ^= 0xff
+	cloned[2].MarkerId = MARKER_COM
+
+	if sl[1].Data[0] != original || sl[2].MarkerId != MARKER_APP1 {
+		t.Fatalf("Original changed through the clone.")
+	} else if sl[1].Digest[0] == cloned[1].Digest[0] {
+		t.Fatalf("Digest shared with the clone.")
+	} else if cloned.Equal(sl) != false {
+		t.Fatalf("Changed clone still equal to the original.")
+	}
+}
+
+func TestSegmentList_Fork(t *testing.T) {
+	filepath := path.Join(assetsPath, testImageRelFilepath)
+
+	sl, err := ParseFileStructure(filepath)
+	log.PanicIf(err)
+
+	// Make room after a payload so that an unrestricted append would write
+	// into it.
+	payload := make([]byte, 4, 100)
+	copy(payload, "abcd")
+	sl[1].Data = payload
+
+	forked := sl.Fork()
+
+	forked[1].Data = append(forked[1].Data, "efgh"...)
+	forked = append(forked[:2], forked[3:]...)
+
+	if string(payload[:cap(payload)][4:8]) == "efgh" {
+		t.Fatalf("Append wrote into the original's payload.")
+	} else if string(sl[1].Data) != "abcd" || sl[2].MarkerId != MARKER_APP1 || len(sl) != len(forked) + 1 {
+		t.Fatalf("Original changed through the fork.")
+	} else if string(forked[1].Data) != "abcdefgh" {
+		t.Fatalf("Fork not changed.")
+	}
+}
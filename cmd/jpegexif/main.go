@@ -0,0 +1,138 @@
+// jpegexif prints the EXIF tags of a JPEG and optionally edits them.
+//
+//	jpegexif [-set Tag=Value ...] [-o <output>] <file>
+//
+// Edited images are written to the output filepath or, if not given, back to
+// the original file.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"encoding/binary"
+
+	"github.com/dsoprea/go-logging"
+
+	"github.com/dsoprea/go-jpeg-structure"
+)
+
+// assignments collects repeated "-set Tag=Value" arguments.
+type assignments []string
+
+func (a *assignments) String() string {
+	return strings.Join(*a, ", ")
+}
+
+func (a *assignments) Set(value string) error {
+	if strings.Contains(value, "=") == false {
+		return fmt.Errorf("assignment must look like Tag=Value: [%s]", value)
+	}
+
+	*a = append(*a, value)
+	return nil
+}
+
+func applyAssignments(sl *jpegstructure.SegmentList, sets assignments) {
+	ed, err := sl.ExifDocument()
+	if log.Is(err, jpegstructure.ErrSegmentNotFound) == true {
+		ed = jpegstructure.NewExifDocument(binary.BigEndian)
+	} else {
+		log.PanicIf(err)
+	}
+
+	for _, assignment := range sets {
+		i := strings.Index(assignment, "=")
+		name, text := assignment[:i], assignment[i + 1:]
+
+		etd, err := jpegstructure.LookupExifTag(name)
+		if err != nil {
+			log.Panicf("tag not known: [%s]", name)
+		}
+
+		value, err := jpegstructure.ParseExifValueString(etd.TagType, text)
+		log.PanicIf(err)
+
+		err = ed.SetValue(etd.IfdName, etd.TagId, etd.TagType, value)
+		log.PanicIf(err)
+	}
+
+	err = sl.SetExifDocument(ed)
+	log.PanicIf(err)
+}
+
+func writeFile(filepath string, sl jpegstructure.SegmentList) {
+	f, err := os.Create(filepath)
+	log.PanicIf(err)
+
+	defer f.Close()
+
+	err = sl.Write(f)
+	log.PanicIf(err)
+}
+
+func printTags(sl jpegstructure.SegmentList) {
+	exifData, err := sl.ExifData()
+	if log.Is(err, jpegstructure.ErrSegmentNotFound) == true {
+		fmt.Printf("No EXIF data.\n")
+		return
+	}
+
+	log.PanicIf(err)
+
+	exifTags, err := jpegstructure.GetExifData(exifData)
+	log.PanicIf(err)
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+
+	fmt.Fprintf(tw, "IFD\tID\tNAME\tTYPE\tVALUE\n")
+
+	for _, et := range exifTags {
+		fmt.Fprintf(tw, "%s\t0x%04x\t%s\t%s\t%v\n", et.IfdName, et.TagId, et.TagName, et.TagTypeName, et.Value)
+	}
+
+	err = tw.Flush()
+	log.PanicIf(err)
+}
+
+func main() {
+	defer func() {
+		if state := recover(); state != nil {
+			err := log.Wrap(state.(error))
+			fmt.Fprintf(os.Stderr, "ERROR: %s\n", err.Error())
+			os.Exit(1)
+		}
+	}()
+
+	var sets assignments
+
+	flag.Var(&sets, "set", "Tag assignment (Tag=Value); may be repeated")
+	outputFilepath := flag.String("o", "", "Output filepath for edits (defaults to the input file)")
+
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		fmt.Fprintf(os.Stderr, "Usage: %s [-set Tag=Value ...] [-o <output>] <file>\n", os.Args[0])
+		os.Exit(1)
+	}
+
+	filepath := flag.Arg(0)
+
+	sl, err := jpegstructure.ParseFileStructure(filepath)
+	log.PanicIf(err)
+
+	if len(sets) > 0 {
+		applyAssignments(&sl, sets)
+
+		if *outputFilepath == "" {
+			*outputFilepath = filepath
+		}
+
+		writeFile(*outputFilepath, sl)
+	}
+
+	printTags(sl)
+}
@@ -0,0 +1,328 @@
+// jpegstructure inspects and rewrites the segment structure of JPEG files.
+//
+//	jpegstructure dump [-json | -exiftool] [-verbose] [-hex N] <file>
+//	jpegstructure strip [-keep-icc] -o <output> <file>
+//	jpegstructure extract (-index N | -name MARKER | -blob exif|xmp|icc) -o <output> <file>
+//	jpegstructure validate [-level structure|decoding|metadata] <file>
+//	jpegstructure shift -by <duration> -o <output> <file>
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"encoding/json"
+	"io/ioutil"
+
+	"github.com/dsoprea/go-logging"
+
+	"github.com/dsoprea/go-jpeg-structure"
+)
+
+type segmentRecord struct {
+	Index int `json:"index"`
+	MarkerId byte `json:"marker_id"`
+	MarkerName string `json:"marker_name"`
+	Offset int `json:"offset"`
+	Size int `json:"size"`
+}
+
+type command struct {
+	name string
+	usage string
+	handler func(args []string)
+}
+
+var (
+	commands = []command{
+		{"dump", "[-json | -exiftool] [-verbose] [-hex N] <file>", handleDump},
+		{"strip", "[-keep-icc] -o <output> <file>", handleStrip},
+		{"extract", "(-index N | -name MARKER | -blob exif|xmp|icc) -o <output> <file>", handleExtract},
+		{"validate", "[-level structure|decoding|metadata] <file>", handleValidate},
+		{"shift", "-by <duration> -o <output> <file>", handleShift},
+	}
+)
+
+func printUsage() {
+	fmt.Fprintf(os.Stderr, "Usage:\n")
+
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "  %s %s %s\n", os.Args[0], c.name, c.usage)
+	}
+}
+
+// parseArgs parses the flags of a subcommand and returns the single
+// positional filepath.
+func parseArgs(fs *flag.FlagSet, args []string) string {
+	err := fs.Parse(args)
+	log.PanicIf(err)
+
+	if fs.NArg() != 1 {
+		log.Panicf("exactly one filepath is required")
+	}
+
+	return fs.Arg(0)
+}
+
+// splitList splits a comma-separated flag value, dropping empty items.
+func splitList(value string) []string {
+	items := make([]string, 0)
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
+
+func handleDump(args []string) {
+	runDump(os.Stdout, args)
+}
+
+// runDump does the work of the "dump" subcommand, writing to `w`.
+func runDump(w io.Writer, args []string) {
+	fs := flag.NewFlagSet("dump", flag.ExitOnError)
+
+	isJson := fs.Bool("json", false, "Print the structure as JSON")
+	isExiftool := fs.Bool("exiftool", false, "Print the metadata as exiftool-compatible JSON (-json -G -n)")
+	isVerbose := fs.Bool("verbose", false, "Break down the known segments")
+	hexBytes := fs.Int("hex", -1, "Include hex listings of up to N bytes per segment (0 for all)")
+	include := fs.String("include", "", "Only list these metadata tags (comma-separated; e.g. \"EXIF:Make,GPS:all,XMP-dc:all\")")
+	exclude := fs.String("exclude", "", "Don't list these metadata tags (comma-separated)")
+
+	filepath := parseArgs(fs, args)
+
+	sl, err := jpegstructure.ParseFileStructure(filepath)
+	log.PanicIf(err)
+
+	filter := jpegstructure.TagFilter{
+		Include: splitList(*include),
+		Exclude: splitList(*exclude),
+	}
+
+	if *isExiftool == true {
+		exiftoolOptions := jpegstructure.ExiftoolOptions{
+			Filter: filter,
+		}
+
+		err := sl.WriteExiftoolJsonWithOptions(w, filepath, exiftoolOptions)
+		log.PanicIf(err)
+
+		return
+	} else if *isJson == true {
+		records := make([]segmentRecord, len(sl))
+		for i, s := range sl {
+			records[i] = segmentRecord{
+				Index: i,
+				MarkerId: s.MarkerId,
+				MarkerName: s.MarkerName,
+				Offset: s.Offset,
+				Size: len(s.Data),
+			}
+		}
+
+		data, err := json.MarshalIndent(records, "", "    ")
+		log.PanicIf(err)
+
+		fmt.Fprintln(w, string(data))
+		return
+	}
+
+	options := jpegstructure.DumpOptions{
+		Verbose: *isVerbose,
+		HexDump: *hexBytes >= 0,
+		HexDumpMaxBytes: *hexBytes,
+		TagFilter: filter,
+	}
+
+	err = sl.DumpTextWithOptions(w, options)
+	log.PanicIf(err)
+}
+
+func writeFile(filepath string, sl jpegstructure.SegmentList) {
+	f, err := os.Create(filepath)
+	log.PanicIf(err)
+
+	defer f.Close()
+
+	err = sl.Write(f)
+	log.PanicIf(err)
+}
+
+func handleStrip(args []string) {
+	fs := flag.NewFlagSet("strip", flag.ExitOnError)
+
+	keepIcc := fs.Bool("keep-icc", false, "Keep the ICC profile")
+	outputFilepath := fs.String("o", "", "Output filepath")
+
+	filepath := parseArgs(fs, args)
+
+	if *outputFilepath == "" {
+		log.Panicf("output filepath is required")
+	}
+
+	sl, err := jpegstructure.ParseFileStructure(filepath)
+	log.PanicIf(err)
+
+	stripped := sl.StripMetadata(*keepIcc)
+	writeFile(*outputFilepath, stripped)
+
+	fmt.Printf("Removed (%d) segments.\n", len(sl) - len(stripped))
+}
+
+func handleExtract(args []string) {
+	fs := flag.NewFlagSet("extract", flag.ExitOnError)
+
+	index := fs.Int("index", -1, "Index of the segment to extract")
+	markerName := fs.String("name", "", "Name of the marker of the first segment to extract (e.g. APP2)")
+	blob := fs.String("blob", "", "Metadata to extract: exif, xmp, or icc")
+	outputFilepath := fs.String("o", "", "Output filepath")
+
+	filepath := parseArgs(fs, args)
+
+	if *outputFilepath == "" {
+		log.Panicf("output filepath is required")
+	}
+
+	sl, err := jpegstructure.ParseFileStructure(filepath)
+	log.PanicIf(err)
+
+	var data []byte
+
+	if *index >= 0 {
+		if *index >= len(sl) {
+			log.Panicf("segment index out of range: (%d) >= (%d)", *index, len(sl))
+		}
+
+		data = sl[*index].Data
+	} else if *markerName != "" {
+		for _, s := range sl {
+			if strings.EqualFold(s.MarkerName, *markerName) == true {
+				data = s.Data
+				break
+			}
+		}
+
+		if data == nil {
+			log.Panicf("no segment with marker: [%s]", *markerName)
+		}
+	} else if *blob == "exif" {
+		data, err = sl.ExifData()
+		log.PanicIf(err)
+	} else if *blob == "xmp" {
+		data, err = sl.XmpData()
+		log.PanicIf(err)
+	} else if *blob == "icc" {
+		data, err = sl.IccProfile()
+		log.PanicIf(err)
+	} else {
+		log.Panicf("one of -index, -name, or -blob (exif, xmp, icc) is required")
+	}
+
+	err = ioutil.WriteFile(*outputFilepath, data, 0644)
+	log.PanicIf(err)
+
+	fmt.Printf("Wrote (%d) bytes.\n", len(data))
+}
+
+func handleShift(args []string) {
+	fs := flag.NewFlagSet("shift", flag.ExitOnError)
+
+	by := fs.String("by", "", "Duration to move the EXIF timestamps by (e.g. -1h30m)")
+	outputFilepath := fs.String("o", "", "Output filepath")
+
+	filepath := parseArgs(fs, args)
+
+	if *outputFilepath == "" {
+		log.Panicf("output filepath is required")
+	} else if *by == "" {
+		log.Panicf("duration is required")
+	}
+
+	d, err := time.ParseDuration(*by)
+	log.PanicIf(err)
+
+	sl, err := jpegstructure.ParseFileStructure(filepath)
+	log.PanicIf(err)
+
+	shifted, err := sl.ShiftTimestamps(d)
+	log.PanicIf(err)
+
+	writeFile(*outputFilepath, sl)
+
+	fmt.Printf("Shifted (%d) timestamps.\n", shifted)
+}
+
+func handleValidate(args []string) {
+	fs := flag.NewFlagSet("validate", flag.ExitOnError)
+
+	levelName := fs.String("level", "decoding", "Thoroughness: structure, decoding, or metadata")
+
+	filepath := parseArgs(fs, args)
+
+	levels := map[string]jpegstructure.LintLevel{
+		"structure": jpegstructure.LintLevelStructure,
+		"decoding": jpegstructure.LintLevelDecoding,
+		"metadata": jpegstructure.LintLevelMetadata,
+	}
+
+	level, found := levels[*levelName]
+	if found == false {
+		log.Panicf("level not valid: [%s]", *levelName)
+	}
+
+	data, err := ioutil.ReadFile(filepath)
+	log.PanicIf(err)
+
+	sl, err := jpegstructure.ParseBytesStructure(data)
+	log.PanicIf(err)
+
+	findings, err := sl.Lint(data, level)
+	log.PanicIf(err)
+
+	hasErrors := false
+	for _, lf := range findings {
+		fmt.Printf("%s: [%s] segment (%d): %s\n", strings.ToUpper(lf.Severity.String()), lf.Code, lf.SegmentIndex, lf.Message)
+
+		if lf.Severity == jpegstructure.LintError {
+			hasErrors = true
+		}
+	}
+
+	if hasErrors == true {
+		os.Exit(2)
+	}
+
+	fmt.Printf("OK\n")
+}
+
+func main() {
+	defer func() {
+		if state := recover(); state != nil {
+			err := log.Wrap(state.(error))
+			fmt.Fprintf(os.Stderr, "ERROR: %s\n", err.Error())
+			os.Exit(1)
+		}
+	}()
+
+	if len(os.Args) < 2 {
+		printUsage()
+		os.Exit(1)
+	}
+
+	for _, c := range commands {
+		if c.name == os.Args[1] {
+			c.handler(os.Args[2:])
+			return
+		}
+	}
+
+	printUsage()
+	os.Exit(1)
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/dsoprea/go-logging"
+)
+
+var (
+	assetsPath = ""
+)
+
+func TestRunDump_XmpGroupFilter(t *testing.T) {
+	filepath := path.Join(assetsPath, "NDM_8901.jpg")
+
+	// The same filter selects the same XMP properties in every mode.
+	modes := [][]string{
+		{"-exiftool"},
+		{"-verbose"},
+	}
+
+	for _, mode := range modes {
+		b := new(bytes.Buffer)
+
+		args := append(append([]string{}, mode...), "-include", "XMP-xmp:all", filepath)
+		runDump(b, args)
+
+		if strings.Contains(b.String(), "Rating") == false {
+			t.Fatalf("XMP group not selected with %v:\n%s", mode, b.String())
+		}
+
+		b.Reset()
+
+		args = append(append([]string{}, mode...), "-include", "XMP-dc:all", filepath)
+		runDump(b, args)
+
+		if strings.Contains(b.String(), "Rating") == true {
+			t.Fatalf("Property outside the XMP group selected with %v:\n%s", mode, b.String())
+		}
+	}
+}
+
+func init() {
+	goPath := os.Getenv("GOPATH")
+	if goPath == "" {
+		log.Panicf("GOPATH is empty")
+	}
+
+	assetsPath = path.Join(goPath, "src", "github.com", "dsoprea", "go-jpeg-structure", "assets")
+}
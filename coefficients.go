@@ -0,0 +1,816 @@
+package jpegstructure
+
+import (
+	"bytes"
+
+	"github.com/dsoprea/go-logging"
+)
+
+// The quantized DCT coefficients of a sequential, Huffman-coded image can be
+// decoded and re-encoded without any loss. This is the basis of the lossless
+// transforms.
+
+var (
+	// zigzagOrder maps the position of a coefficient in the stream to its
+	// position in the block (row * 8 + column).
+	zigzagOrder = [64]int{
+		0, 1, 8, 16, 9, 2, 3, 10,
+		17, 24, 32, 25, 18, 11, 4, 5,
+		12, 19, 26, 33, 40, 48, 41, 34,
+		27, 20, 13, 6, 7, 14, 21, 28,
+		35, 42, 49, 56, 57, 50, 43, 36,
+		29, 22, 15, 23, 30, 37, 44, 51,
+		58, 59, 52, 45, 38, 31, 39, 46,
+		53, 60, 61, 54, 47, 55, 62, 63,
+	}
+)
+
+// ZigzagOrder returns the mapping from the position of a coefficient in the
+// stream (and in a QuantizationTable) to its position in the block in natural
+// order.
+func ZigzagOrder() [64]int {
+	return zigzagOrder
+}
+
+// coefficientBlock is one 8x8 block of quantized coefficients in natural
+// (row-major) order.
+type coefficientBlock [64]int32
+
+// coefficientComponent is the grid of blocks for one component. The grid
+// covers every MCU, including the padding on the right and bottom edges.
+type coefficientComponent struct {
+	SofComponent
+
+	blocksWide, blocksHigh int
+	blocks []coefficientBlock
+}
+
+func (cc *coefficientComponent) block(x, y int) *coefficientBlock {
+	return &cc.blocks[y * cc.blocksWide + x]
+}
+
+// coefficientImage is a frame decoded to its coefficients.
+type coefficientImage struct {
+	markerId byte
+	precision byte
+	width, height int
+
+	components []*coefficientComponent
+}
+
+// maxSamplingFactors returns the largest horizontal and vertical sampling
+// factors of the components.
+func (ci *coefficientImage) maxSamplingFactors() (maxH, maxV int) {
+	for _, cc := range ci.components {
+		if int(cc.HorizontalSamplingFactor) > maxH {
+			maxH = int(cc.HorizontalSamplingFactor)
+		}
+
+		if int(cc.VerticalSamplingFactor) > maxV {
+			maxV = int(cc.VerticalSamplingFactor)
+		}
+	}
+
+	return maxH, maxV
+}
+
+// mcuSize returns the size of an MCU in pixels.
+func (ci *coefficientImage) mcuSize() (width, height int) {
+	maxH, maxV := ci.maxSamplingFactors()
+	return maxH * 8, maxV * 8
+}
+
+// mcuCount returns the number of MCUs across and down.
+func (ci *coefficientImage) mcuCount() (across, down int) {
+	mcuWidth, mcuHeight := ci.mcuSize()
+	return (ci.width + mcuWidth - 1) / mcuWidth, (ci.height + mcuHeight - 1) / mcuHeight
+}
+
+// componentBlockCount returns the number of blocks across and down that
+// actually carry image data for the component (what a non-interleaved scan
+// codes).
+func (ci *coefficientImage) componentBlockCount(cc *coefficientComponent) (across, down int) {
+	maxH, maxV := ci.maxSamplingFactors()
+
+	width := (ci.width * int(cc.HorizontalSamplingFactor) + maxH - 1) / maxH
+	height := (ci.height * int(cc.VerticalSamplingFactor) + maxV - 1) / maxV
+
+	return (width + 7) / 8, (height + 7) / 8
+}
+
+// allocate sizes the block grids for the current dimensions.
+func (ci *coefficientImage) allocate() {
+	mcusAcross, mcusDown := ci.mcuCount()
+
+	for _, cc := range ci.components {
+		cc.blocksWide = mcusAcross * int(cc.HorizontalSamplingFactor)
+		cc.blocksHigh = mcusDown * int(cc.VerticalSamplingFactor)
+		cc.blocks = make([]coefficientBlock, cc.blocksWide * cc.blocksHigh)
+	}
+}
+
+func (ci *coefficientImage) component(componentId byte) *coefficientComponent {
+	for _, cc := range ci.components {
+		if cc.ComponentId == componentId {
+			return cc
+		}
+	}
+
+	return nil
+}
+
+// scanBlock is one block visited by a scan along with the component it
+// belongs to.
+type scanBlock struct {
+	componentIndex int
+	block *coefficientBlock
+}
+
+// scanOrder returns the blocks in the order that a scan over the given
+// components codes them, grouped into the units that restart intervals count
+// (MCUs for interleaved scans and blocks otherwise).
+func (ci *coefficientImage) scanOrder(components []*coefficientComponent) [][]scanBlock {
+	units := make([][]scanBlock, 0)
+
+	if len(components) == 1 {
+		cc := components[0]
+		across, down := ci.componentBlockCount(cc)
+
+		for y := 0; y < down; y++ {
+			for x := 0; x < across; x++ {
+				units = append(units, []scanBlock{{0, cc.block(x, y)}})
+			}
+		}
+
+		return units
+	}
+
+	mcusAcross, mcusDown := ci.mcuCount()
+
+	for mcuY := 0; mcuY < mcusDown; mcuY++ {
+		for mcuX := 0; mcuX < mcusAcross; mcuX++ {
+			unit := make([]scanBlock, 0)
+
+			for i, cc := range components {
+				h := int(cc.HorizontalSamplingFactor)
+				v := int(cc.VerticalSamplingFactor)
+
+				for y := 0; y < v; y++ {
+					for x := 0; x < h; x++ {
+						unit = append(unit, scanBlock{i, cc.block(mcuX * h + x, mcuY * v + y)})
+					}
+				}
+			}
+
+			units = append(units, unit)
+		}
+	}
+
+	return units
+}
+
+// huffmanDecoder decodes the canonical codes of one table.
+type huffmanDecoder struct {
+	minCode [17]int32
+	maxCode [17]int32
+	valuePointer [17]int
+	symbols []byte
+}
+
+func newHuffmanDecoder(ht HuffmanTable) *huffmanDecoder {
+	hd := &huffmanDecoder{
+		symbols: ht.Symbols,
+	}
+
+	code := int32(0)
+	k := 0
+	for length := 1; length <= 16; length++ {
+		count := int(ht.Counts[length - 1])
+
+		hd.valuePointer[length] = k
+		hd.minCode[length] = code
+		hd.maxCode[length] = -1
+
+		if count > 0 {
+			hd.maxCode[length] = code + int32(count) - 1
+		}
+
+		code = (code + int32(count)) << 1
+		k += count
+	}
+
+	return hd
+}
+
+// entropyReader reads bits from entropy-coded data, removing the stuffed
+// zeroes.
+type entropyReader struct {
+	data []byte
+	position int
+
+	bits uint32
+	bitCount int
+
+	// padding counts the zero bytes supplied after the end of the data (or
+	// at a marker). A few are normal at the end of a scan.
+	padding int
+}
+
+func (er *entropyReader) fill() {
+	b := byte(0)
+
+	if er.position >= len(er.data) {
+		er.padding++
+	} else if er.data[er.position] != 0xff {
+		b = er.data[er.position]
+		er.position++
+	} else if er.position + 1 < len(er.data) && er.data[er.position + 1] == 0x00 {
+		b = 0xff
+		er.position += 2
+	} else {
+		// A marker. We don't move past it.
+		er.padding++
+	}
+
+	if er.padding > 16 {
+		log.Panicf("entropy-coded data ended prematurely")
+	}
+
+	er.bits = er.bits << 8 | uint32(b)
+	er.bitCount += 8
+}
+
+func (er *entropyReader) readBits(count int) int32 {
+	for er.bitCount < count {
+		er.fill()
+	}
+
+	er.bitCount -= count
+	value := int32(er.bits >> uint(er.bitCount)) & (1 << uint(count) - 1)
+
+	return value
+}
+
+// receiveExtend reads a value of the given size and sign-extends it.
+func (er *entropyReader) receiveExtend(size int) int32 {
+	if size == 0 {
+		return 0
+	}
+
+	value := er.readBits(size)
+	if value < 1 << uint(size - 1) {
+		value += -1 << uint(size) + 1
+	}
+
+	return value
+}
+
+func (er *entropyReader) decode(hd *huffmanDecoder) byte {
+	code := int32(0)
+	for length := 1; length <= 16; length++ {
+		code = code << 1 | er.readBits(1)
+
+		if code <= hd.maxCode[length] {
+			return hd.symbols[hd.valuePointer[length] + int(code - hd.minCode[length])]
+		}
+	}
+
+	log.Panicf("Huffman code not valid")
+	return 0
+}
+
+// restart discards the remaining bits and moves past the restart marker that
+// has to follow.
+func (er *entropyReader) restart() {
+	er.bits = 0
+	er.bitCount = 0
+	er.padding = 0
+
+	for er.position + 1 < len(er.data) && er.data[er.position] == 0xff && er.data[er.position + 1] == 0xff {
+		er.position++
+	}
+
+	if er.position + 1 >= len(er.data) || er.data[er.position] != 0xff || IsRstMarker(er.data[er.position + 1]) == false {
+		log.Panicf("restart marker not found: (%d)", er.position)
+	}
+
+	er.position += 2
+}
+
+func (er *entropyReader) decodeBlock(block *coefficientBlock, dc, ac *huffmanDecoder, predictor *int32) {
+	size := int(er.decode(dc))
+	*predictor += er.receiveExtend(size)
+	block[0] = *predictor
+
+	for k := 1; k < 64; k++ {
+		rs := er.decode(ac)
+		run := int(rs >> 4)
+		size := int(rs & 0x0f)
+
+		if size == 0 {
+			if run != 15 {
+				// End-of-block.
+				break
+			}
+
+			k += 15
+			continue
+		}
+
+		k += run
+		if k > 63 {
+			log.Panicf("coefficient run out of range")
+		}
+
+		block[zigzagOrder[k]] = er.receiveExtend(size)
+	}
+}
+
+// decodeCoefficients decodes every scan of a sequential, Huffman-coded image.
+func decodeCoefficients(sl SegmentList) (ci *coefficientImage, err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	pi, err := sl.ProcessInfo()
+	log.PanicIf(err)
+
+	if pi.EntropyCoding != EntropyCodingHuffman || pi.Mode != ProcessModeSequential {
+		log.Panicf("only sequential Huffman-coded images are supported: %s", pi)
+	}
+
+	dcDecoders := make(map[byte]*huffmanDecoder)
+	acDecoders := make(map[byte]*huffmanDecoder)
+
+	restartInterval := 0
+
+	for i, s := range sl {
+		switch {
+		case s.MarkerId == MARKER_DHT:
+			tables, err := ParseHuffmanTables(s.Data)
+			log.PanicIf(err)
+
+			for _, ht := range tables {
+				if ht.Class == HUFFMAN_CLASS_DC {
+					dcDecoders[ht.TableId] = newHuffmanDecoder(ht)
+				} else {
+					acDecoders[ht.TableId] = newHuffmanDecoder(ht)
+				}
+			}
+		case s.MarkerId == MARKER_DRI:
+			if len(s.Data) != 2 {
+				log.Panicf("DRI payload not the right size: (%d)", len(s.Data))
+			}
+
+			restartInterval = int(s.Data[0]) << 8 | int(s.Data[1])
+		case IsSofMarker(s.MarkerId) == true:
+			if ci != nil {
+				log.Panicf("more than one frame")
+			}
+
+			sof, err := sl.Sof()
+			log.PanicIf(err)
+
+			components, err := ParseSofComponents(s.Data)
+			log.PanicIf(err)
+
+			ci = &coefficientImage{
+				markerId: s.MarkerId,
+				precision: sof.BitsPerSample,
+				width: int(sof.Width),
+				height: int(sof.Height),
+				components: make([]*coefficientComponent, len(components)),
+			}
+
+			for j, sc := range components {
+				if sc.HorizontalSamplingFactor < 1 || sc.HorizontalSamplingFactor > 4 || sc.VerticalSamplingFactor < 1 || sc.VerticalSamplingFactor > 4 {
+					log.Panicf("sampling factors not valid: %s", sc)
+				}
+
+				ci.components[j] = &coefficientComponent{SofComponent: sc}
+			}
+
+			ci.allocate()
+		case s.MarkerId == 0x0:
+			if ci == nil {
+				log.Panicf("scan appears before the frame: (%d)", i)
+			}
+
+			header, entropyData, err := splitScanData(s.Data)
+			log.PanicIf(err)
+
+			sh, err := ParseSosHeader(header)
+			log.PanicIf(err)
+
+			components := make([]*coefficientComponent, len(sh.Components))
+			dc := make([]*huffmanDecoder, len(sh.Components))
+			ac := make([]*huffmanDecoder, len(sh.Components))
+
+			for j, sc := range sh.Components {
+				components[j] = ci.component(sc.ComponentId)
+				if components[j] == nil {
+					log.Panicf("scan references component not in frame: (%d)", sc.ComponentId)
+				}
+
+				dc[j] = dcDecoders[sc.DcTableId]
+				ac[j] = acDecoders[sc.AcTableId]
+
+				if dc[j] == nil || ac[j] == nil {
+					log.Panicf("Huffman tables for component (%d) not defined", sc.ComponentId)
+				}
+			}
+
+			er := &entropyReader{
+				data: entropyData,
+			}
+
+			predictors := make([]int32, len(components))
+
+			for unitIndex, unit := range ci.scanOrder(components) {
+				if restartInterval > 0 && unitIndex > 0 && unitIndex % restartInterval == 0 {
+					er.restart()
+
+					for j := range predictors {
+						predictors[j] = 0
+					}
+				}
+
+				for _, sb := range unit {
+					j := sb.componentIndex
+					er.decodeBlock(sb.block, dc[j], ac[j], &predictors[j])
+				}
+			}
+		}
+	}
+
+	if ci == nil {
+		log.Panic(ErrSegmentNotFound)
+	}
+
+	return ci, nil
+}
+
+// ComponentCoefficients is the grid of quantized DCT coefficients of one
+// component. The grid covers every MCU, including the padding on the right
+// and bottom edges.
+type ComponentCoefficients struct {
+	SofComponent
+
+	BlocksWide, BlocksHigh int
+
+	// Blocks are in row-major order. The coefficients of each block are in
+	// natural (row-major) order rather than zigzag order.
+	Blocks [][64]int32
+}
+
+// Coefficients decodes the scan-data to the quantized DCT coefficients of
+// each component (in frame order) without dequantizing or transforming them.
+// Only sequential, Huffman-coded images are supported.
+func (sl SegmentList) Coefficients() (components []ComponentCoefficients, err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	ci, err := decodeCoefficients(sl)
+	log.PanicIf(err)
+
+	components = make([]ComponentCoefficients, len(ci.components))
+
+	for i, cc := range ci.components {
+		blocks := make([][64]int32, len(cc.blocks))
+		for j, block := range cc.blocks {
+			blocks[j] = block
+		}
+
+		components[i] = ComponentCoefficients{
+			SofComponent: cc.SofComponent,
+			BlocksWide: cc.blocksWide,
+			BlocksHigh: cc.blocksHigh,
+			Blocks: blocks,
+		}
+	}
+
+	return components, nil
+}
+
+// huffmanEncoder holds the code and code-length of each symbol.
+type huffmanEncoder struct {
+	codes [256]uint16
+	sizes [256]int
+}
+
+func newHuffmanEncoder(ht HuffmanTable) *huffmanEncoder {
+	he := new(huffmanEncoder)
+
+	code := uint16(0)
+	k := 0
+	for length := 1; length <= 16; length++ {
+		for j := 0; j < int(ht.Counts[length - 1]); j++ {
+			symbol := ht.Symbols[k]
+			he.codes[symbol] = code
+			he.sizes[symbol] = length
+
+			code++
+			k++
+		}
+
+		code <<= 1
+	}
+
+	return he
+}
+
+// buildOptimalHuffmanTable generates the table that codes the symbols with
+// the given frequencies in the fewest bits, limited to 16-bit codes. This is
+// the procedure from Annex K.2 of the standard (as implemented by libjpeg).
+func buildOptimalHuffmanTable(class, tableId byte, frequencies [256]int) HuffmanTable {
+	// A reserved symbol guarantees that no real symbol is given a code of
+	// all one-bits.
+	var freq [257]int
+	copy(freq[:], frequencies[:])
+	freq[256] = 1
+
+	var codeSize [257]int
+	var others [257]int
+	for i := range others {
+		others[i] = -1
+	}
+
+	for {
+		c1 := -1
+		v := int(^uint(0) >> 1)
+		for i := 0; i < 257; i++ {
+			if freq[i] != 0 && freq[i] <= v {
+				v = freq[i]
+				c1 = i
+			}
+		}
+
+		c2 := -1
+		v = int(^uint(0) >> 1)
+		for i := 0; i < 257; i++ {
+			if freq[i] != 0 && freq[i] <= v && i != c1 {
+				v = freq[i]
+				c2 = i
+			}
+		}
+
+		if c2 < 0 {
+			break
+		}
+
+		freq[c1] += freq[c2]
+		freq[c2] = 0
+
+		codeSize[c1]++
+		for others[c1] >= 0 {
+			c1 = others[c1]
+			codeSize[c1]++
+		}
+
+		others[c1] = c2
+
+		codeSize[c2]++
+		for others[c2] >= 0 {
+			c2 = others[c2]
+			codeSize[c2]++
+		}
+	}
+
+	var bits [33]int
+	for i := 0; i < 257; i++ {
+		if codeSize[i] > 0 {
+			if codeSize[i] > 32 {
+				log.Panicf("Huffman code-length out of range")
+			}
+
+			bits[codeSize[i]]++
+		}
+	}
+
+	// Shorten the codes longer than 16 bits.
+	for i := 32; i > 16; i-- {
+		for bits[i] > 0 {
+			j := i - 2
+			for bits[j] == 0 {
+				j--
+			}
+
+			bits[i] -= 2
+			bits[i - 1]++
+			bits[j + 1] += 2
+			bits[j]--
+		}
+	}
+
+	// Remove the reserved symbol (which has the longest code).
+	i := 16
+	for bits[i] == 0 {
+		i--
+	}
+
+	bits[i]--
+
+	ht := HuffmanTable{
+		Class: class,
+		TableId: tableId,
+		Symbols: make([]byte, 0),
+	}
+
+	for length := 1; length <= 16; length++ {
+		ht.Counts[length - 1] = byte(bits[length])
+	}
+
+	for length := 1; length <= 32; length++ {
+		for symbol := 0; symbol < 256; symbol++ {
+			if codeSize[symbol] == length {
+				ht.Symbols = append(ht.Symbols, byte(symbol))
+			}
+		}
+	}
+
+	return ht
+}
+
+// entropyWriter writes bits, stuffing a zero after every 0xff.
+type entropyWriter struct {
+	b *bytes.Buffer
+
+	bits uint32
+	bitCount int
+}
+
+func (ew *entropyWriter) writeBits(value uint32, count int) {
+	ew.bits = ew.bits << uint(count) | value & (1 << uint(count) - 1)
+	ew.bitCount += count
+
+	for ew.bitCount >= 8 {
+		b := byte(ew.bits >> uint(ew.bitCount - 8))
+		ew.b.WriteByte(b)
+
+		if b == 0xff {
+			ew.b.WriteByte(0x00)
+		}
+
+		ew.bitCount -= 8
+	}
+
+	ew.bits &= 1 << uint(ew.bitCount) - 1
+}
+
+// flush pads the last byte with one-bits.
+func (ew *entropyWriter) flush() {
+	if ew.bitCount > 0 {
+		ew.writeBits(0xff, 8 - ew.bitCount)
+	}
+}
+
+// magnitudeSize returns the number of bits needed for the magnitude of the
+// value.
+func magnitudeSize(value int32) int {
+	if value < 0 {
+		value = -value
+	}
+
+	size := 0
+	for value > 0 {
+		size++
+		value >>= 1
+	}
+
+	return size
+}
+
+// blockSymbols calls `emit` for every Huffman symbol of the block (with the
+// additional bits that follow it). The DC symbol is flagged.
+func blockSymbols(block *coefficientBlock, predictor *int32, emit func(isDc bool, symbol byte, value int32, size int)) {
+	diff := block[0] - *predictor
+	*predictor = block[0]
+
+	size := magnitudeSize(diff)
+	emit(true, byte(size), diff, size)
+
+	run := 0
+	for k := 1; k < 64; k++ {
+		value := block[zigzagOrder[k]]
+		if value == 0 {
+			run++
+			continue
+		}
+
+		for run > 15 {
+			emit(false, 0xf0, 0, 0)
+			run -= 16
+		}
+
+		size := magnitudeSize(value)
+		emit(false, byte(run << 4 | size), value, size)
+
+		run = 0
+	}
+
+	if run > 0 {
+		// End-of-block.
+		emit(false, 0x00, 0, 0)
+	}
+}
+
+// encode codes every component in one sequential scan with optimal Huffman
+// tables. The first component uses tables zero and the rest use tables one.
+func (ci *coefficientImage) encode() (tables []HuffmanTable, header SosHeader, entropyData []byte) {
+	tableIds := make([]byte, len(ci.components))
+	header.Components = make([]SosComponent, len(ci.components))
+	header.SpectralEnd = 63
+
+	for i, cc := range ci.components {
+		if i > 0 {
+			tableIds[i] = 1
+		}
+
+		header.Components[i] = SosComponent{
+			ComponentId: cc.ComponentId,
+			DcTableId: tableIds[i],
+			AcTableId: tableIds[i],
+		}
+	}
+
+	units := ci.scanOrder(ci.components)
+
+	// Gather the statistics.
+
+	var dcFrequencies [2][256]int
+	var acFrequencies [2][256]int
+
+	predictors := make([]int32, len(ci.components))
+	for _, unit := range units {
+		for _, sb := range unit {
+			tableId := tableIds[sb.componentIndex]
+
+			blockSymbols(sb.block, &predictors[sb.componentIndex], func(isDc bool, symbol byte, value int32, size int) {
+				if isDc == true {
+					dcFrequencies[tableId][symbol]++
+				} else {
+					acFrequencies[tableId][symbol]++
+				}
+			})
+		}
+	}
+
+	tables = make([]HuffmanTable, 0)
+
+	var dcEncoders [2]*huffmanEncoder
+	var acEncoders [2]*huffmanEncoder
+
+	tableCount := 1
+	if len(ci.components) > 1 {
+		tableCount = 2
+	}
+
+	for tableId := 0; tableId < tableCount; tableId++ {
+		dc := buildOptimalHuffmanTable(HUFFMAN_CLASS_DC, byte(tableId), dcFrequencies[tableId])
+		ac := buildOptimalHuffmanTable(HUFFMAN_CLASS_AC, byte(tableId), acFrequencies[tableId])
+
+		dcEncoders[tableId] = newHuffmanEncoder(dc)
+		acEncoders[tableId] = newHuffmanEncoder(ac)
+
+		tables = append(tables, dc, ac)
+	}
+
+	// Code the blocks.
+
+	ew := &entropyWriter{
+		b: new(bytes.Buffer),
+	}
+
+	predictors = make([]int32, len(ci.components))
+	for _, unit := range units {
+		for _, sb := range unit {
+			tableId := tableIds[sb.componentIndex]
+
+			blockSymbols(sb.block, &predictors[sb.componentIndex], func(isDc bool, symbol byte, value int32, size int) {
+				he := acEncoders[tableId]
+				if isDc == true {
+					he = dcEncoders[tableId]
+				}
+
+				ew.writeBits(uint32(he.codes[symbol]), he.sizes[symbol])
+
+				if size > 0 {
+					if value < 0 {
+						value--
+					}
+
+					ew.writeBits(uint32(value), size)
+				}
+			})
+		}
+	}
+
+	ew.flush()
+
+	return tables, header, ew.b.Bytes()
+}
@@ -0,0 +1,243 @@
+package jpegstructure
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/dsoprea/go-logging"
+)
+
+const (
+	// corpusHistogramWidth is the length of the longest bar in WriteText().
+	corpusHistogramWidth = 40
+)
+
+// CorpusReport aggregates the structure of many images, to show what a
+// service that processes them has to support.
+type CorpusReport struct {
+	ImageCount int
+
+	// FailedCount is the number of files that couldn't be parsed or
+	// analyzed. They aren't included in anything else.
+	FailedCount int
+
+	// MarkerCounts is the number of segments with each marker and
+	// MarkerImageCounts is the number of images with at least one.
+	MarkerCounts map[byte]int
+	MarkerImageCounts map[byte]int
+
+	// ProcessCounts is the number of images with each coding process (e.g.
+	// "huffman sequential").
+	ProcessCounts map[string]int
+
+	// EncoderCounts is the number of images attributed to each encoder (its
+	// name if the match was exact, its family otherwise).
+	EncoderCounts map[string]int
+
+	TotalBytes int64
+	MetadataBytes int64
+	ScanDataBytes int64
+
+	// metadataRatioSum is the sum of the per-image metadata ratios.
+	metadataRatioSum float64
+}
+
+// NewCorpusReport returns an empty report.
+func NewCorpusReport() *CorpusReport {
+	return &CorpusReport{
+		MarkerCounts: make(map[byte]int),
+		MarkerImageCounts: make(map[byte]int),
+		ProcessCounts: make(map[string]int),
+		EncoderCounts: make(map[string]int),
+	}
+}
+
+// AverageMetadataRatio returns the mean of the share of each image taken up
+// by metadata.
+func (cr *CorpusReport) AverageMetadataRatio() float64 {
+	if cr.ImageCount == 0 {
+		return 0
+	}
+
+	return cr.metadataRatioSum / float64(cr.ImageCount)
+}
+
+func (cr *CorpusReport) String() string {
+	return fmt.Sprintf("CorpusReport<IMAGES=(%d) FAILED=(%d) TOTAL=(%d) METADATA=(%d) SCAN-DATA=(%d) AVERAGE-METADATA-RATIO=(%.3f)>", cr.ImageCount, cr.FailedCount, cr.TotalBytes, cr.MetadataBytes, cr.ScanDataBytes, cr.AverageMetadataRatio())
+}
+
+// Add includes an image in the report. The report is unchanged if the image
+// can't be analyzed.
+func (cr *CorpusReport) Add(sl SegmentList) (err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	stats, err := sl.Stats()
+	log.PanicIf(err)
+
+	pi, err := sl.ProcessInfo()
+	log.PanicIf(err)
+
+	es, err := sl.EncoderSignature()
+	log.PanicIf(err)
+
+	seen := make(map[byte]bool)
+	for _, s := range sl {
+		cr.MarkerCounts[s.MarkerId]++
+
+		if seen[s.MarkerId] == false {
+			cr.MarkerImageCounts[s.MarkerId]++
+			seen[s.MarkerId] = true
+		}
+	}
+
+	cr.ProcessCounts[fmt.Sprintf("%s %s", pi.EntropyCoding, pi.Mode)]++
+
+	if es.Exact == true {
+		cr.EncoderCounts[es.Name]++
+	} else {
+		cr.EncoderCounts[es.Family]++
+	}
+
+	cr.ImageCount++
+	cr.TotalBytes += int64(stats.TotalBytes)
+	cr.MetadataBytes += int64(stats.MetadataBytes)
+	cr.ScanDataBytes += int64(stats.ScanDataBytes)
+	cr.metadataRatioSum += stats.MetadataRatio()
+
+	return nil
+}
+
+// AddResult includes a result of ParseEach or ParseMany, counting failures.
+func (cr *CorpusReport) AddResult(result ParseResult) {
+	if result.Err == nil {
+		result.Err = cr.Add(result.Segments)
+	}
+
+	if result.Err != nil {
+		jpegLogger.Debugf(nil, "Image not included in the report: [%s] %v", result.Filepath, result.Err)
+		cr.FailedCount++
+	}
+}
+
+// Merge adds the totals of another report (e.g. from another worker or
+// shard) to this one.
+func (cr *CorpusReport) Merge(other *CorpusReport) {
+	cr.ImageCount += other.ImageCount
+	cr.FailedCount += other.FailedCount
+	cr.TotalBytes += other.TotalBytes
+	cr.MetadataBytes += other.MetadataBytes
+	cr.ScanDataBytes += other.ScanDataBytes
+	cr.metadataRatioSum += other.metadataRatioSum
+
+	for markerId, count := range other.MarkerCounts {
+		cr.MarkerCounts[markerId] += count
+	}
+
+	for markerId, count := range other.MarkerImageCounts {
+		cr.MarkerImageCounts[markerId] += count
+	}
+
+	for process, count := range other.ProcessCounts {
+		cr.ProcessCounts[process] += count
+	}
+
+	for encoder, count := range other.EncoderCounts {
+		cr.EncoderCounts[encoder] += count
+	}
+}
+
+// corpusHistogram writes one bar per key, most frequent first.
+func corpusHistogram(w io.Writer, title string, counts map[string]int, total int) {
+	keys := make([]string, 0, len(counts))
+	longest := 0
+	for key := range counts {
+		keys = append(keys, key)
+
+		if len(key) > longest {
+			longest = len(key)
+		}
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		if counts[keys[i]] != counts[keys[j]] {
+			return counts[keys[i]] > counts[keys[j]]
+		}
+
+		return keys[i] < keys[j]
+	})
+
+	_, err := fmt.Fprintf(w, "%s:\n", title)
+	log.PanicIf(err)
+
+	for _, key := range keys {
+		bar := 0
+		if total > 0 {
+			bar = (counts[key] * corpusHistogramWidth + total - 1) / total
+		}
+
+		_, err := fmt.Fprintf(w, "  %-*s %6d %s\n", longest, key, counts[key], strings.Repeat("#", bar))
+		log.PanicIf(err)
+	}
+}
+
+// WriteText writes the report with a histogram of the markers (by the number
+// of images that have them), the coding processes, and the encoders.
+func (cr *CorpusReport) WriteText(w io.Writer) (err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	_, err = fmt.Fprintf(w, "Images: (%d) Failed: (%d)\n", cr.ImageCount, cr.FailedCount)
+	log.PanicIf(err)
+
+	_, err = fmt.Fprintf(w, "Bytes: (%d) Metadata: (%d) Scan-data: (%d) Average metadata ratio: (%.3f)\n", cr.TotalBytes, cr.MetadataBytes, cr.ScanDataBytes, cr.AverageMetadataRatio())
+	log.PanicIf(err)
+
+	markers := make(map[string]int)
+	for markerId, count := range cr.MarkerImageCounts {
+		name := markerNames[markerId]
+		if markerId == 0x0 {
+			name = "!SCANDATA"
+		} else if name == "" {
+			name = fmt.Sprintf("0x%02x", markerId)
+		}
+
+		markers[name] = count
+	}
+
+	corpusHistogram(w, "Markers", markers, cr.ImageCount)
+	corpusHistogram(w, "Processes", cr.ProcessCounts, cr.ImageCount)
+	corpusHistogram(w, "Encoders", cr.EncoderCounts, cr.ImageCount)
+
+	return nil
+}
+
+// BuildCorpusReport parses the files concurrently (see ParseEach) and
+// aggregates them into a report. Files that can't be parsed are counted as
+// failures.
+func BuildCorpusReport(filepaths []string, workers int) (cr *CorpusReport, err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	cr = NewCorpusReport()
+
+	err = ParseEach(filepaths, workers, func(result ParseResult) error {
+		cr.AddResult(result)
+		return nil
+	})
+
+	log.PanicIf(err)
+
+	return cr, nil
+}
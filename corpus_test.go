@@ -0,0 +1,78 @@
+package jpegstructure
+
+import (
+	"bytes"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/dsoprea/go-logging"
+)
+
+func TestBuildCorpusReport(t *testing.T) {
+	filepaths := []string{
+		path.Join(assetsPath, testImageRelFilepath),
+		path.Join(assetsPath, "does-not-exist.jpg"),
+		path.Join(assetsPath, "20180428_212314.jpg"),
+	}
+
+	cr, err := BuildCorpusReport(filepaths, 2)
+	log.PanicIf(err)
+
+	if cr.ImageCount != 2 || cr.FailedCount != 1 {
+		t.Fatalf("Counts not correct: %s", cr)
+	} else if cr.MarkerImageCounts[MARKER_SOI] != 2 || cr.MarkerImageCounts[MARKER_APP0] != 1 {
+		t.Fatalf("Marker image-counts not correct: %v", cr.MarkerImageCounts)
+	} else if cr.MarkerCounts[MARKER_APP1] < 3 {
+		t.Fatalf("Marker counts not correct: %v", cr.MarkerCounts)
+	} else if cr.ProcessCounts["huffman sequential"] != 2 {
+		t.Fatalf("Process counts not correct: %v", cr.ProcessCounts)
+	} else if cr.AverageMetadataRatio() <= 0 || cr.MetadataBytes <= 0 || cr.ScanDataBytes <= 0 {
+		t.Fatalf("Sizes not correct: %s", cr)
+	}
+
+	encoders := 0
+	for _, count := range cr.EncoderCounts {
+		encoders += count
+	}
+
+	if encoders != 2 {
+		t.Fatalf("Encoder counts not correct: %v", cr.EncoderCounts)
+	}
+
+	b := new(bytes.Buffer)
+
+	err = cr.WriteText(b)
+	log.PanicIf(err)
+
+	if strings.HasPrefix(b.String(), "Images: (2) Failed: (1)\n") == false {
+		t.Fatalf("Report not correct:\n%s", b.String())
+	} else if strings.Contains(b.String(), "  SOI ") == false {
+		t.Fatalf("Marker histogram not found:\n%s", b.String())
+	}
+}
+
+func TestCorpusReport_Merge(t *testing.T) {
+	sl, err := ParseFileStructure(path.Join(assetsPath, testImageRelFilepath))
+	log.PanicIf(err)
+
+	first := NewCorpusReport()
+
+	err = first.Add(sl)
+	log.PanicIf(err)
+
+	second := NewCorpusReport()
+
+	err = second.Add(sl)
+	log.PanicIf(err)
+
+	second.AddResult(ParseResult{Err: ErrSegmentNotFound})
+
+	first.Merge(second)
+
+	if first.ImageCount != 2 || first.FailedCount != 1 || first.MarkerImageCounts[MARKER_SOI] != 2 {
+		t.Fatalf("Merged report not correct: %s", first)
+	} else if first.AverageMetadataRatio() != second.AverageMetadataRatio() {
+		t.Fatalf("Average metadata ratio not correct: (%f) != (%f)", first.AverageMetadataRatio(), second.AverageMetadataRatio())
+	}
+}
@@ -0,0 +1,111 @@
+package jpegstructure
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/dsoprea/go-logging"
+)
+
+const (
+	ARITHMETIC_CLASS_DC = 0
+	ARITHMETIC_CLASS_AC = 1
+)
+
+const (
+	// dacEntrySize is the size of one conditioning table in a DAC payload.
+	dacEntrySize = 2
+
+	// maxArithmeticTableId is the largest conditioning-table destination.
+	maxArithmeticTableId = 3
+)
+
+// ArithmeticConditioning is one conditioning table from a DAC segment.
+type ArithmeticConditioning struct {
+	// Class is ARITHMETIC_CLASS_DC or ARITHMETIC_CLASS_AC.
+	Class byte
+
+	TableId byte
+
+	// Value is the raw conditioning value (Cs). For DC tables, it packs the
+	// bounds (see Lower and Upper); for AC tables, it is Kx.
+	Value byte
+}
+
+// Lower returns the lower bound (L) of a DC table.
+func (ac ArithmeticConditioning) Lower() byte {
+	return ac.Value & 0x0f
+}
+
+// Upper returns the upper bound (U) of a DC table.
+func (ac ArithmeticConditioning) Upper() byte {
+	return ac.Value >> 4
+}
+
+func (ac ArithmeticConditioning) String() string {
+	if ac.Class == ARITHMETIC_CLASS_DC {
+		return fmt.Sprintf("ArithmeticConditioning<CLASS=[DC] ID=(%d) L=(%d) U=(%d)>", ac.TableId, ac.Lower(), ac.Upper())
+	}
+
+	return fmt.Sprintf("ArithmeticConditioning<CLASS=[AC] ID=(%d) KX=(%d)>", ac.TableId, ac.Value)
+}
+
+// validate checks the values against the ranges allowed by the standard
+// (Table F.1 and F.2).
+func (ac ArithmeticConditioning) validate() {
+	if ac.Class > ARITHMETIC_CLASS_AC {
+		log.Panicf("DAC table class not valid: (%d)", ac.Class)
+	} else if ac.TableId > maxArithmeticTableId {
+		log.Panicf("DAC table ID not valid: (%d)", ac.TableId)
+	}
+
+	if ac.Class == ARITHMETIC_CLASS_DC {
+		if ac.Lower() > ac.Upper() {
+			log.Panicf("DAC DC bounds not valid: L=(%d) U=(%d)", ac.Lower(), ac.Upper())
+		}
+	} else if ac.Value < 1 || ac.Value > 63 {
+		log.Panicf("DAC AC Kx not valid: (%d)", ac.Value)
+	}
+}
+
+// ParseArithmeticConditioning parses every conditioning table in a DAC
+// payload.
+func ParseArithmeticConditioning(data []byte) (tables []ArithmeticConditioning, err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	if len(data) % dacEntrySize != 0 {
+		log.Panicf("DAC payload truncated: (%d)", len(data))
+	}
+
+	tables = make([]ArithmeticConditioning, 0, len(data) / dacEntrySize)
+
+	for i := 0; i < len(data); i += dacEntrySize {
+		ac := ArithmeticConditioning{
+			Class: data[i] >> 4,
+			TableId: data[i] & 0x0f,
+			Value: data[i + 1],
+		}
+
+		ac.validate()
+
+		tables = append(tables, ac)
+	}
+
+	return tables, nil
+}
+
+// EncodeArithmeticConditioning produces a DAC payload.
+func EncodeArithmeticConditioning(tables []ArithmeticConditioning) []byte {
+	b := new(bytes.Buffer)
+
+	for _, ac := range tables {
+		b.WriteByte(ac.Class << 4 | ac.TableId)
+		b.WriteByte(ac.Value)
+	}
+
+	return b.Bytes()
+}
@@ -0,0 +1,70 @@
+package jpegstructure
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/dsoprea/go-logging"
+)
+
+func TestParseArithmeticConditioning(t *testing.T) {
+	data := []byte{
+		0x00, 0x21,
+		0x11, 0x05,
+	}
+
+	tables, err := ParseArithmeticConditioning(data)
+	log.PanicIf(err)
+
+	expected := []ArithmeticConditioning{
+		{Class: ARITHMETIC_CLASS_DC, TableId: 0, Value: 0x21},
+		{Class: ARITHMETIC_CLASS_AC, TableId: 1, Value: 5},
+	}
+
+	if reflect.DeepEqual(tables, expected) == false {
+		t.Fatalf("Tables not correct: %v", tables)
+	} else if tables[0].Lower() != 1 || tables[0].Upper() != 2 {
+		t.Fatalf("DC bounds not correct: %s", tables[0])
+	}
+
+	if bytes.Equal(EncodeArithmeticConditioning(tables), data) == false {
+		t.Fatalf("Encoded payload not correct.")
+	}
+
+	invalid := [][]byte{
+		{0x00},
+		{0x00, 0x12},
+		{0x10, 0x00},
+		{0x10, 64},
+		{0x04, 0x00},
+	}
+
+	for _, payload := range invalid {
+		_, err := ParseArithmeticConditioning(payload)
+		if err == nil {
+			t.Fatalf("Expected error for (%X).", payload)
+		}
+	}
+}
+
+func TestSegmentList_DumpText_Dac(t *testing.T) {
+	sl := SegmentList{
+		{MarkerId: MARKER_SOI, MarkerName: "SOI"},
+		{MarkerId: MARKER_DAC, MarkerName: "DAC", Data: []byte{0x00, 0x10, 0x10, 0x05}},
+		{MarkerId: MARKER_EOI, MarkerName: "EOI"},
+	}
+
+	b := new(bytes.Buffer)
+
+	err := sl.DumpText(b, true)
+	log.PanicIf(err)
+
+	expected := "|- DAC: CLASS=[DC] TABLE=(0) L=(0) U=(1)\n" +
+		"|- DAC: CLASS=[AC] TABLE=(0) KX=(5)\n"
+
+	if strings.Contains(b.String(), expected) == false {
+		t.Fatalf("DAC tables not found:\n%s", b.String())
+	}
+}
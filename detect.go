@@ -0,0 +1,139 @@
+package jpegstructure
+
+import (
+	"bytes"
+
+	"github.com/dsoprea/go-logging"
+)
+
+const (
+	// DetectPrefixSize is the number of leading bytes that DetectFormat()
+	// needs to tell every format apart.
+	DetectPrefixSize = 16
+)
+
+var (
+	// jp2Signature is the JPEG 2000 signature box that begins a JP2 (or JPX)
+	// file.
+	jp2Signature = []byte{0x00, 0x00, 0x00, 0x0c, 'j', 'P', ' ', ' ', 0x0d, 0x0a, 0x87, 0x0a}
+
+	// spiffPrefix begins the payload of a SPIFF header (APP8).
+	spiffPrefix = []byte{'S', 'P', 'I', 'F', 'F', 0x00}
+)
+
+// ImageFormat is the kind of file that DetectFormat() found.
+type ImageFormat int
+
+const (
+	// ImageFormatUnknown is anything that isn't recognized as an image
+	// (including a JPEG rejected by the DetectOptions).
+	ImageFormatUnknown ImageFormat = iota
+
+	ImageFormatJpeg
+
+	// ImageFormatJ2k is a raw JPEG 2000 codestream (begins with SOC).
+	ImageFormatJ2k
+
+	// ImageFormatJp2 is a JPEG 2000 file in the JP2 container.
+	ImageFormatJp2
+)
+
+func (f ImageFormat) String() string {
+	switch f {
+	case ImageFormatJpeg:
+		return "jpeg"
+	case ImageFormatJ2k:
+		return "j2k"
+	case ImageFormatJp2:
+		return "jp2"
+	}
+
+	return "unknown"
+}
+
+// DetectOptions controls which JPEGs DetectFormatWithOptions() accepts. By
+// default, any SOI followed by a marker is accepted.
+type DetectOptions struct {
+	// RequireHeader only accepts a JPEG whose first segment is a JFIF APP0
+	// (or one of the headers allowed below).
+	RequireHeader bool
+
+	// AllowExifFirst also accepts an EXIF APP1 as the first segment (as
+	// most cameras write) when RequireHeader is set.
+	AllowExifFirst bool
+
+	// AllowSpiff also accepts a SPIFF APP8 as the first segment when
+	// RequireHeader is set.
+	AllowSpiff bool
+}
+
+// DetectFormat identifies the image from the first bytes of the file. Pass at
+// least DetectPrefixSize bytes (or the whole file, if it's smaller).
+func DetectFormat(prefix []byte) ImageFormat {
+	return DetectFormatWithOptions(prefix, DetectOptions{})
+}
+
+// DetectFormatWithOptions is DetectFormat with control over which JPEGs are
+// accepted.
+func DetectFormatWithOptions(prefix []byte, options DetectOptions) ImageFormat {
+	if bytes.HasPrefix(prefix, jp2Signature) == true {
+		return ImageFormatJp2
+	} else if bytes.HasPrefix(prefix, jpegMagic2000) == true {
+		return ImageFormatJ2k
+	} else if bytes.HasPrefix(prefix, jpegMagicStandard) == false {
+		return ImageFormatUnknown
+	}
+
+	if options.RequireHeader == false {
+		return ImageFormatJpeg
+	}
+
+	// The first segment's marker, two-byte length, and payload signature.
+	if len(prefix) < 6 {
+		return ImageFormatUnknown
+	}
+
+	markerId := prefix[3]
+	payload := prefix[6:]
+
+	if markerId == MARKER_APP0 && bytes.HasPrefix(payload, jfifPrefix) == true {
+		return ImageFormatJpeg
+	} else if options.AllowExifFirst == true && markerId == MARKER_APP1 && bytes.HasPrefix(payload, exifPrefix) == true {
+		return ImageFormatJpeg
+	} else if options.AllowSpiff == true && markerId == MARKER_APP8 && bytes.HasPrefix(payload, spiffPrefix) == true {
+		return ImageFormatJpeg
+	}
+
+	return ImageFormatUnknown
+}
+
+// detectPrefixNeeded returns the number of bytes that DetectFormatWithOptions()
+// needs to identify a file that begins with `first`.
+func detectPrefixNeeded(first byte, options DetectOptions) int {
+	if first == jp2Signature[0] {
+		return len(jp2Signature)
+	} else if options.RequireHeader == true {
+		// The first segment's header and the longest payload signature.
+		return len(jpegMagicStandard) + 3 + len(exifPrefix)
+	}
+
+	return len(jpegMagicStandard)
+}
+
+// checkFormat panics unless the prefix is a JPEG that the options accept.
+func checkFormat(prefix []byte, options DetectOptions) {
+	format := DetectFormatWithOptions(prefix, options)
+	if format == ImageFormatJpeg {
+		return
+	} else if format == ImageFormatJ2k || format == ImageFormatJp2 {
+		// TODO(dustin): Return to JPEG2000 support.
+		log.Panicf("JPEG2000 not supported: [%s]", format)
+	}
+
+	shown := prefix
+	if len(shown) > len(jpegMagicStandard) {
+		shown = shown[:len(jpegMagicStandard)]
+	}
+
+	log.Panicf("file does not look like a JPEG: (% X)", shown)
+}
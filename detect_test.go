@@ -0,0 +1,82 @@
+package jpegstructure
+
+import (
+	"bytes"
+	"path"
+	"strings"
+	"testing"
+
+	"io/ioutil"
+
+	"github.com/dsoprea/go-logging"
+)
+
+func TestDetectFormat(t *testing.T) {
+	jfif := append([]byte{0xff, MARKER_SOI, 0xff, MARKER_APP0, 0x00, 0x10}, jfifPrefix...)
+	exifFirst := append([]byte{0xff, MARKER_SOI, 0xff, MARKER_APP1, 0x10, 0x00}, exifPrefix...)
+	spiff := append([]byte{0xff, MARKER_SOI, 0xff, MARKER_APP8, 0x00, 0x20}, spiffPrefix...)
+	j2k := []byte{0xff, 0x4f, 0xff, MARKER_SIZ, 0x00, 0x29}
+	jp2 := append(append([]byte{}, jp2Signature...), 0x00, 0x00, 0x00, 0x14)
+
+	cases := []struct {
+		prefix []byte
+		options DetectOptions
+		expected ImageFormat
+	}{
+		{jfif, DetectOptions{}, ImageFormatJpeg},
+		{exifFirst, DetectOptions{}, ImageFormatJpeg},
+		{spiff, DetectOptions{}, ImageFormatJpeg},
+		{j2k, DetectOptions{}, ImageFormatJ2k},
+		{jp2, DetectOptions{}, ImageFormatJp2},
+		{[]byte("GIF89a"), DetectOptions{}, ImageFormatUnknown},
+		{[]byte{0xff, MARKER_SOI}, DetectOptions{}, ImageFormatUnknown},
+		{nil, DetectOptions{}, ImageFormatUnknown},
+
+		{jfif, DetectOptions{RequireHeader: true}, ImageFormatJpeg},
+		{exifFirst, DetectOptions{RequireHeader: true}, ImageFormatUnknown},
+		{exifFirst, DetectOptions{RequireHeader: true, AllowExifFirst: true}, ImageFormatJpeg},
+		{spiff, DetectOptions{RequireHeader: true, AllowExifFirst: true}, ImageFormatUnknown},
+		{spiff, DetectOptions{RequireHeader: true, AllowSpiff: true}, ImageFormatJpeg},
+	}
+
+	for i, c := range cases {
+		format := DetectFormatWithOptions(c.prefix, c.options)
+		if format != c.expected {
+			t.Fatalf("Case (%d) not correct: [%s] != [%s]", i, format, c.expected)
+		}
+	}
+}
+
+func TestJpegSplitter_Split_Detect(t *testing.T) {
+	filepath := path.Join(assetsPath, testImageRelFilepath)
+
+	data, err := ioutil.ReadFile(filepath)
+	log.PanicIf(err)
+
+	// The image begins with EXIF rather than JFIF.
+
+	options := ParseOptions{
+		Detect: DetectOptions{
+			RequireHeader: true,
+		},
+	}
+
+	_, err = ParseSegmentsWithOptions(bytes.NewReader(data), len(data), options)
+	if err == nil {
+		t.Fatalf("Expected EXIF-first image to be rejected.")
+	}
+
+	options.Detect.AllowExifFirst = true
+
+	_, err = ParseSegmentsWithOptions(bytes.NewReader(data), len(data), options)
+	log.PanicIf(err)
+
+	// JPEG 2000 is recognized but not supported.
+
+	jp2 := append(append([]byte{}, jp2Signature...), 0x00, 0x00, 0x00, 0x14)
+
+	_, err = ParseBytesStructure(jp2)
+	if err == nil || strings.Contains(err.Error(), "JPEG2000 not supported") == false {
+		t.Fatalf("Expected JPEG 2000 error: %v", err)
+	}
+}
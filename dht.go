@@ -0,0 +1,86 @@
+package jpegstructure
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/dsoprea/go-logging"
+)
+
+const (
+	HUFFMAN_CLASS_DC = 0
+	HUFFMAN_CLASS_AC = 1
+)
+
+// HuffmanTable is one table from a DHT segment.
+type HuffmanTable struct {
+	// Class is HUFFMAN_CLASS_DC or HUFFMAN_CLASS_AC.
+	Class byte
+
+	TableId byte
+
+	// Counts is the number of codes of each length (1-16).
+	Counts [16]byte
+
+	// Symbols are the values of the codes, in order of increasing code
+	// length.
+	Symbols []byte
+}
+
+func (ht HuffmanTable) String() string {
+	return fmt.Sprintf("HuffmanTable<CLASS=(%d) ID=(%d) SYMBOLS=(%d)>", ht.Class, ht.TableId, len(ht.Symbols))
+}
+
+// ParseHuffmanTables parses every table in a DHT payload.
+func ParseHuffmanTables(data []byte) (tables []HuffmanTable, err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	tables = make([]HuffmanTable, 0)
+
+	for i := 0; i < len(data); {
+		if i + 17 > len(data) {
+			log.Panicf("DHT payload truncated (counts)")
+		}
+
+		ht := HuffmanTable{
+			Class: data[i] >> 4,
+			TableId: data[i] & 0x0f,
+		}
+
+		copy(ht.Counts[:], data[i + 1:i + 17])
+		i += 17
+
+		total := 0
+		for _, count := range ht.Counts {
+			total += int(count)
+		}
+
+		if i + total > len(data) {
+			log.Panicf("DHT payload truncated (symbols)")
+		}
+
+		ht.Symbols = append([]byte{}, data[i:i + total]...)
+		i += total
+
+		tables = append(tables, ht)
+	}
+
+	return tables, nil
+}
+
+// EncodeHuffmanTables produces a DHT payload.
+func EncodeHuffmanTables(tables []HuffmanTable) []byte {
+	b := new(bytes.Buffer)
+
+	for _, ht := range tables {
+		b.WriteByte(ht.Class << 4 | ht.TableId)
+		b.Write(ht.Counts[:])
+		b.Write(ht.Symbols)
+	}
+
+	return b.Bytes()
+}
@@ -0,0 +1,61 @@
+package jpegstructure
+
+import (
+	"hash"
+
+	"crypto/sha256"
+
+	"github.com/dsoprea/go-logging"
+)
+
+// isMetadataMarker indicates whether the marker carries metadata (APPn and
+// COM) rather than image structure.
+func isMetadataMarker(markerId byte) bool {
+	return markerId == MARKER_COM || IsAppMarker(markerId) == true
+}
+
+// ImageDigest hashes the structural and scan segments (tables, frame, scans,
+// and scan-data) in their serialized form, skipping APPn and COM, and returns
+// the sum. Images that differ only in their metadata produce the same digest.
+func (sl SegmentList) ImageDigest(h hash.Hash) (digest []byte, err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	h.Reset()
+
+	for _, s := range sl {
+		if isMetadataMarker(s.MarkerId) == true {
+			continue
+		}
+
+		err := s.Write(h)
+		log.PanicIf(err)
+	}
+
+	return h.Sum(nil), nil
+}
+
+// Checksums returns the SHA-256 of the payload of each segment, in order. The
+// digest recorded while parsing (see ParseOptions) is used where there is one,
+// so the payloads may be dropped after parsing and the segments still compared
+// or verified later. Edits made through the setters clear the recorded digest,
+// and those segments are hashed from their current payload. Comparing the
+// checksums of two versions of a file shows which segments changed.
+func (sl SegmentList) Checksums() [][]byte {
+	checksums := make([][]byte, len(sl))
+
+	for i, s := range sl {
+		if s.Digest != nil {
+			checksums[i] = s.Digest
+			continue
+		}
+
+		digest := sha256.Sum256(s.Data)
+		checksums[i] = digest[:]
+	}
+
+	return checksums
+}
@@ -0,0 +1,146 @@
+package jpegstructure
+
+import (
+	"bytes"
+	"path"
+	"testing"
+
+	"crypto/sha256"
+
+	"github.com/dsoprea/go-logging"
+)
+
+func TestSegmentList_ImageDigest(t *testing.T) {
+	filepath := path.Join(assetsPath, testImageRelFilepath)
+
+	sl, err := ParseFileStructure(filepath)
+	log.PanicIf(err)
+
+	digest, err := sl.ImageDigest(sha256.New())
+	log.PanicIf(err)
+
+	strippedDigest, err := sl.StripMetadata(false).ImageDigest(sha256.New())
+	log.PanicIf(err)
+
+	if bytes.Compare(digest, strippedDigest) != 0 {
+		t.Fatalf("Digest changed when only metadata was removed.")
+	}
+
+	other, err := ParseFileStructure(path.Join(assetsPath, "20180428_212314.jpg"))
+	log.PanicIf(err)
+
+	otherDigest, err := other.ImageDigest(sha256.New())
+	log.PanicIf(err)
+
+	if bytes.Compare(digest, otherDigest) == 0 {
+		t.Fatalf("Different images have the same digest.")
+	}
+}
+
+func TestSegmentList_Checksums(t *testing.T) {
+	filepath := path.Join(assetsPath, testImageRelFilepath)
+
+	options := ParseOptions{
+		ComputeDigests: true,
+	}
+
+	sl, err := ParseFileStructureWithOptions(filepath, options)
+	log.PanicIf(err)
+
+	checksums := sl.Checksums()
+	if len(checksums) != len(sl) {
+		t.Fatalf("Checksum count not correct: (%d) != (%d)", len(checksums), len(sl))
+	}
+
+	for i, s := range sl {
+		if s.Digest == nil {
+			t.Fatalf("Digest not recorded for segment (%d).", i)
+		}
+
+		expected := sha256.Sum256(s.Data)
+		if bytes.Compare(checksums[i], expected[:]) != 0 {
+			t.Fatalf("Checksum not correct for segment (%d).", i)
+		}
+	}
+
+	// Without digests, the checksums are computed on demand and agree.
+
+	unhashed, err := ParseFileStructure(filepath)
+	log.PanicIf(err)
+
+	if unhashed[0].Digest != nil {
+		t.Fatalf("Digest not expected without the option.")
+	}
+
+	// Change one segment of the second version. The recorded checksums of the
+	// first version identify it without its payload.
+
+	i := sl.Index(MARKER_APP1)
+
+	unhashed[i].Data = append([]byte{}, unhashed[i].Data...)
+	unhashed[i].Data[0] ^= 0xff
+
+	// The recorded digests are used, so the payloads aren't needed.
+	for j := range sl {
+		sl[j].Data = nil
+	}
+
+	checksums = sl.Checksums()
+
+	changed := make([]int, 0)
+	for j, checksum := range unhashed.Checksums() {
+		if bytes.Compare(checksum, checksums[j]) != 0 {
+			changed = append(changed, j)
+		}
+	}
+
+	if len(changed) != 1 || changed[0] != i {
+		t.Fatalf("Changed segments not correct: %v", changed)
+	}
+}
+
+func TestSegmentList_Checksums_AfterEdit(t *testing.T) {
+	filepath := path.Join(assetsPath, testImageRelFilepath)
+
+	options := ParseOptions{
+		ComputeDigests: true,
+	}
+
+	sl, err := ParseFileStructureWithOptions(filepath, options)
+	log.PanicIf(err)
+
+	before := sl.Checksums()
+
+	orientation, err := sl.Orientation()
+	log.PanicIf(err)
+
+	if orientation == ORIENTATION_LEFT_BOTTOM {
+		orientation = ORIENTATION_TOP_LEFT
+	} else {
+		orientation = ORIENTATION_LEFT_BOTTOM
+	}
+
+	err = sl.SetOrientation(orientation)
+	log.PanicIf(err)
+
+	i := sl.Index(MARKER_APP1)
+	after := sl.Checksums()
+
+	if bytes.Equal(before[i], after[i]) == true {
+		t.Fatalf("Checksum of the edited EXIF segment not changed.")
+	}
+
+	expected := sha256.Sum256(sl[i].Data)
+	if bytes.Equal(after[i], expected[:]) == false {
+		t.Fatalf("Checksum does not match the edited payload.")
+	} else if sl[i].Digest != nil {
+		t.Fatalf("Digest of the edited payload is stale.")
+	}
+
+	// The segments that weren't edited keep their recorded digests.
+	for j, s := range sl {
+		if j != i && s.Digest == nil {
+			t.Fatalf("Digest of segment (%d) cleared.", j)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package jpegstructure
+
+import (
+	"encoding/binary"
+
+	"github.com/dsoprea/go-logging"
+)
+
+const (
+	// dnlPayloadSize is the size of a DNL payload (the number of lines). The
+	// marker length is therefore always four.
+	dnlPayloadSize = 2
+)
+
+// ParseDnl returns the number of lines from a DNL payload. A frame declares a
+// height of zero when the real height is only given by a DNL segment that
+// follows the first scan.
+func ParseDnl(data []byte) (lines uint16, err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	if len(data) != dnlPayloadSize {
+		log.Panicf("DNL payload not the right size: (%d)", len(data))
+	}
+
+	lines = binary.BigEndian.Uint16(data)
+	if lines == 0 {
+		log.Panicf("DNL number-of-lines is zero")
+	}
+
+	return lines, nil
+}
+
+// EncodeDnl produces a DNL payload.
+func EncodeDnl(lines uint16) []byte {
+	data := make([]byte, dnlPayloadSize)
+	binary.BigEndian.PutUint16(data, lines)
+
+	return data
+}
@@ -0,0 +1,76 @@
+package jpegstructure
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/dsoprea/go-logging"
+)
+
+func TestParseDnl(t *testing.T) {
+	lines, err := ParseDnl(EncodeDnl(480))
+	log.PanicIf(err)
+
+	if lines != 480 {
+		t.Fatalf("Lines not correct: (%d)", lines)
+	}
+
+	_, err = ParseDnl([]byte{0x01})
+	if err == nil {
+		t.Fatalf("Expected error for short payload.")
+	}
+
+	_, err = ParseDnl(EncodeDnl(0))
+	if err == nil {
+		t.Fatalf("Expected error for zero lines.")
+	}
+}
+
+func TestSegmentList_Dimensions_Dnl(t *testing.T) {
+	sof := SofSegment{
+		BitsPerSample: 8,
+		Width: 640,
+		Height: 0,
+	}
+
+	components := []SofComponent{
+		{ComponentId: 1, HorizontalSamplingFactor: 1, VerticalSamplingFactor: 1},
+	}
+
+	// Include a stuffed zero and a restart marker, which mustn't end the scan.
+	scanData := []byte{0x12, 0xff, 0x00, 0x34, 0xff, 0xd0, 0x56}
+
+	built, err := NewBuilder().
+		AddQuantTables(QuantizationTable{Values: standardLuminanceQuantTable}).
+		AddFrame(MARKER_SOF0, sof, components).
+		AddScanData(scanData).
+		AddSegment(MARKER_DNL, EncodeDnl(480)).
+		Build()
+
+	log.PanicIf(err)
+
+	b := new(bytes.Buffer)
+
+	err = built.Write(b)
+	log.PanicIf(err)
+
+	sl, err := ParseBytesStructure(b.Bytes())
+	log.PanicIf(err)
+
+	if len(sl) != len(built) {
+		t.Fatalf("Segment count not correct: (%d) != (%d)", len(sl), len(built))
+	}
+
+	for i, s := range sl {
+		if s.MarkerId != built[i].MarkerId || bytes.Equal(s.Data, built[i].Data) == false {
+			t.Fatalf("Segment (%d) not correct: (0x%02x)", i, s.MarkerId)
+		}
+	}
+
+	width, height, err := sl.Dimensions()
+	log.PanicIf(err)
+
+	if width != 640 || height != 480 {
+		t.Fatalf("Dimensions not correct: (%d)x(%d)", width, height)
+	}
+}
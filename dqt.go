@@ -0,0 +1,189 @@
+package jpegstructure
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/dsoprea/go-logging"
+)
+
+var (
+	// The example tables from Annex K of the standard (as used by libjpeg for
+	// quality scaling), in natural order.
+	standardLuminanceQuantTable = [64]uint16{
+		16, 11, 10, 16, 24, 40, 51, 61,
+		12, 12, 14, 19, 26, 58, 60, 55,
+		14, 13, 16, 24, 40, 57, 69, 56,
+		14, 17, 22, 29, 51, 87, 80, 62,
+		18, 22, 37, 56, 68, 109, 103, 77,
+		24, 35, 55, 64, 81, 104, 113, 92,
+		49, 64, 78, 87, 103, 121, 120, 101,
+		72, 92, 95, 98, 112, 100, 103, 99,
+	}
+
+	standardChrominanceQuantTable = [64]uint16{
+		17, 18, 24, 47, 99, 99, 99, 99,
+		18, 21, 26, 66, 99, 99, 99, 99,
+		24, 26, 56, 99, 99, 99, 99, 99,
+		47, 66, 99, 99, 99, 99, 99, 99,
+		99, 99, 99, 99, 99, 99, 99, 99,
+		99, 99, 99, 99, 99, 99, 99, 99,
+		99, 99, 99, 99, 99, 99, 99, 99,
+		99, 99, 99, 99, 99, 99, 99, 99,
+	}
+)
+
+// QuantizationTable is one table from a DQT segment. Values are stored in the
+// zigzag order that they appear in the stream.
+type QuantizationTable struct {
+	// Precision is 0 for 8-bit values and 1 for 16-bit values.
+	Precision byte
+
+	TableId byte
+	Values [64]uint16
+}
+
+func (qt QuantizationTable) String() string {
+	return fmt.Sprintf("QuantizationTable<ID=(%d) PRECISION=(%d) QUALITY=(%d)>", qt.TableId, qt.Precision, qt.EstimateQuality())
+}
+
+// EstimateQuality approximates the libjpeg quality setting (1-100) that would
+// produce this table. Table 0 is compared against the standard luminance table
+// and all others against the standard chrominance table.
+func (qt QuantizationTable) EstimateQuality() int {
+	reference := &standardChrominanceQuantTable
+	if qt.TableId == 0 {
+		reference = &standardLuminanceQuantTable
+	}
+
+	// Both sums are independent of coefficient order so we don't have to
+	// de-zigzag.
+	actualSum := 0
+	referenceSum := 0
+	for i := 0; i < 64; i++ {
+		actualSum += int(qt.Values[i])
+		referenceSum += int(reference[i])
+	}
+
+	// libjpeg scales the reference table by a percentage and clamps to one.
+	scale := float64(actualSum) * 100.0 / float64(referenceSum)
+
+	quality := 0.0
+	if scale <= 100.0 {
+		quality = (200.0 - scale) / 2.0
+	} else {
+		quality = 5000.0 / scale
+	}
+
+	if quality < 1 {
+		return 1
+	} else if quality > 100 {
+		return 100
+	}
+
+	return int(quality + 0.5)
+}
+
+// StandardQuantizationTable returns the table that libjpeg produces for the
+// given quality (1-100): the standard luminance table for table 0 and the
+// standard chrominance table otherwise, scaled and limited to eight bits.
+func StandardQuantizationTable(tableId byte, quality int) QuantizationTable {
+	if quality < 1 {
+		quality = 1
+	} else if quality > 100 {
+		quality = 100
+	}
+
+	scale := 200 - quality * 2
+	if quality < 50 {
+		scale = 5000 / quality
+	}
+
+	reference := &standardChrominanceQuantTable
+	if tableId == 0 {
+		reference = &standardLuminanceQuantTable
+	}
+
+	qt := QuantizationTable{
+		TableId: tableId,
+	}
+
+	for k, i := range zigzagOrder {
+		value := (int(reference[i]) * scale + 50) / 100
+		if value < 1 {
+			value = 1
+		} else if value > 255 {
+			value = 255
+		}
+
+		qt.Values[k] = uint16(value)
+	}
+
+	return qt
+}
+
+// ParseQuantizationTables parses every table in a DQT payload.
+func ParseQuantizationTables(data []byte) (tables []QuantizationTable, err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	tables = make([]QuantizationTable, 0)
+
+	for i := 0; i < len(data); {
+		qt := QuantizationTable{
+			Precision: data[i] >> 4,
+			TableId: data[i] & 0x0f,
+		}
+
+		i++
+
+		if qt.Precision == 0 {
+			if i + 64 > len(data) {
+				log.Panicf("DQT payload truncated (8-bit table)")
+			}
+
+			for j := 0; j < 64; j++ {
+				qt.Values[j] = uint16(data[i + j])
+			}
+
+			i += 64
+		} else {
+			if i + 128 > len(data) {
+				log.Panicf("DQT payload truncated (16-bit table)")
+			}
+
+			for j := 0; j < 64; j++ {
+				qt.Values[j] = uint16(data[i + j * 2]) << 8 | uint16(data[i + j * 2 + 1])
+			}
+
+			i += 128
+		}
+
+		tables = append(tables, qt)
+	}
+
+	return tables, nil
+}
+
+// EncodeQuantizationTables produces a DQT payload.
+func EncodeQuantizationTables(tables []QuantizationTable) []byte {
+	b := new(bytes.Buffer)
+
+	for _, qt := range tables {
+		b.WriteByte(qt.Precision << 4 | qt.TableId)
+
+		for _, value := range qt.Values {
+			if qt.Precision == 0 {
+				b.WriteByte(byte(value))
+			} else {
+				b.WriteByte(byte(value >> 8))
+				b.WriteByte(byte(value))
+			}
+		}
+	}
+
+	return b.Bytes()
+}
@@ -0,0 +1,304 @@
+package jpegstructure
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/dsoprea/go-logging"
+)
+
+// DumpOptions controls the detail of DumpTextWithOptions.
+type DumpOptions struct {
+	// Verbose breaks down the known segment types beneath their segment.
+	Verbose bool
+
+	// HexDump includes a hex listing of the payload of every segment other
+	// than the scan-data.
+	HexDump bool
+
+	// HexDumpMaxBytes limits each hex listing. Zero or less lists everything.
+	HexDumpMaxBytes int
+
+	// TagFilter selects the EXIF tags and XMP properties that are listed in
+	// verbose dumps. EXIF tags are in the "EXIF" group and the group of their
+	// IFD (e.g. "GPS"); XMP properties are in the "XMP" group and the group of
+	// their namespace (e.g. "XMP-dc"), as with ExiftoolOptions. If the filter
+	// is empty, every EXIF tag and only the size of the XMP packet are listed.
+	TagFilter TagFilter
+}
+
+// textDumper renders a tree-style description of a segment list.
+type textDumper struct {
+	w io.Writer
+	options DumpOptions
+}
+
+func (td *textDumper) printf(depth int, format string, args ...interface{}) {
+	prefix := ""
+	if depth > 0 {
+		prefix = strings.Repeat("|  ", depth - 1) + "|- "
+	}
+
+	_, err := fmt.Fprintf(td.w, prefix + format + "\n", args...)
+	log.PanicIf(err)
+}
+
+func (td *textDumper) dumpSegment(i int, s Segment) {
+	name := s.MarkerName
+	if name == "" {
+		name = "?"
+	}
+
+	td.printf(0, "% 3d: %-9s ID=(0x%02x) OFFSET=(0x%08x %d) SIZE=(%d)", i, name, s.MarkerId, s.Offset, s.Offset, len(s.Data))
+
+	if s.Source != "" {
+		td.printf(1, "SOURCE: [%s] OFFSET=(0x%08x %d)", s.Source, s.SourceOffset, s.SourceOffset)
+	}
+
+	if td.options.HexDump == true && s.MarkerId != 0x0 && len(s.Data) > 0 {
+		td.dumpHex(s)
+	}
+
+	if td.options.Verbose == false {
+		return
+	}
+
+	if IsAppMarker(s.MarkerId) == true {
+		if as, found := Identify(s); found == true {
+			td.printf(1, "SIGNATURE: NAME=[%s] DESCRIPTION=[%s]", as.Name, as.Description)
+		} else {
+			td.printf(1, "SIGNATURE: (unknown) PREFIX=[%s]", AppSignaturePreview(s))
+		}
+	}
+
+	if s.MarkerId == MARKER_APP0 && isJfifPayload(s.Data) == true {
+		td.dumpJfif(s)
+	} else if s.MarkerId == MARKER_APP0 && isJfxxPayload(s.Data) == true {
+		td.dumpJfxx(s)
+	} else if s.Kind() == SegmentKindExifApp1 {
+		td.dumpExif(s)
+	} else if s.Kind() == SegmentKindXmpApp1 {
+		td.dumpXmp(s)
+	} else if s.MarkerId == MARKER_APP3 && isJpsPayload(s.Data) == true {
+		td.dumpJps(s)
+	} else if s.MarkerId == MARKER_DQT {
+		td.dumpDqt(s)
+	} else if s.MarkerId == MARKER_DHT {
+		td.dumpDht(s)
+	} else if s.MarkerId == MARKER_DAC {
+		td.dumpDac(s)
+	} else if IsSofMarker(s.MarkerId) == true || s.MarkerId == MARKER_DHP {
+		td.dumpSof(s)
+	} else if s.MarkerId == MARKER_EXP {
+		td.dumpExp(s)
+	}
+}
+
+func (td *textDumper) dumpHex(s Segment) {
+	b := new(bytes.Buffer)
+
+	err := s.HexDump(b, td.options.HexDumpMaxBytes)
+	log.PanicIf(err)
+
+	for _, line := range strings.Split(strings.TrimRight(b.String(), "\n"), "\n") {
+		td.printf(1, "%s", line)
+	}
+}
+
+func (td *textDumper) dumpJfif(s Segment) {
+	jfif, err := ParseJfifSegment(s.Data)
+	if err != nil {
+		td.printf(1, "JFIF: (error: %s)", err.Error())
+		return
+	}
+
+	td.printf(1, "JFIF: VERSION=(%d.%02d)", jfif.MajorVersion, jfif.MinorVersion)
+	td.printf(1, "JFIF: DENSITY=(%d x %d) UNITS=[%s]", jfif.XDensity, jfif.YDensity, jfifUnitNames[jfif.DensityUnits])
+	td.printf(1, "JFIF: THUMBNAIL=(%d x %d)", jfif.ThumbnailWidth, jfif.ThumbnailHeight)
+}
+
+func (td *textDumper) dumpJps(s Segment) {
+	si, err := ParseStereoInfo(s.Data)
+	if err != nil {
+		td.printf(1, "JPS: (error: %s)", err.Error())
+		return
+	}
+
+	td.printf(1, "JPS: MEDIA=(%d) LAYOUT=[%s] FLAGS=(0x%02x) SEPARATION=(%d)", si.MediaType, jpsLayoutNames[si.Layout], si.Flags, si.Separation)
+
+	if si.Comment != "" {
+		td.printf(1, "JPS: COMMENT=[%s]", si.Comment)
+	}
+}
+
+func (td *textDumper) dumpJfxx(s Segment) {
+	js, err := ParseJfxxSegment(s.Data)
+	if err != nil {
+		td.printf(1, "JFXX: (error: %s)", err.Error())
+		return
+	}
+
+	td.printf(1, "JFXX: ENCODING=[%s] THUMBNAIL=(%d x %d) SIZE=(%d)", jfxxExtensionNames[js.ExtensionCode], js.Width, js.Height, len(js.Data))
+}
+
+func (td *textDumper) dumpExif(s Segment) {
+	exifTags, err := GetExifData(s.Data[len(exifPrefix):])
+	if err != nil {
+		td.printf(1, "EXIF: (error: %s)", err.Error())
+		return
+	}
+
+	for _, et := range exifTags {
+		if td.options.TagFilter.Matches(et.TagName, "EXIF", et.IfdName) == false {
+			continue
+		}
+
+		td.printf(1, "EXIF: IFD=[%s] ID=(0x%04x) NAME=[%s] TYPE=[%s] VALUE=[%v]", et.IfdName, et.TagId, et.TagName, et.TagTypeName, et.Value)
+	}
+}
+
+func (td *textDumper) dumpXmp(s Segment) {
+	td.printf(1, "XMP: PACKET-SIZE=(%d)", len(s.Data) - len(xmpPrefix))
+
+	if td.options.TagFilter.IsEmpty() == true {
+		return
+	}
+
+	properties, err := ParseXmpProperties(s.Data[len(xmpPrefix):])
+	if err != nil {
+		td.printf(1, "XMP: (error: %s)", err.Error())
+		return
+	}
+
+	for _, xp := range properties {
+		if td.options.TagFilter.Matches(xp.Name, "XMP", xmpTagGroup(xp.Prefix)) == false {
+			continue
+		}
+
+		td.printf(1, "XMP: PREFIX=[%s] NAME=[%s] VALUE=[%s]", xp.Prefix, xp.Name, xp.Value)
+	}
+}
+
+func (td *textDumper) dumpDqt(s Segment) {
+	tables, err := ParseQuantizationTables(s.Data)
+	if err != nil {
+		td.printf(1, "DQT: (error: %s)", err.Error())
+		return
+	}
+
+	for _, qt := range tables {
+		td.printf(1, "DQT: TABLE=(%d) PRECISION=(%d) ESTIMATED-QUALITY=(%d)", qt.TableId, qt.Precision, qt.EstimateQuality())
+	}
+}
+
+func (td *textDumper) dumpDht(s Segment) {
+	tables, err := ParseHuffmanTables(s.Data)
+	if err != nil {
+		td.printf(1, "DHT: (error: %s)", err.Error())
+		return
+	}
+
+	for _, ht := range tables {
+		class := "DC"
+		if ht.Class == HUFFMAN_CLASS_AC {
+			class = "AC"
+		}
+
+		td.printf(1, "DHT: CLASS=[%s] TABLE=(%d) SYMBOLS=(%d)", class, ht.TableId, len(ht.Symbols))
+	}
+}
+
+func (td *textDumper) dumpDac(s Segment) {
+	tables, err := ParseArithmeticConditioning(s.Data)
+	if err != nil {
+		td.printf(1, "DAC: (error: %s)", err.Error())
+		return
+	}
+
+	for _, ac := range tables {
+		if ac.Class == ARITHMETIC_CLASS_DC {
+			td.printf(1, "DAC: CLASS=[DC] TABLE=(%d) L=(%d) U=(%d)", ac.TableId, ac.Lower(), ac.Upper())
+		} else {
+			td.printf(1, "DAC: CLASS=[AC] TABLE=(%d) KX=(%d)", ac.TableId, ac.Value)
+		}
+	}
+}
+
+func (td *textDumper) dumpSof(s Segment) {
+	js := new(JpegSplitter)
+
+	sof, err := js.parseSof(s.Data)
+	if err != nil {
+		td.printf(1, "SOF: (error: %s)", err.Error())
+		return
+	}
+
+	td.printf(1, "SOF: BITS-PER-SAMPLE=(%d) WIDTH=(%d) HEIGHT=(%d) COMPONENTS=(%d)", sof.BitsPerSample, sof.Width, sof.Height, sof.ComponentCount)
+
+	components, err := ParseSofComponents(s.Data)
+	if err != nil {
+		td.printf(2, "(error: %s)", err.Error())
+		return
+	}
+
+	for _, sc := range components {
+		td.printf(2, "COMPONENT: ID=(%d) SAMPLING=(%dx%d) QUANTIZATION-TABLE=(%d)", sc.ComponentId, sc.HorizontalSamplingFactor, sc.VerticalSamplingFactor, sc.QuantizationTableId)
+	}
+}
+
+func (td *textDumper) dumpExp(s Segment) {
+	horizontal, vertical, err := ParseExp(s.Data)
+	if err != nil {
+		td.printf(1, "EXP: (error: %s)", err.Error())
+		return
+	}
+
+	td.printf(1, "EXP: HORIZONTAL=(%v) VERTICAL=(%v)", horizontal, vertical)
+}
+
+// DumpText writes a description of every segment to the writer. If `verbose`
+// is true, the known segment types (JFIF, EXIF, XMP, DQT, SOFn) are broken down
+// beneath their segment.
+func (sl SegmentList) DumpText(w io.Writer, verbose bool) (err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	options := DumpOptions{
+		Verbose: verbose,
+	}
+
+	err = sl.DumpTextWithOptions(w, options)
+	log.PanicIf(err)
+
+	return nil
+}
+
+// DumpTextWithOptions is DumpText with control over hex listings.
+func (sl SegmentList) DumpTextWithOptions(w io.Writer, options DumpOptions) (err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	td := &textDumper{
+		w: w,
+		options: options,
+	}
+
+	if len(sl) == 0 {
+		td.printf(0, "No segments.")
+		return nil
+	}
+
+	for i, s := range sl {
+		td.dumpSegment(i, s)
+	}
+
+	return nil
+}
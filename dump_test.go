@@ -0,0 +1,144 @@
+package jpegstructure
+
+import (
+	"bytes"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/dsoprea/go-logging"
+)
+
+func TestSegmentList_DumpText(t *testing.T) {
+	filepath := path.Join(assetsPath, testImageRelFilepath)
+
+	sl, err := ParseFileStructure(filepath)
+	log.PanicIf(err)
+
+	b := new(bytes.Buffer)
+
+	err = sl.DumpText(b, false)
+	log.PanicIf(err)
+
+	lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+	if len(lines) != len(sl) {
+		t.Fatalf("Number of lines not correct: (%d) != (%d)", len(lines), len(sl))
+	}
+
+	expected := "  4: SOF0      ID=(0xc0) OFFSET=(0x00008b3c 35644) SIZE=(15)"
+	if lines[4] != expected {
+		t.Fatalf("SOF line not correct: [%s]", lines[4])
+	}
+}
+
+func TestSegmentList_DumpText_Verbose(t *testing.T) {
+	filepath := path.Join(assetsPath, testImageRelFilepath)
+
+	sl, err := ParseFileStructure(filepath)
+	log.PanicIf(err)
+
+	b := new(bytes.Buffer)
+
+	err = sl.DumpText(b, true)
+	log.PanicIf(err)
+
+	output := b.String()
+
+	expectedLines := []string {
+		"|- DQT: TABLE=(0) PRECISION=(0) ESTIMATED-QUALITY=(97)",
+		"|- SOF: BITS-PER-SAMPLE=(8) WIDTH=(3840) HEIGHT=(2560) COMPONENTS=(3)",
+		"|  |- COMPONENT: ID=(1) SAMPLING=(2x1) QUANTIZATION-TABLE=(0)",
+		"|  |- COMPONENT: ID=(3) SAMPLING=(1x1) QUANTIZATION-TABLE=(1)",
+	}
+
+	for _, line := range expectedLines {
+		if strings.Contains(output, line + "\n") == false {
+			t.Fatalf("Expected line not found: [%s]\n%s", line, output)
+		}
+	}
+}
+
+func TestSegmentList_DumpText_Jfif(t *testing.T) {
+	filepath := path.Join(assetsPath, "20180428_212314.jpg")
+
+	sl, err := ParseFileStructure(filepath)
+	log.PanicIf(err)
+
+	b := new(bytes.Buffer)
+
+	err = sl.DumpText(b, true)
+	log.PanicIf(err)
+
+	expected := "|- JFIF: DENSITY=(1 x 1) UNITS=[aspect-ratio]\n"
+	if strings.Contains(b.String(), expected) == false {
+		t.Fatalf("JFIF density not found:\n%s", b.String())
+	}
+}
+
+func TestSegmentList_DumpTextWithOptions_HexDump(t *testing.T) {
+	filepath := path.Join(assetsPath, testImageRelFilepath)
+
+	sl, err := ParseFileStructure(filepath)
+	log.PanicIf(err)
+
+	b := new(bytes.Buffer)
+
+	options := DumpOptions{
+		HexDump: true,
+		HexDumpMaxBytes: 16,
+	}
+
+	err = sl.DumpTextWithOptions(b, options)
+	log.PanicIf(err)
+
+	expected := "  1: APP1      ID=(0xe1) OFFSET=(0x00000002 2) SIZE=(32942)\n" +
+		"|- 00000000  45 78 69 66 00 00 49 49  2a 00 08 00 00 00 0c 00  |Exif..II*.......|\n" +
+		"|- ... (32926 more bytes)\n"
+
+	if strings.Contains(b.String(), expected) == false {
+		t.Fatalf("Hex dump not found:\n%s", b.String())
+	}
+}
+
+func TestSegmentList_DumpTextWithOptions_TagFilter(t *testing.T) {
+	filepath := path.Join(assetsPath, testImageRelFilepath)
+
+	sl, err := ParseFileStructure(filepath)
+	log.PanicIf(err)
+
+	b := new(bytes.Buffer)
+
+	options := DumpOptions{
+		Verbose: true,
+		TagFilter: TagFilter{
+			Include: []string{"XMP:Rating"},
+		},
+	}
+
+	err = sl.DumpTextWithOptions(b, options)
+	log.PanicIf(err)
+
+	output := b.String()
+
+	if strings.Contains(output, "XMP: PREFIX=[xmp] NAME=[Rating] VALUE=[0]") == false {
+		t.Fatalf("XMP property not listed:\n%s", output)
+	} else if strings.Count(output, "XMP: PREFIX=") != 1 {
+		t.Fatalf("XMP properties not filtered:\n%s", output)
+	}
+}
+
+func TestSegmentList_DumpText_Dht(t *testing.T) {
+	filepath := path.Join(assetsPath, testImageRelFilepath)
+
+	sl, err := ParseFileStructure(filepath)
+	log.PanicIf(err)
+
+	b := new(bytes.Buffer)
+
+	err = sl.DumpText(b, true)
+	log.PanicIf(err)
+
+	if strings.Count(b.String(), "|- DHT: CLASS=") != 4 || strings.Contains(b.String(), "|- DHT: CLASS=[AC] TABLE=(1) SYMBOLS=(162)\n") == false {
+		t.Fatalf("DHT tables not found:\n%s", b.String())
+	}
+}
@@ -0,0 +1,127 @@
+package jpegstructure
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/dsoprea/go-logging"
+)
+
+const (
+	// defaultEmbeddedDepth limits the recursion of EmbeddedImages() when no
+	// depth is given.
+	defaultEmbeddedDepth = 8
+)
+
+var (
+	// embeddedSignature is the SOI marker followed by the start of the next
+	// marker.
+	embeddedSignature = []byte{0xff, MARKER_SOI, 0xff}
+)
+
+// EmbeddedImage is a JPEG found inside the payload of a segment (e.g. a
+// thumbnail or a preview).
+type EmbeddedImage struct {
+	// SegmentIndex is the segment of the parent image whose payload contains
+	// the image.
+	SegmentIndex int
+
+	// Offset is the position of the image within the payload.
+	Offset int
+
+	// Size is the length of the image, up to and including its EOI.
+	Size int
+
+	Segments SegmentList
+
+	// Children are the images embedded within this one.
+	Children []EmbeddedImage
+}
+
+func (ei EmbeddedImage) String() string {
+	return fmt.Sprintf("EmbeddedImage<SEGMENT=(%d) OFFSET=(%d) SIZE=(%d) SEGMENTS=(%d) CHILDREN=(%d)>", ei.SegmentIndex, ei.Offset, ei.Size, len(ei.Segments), len(ei.Children))
+}
+
+// parseEmbedded parses the image at the front of the data, stopping at its
+// EOI, and returns its length. Whatever follows the image is ignored.
+func parseEmbedded(data []byte) (sl SegmentList, size int, err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	js := NewJpegSplitter(nil)
+
+	for js.MarkerId() != MARKER_EOI {
+		advance, _, err := js.Split(data[size:], true)
+		log.PanicIf(err)
+
+		if advance == 0 {
+			log.Panicf("embedded image truncated")
+		}
+
+		size += advance
+	}
+
+	return js.Segments(), size, nil
+}
+
+// EmbeddedImages looks for JPEGs inside the payloads of the segments (other
+// than the scan-data), parses them, and recursively looks inside those, up to
+// `maxDepth` levels (zero or less uses a default). Candidates that don't
+// parse as complete images are skipped.
+func (sl SegmentList) EmbeddedImages(maxDepth int) (images []EmbeddedImage, err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	if maxDepth <= 0 {
+		maxDepth = defaultEmbeddedDepth
+	}
+
+	images = make([]EmbeddedImage, 0)
+
+	for i, s := range sl {
+		if s.MarkerId == 0x0 {
+			continue
+		}
+
+		offset := 0
+		for {
+			found := bytes.Index(s.Data[offset:], embeddedSignature)
+			if found == -1 {
+				break
+			}
+
+			offset += found
+
+			embedded, size, err := parseEmbedded(s.Data[offset:])
+			if err != nil {
+				jpegLogger.Debugf(nil, "Candidate image in segment (%d) at (%d) did not parse: %s", i, offset, err.Error())
+
+				offset++
+				continue
+			}
+
+			ei := EmbeddedImage{
+				SegmentIndex: i,
+				Offset: offset,
+				Size: size,
+				Segments: embedded,
+			}
+
+			if maxDepth > 1 {
+				ei.Children, err = embedded.EmbeddedImages(maxDepth - 1)
+				log.PanicIf(err)
+			}
+
+			images = append(images, ei)
+			offset += size
+		}
+	}
+
+	return images, nil
+}
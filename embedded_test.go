@@ -0,0 +1,88 @@
+package jpegstructure
+
+import (
+	"bytes"
+	"path"
+	"testing"
+
+	"github.com/dsoprea/go-logging"
+)
+
+// wrapInComment returns the image with a COM segment (after the SOI) that
+// carries the payload.
+func wrapInComment(data []byte, payload []byte) []byte {
+	sl, err := ParseBytesStructure(data)
+	log.PanicIf(err)
+
+	sl = append(sl[:1], append(SegmentList{{MarkerId: MARKER_COM, Data: payload}}, sl[1:]...)...)
+
+	b := new(bytes.Buffer)
+
+	err = sl.Write(b)
+	log.PanicIf(err)
+
+	return b.Bytes()
+}
+
+func TestSegmentList_EmbeddedImages_Thumbnail(t *testing.T) {
+	filepath := path.Join(assetsPath, testImageRelFilepath)
+
+	sl, err := ParseFileStructure(filepath)
+	log.PanicIf(err)
+
+	images, err := sl.EmbeddedImages(0)
+	log.PanicIf(err)
+
+	if len(images) == 0 {
+		t.Fatalf("EXIF thumbnail not found.")
+	}
+
+	ei := images[0]
+	if sl[ei.SegmentIndex].MarkerId != MARKER_APP1 {
+		t.Fatalf("Thumbnail not found in APP1: %s", ei)
+	} else if ei.Segments[0].MarkerId != MARKER_SOI || ei.Segments[len(ei.Segments) - 1].MarkerId != MARKER_EOI {
+		t.Fatalf("Thumbnail not parsed completely: %s", ei)
+	}
+
+	payload := sl[ei.SegmentIndex].Data
+	if payload[ei.Offset + ei.Size - 2] != 0xff || payload[ei.Offset + ei.Size - 1] != MARKER_EOI {
+		t.Fatalf("Thumbnail extent not correct: %s", ei)
+	}
+}
+
+func TestSegmentList_EmbeddedImages_Nested(t *testing.T) {
+	innermost := getTransformTestImage()
+
+	// The inner image is followed by other bytes in its payload.
+	inner := wrapInComment(getTransformTestImage(), append(innermost, []byte("trailing")...))
+	outer := wrapInComment(getTransformTestImage(), append([]byte("leading"), inner...))
+
+	sl, err := ParseBytesStructure(outer)
+	log.PanicIf(err)
+
+	images, err := sl.EmbeddedImages(0)
+	log.PanicIf(err)
+
+	if len(images) != 1 {
+		t.Fatalf("Embedded image count not correct: (%d)", len(images))
+	}
+
+	ei := images[0]
+	if ei.SegmentIndex != 1 || ei.Offset != len("leading") || ei.Size != len(inner) {
+		t.Fatalf("Embedded image not correct: %s", ei)
+	} else if len(ei.Children) != 1 {
+		t.Fatalf("Nested image not found: %s", ei)
+	}
+
+	child := ei.Children[0]
+	if child.Offset != 0 || child.Size != len(innermost) || len(child.Children) != 0 {
+		t.Fatalf("Nested image not correct: %s", child)
+	}
+
+	images, err = sl.EmbeddedImages(1)
+	log.PanicIf(err)
+
+	if len(images) != 1 || len(images[0].Children) != 0 {
+		t.Fatalf("Depth not limited.")
+	}
+}
@@ -0,0 +1,378 @@
+package jpegstructure
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"sync"
+
+	"github.com/dsoprea/go-logging"
+)
+
+const (
+	ENCODER_FAMILY_LIBJPEG = "libjpeg"
+	ENCODER_FAMILY_ADOBE = "Adobe"
+	ENCODER_FAMILY_CANON = "Canon"
+	ENCODER_FAMILY_UNKNOWN = "unknown"
+)
+
+var (
+	// duckyPrefix identifies the APP12 segment that Photoshop's "Save for
+	// Web" writes.
+	duckyPrefix = []byte("Ducky")
+
+	// standardHuffmanTables are the example tables from Annex K (K.3 of ITU-T
+	// T.81), which many encoders use unmodified. Go's image/jpeg writes the
+	// same tables.
+	standardHuffmanTables = []HuffmanTable{
+		{
+			Class: HUFFMAN_CLASS_DC,
+			TableId: 0,
+			Counts: [16]byte{0, 1, 5, 1, 1, 1, 1, 1, 1, 0, 0, 0, 0, 0, 0, 0},
+			Symbols: []byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11},
+		},
+		{
+			Class: HUFFMAN_CLASS_DC,
+			TableId: 1,
+			Counts: [16]byte{0, 3, 1, 1, 1, 1, 1, 1, 1, 1, 1, 0, 0, 0, 0, 0},
+			Symbols: []byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11},
+		},
+		{
+			Class: HUFFMAN_CLASS_AC,
+			TableId: 0,
+			Counts: [16]byte{0, 2, 1, 3, 3, 2, 4, 3, 5, 5, 4, 4, 0, 0, 1, 125},
+			Symbols: []byte{
+				0x01, 0x02, 0x03, 0x00, 0x04, 0x11, 0x05, 0x12,
+				0x21, 0x31, 0x41, 0x06, 0x13, 0x51, 0x61, 0x07,
+				0x22, 0x71, 0x14, 0x32, 0x81, 0x91, 0xa1, 0x08,
+				0x23, 0x42, 0xb1, 0xc1, 0x15, 0x52, 0xd1, 0xf0,
+				0x24, 0x33, 0x62, 0x72, 0x82, 0x09, 0x0a, 0x16,
+				0x17, 0x18, 0x19, 0x1a, 0x25, 0x26, 0x27, 0x28,
+				0x29, 0x2a, 0x34, 0x35, 0x36, 0x37, 0x38, 0x39,
+				0x3a, 0x43, 0x44, 0x45, 0x46, 0x47, 0x48, 0x49,
+				0x4a, 0x53, 0x54, 0x55, 0x56, 0x57, 0x58, 0x59,
+				0x5a, 0x63, 0x64, 0x65, 0x66, 0x67, 0x68, 0x69,
+				0x6a, 0x73, 0x74, 0x75, 0x76, 0x77, 0x78, 0x79,
+				0x7a, 0x83, 0x84, 0x85, 0x86, 0x87, 0x88, 0x89,
+				0x8a, 0x92, 0x93, 0x94, 0x95, 0x96, 0x97, 0x98,
+				0x99, 0x9a, 0xa2, 0xa3, 0xa4, 0xa5, 0xa6, 0xa7,
+				0xa8, 0xa9, 0xaa, 0xb2, 0xb3, 0xb4, 0xb5, 0xb6,
+				0xb7, 0xb8, 0xb9, 0xba, 0xc2, 0xc3, 0xc4, 0xc5,
+				0xc6, 0xc7, 0xc8, 0xc9, 0xca, 0xd2, 0xd3, 0xd4,
+				0xd5, 0xd6, 0xd7, 0xd8, 0xd9, 0xda, 0xe1, 0xe2,
+				0xe3, 0xe4, 0xe5, 0xe6, 0xe7, 0xe8, 0xe9, 0xea,
+				0xf1, 0xf2, 0xf3, 0xf4, 0xf5, 0xf6, 0xf7, 0xf8,
+				0xf9, 0xfa,
+			},
+		},
+		{
+			Class: HUFFMAN_CLASS_AC,
+			TableId: 1,
+			Counts: [16]byte{0, 2, 1, 2, 4, 4, 3, 4, 7, 5, 4, 4, 0, 1, 2, 119},
+			Symbols: []byte{
+				0x00, 0x01, 0x02, 0x03, 0x11, 0x04, 0x05, 0x21,
+				0x31, 0x06, 0x12, 0x41, 0x51, 0x07, 0x61, 0x71,
+				0x13, 0x22, 0x32, 0x81, 0x08, 0x14, 0x42, 0x91,
+				0xa1, 0xb1, 0xc1, 0x09, 0x23, 0x33, 0x52, 0xf0,
+				0x15, 0x62, 0x72, 0xd1, 0x0a, 0x16, 0x24, 0x34,
+				0xe1, 0x25, 0xf1, 0x17, 0x18, 0x19, 0x1a, 0x26,
+				0x27, 0x28, 0x29, 0x2a, 0x35, 0x36, 0x37, 0x38,
+				0x39, 0x3a, 0x43, 0x44, 0x45, 0x46, 0x47, 0x48,
+				0x49, 0x4a, 0x53, 0x54, 0x55, 0x56, 0x57, 0x58,
+				0x59, 0x5a, 0x63, 0x64, 0x65, 0x66, 0x67, 0x68,
+				0x69, 0x6a, 0x73, 0x74, 0x75, 0x76, 0x77, 0x78,
+				0x79, 0x7a, 0x82, 0x83, 0x84, 0x85, 0x86, 0x87,
+				0x88, 0x89, 0x8a, 0x92, 0x93, 0x94, 0x95, 0x96,
+				0x97, 0x98, 0x99, 0x9a, 0xa2, 0xa3, 0xa4, 0xa5,
+				0xa6, 0xa7, 0xa8, 0xa9, 0xaa, 0xb2, 0xb3, 0xb4,
+				0xb5, 0xb6, 0xb7, 0xb8, 0xb9, 0xba, 0xc2, 0xc3,
+				0xc4, 0xc5, 0xc6, 0xc7, 0xc8, 0xc9, 0xca, 0xd2,
+				0xd3, 0xd4, 0xd5, 0xd6, 0xd7, 0xd8, 0xd9, 0xda,
+				0xe2, 0xe3, 0xe4, 0xe5, 0xe6, 0xe7, 0xe8, 0xe9,
+				0xea, 0xf2, 0xf3, 0xf4, 0xf5, 0xf6, 0xf7, 0xf8,
+				0xf9, 0xfa,
+			},
+		},
+	}
+)
+
+// EncoderFingerprint is a pair of quantization tables (in zigzag order) known
+// to be written by a particular encoder or device.
+type EncoderFingerprint struct {
+	Family string
+	Name string
+
+	Luminance [64]uint16
+	Chrominance [64]uint16
+
+	// StandardHuffmanTables indicates that the encoder always writes the
+	// Annex K Huffman tables. Such a fingerprint only matches an image with
+	// other tables approximately.
+	StandardHuffmanTables bool
+}
+
+var (
+	encoderFingerprintsLock sync.RWMutex
+
+	// encoderFingerprints are the built-in tables other than libjpeg's (which
+	// are generated). There is only one camera; Photoshop's tables and those
+	// of other devices aren't included, and can be added with
+	// RegisterEncoderFingerprint(). Devices that adapt their tables to each
+	// picture can't be fingerprinted this way.
+	encoderFingerprints = []EncoderFingerprint{
+		// From assets/NDM_8901.jpg (Make "Canon", Model "Canon EOS 5D Mark
+		// III").
+		{
+			Family: ENCODER_FAMILY_CANON,
+			Name: "Canon EOS 5D Mark III",
+			Luminance: [64]uint16{
+				1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 2,
+				1, 1, 1, 1, 1, 2, 2, 2, 2, 2, 3, 3, 3, 3, 3, 3,
+				3, 3, 3, 4, 4, 4, 3, 3, 4, 3, 3, 3, 4, 5, 4, 4,
+				5, 5, 5, 5, 5, 3, 4, 5, 6, 5, 5, 6, 4, 5, 5, 5,
+			},
+			Chrominance: [64]uint16{
+				1, 1, 1, 1, 1, 1, 2, 1, 1, 2, 5, 3, 3, 3, 5, 5,
+				5, 5, 5, 5, 5, 5, 5, 5, 5, 5, 5, 5, 5, 5, 5, 5,
+				5, 5, 5, 5, 5, 5, 5, 5, 5, 5, 5, 5, 5, 5, 5, 5,
+				5, 5, 5, 5, 5, 5, 5, 5, 5, 5, 5, 5, 5, 5, 5, 5,
+			},
+			StandardHuffmanTables: true,
+		},
+	}
+)
+
+// RegisterEncoderFingerprint adds to the tables that EncoderSignature()
+// recognizes.
+func RegisterEncoderFingerprint(ef EncoderFingerprint) {
+	encoderFingerprintsLock.Lock()
+	defer encoderFingerprintsLock.Unlock()
+
+	encoderFingerprints = append(encoderFingerprints, ef)
+}
+
+// EncoderSignature is the encoder (or device family) that most likely wrote
+// the image.
+type EncoderSignature struct {
+	Family string
+	Name string
+
+	// Exact indicates that the quantization tables match the fingerprint
+	// exactly. Otherwise, Family and Name are of the closest fingerprint
+	// (or identify Adobe from its segments).
+	Exact bool
+
+	// Distance is the mean absolute difference of the quantization values
+	// from the fingerprint.
+	Distance float64
+
+	// Quality is the estimated libjpeg-equivalent quality of the luminance
+	// table.
+	Quality int
+
+	// StandardHuffmanTables indicates that every Huffman table is one of the
+	// example tables from the standard. Encoders that optimize the tables
+	// for each image (e.g. "jpegtran -optimize") produce others.
+	StandardHuffmanTables bool
+}
+
+func (es EncoderSignature) String() string {
+	return fmt.Sprintf("EncoderSignature<FAMILY=[%s] NAME=[%s] EXACT=[%v] DISTANCE=(%.3f) QUALITY=(%d) STANDARD-HUFFMAN=[%v]>", es.Family, es.Name, es.Exact, es.Distance, es.Quality, es.StandardHuffmanTables)
+}
+
+// tableDistance is the mean absolute difference of the given tables from the
+// fingerprint. The chrominance table is skipped for grayscale images.
+func tableDistance(ef EncoderFingerprint, luminance, chrominance *QuantizationTable) float64 {
+	sum := 0.0
+	count := 0
+
+	for i := 0; i < 64; i++ {
+		sum += math.Abs(float64(luminance.Values[i]) - float64(ef.Luminance[i]))
+		count++
+
+		if chrominance != nil {
+			sum += math.Abs(float64(chrominance.Values[i]) - float64(ef.Chrominance[i]))
+			count++
+		}
+	}
+
+	return sum / float64(count)
+}
+
+// isStandardHuffmanTable indicates whether the table is one of the Annex K
+// tables of its class (under any table ID).
+func isStandardHuffmanTable(ht HuffmanTable) bool {
+	for _, standard := range standardHuffmanTables {
+		if ht.Class == standard.Class && ht.Counts == standard.Counts && bytes.Equal(ht.Symbols, standard.Symbols) == true {
+			return true
+		}
+	}
+
+	return false
+}
+
+// hasStandardHuffmanTables indicates whether every DHT table is one of the
+// Annex K tables (both the code lengths and the symbols).
+func (sl SegmentList) hasStandardHuffmanTables() bool {
+	found := false
+
+	for _, s := range sl.FindAll(MARKER_DHT) {
+		tables, err := ParseHuffmanTables(s.Data)
+		log.PanicIf(err)
+
+		for _, ht := range tables {
+			found = true
+
+			if isStandardHuffmanTable(ht) == false {
+				return false
+			}
+		}
+	}
+
+	return found
+}
+
+// frameQuantizationTables returns the tables used by the first two
+// components of the frame (the second is nil for grayscale images). Both are
+// nil if there's no frame header, it has no components, or the luminance
+// table isn't defined.
+func (sl SegmentList) frameQuantizationTables() (luminance, chrominance *QuantizationTable) {
+	sofIndex := -1
+	for i, s := range sl {
+		if IsSofMarker(s.MarkerId) == true {
+			sofIndex = i
+			break
+		}
+	}
+
+	if sofIndex == -1 {
+		return nil, nil
+	}
+
+	components, err := ParseSofComponents(sl[sofIndex].Data)
+	log.PanicIf(err)
+
+	tables := make(map[byte]QuantizationTable)
+
+	for _, s := range sl[:sofIndex] {
+		if s.MarkerId != MARKER_DQT {
+			continue
+		}
+
+		parsed, err := ParseQuantizationTables(s.Data)
+		log.PanicIf(err)
+
+		for _, qt := range parsed {
+			tables[qt.TableId] = qt
+		}
+	}
+
+	for i, sc := range components {
+		if i > 1 {
+			break
+		}
+
+		qt, found := tables[sc.QuantizationTableId]
+		if found == false {
+			jpegLogger.Debugf(nil, "Quantization table (%d) not defined.", sc.QuantizationTableId)
+
+			if i == 0 {
+				return nil, nil
+			}
+
+			break
+		}
+
+		if i == 0 {
+			luminance = &qt
+		} else {
+			chrominance = &qt
+		}
+	}
+
+	return luminance, chrominance
+}
+
+// EncoderSignature identifies the encoder from the DQT and DHT tables. The
+// quantization tables are compared against libjpeg's (at every quality) and
+// against the registered fingerprints (only one camera is built in), and the
+// closest is returned. A
+// fingerprint that requires the standard Huffman tables only matches exactly
+// if the DHT tables are the standard ones, and is preferred over an equally
+// close one when they are. libjpeg tables with optimized Huffman tables (e.g.
+// from "jpegtran -optimize" or "cjpeg -optimize") are reported as such. When
+// nothing matches exactly but the image has Adobe's APP14 segment, Adobe is
+// reported; Adobe isn't recognized from its tables. If the image has no
+// quantization tables for its frame, the family is unknown.
+func (sl SegmentList) EncoderSignature() (es *EncoderSignature, err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	luminance, chrominance := sl.frameQuantizationTables()
+
+	es = &EncoderSignature{
+		Family: ENCODER_FAMILY_UNKNOWN,
+		Distance: math.Inf(1),
+		StandardHuffmanTables: sl.hasStandardHuffmanTables(),
+	}
+
+	if luminance == nil {
+		return es, nil
+	}
+
+	es.Quality = luminance.EstimateQuality()
+
+	// huffmanMatches is whether the closest fingerprint so far agrees with
+	// the DHT tables.
+	huffmanMatches := false
+
+	consider := func(ef EncoderFingerprint) {
+		distance := tableDistance(ef, luminance, chrominance)
+		matches := ef.StandardHuffmanTables == false || es.StandardHuffmanTables == true
+
+		if distance < es.Distance || (distance == es.Distance && matches == true && huffmanMatches == false) {
+			es.Family = ef.Family
+			es.Name = ef.Name
+			es.Distance = distance
+			huffmanMatches = matches
+		}
+	}
+
+	for quality := 1; quality <= 100; quality++ {
+		ef := EncoderFingerprint{
+			Family: ENCODER_FAMILY_LIBJPEG,
+			Name: fmt.Sprintf("libjpeg quality %d", quality),
+			Luminance: StandardQuantizationTable(0, quality).Values,
+			Chrominance: StandardQuantizationTable(1, quality).Values,
+		}
+
+		consider(ef)
+	}
+
+	encoderFingerprintsLock.RLock()
+	defer encoderFingerprintsLock.RUnlock()
+
+	for _, ef := range encoderFingerprints {
+		consider(ef)
+	}
+
+	es.Exact = es.Distance == 0 && huffmanMatches == true
+
+	if es.Exact == true && es.Family == ENCODER_FAMILY_LIBJPEG && es.StandardHuffmanTables == false {
+		es.Name += " (optimized Huffman tables)"
+	}
+
+	if es.Exact == false && len(sl.FindWithPrefix(MARKER_APP14, adobePrefix)) > 0 {
+		es.Family = ENCODER_FAMILY_ADOBE
+		es.Name = "Adobe"
+
+		if len(sl.FindWithPrefix(MARKER_APP12, duckyPrefix)) > 0 {
+			es.Name = "Adobe Photoshop (Save for Web)"
+		}
+	}
+
+	return es, nil
+}
@@ -0,0 +1,170 @@
+package jpegstructure
+
+import (
+	"path"
+	"testing"
+
+	"github.com/dsoprea/go-logging"
+)
+
+// getCustomTablesTestImage returns the transform test-image with its
+// quantization tables replaced by ones that no libjpeg quality produces.
+func getCustomTablesTestImage() SegmentList {
+	sl, err := ParseBytesStructure(getTransformTestImage())
+	log.PanicIf(err)
+
+	for i, s := range sl {
+		if s.MarkerId != MARKER_DQT {
+			continue
+		}
+
+		tables, err := ParseQuantizationTables(s.Data)
+		log.PanicIf(err)
+
+		for j := range tables {
+			for k := range tables[j].Values {
+				tables[j].Values[k] = uint16(k % 7 + 1)
+			}
+		}
+
+		sl[i].Data = EncodeQuantizationTables(tables)
+	}
+
+	return sl
+}
+
+func TestSegmentList_EncoderSignature_Camera(t *testing.T) {
+	filepath := path.Join(assetsPath, testImageRelFilepath)
+
+	sl, err := ParseFileStructure(filepath)
+	log.PanicIf(err)
+
+	es, err := sl.EncoderSignature()
+	log.PanicIf(err)
+
+	if es.Family != ENCODER_FAMILY_CANON || es.Exact != true || es.Distance != 0 {
+		t.Fatalf("Camera not identified: %s", es)
+	} else if es.Quality != 97 {
+		t.Fatalf("Quality not correct: %s", es)
+	}
+}
+
+func TestSegmentList_EncoderSignature_Libjpeg(t *testing.T) {
+	sl, err := ParseBytesStructure(getTransformTestImage())
+	log.PanicIf(err)
+
+	es, err := sl.EncoderSignature()
+	log.PanicIf(err)
+
+	if es.Family != ENCODER_FAMILY_LIBJPEG || es.Name != "libjpeg quality 90" || es.Exact != true {
+		t.Fatalf("Encoder not identified: %s", es)
+	} else if es.Quality != 90 {
+		t.Fatalf("Quality not correct: %s", es)
+	} else if es.StandardHuffmanTables != true {
+		t.Fatalf("Huffman tables expected to be standard: %s", es)
+	}
+}
+
+func TestSegmentList_EncoderSignature_NoTables(t *testing.T) {
+	sl, err := ParseBytesStructure(getTransformTestImage())
+	log.PanicIf(err)
+
+	// Without its DQT segments.
+	minimal := make(SegmentList, 0, len(sl))
+	for _, s := range sl {
+		if s.MarkerId != MARKER_DQT {
+			minimal = append(minimal, s)
+		}
+	}
+
+	es, err := minimal.EncoderSignature()
+	log.PanicIf(err)
+
+	if es.Family != ENCODER_FAMILY_UNKNOWN || es.Exact != false {
+		t.Fatalf("Encoder not expected to be identified: %s", es)
+	}
+
+	// Without a frame header.
+	es, err = SegmentList{sl[0], sl[len(sl) - 1]}.EncoderSignature()
+	log.PanicIf(err)
+
+	if es.Family != ENCODER_FAMILY_UNKNOWN {
+		t.Fatalf("Encoder not expected to be identified: %s", es)
+	}
+}
+
+func TestSegmentList_EncoderSignature_HuffmanSymbols(t *testing.T) {
+	sl, err := ParseBytesStructure(getTransformTestImage())
+	log.PanicIf(err)
+
+	// Keep the code lengths but swap two symbols of the first table.
+	i := sl.Index(MARKER_DHT)
+
+	tables, err := ParseHuffmanTables(sl[i].Data)
+	log.PanicIf(err)
+
+	tables[0].Symbols[0], tables[0].Symbols[1] = tables[0].Symbols[1], tables[0].Symbols[0]
+	sl[i].Data = EncodeHuffmanTables(tables)
+
+	es, err := sl.EncoderSignature()
+	log.PanicIf(err)
+
+	if es.StandardHuffmanTables != false {
+		t.Fatalf("Huffman tables not expected to be standard: %s", es)
+	} else if es.Name != "libjpeg quality 90 (optimized Huffman tables)" || es.Exact != true {
+		t.Fatalf("Encoder not identified: %s", es)
+	}
+}
+
+func TestSegmentList_EncoderSignature_Adobe(t *testing.T) {
+	sl := getCustomTablesTestImage()
+
+	es, err := sl.EncoderSignature()
+	log.PanicIf(err)
+
+	if es.Exact != false || es.Family == ENCODER_FAMILY_ADOBE {
+		t.Fatalf("Custom tables not expected to be identified: %s", es)
+	}
+
+	adobe := Segment{
+		MarkerId: MARKER_APP14,
+		Data: []byte{'A', 'd', 'o', 'b', 'e', 0x00, 0x64, 0x00, 0x00, 0x00, 0x00, 0x01},
+	}
+
+	sl = append(sl[:1], append(SegmentList{adobe}, sl[1:]...)...)
+
+	es, err = sl.EncoderSignature()
+	log.PanicIf(err)
+
+	if es.Family != ENCODER_FAMILY_ADOBE || es.Name != "Adobe" || es.Exact != false {
+		t.Fatalf("Adobe not identified: %s", es)
+	}
+}
+
+func TestRegisterEncoderFingerprint(t *testing.T) {
+	original := encoderFingerprints
+	defer func() {
+		encoderFingerprints = original
+	}()
+
+	ef := EncoderFingerprint{
+		Family: "Test",
+		Name: "Test Camera",
+	}
+
+	for k := 0; k < 64; k++ {
+		ef.Luminance[k] = uint16(k % 7 + 1)
+		ef.Chrominance[k] = uint16(k % 7 + 1)
+	}
+
+	RegisterEncoderFingerprint(ef)
+
+	sl := getCustomTablesTestImage()
+
+	es, err := sl.EncoderSignature()
+	log.PanicIf(err)
+
+	if es.Family != "Test" || es.Name != "Test Camera" || es.Exact != true {
+		t.Fatalf("Registered fingerprint not identified: %s", es)
+	}
+}
@@ -0,0 +1,890 @@
+package jpegstructure
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+
+	"encoding/binary"
+
+	"github.com/dsoprea/go-logging"
+)
+
+const (
+	EXIF_TYPE_BYTE = 1
+	EXIF_TYPE_ASCII = 2
+	EXIF_TYPE_SHORT = 3
+	EXIF_TYPE_LONG = 4
+	EXIF_TYPE_RATIONAL = 5
+	EXIF_TYPE_SBYTE = 6
+	EXIF_TYPE_UNDEFINED = 7
+	EXIF_TYPE_SSHORT = 8
+	EXIF_TYPE_SLONG = 9
+	EXIF_TYPE_SRATIONAL = 10
+)
+
+const (
+	EXIF_IFD_ROOT = "IFD0"
+	EXIF_IFD_THUMBNAIL = "IFD1"
+	EXIF_IFD_EXIF = "Exif"
+	EXIF_IFD_GPS = "GPS"
+	EXIF_IFD_INTEROP = "Interop"
+)
+
+const (
+	exifTagExifIfdPointer = 0x8769
+	exifTagGpsIfdPointer = 0x8825
+	exifTagInteropIfdPointer = 0xa005
+
+	exifTagThumbnailOffset = 0x0201
+	exifTagThumbnailLength = 0x0202
+)
+
+var (
+	// ErrExifTagNotFound indicates that the requested tag isn't present.
+	ErrExifTagNotFound = errors.New("EXIF tag not found")
+)
+
+var (
+	exifTypeSizes = map[uint16]int{
+		EXIF_TYPE_BYTE: 1,
+		EXIF_TYPE_ASCII: 1,
+		EXIF_TYPE_SHORT: 2,
+		EXIF_TYPE_LONG: 4,
+		EXIF_TYPE_RATIONAL: 8,
+		EXIF_TYPE_SBYTE: 1,
+		EXIF_TYPE_UNDEFINED: 1,
+		EXIF_TYPE_SSHORT: 2,
+		EXIF_TYPE_SLONG: 4,
+		EXIF_TYPE_SRATIONAL: 8,
+	}
+
+	ExifTypeNames = map[uint16]string{
+		EXIF_TYPE_BYTE: "BYTE",
+		EXIF_TYPE_ASCII: "ASCII",
+		EXIF_TYPE_SHORT: "SHORT",
+		EXIF_TYPE_LONG: "LONG",
+		EXIF_TYPE_RATIONAL: "RATIONAL",
+		EXIF_TYPE_SBYTE: "SBYTE",
+		EXIF_TYPE_UNDEFINED: "UNDEFINED",
+		EXIF_TYPE_SSHORT: "SSHORT",
+		EXIF_TYPE_SLONG: "SLONG",
+		EXIF_TYPE_SRATIONAL: "SRATIONAL",
+	}
+
+	// exifChildIfds maps the pointer tags that we follow to the name of the
+	// IFD that they point to.
+	exifChildIfds = map[uint16]string{
+		exifTagExifIfdPointer: EXIF_IFD_EXIF,
+		exifTagGpsIfdPointer: EXIF_IFD_GPS,
+		exifTagInteropIfdPointer: EXIF_IFD_INTEROP,
+	}
+
+	// exifChildPointerTags is the reverse of exifChildIfds.
+	exifChildPointerTags = map[string]uint16{
+		EXIF_IFD_EXIF: exifTagExifIfdPointer,
+		EXIF_IFD_GPS: exifTagGpsIfdPointer,
+		EXIF_IFD_INTEROP: exifTagInteropIfdPointer,
+	}
+
+	// exifChildParents maps each child IFD to the IFD that points to it.
+	exifChildParents = map[string]string{
+		EXIF_IFD_EXIF: EXIF_IFD_ROOT,
+		EXIF_IFD_GPS: EXIF_IFD_ROOT,
+		EXIF_IFD_INTEROP: EXIF_IFD_EXIF,
+	}
+)
+
+// Rational is an unsigned EXIF RATIONAL.
+type Rational struct {
+	Numerator, Denominator uint32
+}
+
+// SignedRational is an EXIF SRATIONAL.
+type SignedRational struct {
+	Numerator, Denominator int32
+}
+
+// ExifEntry is one tag of an IFD. The raw value is kept in the byte-order of
+// the document.
+type ExifEntry struct {
+	TagId uint16
+	TagType uint16
+	Count uint32
+	RawValue []byte
+}
+
+func (ee *ExifEntry) String() string {
+	return fmt.Sprintf("ExifEntry<ID=(0x%04x) TYPE=[%s] COUNT=(%d)>", ee.TagId, ExifTypeNames[ee.TagType], ee.Count)
+}
+
+// ExifIfd is one IFD. Child IFDs are tracked separately from the entries
+// that point to them; the pointers are regenerated during encoding.
+type ExifIfd struct {
+	Name string
+	Entries []*ExifEntry
+	Children []*ExifIfd
+
+	// Thumbnail is the JPEG thumbnail referenced by IFD1.
+	Thumbnail []byte
+}
+
+// Entry returns the entry with the given tag-ID.
+func (ifd *ExifIfd) Entry(tagId uint16) (ee *ExifEntry, err error) {
+	for _, ee := range ifd.Entries {
+		if ee.TagId == tagId {
+			return ee, nil
+		}
+	}
+
+	return nil, ErrExifTagNotFound
+}
+
+// Child returns the child IFD with the given name or nil.
+func (ifd *ExifIfd) Child(name string) *ExifIfd {
+	for _, child := range ifd.Children {
+		if child.Name == name {
+			return child
+		}
+	}
+
+	return nil
+}
+
+// DeleteEntry removes the entry with the given tag-ID, if present.
+func (ifd *ExifIfd) DeleteEntry(tagId uint16) {
+	for i, ee := range ifd.Entries {
+		if ee.TagId == tagId {
+			ifd.Entries = append(ifd.Entries[:i], ifd.Entries[i + 1:]...)
+			return
+		}
+	}
+}
+
+// ExifDocument is an editable model of the TIFF-formatted EXIF data, as found
+// after the "Exif\0\0" preamble of an APP1 segment.
+//
+// Only the standard IFDs (IFD0, IFD1, Exif, GPS, Interop) are modeled. Other
+// tags that store offsets (e.g. SubIFDs) are carried as opaque values.
+type ExifDocument struct {
+	ByteOrder binary.ByteOrder
+
+	// Root is IFD0.
+	Root *ExifIfd
+
+	// Thumbnail is IFD1, if present.
+	ThumbnailIfd *ExifIfd
+
+	// PreserveMakerNote keeps the MakerNote usable across edits. Many
+	// MakerNotes store offsets relative to the TIFF header, so the encoder
+	// leaves the MakerNote at its original offset or, if the data before it
+	// has grown too much, adjusts its internal offsets to the new position.
+	// It is enabled for parsed documents.
+	PreserveMakerNote bool
+
+	// makerNoteOffset and makerNoteValue describe the MakerNote as parsed.
+	makerNoteOffset uint32
+	makerNoteValue []byte
+}
+
+// NewExifDocument returns an empty document with the given byte-order.
+func NewExifDocument(byteOrder binary.ByteOrder) *ExifDocument {
+	return &ExifDocument{
+		ByteOrder: byteOrder,
+		Root: &ExifIfd{
+			Name: EXIF_IFD_ROOT,
+		},
+	}
+}
+
+type exifParser struct {
+	data []byte
+	byteOrder binary.ByteOrder
+	visited map[uint32]bool
+
+	// makerNoteOffset is where the MakerNote value was found, if anywhere.
+	makerNoteOffset uint32
+}
+
+func (ep *exifParser) parseIfd(name string, offset uint32) (ifd *ExifIfd, nextOffset uint32) {
+	if ep.visited[offset] == true {
+		log.Panicf("IFD loop detected at offset (0x%08x)", offset)
+	}
+
+	ep.visited[offset] = true
+
+	if int(offset) + 2 > len(ep.data) {
+		log.Panicf("IFD offset out of bounds: [%s] (0x%08x)", name, offset)
+	}
+
+	count := int(ep.byteOrder.Uint16(ep.data[offset:]))
+	end := int(offset) + 2 + count * 12
+
+	if end + 4 > len(ep.data) {
+		log.Panicf("IFD entries out of bounds: [%s] (%d)", name, count)
+	}
+
+	ifd = &ExifIfd{
+		Name: name,
+		Entries: make([]*ExifEntry, 0, count),
+	}
+
+	var thumbnailOffset, thumbnailLength uint32
+
+	for i := 0; i < count; i++ {
+		raw := ep.data[int(offset) + 2 + i * 12:]
+
+		ee := &ExifEntry{
+			TagId: ep.byteOrder.Uint16(raw[0:2]),
+			TagType: ep.byteOrder.Uint16(raw[2:4]),
+			Count: ep.byteOrder.Uint32(raw[4:8]),
+		}
+
+		unitSize, found := exifTypeSizes[ee.TagType]
+		if found == false {
+			// The size of an unknown type can't be known, so neither can
+			// whether the value field holds the value or an offset to it. An
+			// offset would point at unrelated bytes once the encoder moves
+			// the data, so the entry is dropped (TIFF readers are meant to
+			// skip fields of unknown types).
+			jpegLogger.Warningf(nil, "Dropping tag (0x%04x) with unknown type (%d).", ee.TagId, ee.TagType)
+			continue
+		}
+
+		size := int(ee.Count) * unitSize
+		if size <= 4 {
+			ee.RawValue = append([]byte{}, raw[8:8 + size]...)
+		} else {
+			valueOffset := ep.byteOrder.Uint32(raw[8:12])
+			if int(valueOffset) + size > len(ep.data) || int(valueOffset) + size < 0 {
+				log.Panicf("value for tag (0x%04x) out of bounds", ee.TagId)
+			}
+
+			ee.RawValue = append([]byte{}, ep.data[valueOffset:int(valueOffset) + size]...)
+
+			if name == EXIF_IFD_EXIF && ee.TagId == exifTagMakerNote {
+				ep.makerNoteOffset = valueOffset
+			}
+		}
+
+		if childName, found := exifChildIfds[ee.TagId]; found == true {
+			childOffset := ep.byteOrder.Uint32(raw[8:12])
+
+			child, _ := ep.parseIfd(childName, childOffset)
+			ifd.Children = append(ifd.Children, child)
+
+			// The pointer is regenerated on encoding.
+			continue
+		}
+
+		if name == EXIF_IFD_THUMBNAIL && ee.TagId == exifTagThumbnailOffset {
+			thumbnailOffset = ep.byteOrder.Uint32(raw[8:12])
+			continue
+		} else if name == EXIF_IFD_THUMBNAIL && ee.TagId == exifTagThumbnailLength {
+			thumbnailLength = ep.byteOrder.Uint32(raw[8:12])
+			continue
+		}
+
+		ifd.Entries = append(ifd.Entries, ee)
+	}
+
+	if thumbnailOffset != 0 && thumbnailLength != 0 {
+		if int(thumbnailOffset) + int(thumbnailLength) > len(ep.data) {
+			log.Panicf("thumbnail out of bounds")
+		}
+
+		ifd.Thumbnail = append([]byte{}, ep.data[thumbnailOffset:thumbnailOffset + thumbnailLength]...)
+	}
+
+	nextOffset = ep.byteOrder.Uint32(ep.data[end:])
+
+	return ifd, nextOffset
+}
+
+// ParseExifDocument parses TIFF-formatted EXIF data.
+func ParseExifDocument(data []byte) (ed *ExifDocument, err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	if len(data) < 8 {
+		log.Panicf("EXIF data too short for a TIFF header")
+	}
+
+	var byteOrder binary.ByteOrder
+	if data[0] == 'I' && data[1] == 'I' {
+		byteOrder = binary.LittleEndian
+	} else if data[0] == 'M' && data[1] == 'M' {
+		byteOrder = binary.BigEndian
+	} else {
+		log.Panicf("TIFF byte-order not valid: (%02x) (%02x)", data[0], data[1])
+	}
+
+	if byteOrder.Uint16(data[2:4]) != 0x2a {
+		log.Panicf("TIFF magic not valid")
+	}
+
+	ep := &exifParser{
+		data: data,
+		byteOrder: byteOrder,
+		visited: make(map[uint32]bool),
+	}
+
+	root, nextOffset := ep.parseIfd(EXIF_IFD_ROOT, byteOrder.Uint32(data[4:8]))
+
+	ed = &ExifDocument{
+		ByteOrder: byteOrder,
+		Root: root,
+		PreserveMakerNote: true,
+	}
+
+	if ep.makerNoteOffset != 0 {
+		makerNote, err := ed.Entry(EXIF_IFD_EXIF, exifTagMakerNote)
+		log.PanicIf(err)
+
+		ed.makerNoteOffset = ep.makerNoteOffset
+		ed.makerNoteValue = append([]byte{}, makerNote.RawValue...)
+	}
+
+	if nextOffset != 0 {
+		ed.ThumbnailIfd, _ = ep.parseIfd(EXIF_IFD_THUMBNAIL, nextOffset)
+	}
+
+	return ed, nil
+}
+
+// Ifd returns the IFD with the given name or nil.
+func (ed *ExifDocument) Ifd(name string) *ExifIfd {
+	if name == EXIF_IFD_ROOT {
+		return ed.Root
+	} else if name == EXIF_IFD_THUMBNAIL {
+		return ed.ThumbnailIfd
+	}
+
+	parentName, found := exifChildParents[name]
+	if found == false {
+		return nil
+	}
+
+	parent := ed.Ifd(parentName)
+	if parent == nil {
+		return nil
+	}
+
+	return parent.Child(name)
+}
+
+// ensureIfd returns the IFD with the given name, creating it (and its
+// parents) if necessary.
+func (ed *ExifDocument) ensureIfd(name string) *ExifIfd {
+	if ifd := ed.Ifd(name); ifd != nil {
+		return ifd
+	}
+
+	if name == EXIF_IFD_THUMBNAIL {
+		ed.ThumbnailIfd = &ExifIfd{
+			Name: EXIF_IFD_THUMBNAIL,
+		}
+
+		return ed.ThumbnailIfd
+	}
+
+	parentName, found := exifChildParents[name]
+	if found == false {
+		log.Panicf("IFD not known: [%s]", name)
+	}
+
+	parent := ed.ensureIfd(parentName)
+
+	ifd := &ExifIfd{
+		Name: name,
+	}
+
+	parent.Children = append(parent.Children, ifd)
+	return ifd
+}
+
+// Entry returns the given tag from the given IFD.
+func (ed *ExifDocument) Entry(ifdName string, tagId uint16) (ee *ExifEntry, err error) {
+	ifd := ed.Ifd(ifdName)
+	if ifd == nil {
+		return nil, ErrExifTagNotFound
+	}
+
+	return ifd.Entry(tagId)
+}
+
+// Value decodes the value of the given entry. ASCII values are returned as
+// strings, BYTE/UNDEFINED as []byte, and the numeric types as slices of
+// uint16, uint32, int8, int16, int32, Rational, and SignedRational.
+func (ed *ExifDocument) Value(ee *ExifEntry) (value interface{}, err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	bo := ed.ByteOrder
+	raw := ee.RawValue
+	count := int(ee.Count)
+
+	if len(raw) < count * exifTypeSizes[ee.TagType] {
+		log.Panicf("raw value too short for tag (0x%04x)", ee.TagId)
+	}
+
+	switch ee.TagType {
+	case EXIF_TYPE_ASCII:
+		return string(bytes.TrimRight(raw, "\x00")), nil
+	case EXIF_TYPE_BYTE, EXIF_TYPE_UNDEFINED:
+		return raw, nil
+	case EXIF_TYPE_SBYTE:
+		values := make([]int8, count)
+		for i := range values {
+			values[i] = int8(raw[i])
+		}
+
+		return values, nil
+	case EXIF_TYPE_SHORT:
+		values := make([]uint16, count)
+		for i := range values {
+			values[i] = bo.Uint16(raw[i * 2:])
+		}
+
+		return values, nil
+	case EXIF_TYPE_SSHORT:
+		values := make([]int16, count)
+		for i := range values {
+			values[i] = int16(bo.Uint16(raw[i * 2:]))
+		}
+
+		return values, nil
+	case EXIF_TYPE_LONG:
+		values := make([]uint32, count)
+		for i := range values {
+			values[i] = bo.Uint32(raw[i * 4:])
+		}
+
+		return values, nil
+	case EXIF_TYPE_SLONG:
+		values := make([]int32, count)
+		for i := range values {
+			values[i] = int32(bo.Uint32(raw[i * 4:]))
+		}
+
+		return values, nil
+	case EXIF_TYPE_RATIONAL:
+		values := make([]Rational, count)
+		for i := range values {
+			values[i] = Rational{
+				Numerator: bo.Uint32(raw[i * 8:]),
+				Denominator: bo.Uint32(raw[i * 8 + 4:]),
+			}
+		}
+
+		return values, nil
+	case EXIF_TYPE_SRATIONAL:
+		values := make([]SignedRational, count)
+		for i := range values {
+			values[i] = SignedRational{
+				Numerator: int32(bo.Uint32(raw[i * 8:])),
+				Denominator: int32(bo.Uint32(raw[i * 8 + 4:])),
+			}
+		}
+
+		return values, nil
+	}
+
+	log.Panicf("type not handled: (%d)", ee.TagType)
+	return nil, nil
+}
+
+// encodeValue produces the raw bytes and count for a value given as one of
+// the types returned by Value().
+func (ed *ExifDocument) encodeValue(tagType uint16, value interface{}) (raw []byte, count uint32) {
+	bo := ed.ByteOrder
+	b := new(bytes.Buffer)
+
+	switch tagType {
+	case EXIF_TYPE_ASCII:
+		s, ok := value.(string)
+		if ok == false {
+			log.Panicf("ASCII value must be a string: [%v]", value)
+		}
+
+		b.WriteString(s)
+		b.WriteByte(0)
+	case EXIF_TYPE_BYTE, EXIF_TYPE_UNDEFINED:
+		data, ok := value.([]byte)
+		if ok == false {
+			log.Panicf("BYTE/UNDEFINED value must be a []byte: [%v]", value)
+		}
+
+		b.Write(data)
+	case EXIF_TYPE_SHORT, EXIF_TYPE_SSHORT, EXIF_TYPE_LONG, EXIF_TYPE_SLONG, EXIF_TYPE_RATIONAL, EXIF_TYPE_SRATIONAL, EXIF_TYPE_SBYTE:
+		switch value.(type) {
+		case []uint16, []int16, []uint32, []int32, []Rational, []SignedRational, []int8:
+		default:
+			log.Panicf("value type not valid for EXIF type (%d): [%T]", tagType, value)
+		}
+
+		err := binary.Write(b, bo, value)
+		log.PanicIf(err)
+	default:
+		log.Panicf("type not handled: (%d)", tagType)
+	}
+
+	raw = b.Bytes()
+	count = uint32(len(raw) / exifTypeSizes[tagType])
+
+	return raw, count
+}
+
+// SetValue adds or replaces a tag. The value must be one of the types
+// returned by Value(). The IFD is created if necessary.
+func (ed *ExifDocument) SetValue(ifdName string, tagId uint16, tagType uint16, value interface{}) (err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	if _, found := exifChildIfds[tagId]; found == true {
+		log.Panicf("IFD pointers can not be set directly: (0x%04x)", tagId)
+	}
+
+	raw, count := ed.encodeValue(tagType, value)
+
+	ifd := ed.ensureIfd(ifdName)
+
+	ee, err := ifd.Entry(tagId)
+	if err == nil {
+		ee.TagType = tagType
+		ee.Count = count
+		ee.RawValue = raw
+
+		return nil
+	}
+
+	ee = &ExifEntry{
+		TagId: tagId,
+		TagType: tagType,
+		Count: count,
+		RawValue: raw,
+	}
+
+	ifd.Entries = append(ifd.Entries, ee)
+	return nil
+}
+
+// exifEncoder lays out and writes the IFDs.
+type exifEncoder struct {
+	ed *ExifDocument
+	b *bytes.Buffer
+
+	// makerNote is the MakerNote entry being preserved, if any, and
+	// makerNotePadding is the space to insert in front of its value to keep
+	// it at its original offset.
+	makerNote *ExifEntry
+	makerNotePadding int
+
+	// makerNoteOffset is where the MakerNote value was written.
+	makerNoteOffset int
+}
+
+// ifdEntryCount returns the number of entries that will be written for the
+// IFD, including the regenerated pointers.
+func (ee *exifEncoder) ifdEntryCount(ifd *ExifIfd) int {
+	count := len(ifd.Entries) + len(ifd.Children)
+	if ifd.Thumbnail != nil {
+		count += 2
+	}
+
+	return count
+}
+
+// ifdSize returns the size of the IFD table plus its out-of-line values.
+func (ee *exifEncoder) ifdSize(ifd *ExifIfd) int {
+	size := 2 + ee.ifdEntryCount(ifd) * 12 + 4
+
+	for _, entry := range ifd.Entries {
+		if entry == ee.makerNote {
+			size += ee.makerNotePadding
+		}
+
+		if len(entry.RawValue) > 4 {
+			size += len(entry.RawValue) + len(entry.RawValue) % 2
+		}
+	}
+
+	return size
+}
+
+// treeSize returns the size of the IFD and all of its descendants.
+func (ee *exifEncoder) treeSize(ifd *ExifIfd) int {
+	size := ee.ifdSize(ifd)
+	for _, child := range ifd.Children {
+		size += ee.treeSize(child)
+	}
+
+	return size
+}
+
+// writeIfd writes the IFD at the current position (which must equal
+// `offset`) followed by its children, and returns the offset after them.
+func (ee *exifEncoder) writeIfd(ifd *ExifIfd, offset int, nextOffset uint32, thumbnailOffset uint32) int {
+	bo := ee.ed.ByteOrder
+
+	type pending struct {
+		tagId uint16
+		tagType uint16
+		count uint32
+		raw []byte
+		entry *ExifEntry
+	}
+
+	entries := make([]pending, 0, ee.ifdEntryCount(ifd))
+
+	for _, entry := range ifd.Entries {
+		entries = append(entries, pending{entry.TagId, entry.TagType, entry.Count, entry.RawValue, entry})
+	}
+
+	// Children are written immediately after this IFD, in order.
+	childOffset := offset + ee.ifdSize(ifd)
+	childOffsets := make([]int, len(ifd.Children))
+
+	for i, child := range ifd.Children {
+		pointerTagId, found := exifChildPointerTags[child.Name]
+		if found == false {
+			log.Panicf("no pointer tag for child IFD [%s]", child.Name)
+		}
+
+		raw := make([]byte, 4)
+		bo.PutUint32(raw, uint32(childOffset))
+
+		entries = append(entries, pending{pointerTagId, EXIF_TYPE_LONG, 1, raw, nil})
+
+		childOffsets[i] = childOffset
+		childOffset += ee.treeSize(child)
+	}
+
+	if ifd.Thumbnail != nil {
+		raw := make([]byte, 4)
+		bo.PutUint32(raw, thumbnailOffset)
+		entries = append(entries, pending{exifTagThumbnailOffset, EXIF_TYPE_LONG, 1, raw, nil})
+
+		raw = make([]byte, 4)
+		bo.PutUint32(raw, uint32(len(ifd.Thumbnail)))
+		entries = append(entries, pending{exifTagThumbnailLength, EXIF_TYPE_LONG, 1, raw, nil})
+	}
+
+	// TIFF requires ascending tag order.
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].tagId < entries[j].tagId
+	})
+
+	valueOffset := offset + 2 + len(entries) * 12 + 4
+	values := new(bytes.Buffer)
+
+	err := binary.Write(ee.b, bo, uint16(len(entries)))
+	log.PanicIf(err)
+
+	for _, p := range entries {
+		header := make([]byte, 12)
+		bo.PutUint16(header[0:], p.tagId)
+		bo.PutUint16(header[2:], p.tagType)
+		bo.PutUint32(header[4:], p.count)
+
+		if len(p.raw) <= 4 {
+			copy(header[8:], p.raw)
+		} else {
+			if p.entry == ee.makerNote {
+				values.Write(make([]byte, ee.makerNotePadding))
+				ee.makerNoteOffset = valueOffset + values.Len()
+			}
+
+			bo.PutUint32(header[8:], uint32(valueOffset + values.Len()))
+
+			values.Write(p.raw)
+			if len(p.raw) % 2 == 1 {
+				values.WriteByte(0)
+			}
+		}
+
+		ee.b.Write(header)
+	}
+
+	err = binary.Write(ee.b, bo, nextOffset)
+	log.PanicIf(err)
+
+	ee.b.Write(values.Bytes())
+
+	for i, child := range ifd.Children {
+		ee.writeIfd(child, childOffsets[i], 0, 0)
+	}
+
+	return childOffset
+}
+
+// encode lays out and writes the whole document.
+func (ee *exifEncoder) encode() []byte {
+	ed := ee.ed
+	ee.b = new(bytes.Buffer)
+
+	if ed.ByteOrder == binary.LittleEndian {
+		ee.b.Write([]byte{'I', 'I', 0x2a, 0x00})
+	} else {
+		ee.b.Write([]byte{'M', 'M', 0x00, 0x2a})
+	}
+
+	err := binary.Write(ee.b, ed.ByteOrder, uint32(8))
+	log.PanicIf(err)
+
+	rootEnd := 8 + ee.treeSize(ed.Root)
+
+	nextOffset := uint32(0)
+	if ed.ThumbnailIfd != nil {
+		nextOffset = uint32(rootEnd)
+	}
+
+	ee.writeIfd(ed.Root, 8, nextOffset, 0)
+
+	if ed.ThumbnailIfd != nil {
+		thumbnailOffset := uint32(rootEnd + ee.treeSize(ed.ThumbnailIfd))
+		ee.writeIfd(ed.ThumbnailIfd, rootEnd, 0, thumbnailOffset)
+
+		ee.b.Write(ed.ThumbnailIfd.Thumbnail)
+	}
+
+	return ee.b.Bytes()
+}
+
+// Encode serializes the document to TIFF-formatted EXIF data.
+func (ed *ExifDocument) Encode() (data []byte, err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	ee := &exifEncoder{
+		ed: ed,
+		makerNote: ed.preservedMakerNote(),
+	}
+
+	data = ee.encode()
+
+	if ee.makerNote == nil || ee.makerNoteOffset == int(ed.makerNoteOffset) {
+		return data, nil
+	}
+
+	if ee.makerNoteOffset < int(ed.makerNoteOffset) {
+		// Pad in front of the MakerNote so that it lands where it was.
+		ee.makerNotePadding = int(ed.makerNoteOffset) - ee.makerNoteOffset
+		data = ee.encode()
+
+		return data, nil
+	}
+
+	// Everything in front of the MakerNote has grown past it. Move it and
+	// adjust its offsets instead.
+	delta := int64(ee.makerNoteOffset) - int64(ed.makerNoteOffset)
+	region := data[ee.makerNoteOffset:ee.makerNoteOffset + len(ee.makerNote.RawValue)]
+
+	if relocateMakerNote(region, ed.ByteOrder, ed.makerNoteOffset, delta) == false {
+		jpegLogger.Debugf(nil, "MakerNote moved by (%d) but its format isn't known; its offsets weren't adjusted.", delta)
+	}
+
+	return data, nil
+}
+
+// ExifDocument parses the first EXIF APP1 segment.
+func (sl SegmentList) ExifDocument() (ed *ExifDocument, err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	exifData, err := sl.ExifData()
+	log.PanicIf(err)
+
+	ed, err = ParseExifDocument(exifData)
+	log.PanicIf(err)
+
+	return ed, nil
+}
+
+// SetExif replaces the payload of the first EXIF APP1 segment with the given
+// TIFF-formatted data. If there is no EXIF segment, one is inserted after the
+// SOI and any JFIF APP0.
+func (sl *SegmentList) SetExif(exifData []byte) (err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	payload := make([]byte, len(exifPrefix) + len(exifData))
+	copy(payload, exifPrefix)
+	copy(payload[len(exifPrefix):], exifData)
+
+	// Unlike XMP and ICC, EXIF can't be split across segments.
+	if len(payload) > maxSegmentPayloadSize {
+		jpegLogger.Debugf(nil, "EXIF data too large for one segment: (%d)", len(exifData))
+		log.Panic(ErrSegmentTooLarge)
+	}
+
+	for i, s := range *sl {
+		if s.Kind() == SegmentKindExifApp1 {
+			(*sl)[i].setData(payload)
+			sl.updateOffsets()
+
+			return nil
+		}
+	}
+
+	position := 0
+	for i, s := range *sl {
+		if s.MarkerId == MARKER_SOI || (s.MarkerId == MARKER_APP0 && isJfifPayload(s.Data) == true) {
+			position = i + 1
+		} else {
+			break
+		}
+	}
+
+	s := Segment{
+		MarkerId: MARKER_APP1,
+		MarkerName: markerNames[MARKER_APP1],
+		Data: payload,
+	}
+
+	updated := make(SegmentList, 0, len(*sl) + 1)
+	updated = append(updated, (*sl)[:position]...)
+	updated = append(updated, s)
+	updated = append(updated, (*sl)[position:]...)
+
+	updated.updateOffsets()
+
+	*sl = updated
+	return nil
+}
+
+// SetExifDocument encodes the document and stores it with SetExif.
+func (sl *SegmentList) SetExifDocument(ed *ExifDocument) (err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	exifData, err := ed.Encode()
+	log.PanicIf(err)
+
+	err = sl.SetExif(exifData)
+	log.PanicIf(err)
+
+	return nil
+}
@@ -0,0 +1,183 @@
+package jpegstructure
+
+import (
+	"bytes"
+	"path"
+	"reflect"
+	"testing"
+
+	"encoding/binary"
+
+	"github.com/dsoprea/go-logging"
+)
+
+func getTestExifDocument(filename string) *ExifDocument {
+	filepath := path.Join(assetsPath, filename)
+
+	sl, err := ParseFileStructure(filepath)
+	log.PanicIf(err)
+
+	ed, err := sl.ExifDocument()
+	log.PanicIf(err)
+
+	return ed
+}
+
+func TestParseExifDocument(t *testing.T) {
+	ed := getTestExifDocument(testImageRelFilepath)
+
+	ee, err := ed.Entry(EXIF_IFD_ROOT, 0x010f)
+	log.PanicIf(err)
+
+	value, err := ed.Value(ee)
+	log.PanicIf(err)
+
+	if value.(string) != "Canon" {
+		t.Fatalf("Make not correct: [%v]", value)
+	}
+
+	ee, err = ed.Entry(EXIF_IFD_EXIF, 0x9003)
+	log.PanicIf(err)
+
+	value, err = ed.Value(ee)
+	log.PanicIf(err)
+
+	if value.(string) != "2017:12:02 08:18:50" {
+		t.Fatalf("DateTimeOriginal not correct: [%v]", value)
+	}
+
+	if ed.ThumbnailIfd == nil || bytes.HasPrefix(ed.ThumbnailIfd.Thumbnail, []byte { 0xff, 0xd8 }) == false {
+		t.Fatalf("Thumbnail not found.")
+	}
+}
+
+func TestExifDocument_Encode_RoundTrip(t *testing.T) {
+	for _, filename := range []string { testImageRelFilepath, "20180428_212314.jpg" } {
+		ed := getTestExifDocument(filename)
+
+		encoded, err := ed.Encode()
+		log.PanicIf(err)
+
+		reparsed, err := ParseExifDocument(encoded)
+		log.PanicIf(err)
+
+		if reflect.DeepEqual(reparsed, ed) == false {
+			t.Fatalf("Round-tripped document not equal: [%s]", filename)
+		}
+	}
+}
+
+func TestExifDocument_Encode_UnknownType(t *testing.T) {
+	ed := getTestExifDocument(testImageRelFilepath)
+
+	// Too large to be stored in the entry, so the value is written elsewhere
+	// and the entry holds its offset.
+	unknown := &ExifEntry{
+		TagId: 0xc7a1,
+		TagType: 99,
+		Count: 2,
+		RawValue: []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08},
+	}
+
+	ed.Root.Entries = append(ed.Root.Entries, unknown)
+
+	encoded, err := ed.Encode()
+	log.PanicIf(err)
+
+	reparsed, err := ParseExifDocument(encoded)
+	log.PanicIf(err)
+
+	_, err = reparsed.Entry(EXIF_IFD_ROOT, unknown.TagId)
+	if log.Is(err, ErrExifTagNotFound) == false {
+		t.Fatalf("Entry with unknown type not dropped: %v", err)
+	}
+
+	// The rest of the document survives another round-trip unchanged.
+
+	encoded, err = reparsed.Encode()
+	log.PanicIf(err)
+
+	again, err := ParseExifDocument(encoded)
+	log.PanicIf(err)
+
+	expected := getTestExifDocument(testImageRelFilepath)
+
+	for _, expectedEe := range expected.Root.Entries {
+		ee, err := again.Entry(EXIF_IFD_ROOT, expectedEe.TagId)
+		log.PanicIf(err)
+
+		if ee.TagType != expectedEe.TagType || bytes.Equal(ee.RawValue, expectedEe.RawValue) == false {
+			t.Fatalf("Entry not kept: %s", ee)
+		}
+	}
+}
+
+func TestExifDocument_SetValue(t *testing.T) {
+	ed := getTestExifDocument(testImageRelFilepath)
+
+	err := ed.SetValue(EXIF_IFD_ROOT, 0x0112, EXIF_TYPE_SHORT, []uint16 { 6 })
+	log.PanicIf(err)
+
+	err = ed.SetValue(EXIF_IFD_GPS, 0x0001, EXIF_TYPE_ASCII, "N")
+	log.PanicIf(err)
+
+	encoded, err := ed.Encode()
+	log.PanicIf(err)
+
+	reparsed, err := ParseExifDocument(encoded)
+	log.PanicIf(err)
+
+	ee, err := reparsed.Entry(EXIF_IFD_ROOT, 0x0112)
+	log.PanicIf(err)
+
+	value, err := reparsed.Value(ee)
+	log.PanicIf(err)
+
+	if reflect.DeepEqual(value, []uint16 { 6 }) == false {
+		t.Fatalf("Orientation not correct: %v", value)
+	}
+
+	ee, err = reparsed.Entry(EXIF_IFD_GPS, 0x0001)
+	log.PanicIf(err)
+
+	value, err = reparsed.Value(ee)
+	log.PanicIf(err)
+
+	if value.(string) != "N" {
+		t.Fatalf("GPSLatitudeRef not correct: %v", value)
+	}
+}
+
+func TestSegmentList_SetExif_Insert(t *testing.T) {
+	filepath := path.Join(assetsPath, testImageRelFilepath)
+
+	sl, err := ParseFileStructure(filepath)
+	log.PanicIf(err)
+
+	stripped := sl.StripMetadata(false)
+
+	ed := NewExifDocument(binary.BigEndian)
+
+	err = ed.SetValue(EXIF_IFD_ROOT, 0x0131, EXIF_TYPE_ASCII, "test")
+	log.PanicIf(err)
+
+	err = stripped.SetExifDocument(ed)
+	log.PanicIf(err)
+
+	if stripped[1].MarkerId != MARKER_APP1 {
+		t.Fatalf("EXIF segment not inserted after SOI.")
+	}
+
+	reparsed, err := stripped.ExifDocument()
+	log.PanicIf(err)
+
+	ee, err := reparsed.Entry(EXIF_IFD_ROOT, 0x0131)
+	log.PanicIf(err)
+
+	value, err := reparsed.Value(ee)
+	log.PanicIf(err)
+
+	if value.(string) != "test" {
+		t.Fatalf("Software not correct: %v", value)
+	}
+}
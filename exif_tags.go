@@ -0,0 +1,246 @@
+package jpegstructure
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/dsoprea/go-logging"
+)
+
+// ExifTagDefinition describes a commonly-used tag.
+type ExifTagDefinition struct {
+	IfdName string
+	TagId uint16
+	Name string
+	TagType uint16
+}
+
+var (
+	exifTagDefinitions = []ExifTagDefinition{
+		{EXIF_IFD_ROOT, 0x010e, "ImageDescription", EXIF_TYPE_ASCII},
+		{EXIF_IFD_ROOT, 0x010f, "Make", EXIF_TYPE_ASCII},
+		{EXIF_IFD_ROOT, 0x0110, "Model", EXIF_TYPE_ASCII},
+		{EXIF_IFD_ROOT, 0x0112, "Orientation", EXIF_TYPE_SHORT},
+		{EXIF_IFD_ROOT, 0x011a, "XResolution", EXIF_TYPE_RATIONAL},
+		{EXIF_IFD_ROOT, 0x011b, "YResolution", EXIF_TYPE_RATIONAL},
+		{EXIF_IFD_ROOT, 0x0128, "ResolutionUnit", EXIF_TYPE_SHORT},
+		{EXIF_IFD_ROOT, 0x0131, "Software", EXIF_TYPE_ASCII},
+		{EXIF_IFD_ROOT, 0x0132, "DateTime", EXIF_TYPE_ASCII},
+		{EXIF_IFD_ROOT, 0x013b, "Artist", EXIF_TYPE_ASCII},
+		{EXIF_IFD_ROOT, 0x0213, "YCbCrPositioning", EXIF_TYPE_SHORT},
+		{EXIF_IFD_ROOT, 0x8298, "Copyright", EXIF_TYPE_ASCII},
+
+		{EXIF_IFD_EXIF, 0x829a, "ExposureTime", EXIF_TYPE_RATIONAL},
+		{EXIF_IFD_EXIF, 0x829d, "FNumber", EXIF_TYPE_RATIONAL},
+		{EXIF_IFD_EXIF, 0x8822, "ExposureProgram", EXIF_TYPE_SHORT},
+		{EXIF_IFD_EXIF, 0x8827, "ISOSpeedRatings", EXIF_TYPE_SHORT},
+		{EXIF_IFD_EXIF, 0x9000, "ExifVersion", EXIF_TYPE_UNDEFINED},
+		{EXIF_IFD_EXIF, 0x9003, "DateTimeOriginal", EXIF_TYPE_ASCII},
+		{EXIF_IFD_EXIF, 0x9004, "DateTimeDigitized", EXIF_TYPE_ASCII},
+		{EXIF_IFD_EXIF, 0x9010, "OffsetTime", EXIF_TYPE_ASCII},
+		{EXIF_IFD_EXIF, 0x9011, "OffsetTimeOriginal", EXIF_TYPE_ASCII},
+		{EXIF_IFD_EXIF, 0x9012, "OffsetTimeDigitized", EXIF_TYPE_ASCII},
+		{EXIF_IFD_EXIF, 0x9201, "ShutterSpeedValue", EXIF_TYPE_SRATIONAL},
+		{EXIF_IFD_EXIF, 0x9202, "ApertureValue", EXIF_TYPE_RATIONAL},
+		{EXIF_IFD_EXIF, 0x9204, "ExposureBiasValue", EXIF_TYPE_SRATIONAL},
+		{EXIF_IFD_EXIF, 0x9207, "MeteringMode", EXIF_TYPE_SHORT},
+		{EXIF_IFD_EXIF, 0x9209, "Flash", EXIF_TYPE_SHORT},
+		{EXIF_IFD_EXIF, 0x920a, "FocalLength", EXIF_TYPE_RATIONAL},
+		{EXIF_IFD_EXIF, 0x927c, "MakerNote", EXIF_TYPE_UNDEFINED},
+		{EXIF_IFD_EXIF, 0x9286, "UserComment", EXIF_TYPE_UNDEFINED},
+		{EXIF_IFD_EXIF, 0x9290, "SubSecTime", EXIF_TYPE_ASCII},
+		{EXIF_IFD_EXIF, 0x9291, "SubSecTimeOriginal", EXIF_TYPE_ASCII},
+		{EXIF_IFD_EXIF, 0x9292, "SubSecTimeDigitized", EXIF_TYPE_ASCII},
+		{EXIF_IFD_EXIF, 0xa001, "ColorSpace", EXIF_TYPE_SHORT},
+		{EXIF_IFD_EXIF, 0xa002, "PixelXDimension", EXIF_TYPE_LONG},
+		{EXIF_IFD_EXIF, 0xa003, "PixelYDimension", EXIF_TYPE_LONG},
+		{EXIF_IFD_EXIF, 0xa402, "ExposureMode", EXIF_TYPE_SHORT},
+		{EXIF_IFD_EXIF, 0xa403, "WhiteBalance", EXIF_TYPE_SHORT},
+		{EXIF_IFD_EXIF, 0xa405, "FocalLengthIn35mmFilm", EXIF_TYPE_SHORT},
+		{EXIF_IFD_EXIF, 0xa420, "ImageUniqueID", EXIF_TYPE_ASCII},
+		{EXIF_IFD_EXIF, 0xa430, "CameraOwnerName", EXIF_TYPE_ASCII},
+		{EXIF_IFD_EXIF, 0xa431, "BodySerialNumber", EXIF_TYPE_ASCII},
+		{EXIF_IFD_EXIF, 0xa433, "LensMake", EXIF_TYPE_ASCII},
+		{EXIF_IFD_EXIF, 0xa434, "LensModel", EXIF_TYPE_ASCII},
+
+		{EXIF_IFD_GPS, 0x0000, "GPSVersionID", EXIF_TYPE_BYTE},
+		{EXIF_IFD_GPS, 0x0001, "GPSLatitudeRef", EXIF_TYPE_ASCII},
+		{EXIF_IFD_GPS, 0x0002, "GPSLatitude", EXIF_TYPE_RATIONAL},
+		{EXIF_IFD_GPS, 0x0003, "GPSLongitudeRef", EXIF_TYPE_ASCII},
+		{EXIF_IFD_GPS, 0x0004, "GPSLongitude", EXIF_TYPE_RATIONAL},
+		{EXIF_IFD_GPS, 0x0005, "GPSAltitudeRef", EXIF_TYPE_BYTE},
+		{EXIF_IFD_GPS, 0x0006, "GPSAltitude", EXIF_TYPE_RATIONAL},
+		{EXIF_IFD_GPS, 0x0007, "GPSTimeStamp", EXIF_TYPE_RATIONAL},
+		{EXIF_IFD_GPS, 0x0012, "GPSMapDatum", EXIF_TYPE_ASCII},
+		{EXIF_IFD_GPS, 0x001d, "GPSDateStamp", EXIF_TYPE_ASCII},
+
+		{EXIF_IFD_INTEROP, 0x0001, "InteroperabilityIndex", EXIF_TYPE_ASCII},
+
+		{EXIF_IFD_THUMBNAIL, 0x0103, "Compression", EXIF_TYPE_SHORT},
+	}
+)
+
+// LookupExifTag returns the definition of a tag by name (case-insensitive).
+func LookupExifTag(name string) (etd ExifTagDefinition, err error) {
+	for _, etd := range exifTagDefinitions {
+		if strings.EqualFold(etd.Name, name) == true {
+			return etd, nil
+		}
+	}
+
+	return etd, ErrExifTagNotFound
+}
+
+// ExifTagName returns the name of the tag in the given IFD, or a hex
+// placeholder if it isn't one that we know.
+func ExifTagName(ifdName string, tagId uint16) string {
+	for _, etd := range exifTagDefinitions {
+		if etd.TagId == tagId && (etd.IfdName == ifdName || (etd.IfdName == EXIF_IFD_ROOT && ifdName == EXIF_IFD_THUMBNAIL)) {
+			return etd.Name
+		}
+	}
+
+	return fmt.Sprintf("0x%04x", tagId)
+}
+
+// ParseExifValueString converts a textual value into the representation
+// expected by ExifDocument.SetValue() for the given type. Multiple numeric
+// values are separated by commas and rationals may be given as "N/D" or as a
+// decimal.
+func ParseExifValueString(tagType uint16, text string) (value interface{}, err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	if tagType == EXIF_TYPE_ASCII {
+		return text, nil
+	} else if tagType == EXIF_TYPE_UNDEFINED {
+		return []byte(text), nil
+	}
+
+	parts := strings.Split(text, ",")
+
+	switch tagType {
+	case EXIF_TYPE_BYTE:
+		values := make([]byte, len(parts))
+		for i, part := range parts {
+			n, err := strconv.ParseUint(strings.TrimSpace(part), 10, 8)
+			log.PanicIf(err)
+
+			values[i] = byte(n)
+		}
+
+		return values, nil
+	case EXIF_TYPE_SHORT:
+		values := make([]uint16, len(parts))
+		for i, part := range parts {
+			n, err := strconv.ParseUint(strings.TrimSpace(part), 10, 16)
+			log.PanicIf(err)
+
+			values[i] = uint16(n)
+		}
+
+		return values, nil
+	case EXIF_TYPE_LONG:
+		values := make([]uint32, len(parts))
+		for i, part := range parts {
+			n, err := strconv.ParseUint(strings.TrimSpace(part), 10, 32)
+			log.PanicIf(err)
+
+			values[i] = uint32(n)
+		}
+
+		return values, nil
+	case EXIF_TYPE_RATIONAL:
+		values := make([]Rational, len(parts))
+		for i, part := range parts {
+			numerator, denominator := parseRationalString(strings.TrimSpace(part))
+			if numerator < 0 {
+				log.Panicf("RATIONAL value can not be negative: [%s]", part)
+			}
+
+			values[i] = Rational{uint32(numerator), uint32(denominator)}
+		}
+
+		return values, nil
+	case EXIF_TYPE_SRATIONAL:
+		values := make([]SignedRational, len(parts))
+		for i, part := range parts {
+			numerator, denominator := parseRationalString(strings.TrimSpace(part))
+			values[i] = SignedRational{int32(numerator), int32(denominator)}
+		}
+
+		return values, nil
+	}
+
+	log.Panicf("type not supported for text values: (%d)", tagType)
+	return nil, nil
+}
+
+// parseRationalString parses "N/D" or a decimal into a fraction.
+func parseRationalString(text string) (numerator, denominator int64) {
+	if i := strings.Index(text, "/"); i != -1 {
+		numerator, err := strconv.ParseInt(text[:i], 10, 32)
+		log.PanicIf(err)
+
+		denominator, err := strconv.ParseInt(text[i + 1:], 10, 32)
+		log.PanicIf(err)
+
+		return numerator, denominator
+	}
+
+	f, err := strconv.ParseFloat(text, 64)
+	log.PanicIf(err)
+
+	return floatToFraction(f)
+}
+
+// floatToFraction approximates a decimal with a fixed denominator.
+func floatToFraction(f float64) (numerator, denominator int64) {
+	denominator = 10000
+	if f >= 200000 || f <= -200000 {
+		denominator = 1
+	}
+
+	if f < 0 {
+		numerator = int64(f * float64(denominator) - 0.5)
+	} else {
+		numerator = int64(f * float64(denominator) + 0.5)
+	}
+
+	return numerator, denominator
+}
+
+// FormatExifValue renders a value returned by ExifDocument.Value() as text.
+func FormatExifValue(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case []byte:
+		if len(v) > 32 {
+			return fmt.Sprintf("(%d bytes)", len(v))
+		}
+
+		return DumpBytesToString(v)
+	case []Rational:
+		parts := make([]string, len(v))
+		for i, r := range v {
+			parts[i] = fmt.Sprintf("%d/%d", r.Numerator, r.Denominator)
+		}
+
+		return strings.Join(parts, ", ")
+	case []SignedRational:
+		parts := make([]string, len(v))
+		for i, r := range v {
+			parts[i] = fmt.Sprintf("%d/%d", r.Numerator, r.Denominator)
+		}
+
+		return strings.Join(parts, ", ")
+	}
+
+	text := fmt.Sprintf("%v", value)
+	return strings.Replace(strings.Trim(text, "[]"), " ", ", ", -1)
+}
@@ -0,0 +1,363 @@
+package jpegstructure
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+
+	"encoding/json"
+
+	"github.com/dsoprea/go-logging"
+)
+
+var (
+	// exiftoolTagNames maps the names in our tag table to the names that
+	// exiftool uses, where they differ.
+	exiftoolTagNames = map[string]string{
+		"DateTime": "ModifyDate",
+		"DateTimeDigitized": "CreateDate",
+		"ISOSpeedRatings": "ISO",
+		"ExposureBiasValue": "ExposureCompensation",
+		"PixelXDimension": "ExifImageWidth",
+		"PixelYDimension": "ExifImageHeight",
+		"FocalLengthIn35mmFilm": "FocalLengthIn35mmFormat",
+		"CameraOwnerName": "OwnerName",
+		"BodySerialNumber": "SerialNumber",
+		"InteroperabilityIndex": "InteropIndex",
+	}
+
+	// exiftoolVersionTags are UNDEFINED tags whose bytes are ASCII digits.
+	exiftoolVersionTags = map[string]bool{
+		"ExifVersion": true,
+	}
+)
+
+// ExiftoolTag is one "Group:Name" key and its value.
+type ExiftoolTag struct {
+	Key string
+	Value interface{}
+}
+
+// ExiftoolOptions controls ExiftoolTagsWithOptions.
+type ExiftoolOptions struct {
+	// Filter selects the tags to report. The groups are the exiftool groups
+	// (File, JFIF, EXIF, XMP) and, more specifically, the IFD of EXIF tags
+	// (e.g. "GPS") and the namespace of XMP properties (e.g. "XMP-dc").
+	// SourceFile is always reported.
+	Filter TagFilter
+}
+
+// exiftoolCollector accumulates tags in order, dropping repeated keys the way
+// exiftool does without "-a".
+type exiftoolCollector struct {
+	tags []ExiftoolTag
+	seen map[string]bool
+	filter TagFilter
+}
+
+func (ec *exiftoolCollector) add(group, name string, value interface{}, subgroups ...string) {
+	key := group + ":" + name
+	if ec.seen[key] == true {
+		return
+	} else if ec.filter.Matches(name, append([]string{group}, subgroups...)...) == false {
+		return
+	}
+
+	ec.seen[key] = true
+	ec.tags = append(ec.tags, ExiftoolTag{key, value})
+}
+
+// exiftoolRational renders a rational as exiftool does with "-n".
+func exiftoolRational(numerator, denominator float64) interface{} {
+	if denominator == 0 {
+		if numerator == 0 {
+			return "undef"
+		}
+
+		return "inf"
+	}
+
+	return numerator / denominator
+}
+
+// exiftoolValue converts a decoded EXIF value to the representation that
+// "exiftool -json -n" would emit.
+func exiftoolValue(name string, value interface{}) interface{} {
+	switch v := value.(type) {
+	case string:
+		return v
+	case []byte:
+		if name == "GPSVersionID" {
+			parts := make([]string, len(v))
+			for i, b := range v {
+				parts[i] = fmt.Sprintf("%d", b)
+			}
+
+			return strings.Join(parts, ".")
+		} else if exiftoolVersionTags[name] == true {
+			return string(v)
+		} else if name == "UserComment" {
+			if len(v) < 8 {
+				return ""
+			}
+
+			return strings.TrimRight(string(v[8:]), "\x00 ")
+		} else if name == "GPSAltitudeRef" && len(v) == 1 {
+			return int(v[0])
+		}
+
+		return fmt.Sprintf("(Binary data %d bytes, use -b option to extract)", len(v))
+	case []Rational:
+		if name == "GPSLatitude" || name == "GPSLongitude" {
+			degrees := 0.0
+			divisor := 1.0
+			for _, r := range v {
+				if r.Denominator != 0 {
+					degrees += float64(r.Numerator) / float64(r.Denominator) / divisor
+				}
+
+				divisor *= 60
+			}
+
+			return degrees
+		} else if name == "GPSTimeStamp" && len(v) == 3 {
+			parts := make([]string, 3)
+			for i, r := range v {
+				if r.Denominator == 0 {
+					return "undef"
+				}
+
+				parts[i] = fmt.Sprintf("%02d", r.Numerator / r.Denominator)
+			}
+
+			return strings.Join(parts, ":")
+		}
+
+		values := make([]interface{}, len(v))
+		for i, r := range v {
+			values[i] = exiftoolRational(float64(r.Numerator), float64(r.Denominator))
+		}
+
+		return exiftoolJoin(values)
+	case []SignedRational:
+		values := make([]interface{}, len(v))
+		for i, r := range v {
+			values[i] = exiftoolRational(float64(r.Numerator), float64(r.Denominator))
+		}
+
+		return exiftoolJoin(values)
+	case []uint16:
+		values := make([]interface{}, len(v))
+		for i, n := range v {
+			values[i] = n
+		}
+
+		return exiftoolJoin(values)
+	case []uint32:
+		values := make([]interface{}, len(v))
+		for i, n := range v {
+			values[i] = n
+		}
+
+		return exiftoolJoin(values)
+	}
+
+	return fmt.Sprintf("%v", value)
+}
+
+// exiftoolJoin returns a lone value as-is and multiple values joined by
+// spaces.
+func exiftoolJoin(values []interface{}) interface{} {
+	if len(values) == 1 {
+		return values[0]
+	}
+
+	parts := make([]string, len(values))
+	for i, value := range values {
+		parts[i] = fmt.Sprintf("%v", value)
+	}
+
+	return strings.Join(parts, " ")
+}
+
+func (ec *exiftoolCollector) addExifIfd(ed *ExifDocument, ifd *ExifIfd) {
+	for _, ee := range ifd.Entries {
+		name := ExifTagName(ifd.Name, ee.TagId)
+		if strings.HasPrefix(name, "0x") == true {
+			// exiftool omits unknown tags by default.
+			continue
+		}
+
+		value, err := ed.Value(ee)
+		if err != nil {
+			continue
+		}
+
+		if exiftoolName, found := exiftoolTagNames[name]; found == true {
+			name = exiftoolName
+		}
+
+		ec.add("EXIF", name, exiftoolValue(name, value), ifd.Name)
+	}
+
+	for _, child := range ifd.Children {
+		ec.addExifIfd(ed, child)
+	}
+
+	if ifd.Thumbnail != nil {
+		ec.add("EXIF", "ThumbnailLength", len(ifd.Thumbnail), ifd.Name)
+		ec.add("EXIF", "ThumbnailImage", fmt.Sprintf("(Binary data %d bytes, use -b option to extract)", len(ifd.Thumbnail)), ifd.Name)
+	}
+}
+
+// ExiftoolTags returns the tags that "exiftool -json -G -n" would report for
+// the structure, in the same order and with the same "Group:Name" keys, for
+// the groups that we understand (File, JFIF, EXIF, XMP).
+func (sl SegmentList) ExiftoolTags(sourceFile string) (tags []ExiftoolTag, err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	tags, err = sl.ExiftoolTagsWithOptions(sourceFile, ExiftoolOptions{})
+	log.PanicIf(err)
+
+	return tags, nil
+}
+
+// ExiftoolTagsWithOptions is ExiftoolTags with only the tags selected by the
+// filter (e.g. including "EXIF:Make" and "GPS:all", or excluding "XMP:all").
+func (sl SegmentList) ExiftoolTagsWithOptions(sourceFile string, options ExiftoolOptions) (tags []ExiftoolTag, err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	ec := &exiftoolCollector{
+		tags: make([]ExiftoolTag, 0),
+		seen: make(map[string]bool),
+		filter: options.Filter,
+	}
+
+	ec.tags = append(ec.tags, ExiftoolTag{"SourceFile", sourceFile})
+
+	for _, s := range sl {
+		if IsSofMarker(s.MarkerId) == false {
+			continue
+		}
+
+		js := new(JpegSplitter)
+
+		sof, err := js.parseSof(s.Data)
+		log.PanicIf(err)
+
+		ec.add("File", "ImageWidth", sof.Width)
+		ec.add("File", "ImageHeight", sof.Height)
+		ec.add("File", "EncodingProcess", s.MarkerId - MARKER_SOF0)
+		ec.add("File", "BitsPerSample", sof.BitsPerSample)
+		ec.add("File", "ColorComponents", sof.ComponentCount)
+
+		components, err := ParseSofComponents(s.Data)
+		if err == nil && len(components) == 3 {
+			ec.add("File", "YCbCrSubSampling", fmt.Sprintf("%d %d", components[0].HorizontalSamplingFactor, components[0].VerticalSamplingFactor))
+		}
+
+		break
+	}
+
+	for _, s := range sl {
+		if s.MarkerId == MARKER_APP0 && isJfifPayload(s.Data) == true {
+			jfif, err := ParseJfifSegment(s.Data)
+			if err != nil {
+				continue
+			}
+
+			ec.add("JFIF", "JFIFVersion", fmt.Sprintf("%d.%02d", jfif.MajorVersion, jfif.MinorVersion))
+			ec.add("JFIF", "ResolutionUnit", jfif.DensityUnits)
+			ec.add("JFIF", "XResolution", jfif.XDensity)
+			ec.add("JFIF", "YResolution", jfif.YDensity)
+		} else if s.Kind() == SegmentKindExifApp1 {
+			ed, err := ParseExifDocument(s.Data[len(exifPrefix):])
+			if err != nil {
+				continue
+			}
+
+			ec.addExifIfd(ed, ed.Root)
+			if ed.ThumbnailIfd != nil {
+				ec.addExifIfd(ed, ed.ThumbnailIfd)
+			}
+		} else if s.Kind() == SegmentKindXmpApp1 {
+			properties, err := ParseXmpProperties(s.Data[len(xmpPrefix):])
+			if err != nil {
+				continue
+			}
+
+			for _, xp := range properties {
+				name := strings.ToUpper(xp.Name[:1]) + xp.Name[1:]
+				ec.add("XMP", name, xp.Value, xmpTagGroup(xp.Prefix))
+			}
+		}
+	}
+
+	return ec.tags, nil
+}
+
+// WriteExiftoolJson writes the tags from ExiftoolTags() as a one-element JSON
+// array, matching the layout of "exiftool -json -G -n".
+func (sl SegmentList) WriteExiftoolJson(w io.Writer, sourceFile string) (err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	err = sl.WriteExiftoolJsonWithOptions(w, sourceFile, ExiftoolOptions{})
+	log.PanicIf(err)
+
+	return nil
+}
+
+// WriteExiftoolJsonWithOptions is WriteExiftoolJson with the tags from
+// ExiftoolTagsWithOptions().
+func (sl SegmentList) WriteExiftoolJsonWithOptions(w io.Writer, sourceFile string, options ExiftoolOptions) (err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	tags, err := sl.ExiftoolTagsWithOptions(sourceFile, options)
+	log.PanicIf(err)
+
+	b := new(bytes.Buffer)
+	b.WriteString("[{\n")
+
+	for i, tag := range tags {
+		key, err := json.Marshal(tag.Key)
+		log.PanicIf(err)
+
+		value, err := json.Marshal(tag.Value)
+		log.PanicIf(err)
+
+		b.WriteString("  ")
+		b.Write(key)
+		b.WriteString(": ")
+		b.Write(value)
+
+		if i < len(tags) - 1 {
+			b.WriteString(",")
+		}
+
+		b.WriteString("\n")
+	}
+
+	b.WriteString("}]\n")
+
+	_, err = w.Write(b.Bytes())
+	log.PanicIf(err)
+
+	return nil
+}
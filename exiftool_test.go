@@ -0,0 +1,118 @@
+package jpegstructure
+
+import (
+	"bytes"
+	"path"
+	"testing"
+
+	"encoding/json"
+
+	"github.com/dsoprea/go-logging"
+)
+
+func TestSegmentList_WriteExiftoolJson(t *testing.T) {
+	filepath := path.Join(assetsPath, "20180428_212314.jpg")
+
+	sl, err := ParseFileStructure(filepath)
+	log.PanicIf(err)
+
+	b := new(bytes.Buffer)
+
+	err = sl.WriteExiftoolJson(b, filepath)
+	log.PanicIf(err)
+
+	records := make([]map[string]interface{}, 0)
+
+	err = json.Unmarshal(b.Bytes(), &records)
+	log.PanicIf(err)
+
+	if len(records) != 1 {
+		t.Fatalf("Expected exactly one record: (%d)", len(records))
+	}
+
+	expected := map[string]interface{} {
+		"SourceFile": filepath,
+		"File:ImageWidth": 5312.0,
+		"File:ImageHeight": 2988.0,
+		"File:YCbCrSubSampling": "2 2",
+		"JFIF:JFIFVersion": "1.01",
+		"EXIF:Make": "samsung",
+		"EXIF:ModifyDate": "2018:04:28 21:23:14",
+		"EXIF:ISO": 200.0,
+		"EXIF:ExposureTime": 1.0 / 13.0,
+		"EXIF:ExifVersion": "0220",
+		"EXIF:GPSVersionID": "2.2.0.0",
+		"EXIF:GPSLatitude": 26.0 + 35.0 / 60.0 + 12.0 / 3600.0,
+		"EXIF:GPSTimeStamp": "01:22:57",
+		"EXIF:ThumbnailLength": 18318.0,
+	}
+
+	for key, value := range expected {
+		if records[0][key] != value {
+			t.Fatalf("Tag [%s] not correct: [%v] != [%v]", key, records[0][key], value)
+		}
+	}
+
+	if bytes.HasPrefix(b.Bytes(), []byte("[{\n  \"SourceFile\": ")) == false {
+		t.Fatalf("SourceFile not first.")
+	}
+}
+
+func TestSegmentList_ExiftoolTags_Xmp(t *testing.T) {
+	filepath := path.Join(assetsPath, testImageRelFilepath)
+
+	sl, err := ParseFileStructure(filepath)
+	log.PanicIf(err)
+
+	tags, err := sl.ExiftoolTags(filepath)
+	log.PanicIf(err)
+
+	found := false
+	for _, tag := range tags {
+		if tag.Key == "XMP:Rating" {
+			if tag.Value != "0" {
+				t.Fatalf("XMP:Rating not correct: [%v]", tag.Value)
+			}
+
+			found = true
+		}
+	}
+
+	if found == false {
+		t.Fatalf("XMP:Rating not found.")
+	}
+}
+
+func TestSegmentList_ExiftoolTagsWithOptions(t *testing.T) {
+	filepath := path.Join(assetsPath, "20180428_212314.jpg")
+
+	sl, err := ParseFileStructure(filepath)
+	log.PanicIf(err)
+
+	options := ExiftoolOptions{
+		Filter: TagFilter{
+			Include: []string{"EXIF:Make", "GPS:all"},
+			Exclude: []string{"GPSVersionID"},
+		},
+	}
+
+	tags, err := sl.ExiftoolTagsWithOptions(filepath, options)
+	log.PanicIf(err)
+
+	keys := make(map[string]bool)
+	for _, tag := range tags {
+		keys[tag.Key] = true
+	}
+
+	if keys["SourceFile"] == false {
+		t.Fatalf("SourceFile not reported.")
+	} else if keys["EXIF:Make"] == false {
+		t.Fatalf("EXIF:Make not reported.")
+	} else if keys["EXIF:GPSLatitude"] == false {
+		t.Fatalf("GPS tag not reported.")
+	} else if keys["EXIF:GPSVersionID"] == true {
+		t.Fatalf("Excluded tag reported.")
+	} else if keys["EXIF:Model"] == true || keys["File:ImageWidth"] == true {
+		t.Fatalf("Unselected tag reported.")
+	}
+}
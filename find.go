@@ -0,0 +1,83 @@
+package jpegstructure
+
+import (
+	"bytes"
+
+	"github.com/dsoprea/go-logging"
+)
+
+// Index returns the position of the first segment with the given marker or
+// -1 if there isn't one.
+func (sl SegmentList) Index(markerId byte) int {
+	for i, s := range sl {
+		if s.MarkerId == markerId {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// FindFirst returns the first segment with the given marker.
+func (sl SegmentList) FindFirst(markerId byte) (s Segment, err error) {
+	i := sl.Index(markerId)
+	if i == -1 {
+		return s, ErrSegmentNotFound
+	}
+
+	return sl[i], nil
+}
+
+// FindAll returns every segment with the given marker.
+func (sl SegmentList) FindAll(markerId byte) SegmentList {
+	found := make(SegmentList, 0)
+
+	for _, s := range sl {
+		if s.MarkerId == markerId {
+			found = append(found, s)
+		}
+	}
+
+	return found
+}
+
+// FindWithPrefix returns every segment with the given marker whose payload
+// starts with the given signature (e.g. "ICC_PROFILE\0" for APP2).
+func (sl SegmentList) FindWithPrefix(markerId byte, signature []byte) SegmentList {
+	found := make(SegmentList, 0)
+
+	for _, s := range sl {
+		if s.MarkerId == markerId && bytes.HasPrefix(s.Data, signature) == true {
+			found = append(found, s)
+		}
+	}
+
+	return found
+}
+
+// findFirstWithPrefix returns the first segment with the given marker and
+// payload signature.
+func (sl SegmentList) findFirstWithPrefix(markerId byte, signature []byte) (s Segment, err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	found := sl.FindWithPrefix(markerId, signature)
+	if len(found) == 0 {
+		log.Panic(ErrSegmentNotFound)
+	}
+
+	return found[0], nil
+}
+
+// App1Exif returns the first EXIF APP1 segment.
+func (sl SegmentList) App1Exif() (s Segment, err error) {
+	return sl.findFirstWithPrefix(MARKER_APP1, exifPrefix)
+}
+
+// App1Xmp returns the first (standard) XMP APP1 segment.
+func (sl SegmentList) App1Xmp() (s Segment, err error) {
+	return sl.findFirstWithPrefix(MARKER_APP1, xmpPrefix)
+}
@@ -0,0 +1,56 @@
+package jpegstructure
+
+import (
+	"path"
+	"testing"
+
+	"github.com/dsoprea/go-logging"
+)
+
+func TestSegmentList_Find(t *testing.T) {
+	filepath := path.Join(assetsPath, testImageRelFilepath)
+
+	sl, err := ParseFileStructure(filepath)
+	log.PanicIf(err)
+
+	if sl.Index(MARKER_DQT) != 3 {
+		t.Fatalf("DQT index not correct: (%d)", sl.Index(MARKER_DQT))
+	} else if sl.Index(MARKER_APP0) != -1 {
+		t.Fatalf("Expected no APP0.")
+	}
+
+	s, err := sl.FindFirst(MARKER_APP1)
+	log.PanicIf(err)
+
+	if s.Offset != 0x2 {
+		t.Fatalf("First APP1 not correct: (0x%08x)", s.Offset)
+	}
+
+	_, err = sl.FindFirst(MARKER_COM)
+	if err != ErrSegmentNotFound {
+		t.Fatalf("Expected not-found error: %v", err)
+	}
+
+	if len(sl.FindAll(MARKER_APP1)) != 2 {
+		t.Fatalf("Expected two APP1 segments.")
+	}
+
+	found := sl.FindWithPrefix(MARKER_APP1, xmpPrefix)
+	if len(found) != 1 || found[0].Offset != 0x80b4 {
+		t.Fatalf("XMP segment not found by prefix.")
+	}
+
+	s, err = sl.App1Exif()
+	log.PanicIf(err)
+
+	if s.Offset != 0x2 {
+		t.Fatalf("EXIF segment not correct: (0x%08x)", s.Offset)
+	}
+
+	s, err = sl.App1Xmp()
+	log.PanicIf(err)
+
+	if s.Offset != 0x80b4 {
+		t.Fatalf("XMP segment not correct: (0x%08x)", s.Offset)
+	}
+}
@@ -0,0 +1,325 @@
+// Package forensics looks for traces of editing in the compression history of
+// JPEG images.
+package forensics
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/dsoprea/go-logging"
+
+	"github.com/dsoprea/go-jpeg-structure"
+)
+
+const (
+	// analyzedFrequencies is the number of low-frequency AC coefficients (in
+	// zigzag order, after the DC) that are examined.
+	analyzedFrequencies = 12
+
+	// histogramBins is the number of (absolute, non-zero) coefficient values
+	// that are compared against the models.
+	histogramBins = 20
+
+	// minimumSamples is the number of non-zero coefficients that a frequency
+	// needs before its histogram is trusted.
+	minimumSamples = 500
+
+	// maximumPrimaryStep is the largest first quantization step tried.
+	maximumPrimaryStep = 64
+
+	// doubleErrorRatio is how much better the double-compression model has
+	// to fit than the single-compression model before a frequency counts as
+	// double-compressed.
+	doubleErrorRatio = 0.5
+)
+
+var (
+	// standardLuminance is the libjpeg quality-50 luminance table, which is
+	// also the unscaled reference table (zigzag order).
+	standardLuminance = jpegstructure.StandardQuantizationTable(0, 50)
+)
+
+// FrequencyAnalysis is the result for one DCT frequency of the luminance.
+type FrequencyAnalysis struct {
+	// Index is the position of the frequency in zigzag order.
+	Index int
+
+	// Step is the quantization step of the image.
+	Step int
+
+	// Samples is the number of non-zero coefficients.
+	Samples int
+
+	// PrimaryStep is the first quantization step that best explains the
+	// histogram, or zero if the frequency looks singly-compressed.
+	PrimaryStep int
+
+	// ErrorRatio is the error of the best double-compression model relative
+	// to the single-compression model. Small values favor double
+	// compression.
+	ErrorRatio float64
+}
+
+// DoubleCompressionReport is the result of AnalyzeDoubleCompression().
+type DoubleCompressionReport struct {
+	// Likely indicates that the image was probably compressed twice (with
+	// aligned blocks and different tables).
+	Likely bool
+
+	// Confidence is the fraction of the analyzed frequencies that show
+	// double compression.
+	Confidence float64
+
+	// PrimaryQuality is the estimated libjpeg quality of the first
+	// compression, or zero if double compression wasn't found.
+	PrimaryQuality int
+
+	// SecondaryQuality is the estimated libjpeg quality of the current
+	// tables.
+	SecondaryQuality int
+
+	// StandardTables indicates that the current tables are exactly the
+	// libjpeg tables for SecondaryQuality.
+	StandardTables bool
+
+	Frequencies []FrequencyAnalysis
+}
+
+func (dcr DoubleCompressionReport) String() string {
+	return fmt.Sprintf("DoubleCompressionReport<LIKELY=[%v] CONFIDENCE=(%.2f) PRIMARY-QUALITY=(%d) SECONDARY-QUALITY=(%d) STANDARD-TABLES=[%v]>", dcr.Likely, dcr.Confidence, dcr.PrimaryQuality, dcr.SecondaryQuality, dcr.StandardTables)
+}
+
+// primaryBinCounts returns, for each bin of the second quantization, the
+// number of values of the first quantization that fall into it.
+func primaryBinCounts(primaryStep, step int) []float64 {
+	counts := make([]float64, histogramBins + 1)
+
+	for m := 0; ; m++ {
+		bin := int(math.Floor(float64(m * primaryStep) / float64(step) + 0.5))
+		if bin > histogramBins {
+			break
+		}
+
+		counts[bin]++
+	}
+
+	return counts
+}
+
+// modelError fits `histogram ~ a * counts * exp(-lambda * bin)` over the
+// non-zero bins and returns the relative squared error of the best fit.
+func modelError(histogram, counts []float64) float64 {
+	energy := 0.0
+	for bin := 1; bin <= histogramBins; bin++ {
+		energy += histogram[bin] * histogram[bin]
+	}
+
+	best := math.Inf(1)
+
+	for lambda := 0.0; lambda <= 2.0; lambda += 0.01 {
+		hg := 0.0
+		gg := 0.0
+
+		for bin := 1; bin <= histogramBins; bin++ {
+			g := counts[bin] * math.Exp(-lambda * float64(bin))
+			hg += histogram[bin] * g
+			gg += g * g
+		}
+
+		if gg == 0 {
+			continue
+		}
+
+		a := hg / gg
+
+		e := 0.0
+		for bin := 1; bin <= histogramBins; bin++ {
+			d := histogram[bin] - a * counts[bin] * math.Exp(-lambda * float64(bin))
+			e += d * d
+		}
+
+		if e < best {
+			best = e
+		}
+	}
+
+	return best / energy
+}
+
+// isUniform indicates whether every non-zero bin receives the same number of
+// values, in which case the model can't be told apart from single
+// compression.
+func isUniform(counts []float64) bool {
+	for bin := 2; bin <= histogramBins; bin++ {
+		if counts[bin] != counts[1] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// analyzeFrequency compares the single- and double-compression models for
+// one frequency.
+func analyzeFrequency(histogram []float64, step int) (primaryStep int, ratio float64) {
+	singleCounts := make([]float64, histogramBins + 1)
+	for bin := range singleCounts {
+		singleCounts[bin] = 1
+	}
+
+	singleError := modelError(histogram, singleCounts)
+
+	bestError := math.Inf(1)
+
+	for candidate := 1; candidate <= maximumPrimaryStep; candidate++ {
+		if candidate == step {
+			continue
+		}
+
+		counts := primaryBinCounts(candidate, step)
+		if isUniform(counts) == true {
+			continue
+		}
+
+		e := modelError(histogram, counts)
+		if e < bestError {
+			bestError = e
+			primaryStep = candidate
+		}
+	}
+
+	if singleError == 0 {
+		return 0, 1
+	}
+
+	return primaryStep, bestError / singleError
+}
+
+// qualityFromScale inverts the libjpeg scaling of the reference tables.
+func qualityFromScale(scale float64) int {
+	quality := 0.0
+	if scale <= 100 {
+		quality = (200 - scale) / 2
+	} else {
+		quality = 5000 / scale
+	}
+
+	if quality < 1 {
+		return 1
+	} else if quality > 100 {
+		return 100
+	}
+
+	return int(quality + 0.5)
+}
+
+// lumaTable returns the quantization table used by the first component.
+func lumaTable(sl jpegstructure.SegmentList, tableId byte) jpegstructure.QuantizationTable {
+	for _, s := range sl.FindAll(jpegstructure.MARKER_DQT) {
+		tables, err := jpegstructure.ParseQuantizationTables(s.Data)
+		log.PanicIf(err)
+
+		for _, qt := range tables {
+			if qt.TableId == tableId {
+				return qt
+			}
+		}
+	}
+
+	log.Panicf("quantization table (%d) not found", tableId)
+	return jpegstructure.QuantizationTable{}
+}
+
+// AnalyzeDoubleCompression looks for the periodic gaps and peaks that a
+// second compression with different tables leaves in the histograms of the
+// luminance DCT coefficients. For each low frequency, the histogram is fit
+// both to a single compression and to a double compression with every
+// plausible first quantization step; the frequency counts as double-
+// compressed if the latter fits much better. The first quality is estimated
+// from the steps that were found.
+//
+// This only detects aligned recompression (the usual result of editing and
+// resaving) and only works on sequential, Huffman-coded images.
+func AnalyzeDoubleCompression(sl jpegstructure.SegmentList) (report *DoubleCompressionReport, err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	components, err := sl.Coefficients()
+	log.PanicIf(err)
+
+	luma := components[0]
+	qt := lumaTable(sl, luma.QuantizationTableId)
+
+	secondaryQuality := qt.EstimateQuality()
+
+	report = &DoubleCompressionReport{
+		SecondaryQuality: secondaryQuality,
+		StandardTables: jpegstructure.StandardQuantizationTable(0, secondaryQuality).Values == qt.Values,
+		Frequencies: make([]FrequencyAnalysis, 0, analyzedFrequencies),
+	}
+
+	zigzag := jpegstructure.ZigzagOrder()
+	qualities := make([]int, 0)
+
+	for k := 1; k <= analyzedFrequencies; k++ {
+		histogram := make([]float64, histogramBins + 1)
+		samples := 0
+
+		for _, block := range luma.Blocks {
+			value := block[zigzag[k]]
+			if value < 0 {
+				value = -value
+			}
+
+			if value == 0 {
+				continue
+			}
+
+			samples++
+
+			if value <= histogramBins {
+				histogram[value]++
+			}
+		}
+
+		fa := FrequencyAnalysis{
+			Index: k,
+			Step: int(qt.Values[k]),
+			Samples: samples,
+		}
+
+		if samples < minimumSamples {
+			continue
+		}
+
+		primaryStep, ratio := analyzeFrequency(histogram, fa.Step)
+		fa.ErrorRatio = ratio
+
+		if ratio < doubleErrorRatio {
+			fa.PrimaryStep = primaryStep
+
+			scale := float64(primaryStep) * 100 / float64(standardLuminance.Values[k])
+			qualities = append(qualities, qualityFromScale(scale))
+		}
+
+		report.Frequencies = append(report.Frequencies, fa)
+	}
+
+	if len(report.Frequencies) == 0 {
+		return report, nil
+	}
+
+	report.Confidence = float64(len(qualities)) / float64(len(report.Frequencies))
+	report.Likely = report.Confidence >= 0.5
+
+	if report.Likely == true {
+		sort.Ints(qualities)
+		report.PrimaryQuality = qualities[len(qualities) / 2]
+	}
+
+	return report, nil
+}
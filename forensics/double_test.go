@@ -0,0 +1,104 @@
+package forensics
+
+import (
+	"bytes"
+	"image"
+	"os"
+	"path"
+	"testing"
+
+	"image/draw"
+	"image/jpeg"
+
+	"github.com/dsoprea/go-logging"
+
+	"github.com/dsoprea/go-jpeg-structure"
+)
+
+var (
+	assetsPath = ""
+
+	testSource image.Image
+)
+
+func init() {
+	goPath := os.Getenv("GOPATH")
+	if goPath == "" {
+		log.Panicf("GOPATH is empty")
+	}
+
+	assetsPath = path.Join(goPath, "src", "github.com", "dsoprea", "go-jpeg-structure", "assets")
+}
+
+// getTestSource returns a region of a camera image. The region is offset so
+// that the camera's blocks don't line up with those of the test encodings.
+func getTestSource() image.Image {
+	if testSource != nil {
+		return testSource
+	}
+
+	sl, err := jpegstructure.ParseFileStructure(path.Join(assetsPath, "NDM_8901.jpg"))
+	log.PanicIf(err)
+
+	img, err := sl.Image()
+	log.PanicIf(err)
+
+	region := image.Rect(0, 0, 512, 512)
+	rgba := image.NewRGBA(region)
+	draw.Draw(rgba, region, img, image.Point{1603, 1005}, draw.Src)
+
+	testSource = rgba
+	return testSource
+}
+
+// encodeTestImage compresses the image at each quality in turn.
+func encodeTestImage(img image.Image, qualities ...int) jpegstructure.SegmentList {
+	var data []byte
+
+	for _, quality := range qualities {
+		b := new(bytes.Buffer)
+
+		err := jpeg.Encode(b, img, &jpeg.Options{Quality: quality})
+		log.PanicIf(err)
+
+		data = b.Bytes()
+
+		img, err = jpeg.Decode(bytes.NewReader(data))
+		log.PanicIf(err)
+	}
+
+	sl, err := jpegstructure.ParseBytesStructure(data)
+	log.PanicIf(err)
+
+	return sl
+}
+
+func TestAnalyzeDoubleCompression_Single(t *testing.T) {
+	sl := encodeTestImage(getTestSource(), 90)
+
+	report, err := AnalyzeDoubleCompression(sl)
+	log.PanicIf(err)
+
+	if report.Likely != false {
+		t.Fatalf("Double compression not expected: %s %v", report, report.Frequencies)
+	} else if report.SecondaryQuality != 90 || report.StandardTables != true {
+		t.Fatalf("Secondary quality not correct: %s", report)
+	} else if report.PrimaryQuality != 0 {
+		t.Fatalf("Primary quality not expected: %s", report)
+	}
+}
+
+func TestAnalyzeDoubleCompression_Double(t *testing.T) {
+	sl := encodeTestImage(getTestSource(), 50, 90)
+
+	report, err := AnalyzeDoubleCompression(sl)
+	log.PanicIf(err)
+
+	if report.Likely != true {
+		t.Fatalf("Double compression expected: %s %v", report, report.Frequencies)
+	} else if report.SecondaryQuality != 90 {
+		t.Fatalf("Secondary quality not correct: %s", report)
+	} else if report.PrimaryQuality < 45 || report.PrimaryQuality > 55 {
+		t.Fatalf("Primary quality not correct: %s %v", report, report.Frequencies)
+	}
+}
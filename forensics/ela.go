@@ -0,0 +1,98 @@
+package forensics
+
+import (
+	"bytes"
+	"image"
+	"math"
+
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+
+	"github.com/dsoprea/go-logging"
+
+	"github.com/dsoprea/go-jpeg-structure"
+)
+
+// Ela performs Error Level Analysis: the image is decoded, re-encoded at the
+// given quality, and decoded again, and the per-channel differences are
+// returned as an image. Regions that were already compressed at (about) that
+// quality change little and come out dark, while regions with a different
+// compression history (e.g. pasted in or retouched) stand out.
+//
+// The differences are multiplied by `scale` (and clamped). If `scale` is zero
+// or less, they are stretched so that the largest difference is white.
+func Ela(sl jpegstructure.SegmentList, quality int, scale float64) (difference *image.RGBA, err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	original, err := sl.Image()
+	log.PanicIf(err)
+
+	bounds := original.Bounds()
+
+	// Normalize to RGBA so that both images are compared in the same model.
+	before := image.NewRGBA(bounds)
+	draw.Draw(before, bounds, original, bounds.Min, draw.Src)
+
+	b := new(bytes.Buffer)
+
+	err = jpeg.Encode(b, before, &jpeg.Options{Quality: quality})
+	log.PanicIf(err)
+
+	recompressed, err := jpeg.Decode(b)
+	log.PanicIf(err)
+
+	after := image.NewRGBA(bounds)
+	draw.Draw(after, bounds, recompressed, recompressed.Bounds().Min, draw.Src)
+
+	difference = image.NewRGBA(bounds)
+
+	largest := 0
+	for i := 0; i < len(before.Pix); i++ {
+		if i % 4 == 3 {
+			continue
+		}
+
+		d := int(before.Pix[i]) - int(after.Pix[i])
+		if d < 0 {
+			d = -d
+		}
+
+		difference.Pix[i] = uint8(d)
+
+		if d > largest {
+			largest = d
+		}
+	}
+
+	if scale <= 0 {
+		scale = 1
+		if largest > 0 {
+			scale = 255 / float64(largest)
+		}
+	}
+
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			c := difference.RGBAAt(x, y)
+
+			difference.SetRGBA(x, y, color.RGBA{
+				R: scaleDifference(c.R, scale),
+				G: scaleDifference(c.G, scale),
+				B: scaleDifference(c.B, scale),
+				A: 0xff,
+			})
+		}
+	}
+
+	return difference, nil
+}
+
+// scaleDifference multiplies and clamps one channel of the difference.
+func scaleDifference(value uint8, scale float64) uint8 {
+	return uint8(math.Min(255, math.Round(float64(value) * scale)))
+}
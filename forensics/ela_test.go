@@ -0,0 +1,81 @@
+package forensics
+
+import (
+	"bytes"
+	"image"
+	"testing"
+
+	"image/draw"
+	"image/jpeg"
+
+	"github.com/dsoprea/go-logging"
+
+	"github.com/dsoprea/go-jpeg-structure"
+)
+
+// meanDifference returns the average channel value over the region.
+func meanDifference(img *image.RGBA, region image.Rectangle) float64 {
+	sum := 0
+	count := 0
+
+	for y := region.Min.Y; y < region.Max.Y; y++ {
+		for x := region.Min.X; x < region.Max.X; x++ {
+			c := img.RGBAAt(x, y)
+			sum += int(c.R) + int(c.G) + int(c.B)
+			count += 3
+		}
+	}
+
+	return float64(sum) / float64(count)
+}
+
+func TestEla(t *testing.T) {
+	source := getTestSource()
+
+	// Compress the whole image, then paste in a region that hasn't been
+	// compressed and save it again at the same quality.
+	b := new(bytes.Buffer)
+
+	err := jpeg.Encode(b, source, &jpeg.Options{Quality: 75})
+	log.PanicIf(err)
+
+	decoded, err := jpeg.Decode(b)
+	log.PanicIf(err)
+
+	bounds := decoded.Bounds()
+	composite := image.NewRGBA(bounds)
+	draw.Draw(composite, bounds, decoded, bounds.Min, draw.Src)
+
+	pasted := image.Rect(128, 128, 256, 256)
+	draw.Draw(composite, pasted, source, pasted.Min, draw.Src)
+
+	b.Reset()
+
+	err = jpeg.Encode(b, composite, &jpeg.Options{Quality: 75})
+	log.PanicIf(err)
+
+	sl, err := jpegstructure.ParseBytesStructure(b.Bytes())
+	log.PanicIf(err)
+
+	difference, err := Ela(sl, 75, 0)
+	log.PanicIf(err)
+
+	if difference.Bounds() != bounds {
+		t.Fatalf("Bounds not correct: %v", difference.Bounds())
+	}
+
+	inside := meanDifference(difference, pasted)
+	outside := meanDifference(difference, image.Rect(320, 320, 448, 448))
+
+	if inside < outside * 1.5 {
+		t.Fatalf("Pasted region doesn't stand out: (%.2f) vs (%.2f)", inside, outside)
+	}
+
+	// A fixed scale is applied as given.
+	unscaled, err := Ela(sl, 75, 1)
+	log.PanicIf(err)
+
+	if meanDifference(unscaled, pasted) > inside {
+		t.Fatalf("Stretched differences expected to be brighter.")
+	}
+}
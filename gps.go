@@ -0,0 +1,267 @@
+package jpegstructure
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"encoding/binary"
+
+	"github.com/dsoprea/go-logging"
+)
+
+const (
+	exifTagGpsVersionId = 0x0000
+	exifTagGpsLatitudeRef = 0x0001
+	exifTagGpsLatitude = 0x0002
+	exifTagGpsLongitudeRef = 0x0003
+	exifTagGpsLongitude = 0x0004
+	exifTagGpsAltitudeRef = 0x0005
+	exifTagGpsAltitude = 0x0006
+	exifTagGpsTimeStamp = 0x0007
+	exifTagGpsDateStamp = 0x001d
+)
+
+const (
+	// gpsSecondsDenominator and gpsAltitudeDenominator set the precision of
+	// the written rationals.
+	gpsSecondsDenominator = 10000
+	gpsAltitudeDenominator = 1000
+)
+
+// GpsInfo is the position (in decimal degrees) and time from the GPS IFD.
+type GpsInfo struct {
+	// Latitude is negative in the southern hemisphere and Longitude is
+	// negative in the western hemisphere.
+	Latitude, Longitude float64
+
+	// Altitude is in meters and negative below sea-level. It is NaN if not
+	// recorded.
+	Altitude float64
+
+	// Timestamp is in UTC. It is the zero time if not recorded.
+	Timestamp time.Time
+}
+
+func (gi GpsInfo) String() string {
+	return fmt.Sprintf("GpsInfo<LAT=(%.6f) LON=(%.6f) ALT=(%.2f) TIME=[%s]>", gi.Latitude, gi.Longitude, gi.Altitude, gi.Timestamp.Format(time.RFC3339))
+}
+
+// gpsRationals returns the given GPS tag as rationals.
+func gpsRationals(ed *ExifDocument, tagId uint16) []Rational {
+	ee, err := ed.Entry(EXIF_IFD_GPS, tagId)
+	log.PanicIf(err)
+
+	value, err := ed.Value(ee)
+	log.PanicIf(err)
+
+	rationals, ok := value.([]Rational)
+	if ok == false {
+		log.Panicf("GPS tag (0x%04x) is not RATIONAL", tagId)
+	}
+
+	return rationals
+}
+
+// gpsString returns the given GPS tag as a string.
+func gpsString(ed *ExifDocument, tagId uint16) string {
+	ee, err := ed.Entry(EXIF_IFD_GPS, tagId)
+	log.PanicIf(err)
+
+	value, err := ed.Value(ee)
+	log.PanicIf(err)
+
+	s, ok := value.(string)
+	if ok == false {
+		log.Panicf("GPS tag (0x%04x) is not ASCII", tagId)
+	}
+
+	return s
+}
+
+// rationalFloat returns the value of the rational (zero if the denominator
+// is zero).
+func rationalFloat(r Rational) float64 {
+	if r.Denominator == 0 {
+		return 0
+	}
+
+	return float64(r.Numerator) / float64(r.Denominator)
+}
+
+// gpsDegrees converts degrees, minutes, and seconds to decimal degrees.
+func gpsDegrees(ed *ExifDocument, tagId, refTagId uint16, negativeRef string) float64 {
+	dms := gpsRationals(ed, tagId)
+	if len(dms) != 3 {
+		log.Panicf("GPS coordinate (0x%04x) does not have three components", tagId)
+	}
+
+	degrees := rationalFloat(dms[0]) + rationalFloat(dms[1]) / 60 + rationalFloat(dms[2]) / 3600
+
+	if gpsString(ed, refTagId) == negativeRef {
+		degrees = -degrees
+	}
+
+	return degrees
+}
+
+// GpsInfo returns the position and time recorded in the GPS IFD. The latitude
+// and longitude are required; ErrExifTagNotFound is returned if either is
+// missing.
+func (sl SegmentList) GpsInfo() (gi *GpsInfo, err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	ed, err := sl.ExifDocument()
+	log.PanicIf(err)
+
+	gi = &GpsInfo{
+		Latitude: gpsDegrees(ed, exifTagGpsLatitude, exifTagGpsLatitudeRef, "S"),
+		Longitude: gpsDegrees(ed, exifTagGpsLongitude, exifTagGpsLongitudeRef, "W"),
+		Altitude: math.NaN(),
+	}
+
+	if _, err := ed.Entry(EXIF_IFD_GPS, exifTagGpsAltitude); err == nil {
+		altitude := gpsRationals(ed, exifTagGpsAltitude)
+		if len(altitude) == 1 {
+			gi.Altitude = rationalFloat(altitude[0])
+
+			ee, err := ed.Entry(EXIF_IFD_GPS, exifTagGpsAltitudeRef)
+			if err == nil && len(ee.RawValue) > 0 && ee.RawValue[0] == 1 {
+				gi.Altitude = -gi.Altitude
+			}
+		}
+	}
+
+	_, dateErr := ed.Entry(EXIF_IFD_GPS, exifTagGpsDateStamp)
+	_, timeErr := ed.Entry(EXIF_IFD_GPS, exifTagGpsTimeStamp)
+
+	if dateErr == nil && timeErr == nil {
+		date, err := time.Parse("2006:01:02", gpsString(ed, exifTagGpsDateStamp))
+		if err == nil {
+			hms := gpsRationals(ed, exifTagGpsTimeStamp)
+			if len(hms) == 3 {
+				seconds := rationalFloat(hms[0]) * 3600 + rationalFloat(hms[1]) * 60 + rationalFloat(hms[2])
+				gi.Timestamp = date.Add(time.Duration(seconds * float64(time.Second)))
+			}
+		}
+	}
+
+	return gi, nil
+}
+
+// toGpsDms converts decimal degrees to degrees, minutes, and seconds.
+func toGpsDms(degrees float64) []Rational {
+	degrees = math.Abs(degrees)
+
+	whole := math.Floor(degrees)
+	minutes := math.Floor((degrees - whole) * 60)
+	seconds := ((degrees - whole) * 60 - minutes) * 60
+
+	return []Rational{
+		{uint32(whole), 1},
+		{uint32(minutes), 1},
+		{uint32(math.Round(seconds * gpsSecondsDenominator)), gpsSecondsDenominator},
+	}
+}
+
+// SetGps records the position and time in the GPS IFD (creating the EXIF
+// segment and the IFD as necessary). Pass NaN for the altitude to leave it
+// out and the zero time to leave the timestamp out; either is then removed if
+// it was previously recorded. Other GPS tags are kept.
+func (sl *SegmentList) SetGps(latitude, longitude, altitude float64, timestamp time.Time) (err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	if latitude < -90 || latitude > 90 {
+		log.Panicf("latitude out of range: (%f)", latitude)
+	} else if longitude < -180 || longitude > 180 {
+		log.Panicf("longitude out of range: (%f)", longitude)
+	}
+
+	ed, err := sl.ExifDocument()
+	if err != nil {
+		if log.Is(err, ErrSegmentNotFound) == false {
+			log.Panic(err)
+		}
+
+		ed = NewExifDocument(binary.BigEndian)
+	}
+
+	latitudeRef := "N"
+	if latitude < 0 {
+		latitudeRef = "S"
+	}
+
+	longitudeRef := "E"
+	if longitude < 0 {
+		longitudeRef = "W"
+	}
+
+	err = ed.SetValue(EXIF_IFD_GPS, exifTagGpsVersionId, EXIF_TYPE_BYTE, []byte{2, 3, 0, 0})
+	log.PanicIf(err)
+
+	err = ed.SetValue(EXIF_IFD_GPS, exifTagGpsLatitudeRef, EXIF_TYPE_ASCII, latitudeRef)
+	log.PanicIf(err)
+
+	err = ed.SetValue(EXIF_IFD_GPS, exifTagGpsLatitude, EXIF_TYPE_RATIONAL, toGpsDms(latitude))
+	log.PanicIf(err)
+
+	err = ed.SetValue(EXIF_IFD_GPS, exifTagGpsLongitudeRef, EXIF_TYPE_ASCII, longitudeRef)
+	log.PanicIf(err)
+
+	err = ed.SetValue(EXIF_IFD_GPS, exifTagGpsLongitude, EXIF_TYPE_RATIONAL, toGpsDms(longitude))
+	log.PanicIf(err)
+
+	gpsIfd := ed.Ifd(EXIF_IFD_GPS)
+
+	if math.IsNaN(altitude) == true {
+		gpsIfd.DeleteEntry(exifTagGpsAltitudeRef)
+		gpsIfd.DeleteEntry(exifTagGpsAltitude)
+	} else {
+		altitudeRef := byte(0)
+		if altitude < 0 {
+			altitudeRef = 1
+		}
+
+		err = ed.SetValue(EXIF_IFD_GPS, exifTagGpsAltitudeRef, EXIF_TYPE_BYTE, []byte{altitudeRef})
+		log.PanicIf(err)
+
+		altitudeRational := Rational{uint32(math.Round(math.Abs(altitude) * gpsAltitudeDenominator)), gpsAltitudeDenominator}
+
+		err = ed.SetValue(EXIF_IFD_GPS, exifTagGpsAltitude, EXIF_TYPE_RATIONAL, []Rational{altitudeRational})
+		log.PanicIf(err)
+	}
+
+	if timestamp.IsZero() == true {
+		gpsIfd.DeleteEntry(exifTagGpsTimeStamp)
+		gpsIfd.DeleteEntry(exifTagGpsDateStamp)
+	} else {
+		utc := timestamp.UTC()
+
+		seconds := float64(utc.Second()) + float64(utc.Nanosecond()) / float64(time.Second)
+
+		hms := []Rational{
+			{uint32(utc.Hour()), 1},
+			{uint32(utc.Minute()), 1},
+			{uint32(math.Round(seconds * 1000)), 1000},
+		}
+
+		err = ed.SetValue(EXIF_IFD_GPS, exifTagGpsTimeStamp, EXIF_TYPE_RATIONAL, hms)
+		log.PanicIf(err)
+
+		err = ed.SetValue(EXIF_IFD_GPS, exifTagGpsDateStamp, EXIF_TYPE_ASCII, utc.Format("2006:01:02"))
+		log.PanicIf(err)
+	}
+
+	err = sl.SetExifDocument(ed)
+	log.PanicIf(err)
+
+	return nil
+}
@@ -0,0 +1,70 @@
+package jpegstructure
+
+import (
+	"math"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/dsoprea/go-logging"
+)
+
+func TestSegmentList_GpsInfo(t *testing.T) {
+	filepath := path.Join(assetsPath, "20180428_212314.jpg")
+
+	sl, err := ParseFileStructure(filepath)
+	log.PanicIf(err)
+
+	gi, err := sl.GpsInfo()
+	log.PanicIf(err)
+
+	if gi.Latitude == 0 || gi.Longitude == 0 || math.Abs(gi.Latitude) > 90 || math.Abs(gi.Longitude) > 180 {
+		t.Fatalf("Position not correct: %s", gi)
+	} else if gi.Timestamp.Year() != 2018 {
+		t.Fatalf("Timestamp not correct: %s", gi)
+	}
+}
+
+func TestSegmentList_SetGps(t *testing.T) {
+	filepath := path.Join(assetsPath, testImageRelFilepath)
+
+	sl, err := ParseFileStructure(filepath)
+	log.PanicIf(err)
+
+	_, err = sl.GpsInfo()
+	if log.Is(err, ErrExifTagNotFound) == false {
+		t.Fatalf("Expected tag-not-found error: %v", err)
+	}
+
+	timestamp := time.Date(2020, 7, 4, 18, 30, 15, 500000000, time.UTC)
+
+	err = sl.SetGps(-33.856784, 151.215297, -12.5, timestamp)
+	log.PanicIf(err)
+
+	gi, err := sl.GpsInfo()
+	log.PanicIf(err)
+
+	if math.Abs(gi.Latitude - -33.856784) > 0.000001 || math.Abs(gi.Longitude - 151.215297) > 0.000001 {
+		t.Fatalf("Position not correct: %s", gi)
+	} else if gi.Altitude != -12.5 {
+		t.Fatalf("Altitude not correct: %s", gi)
+	} else if gi.Timestamp.Equal(timestamp) == false {
+		t.Fatalf("Timestamp not correct: %s", gi)
+	}
+
+	// Leave the altitude and time out.
+	err = sl.SetGps(40.689247, -74.044502, math.NaN(), time.Time{})
+	log.PanicIf(err)
+
+	gi, err = sl.GpsInfo()
+	log.PanicIf(err)
+
+	if math.IsNaN(gi.Altitude) == false || gi.Timestamp.IsZero() == false || gi.Longitude > 0 {
+		t.Fatalf("GPS not correct: %s", gi)
+	}
+
+	err = sl.SetGps(91, 0, 0, time.Time{})
+	if err == nil {
+		t.Fatalf("Expected error for out-of-range latitude.")
+	}
+}
@@ -5,10 +5,53 @@ import (
     "io"
     "bufio"
     "bytes"
+    "math"
 
     "github.com/dsoprea/go-logging"
 )
 
+// ParseOptions controls what is recorded while parsing.
+type ParseOptions struct {
+    // ComputeDigests stores the SHA-256 of each payload in the segment (see
+    // SegmentList.Checksums).
+    ComputeDigests bool
+
+    // Progress, if set, is called after each segment is parsed. Returning an
+    // error aborts the parse with that error (e.g. to implement a timeout or
+    // cancellation).
+    Progress func(progress ParseProgress) error
+
+    // Lenient tolerates common problems in real-world files rather than
+    // failing: zero padding between segments, data after the EOI, a missing
+    // EOI, repeated JFIF segments, and non-standard EXIF prefixes (which are
+    // replaced with the standard one, so the segment won't be written back
+    // byte-for-byte). Each is recorded as a warning on the segment that
+    // follows it (see Segment.Warnings).
+    Lenient bool
+
+    // Logger receives the parser's diagnostics (see JpegSplitter.SetLogger).
+    Logger Logger
+
+    // MarkerFilter, if set, keeps only the segments with these markers (see
+    // JpegSplitter.SetMarkerFilter).
+    MarkerFilter []byte
+
+    // Detect controls which files are accepted as JPEGs (see
+    // DetectFormatWithOptions).
+    Detect DetectOptions
+}
+
+// ParseProgress describes how far parsing has gotten.
+type ParseProgress struct {
+    // BytesConsumed is the number of bytes of the stream that have been
+    // parsed into segments.
+    BytesConsumed int
+
+    // SegmentCount is the number of segments parsed so far (including the
+    // scan-data).
+    SegmentCount int
+}
+
 func ParseSegments(r io.Reader, size int) (sl SegmentList, err error) {
     defer func() {
         if state := recover(); state != nil {
@@ -16,6 +59,21 @@ func ParseSegments(r io.Reader, size int) (sl SegmentList, err error) {
         }
     }()
 
+    sl, err = ParseSegmentsWithOptions(r, size, ParseOptions{})
+    log.PanicIf(err)
+
+    return sl, nil
+}
+
+// ParseSegmentsWithOptions is ParseSegments with control over what is
+// recorded.
+func ParseSegmentsWithOptions(r io.Reader, size int, options ParseOptions) (sl SegmentList, err error) {
+    defer func() {
+        if state := recover(); state != nil {
+            err = log.Wrap(state.(error))
+        }
+    }()
+
     s := bufio.NewScanner(r)
 
     // Since each segment can be any size, our buffer must allowed to grow as
@@ -23,7 +81,7 @@ func ParseSegments(r io.Reader, size int) (sl SegmentList, err error) {
     buffer := []byte {}
     s.Buffer(buffer, size)
 
-    js := NewJpegSplitter(nil)
+    js := NewJpegSplitterWithOptions(nil, options)
     s.Split(js.Split)
 
     for ; s.Scan() != false; { }
@@ -39,6 +97,21 @@ func ParseFileStructure(filepath string) (sl SegmentList, err error) {
         }
     }()
 
+    sl, err = ParseFileStructureWithOptions(filepath, ParseOptions{})
+    log.PanicIf(err)
+
+    return sl, nil
+}
+
+// ParseFileStructureWithOptions is ParseFileStructure with control over what
+// is recorded.
+func ParseFileStructureWithOptions(filepath string, options ParseOptions) (sl SegmentList, err error) {
+    defer func() {
+        if state := recover(); state != nil {
+            err = log.Wrap(state.(error))
+        }
+    }()
+
     f, err := os.Open(filepath)
     log.PanicIf(err)
 
@@ -47,7 +120,7 @@ func ParseFileStructure(filepath string) (sl SegmentList, err error) {
 
     size := stat.Size()
 
-    sl, err = ParseSegments(f, int(size))
+    sl, err = ParseSegmentsWithOptions(f, int(size), options)
     log.PanicIf(err)
 
     return sl, nil
@@ -60,10 +133,75 @@ func ParseBytesStructure(data []byte) (sl SegmentList, err error) {
         }
     }()
 
+    sl, err = ParseBytesStructureWithOptions(data, ParseOptions{})
+    log.PanicIf(err)
+
+    return sl, nil
+}
+
+// ParseBytesStructureWithOptions is ParseBytesStructure with control over
+// what is recorded.
+func ParseBytesStructureWithOptions(data []byte, options ParseOptions) (sl SegmentList, err error) {
+    defer func() {
+        if state := recover(); state != nil {
+            err = log.Wrap(state.(error))
+        }
+    }()
+
     b := bytes.NewBuffer(data)
 
-    sl, err = ParseSegments(b, len(data))
+    sl, err = ParseSegmentsWithOptions(b, len(data), options)
     log.PanicIf(err)
 
     return sl, nil
 }
+
+// ParseAt parses a JPEG embedded in a larger file (e.g. the preview in a RAW
+// file or an image in a PDF) without copying it out first. The image starts
+// at `offset` and is at most `limit` bytes long (zero or less reads to the
+// end of the reader). Parsing stops at the image's EOI, so the limit may
+// include whatever follows it. The segment offsets are relative to the start
+// of the image.
+func ParseAt(r io.ReaderAt, offset, limit int64) (sl SegmentList, err error) {
+    defer func() {
+        if state := recover(); state != nil {
+            err = log.Wrap(state.(error))
+        }
+    }()
+
+    if limit <= 0 || limit > math.MaxInt64 - offset {
+        limit = math.MaxInt64 - offset
+    }
+
+    size := limit
+    if size > math.MaxInt32 {
+        size = math.MaxInt32
+    }
+
+    sr := io.NewSectionReader(r, offset, limit)
+    s := bufio.NewScanner(sr)
+
+    buffer := []byte {}
+    s.Buffer(buffer, int(size))
+
+    js := NewJpegSplitter(nil)
+
+    s.Split(func(data []byte, atEOF bool) (advance int, token []byte, err error) {
+        advance, token, err = js.Split(data, atEOF)
+        if err == nil && advance > 0 && js.MarkerId() == MARKER_EOI {
+            return advance, token, bufio.ErrFinalToken
+        }
+
+        return advance, token, err
+    })
+
+    for ; s.Scan() != false; { }
+    log.PanicIf(s.Err())
+
+    sl = js.Segments()
+    if len(sl) == 0 || sl[len(sl) - 1].MarkerId != MARKER_EOI {
+        log.Panicf("embedded image truncated")
+    }
+
+    return sl, nil
+}
@@ -4,6 +4,9 @@ import (
     "testing"
     "os"
     "path"
+    "errors"
+    "bytes"
+    "bufio"
 
     "io/ioutil"
 
@@ -235,3 +238,207 @@ func TestParseBytesStructure_Offsets_Error(t *testing.T) {
         log.Panic(err)
     }
 }
+
+func TestParseBytesStructure_Extents(t *testing.T) {
+    filepath := path.Join(assetsPath, testImageRelFilepath)
+
+    data, err := ioutil.ReadFile(filepath)
+    log.PanicIf(err)
+
+    sl, err := ParseBytesStructure(data)
+    log.PanicIf(err)
+
+    if sl[1].HeaderSize != 4 || sl[1].MarkerLength != 32944 || sl[1].TotalSize != 32946 {
+        t.Fatalf("APP1 extent not correct: (%d) (%d) (%d)", sl[1].HeaderSize, sl[1].MarkerLength, sl[1].TotalSize)
+    }
+
+    for i, s := range sl[:len(sl) - 1] {
+        if s.EndOffset() != sl[i + 1].Offset {
+            t.Fatalf("Segment (%d) does not end where the next begins: (0x%08x) != (0x%08x)", i, s.EndOffset(), sl[i + 1].Offset)
+        }
+    }
+
+    if sl[len(sl) - 1].EndOffset() != len(data) {
+        t.Fatalf("Last segment does not end at the end of the data.")
+    }
+}
+
+func TestParseBytesStructure_FillBytes(t *testing.T) {
+    filepath := path.Join(assetsPath, testImageRelFilepath)
+
+    original, err := ioutil.ReadFile(filepath)
+    log.PanicIf(err)
+
+    // Insert three fill bytes before the DQT marker.
+    data := make([]byte, 0, len(original) + 3)
+    data = append(data, original[:0x8ab6]...)
+    data = append(data, 0xff, 0xff, 0xff)
+    data = append(data, original[0x8ab6:]...)
+
+    sl, err := ParseBytesStructure(data)
+    log.PanicIf(err)
+
+    if sl[3].Offset != 0x8ab6 + 3 {
+        t.Fatalf("DQT offset does not account for fill bytes: (0x%08x)", sl[3].Offset)
+    }
+
+    err = sl.Validate(data)
+    log.PanicIf(err)
+}
+
+func TestParseSegmentsWithOptions_Progress(t *testing.T) {
+    filepath := path.Join(assetsPath, testImageRelFilepath)
+
+    reports := make([]ParseProgress, 0)
+
+    options := ParseOptions{
+        Progress: func(progress ParseProgress) error {
+            reports = append(reports, progress)
+            return nil
+        },
+    }
+
+    sl, err := ParseFileStructureWithOptions(filepath, options)
+    log.PanicIf(err)
+
+    if len(reports) != len(sl) {
+        t.Fatalf("Progress not reported for every segment: (%d) != (%d)", len(reports), len(sl))
+    }
+
+    for i, progress := range reports {
+        if progress.SegmentCount != i + 1 {
+            t.Fatalf("Segment count not correct: (%d) != (%d)", progress.SegmentCount, i + 1)
+        } else if progress.BytesConsumed != sl[i].EndOffset() {
+            t.Fatalf("Bytes consumed not correct for segment (%d): (%d) != (%d)", i, progress.BytesConsumed, sl[i].EndOffset())
+        }
+    }
+
+    stat, err := os.Stat(filepath)
+    log.PanicIf(err)
+
+    if int64(reports[len(reports) - 1].BytesConsumed) != stat.Size() {
+        t.Fatalf("Final progress not at the end of the file.")
+    }
+}
+
+func TestParseSegmentsWithOptions_ProgressAbort(t *testing.T) {
+    filepath := path.Join(assetsPath, testImageRelFilepath)
+
+    abortErr := errors.New("aborted")
+
+    count := 0
+    options := ParseOptions{
+        Progress: func(progress ParseProgress) error {
+            count++
+            if progress.SegmentCount == 3 {
+                return abortErr
+            }
+
+            return nil
+        },
+    }
+
+    _, err := ParseFileStructureWithOptions(filepath, options)
+    if err == nil {
+        t.Fatalf("Expected error.")
+    } else if log.Is(err, abortErr) == false {
+        t.Fatalf("Error not correct: %v", err)
+    } else if count != 3 {
+        t.Fatalf("Parsing not aborted: (%d) reports", count)
+    }
+}
+
+func TestParseAt(t *testing.T) {
+    filepath := path.Join(assetsPath, testImageRelFilepath)
+
+    data, err := ioutil.ReadFile(filepath)
+    log.PanicIf(err)
+
+    expected, err := ParseBytesStructure(data)
+    log.PanicIf(err)
+
+    // Surround the image with other bytes, as in a container.
+    container := append([]byte("container header"), data...)
+    container = append(container, []byte("container footer")...)
+
+    offset := int64(len("container header"))
+
+    for _, limit := range []int64 { int64(len(data)), int64(len(data)) + 10, 0 } {
+        sl, err := ParseAt(bytes.NewReader(container), offset, limit)
+        log.PanicIf(err)
+
+        if len(sl) != len(expected) {
+            t.Fatalf("Segment count not correct with limit (%d): (%d) != (%d)", limit, len(sl), len(expected))
+        }
+
+        for i, s := range sl {
+            if s.MarkerId != expected[i].MarkerId || s.Offset != expected[i].Offset || bytes.Equal(s.Data, expected[i].Data) == false {
+                t.Fatalf("Segment (%d) not correct with limit (%d).", i, limit)
+            }
+        }
+    }
+
+    _, err = ParseAt(bytes.NewReader(container), offset, int64(len(data)) - 100)
+    if err == nil {
+        t.Fatalf("Expected error for a truncated image.")
+    }
+}
+
+func TestParseSegmentsWithOptions_MarkerFilter(t *testing.T) {
+    filepath := path.Join(assetsPath, testImageRelFilepath)
+
+    all, err := ParseFileStructure(filepath)
+    log.PanicIf(err)
+
+    reports := 0
+
+    options := ParseOptions{
+        MarkerFilter: []byte{MARKER_APP1, MARKER_SOF0},
+        Progress: func(progress ParseProgress) error {
+            reports++
+            return nil
+        },
+    }
+
+    sl, err := ParseFileStructureWithOptions(filepath, options)
+    log.PanicIf(err)
+
+    expected := make(SegmentList, 0)
+    for _, s := range all {
+        if s.MarkerId == MARKER_APP1 || s.MarkerId == MARKER_SOF0 {
+            expected = append(expected, s)
+        }
+    }
+
+    if len(expected) < 3 {
+        t.Fatalf("Test image doesn't have the expected segments.")
+    } else if len(sl) != len(expected) {
+        t.Fatalf("Filtered segment count not correct: (%d) != (%d)", len(sl), len(expected))
+    } else if reports != len(all) {
+        t.Fatalf("Progress not reported for every segment: (%d) != (%d)", reports, len(all))
+    }
+
+    for i, s := range sl {
+        if s.MarkerId != expected[i].MarkerId || s.Offset != expected[i].Offset || bytes.Equal(s.Data, expected[i].Data) == false {
+            t.Fatalf("Filtered segment (%d) not correct: %v != %v", i, s, expected[i])
+        }
+    }
+
+    // Clearing the filter keeps everything again.
+    js := NewJpegSplitterWithOptions(nil, options)
+    js.SetMarkerFilter()
+
+    data, err := ioutil.ReadFile(filepath)
+    log.PanicIf(err)
+
+    s := bufio.NewScanner(bytes.NewReader(data))
+    s.Buffer([]byte{}, len(data))
+    s.Split(js.Split)
+
+    for ; s.Scan() != false; { }
+    log.PanicIf(s.Err())
+
+    if len(js.Segments()) != len(all) {
+        t.Fatalf("Unfiltered segment count not correct: (%d) != (%d)", len(js.Segments()), len(all))
+    }
+}
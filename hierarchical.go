@@ -0,0 +1,152 @@
+package jpegstructure
+
+import (
+	"fmt"
+
+	"github.com/dsoprea/go-logging"
+)
+
+// In hierarchical mode, the image is coded as a sequence of frames of
+// increasing resolution. A DHP segment (which has the same layout as a frame
+// header) declares the final dimensions and components. Each subsequent frame
+// may be preceded by an EXP segment that doubles the reference image before
+// the frame's differences are applied.
+
+const (
+	// expPayloadSize is the size of an EXP payload.
+	expPayloadSize = 1
+)
+
+// Frame is one frame header and the scans that follow it.
+type Frame struct {
+	// SegmentIndex is the position of the frame header.
+	SegmentIndex int
+
+	MarkerId byte
+	Sof *SofSegment
+	Components []SofComponent
+
+	// ExpandHorizontally and ExpandVertically come from an EXP segment that
+	// preceded the frame (hierarchical mode only).
+	ExpandHorizontally, ExpandVertically bool
+
+	// ScanIndices are the positions of the frame's SOS segments.
+	ScanIndices []int
+}
+
+func (f Frame) String() string {
+	return fmt.Sprintf("Frame<INDEX=(%d) MARKER=[%s] WIDTH=(%d) HEIGHT=(%d) SCANS=(%d)>", f.SegmentIndex, markerNames[f.MarkerId], f.Sof.Width, f.Sof.Height, len(f.ScanIndices))
+}
+
+// ParseExp parses the payload of an EXP segment.
+func ParseExp(data []byte) (horizontal, vertical bool, err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	if len(data) != expPayloadSize {
+		log.Panicf("EXP payload not the right size: (%d)", len(data))
+	}
+
+	eh := data[0] >> 4
+	ev := data[0] & 0x0f
+
+	if eh > 1 || ev > 1 {
+		log.Panicf("EXP expansion not valid: (%d) (%d)", eh, ev)
+	}
+
+	return eh == 1, ev == 1, nil
+}
+
+// EncodeExp produces an EXP payload.
+func EncodeExp(horizontal, vertical bool) []byte {
+	b := byte(0)
+	if horizontal == true {
+		b |= 0x10
+	}
+
+	if vertical == true {
+		b |= 0x01
+	}
+
+	return []byte{b}
+}
+
+// IsHierarchical indicates whether the image is coded in hierarchical mode
+// (has a DHP segment).
+func (sl SegmentList) IsHierarchical() bool {
+	return sl.Index(MARKER_DHP) != -1
+}
+
+// Dhp returns the header from the DHP segment of a hierarchical image.
+func (sl SegmentList) Dhp() (dhp *SofSegment, err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	s, err := sl.FindFirst(MARKER_DHP)
+	if err != nil {
+		return nil, err
+	}
+
+	dhp, err = new(JpegSplitter).parseSof(s.Data)
+	log.PanicIf(err)
+
+	return dhp, nil
+}
+
+// Frames returns every frame along with its scans. Non-hierarchical images
+// have exactly one.
+func (sl SegmentList) Frames() (frames []Frame, err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	frames = make([]Frame, 0)
+
+	expandHorizontally := false
+	expandVertically := false
+
+	for i, s := range sl {
+		if s.MarkerId == MARKER_EXP {
+			expandHorizontally, expandVertically, err = ParseExp(s.Data)
+			log.PanicIf(err)
+		} else if IsSofMarker(s.MarkerId) == true {
+			sof, err := new(JpegSplitter).parseSof(s.Data)
+			log.PanicIf(err)
+
+			components, err := ParseSofComponents(s.Data)
+			log.PanicIf(err)
+
+			f := Frame{
+				SegmentIndex: i,
+				MarkerId: s.MarkerId,
+				Sof: sof,
+				Components: components,
+				ExpandHorizontally: expandHorizontally,
+				ExpandVertically: expandVertically,
+				ScanIndices: make([]int, 0),
+			}
+
+			frames = append(frames, f)
+
+			expandHorizontally = false
+			expandVertically = false
+		} else if s.MarkerId == MARKER_SOS {
+			if len(frames) == 0 {
+				log.Panicf("scan appears before any frame header: (%d)", i)
+			}
+
+			f := &frames[len(frames) - 1]
+			f.ScanIndices = append(f.ScanIndices, i)
+		}
+	}
+
+	return frames, nil
+}
@@ -0,0 +1,115 @@
+package jpegstructure
+
+import (
+	"bytes"
+	"path"
+	"testing"
+
+	"github.com/dsoprea/go-logging"
+)
+
+func getHierarchicalTestImage() []byte {
+	components := []SofComponent{
+		{ComponentId: 1, HorizontalSamplingFactor: 1, VerticalSamplingFactor: 1},
+	}
+
+	header := SosHeader{
+		Components: []SosComponent{
+			{ComponentId: 1},
+		},
+		SpectralEnd: 63,
+	}
+
+	segment := func(markerId byte, data []byte) Segment {
+		return Segment{
+			MarkerId: markerId,
+			MarkerName: markerNames[markerId],
+			Data: data,
+		}
+	}
+
+	sl := SegmentList{
+		segment(MARKER_SOI, []byte{}),
+		segment(MARKER_DQT, EncodeQuantizationTables([]QuantizationTable{{Values: standardLuminanceQuantTable}})),
+		segment(MARKER_DHP, EncodeSof(SofSegment{BitsPerSample: 8, Width: 32, Height: 16}, components)),
+		segment(MARKER_SOF0, EncodeSof(SofSegment{BitsPerSample: 8, Width: 16, Height: 8}, components)),
+		segment(MARKER_SOS, []byte{}),
+		segment(0x0, joinScanData(header.Encode(), []byte{0x11, 0x22})),
+		segment(MARKER_EXP, EncodeExp(true, true)),
+		segment(MARKER_SOF5, EncodeSof(SofSegment{BitsPerSample: 8, Width: 32, Height: 16}, components)),
+		segment(MARKER_SOS, []byte{}),
+		segment(0x0, joinScanData(header.Encode(), []byte{0x33, 0x44})),
+		segment(MARKER_EOI, []byte{}),
+	}
+
+	b := new(bytes.Buffer)
+
+	err := sl.Write(b)
+	log.PanicIf(err)
+
+	return b.Bytes()
+}
+
+func TestSegmentList_Frames_Hierarchical(t *testing.T) {
+	data := getHierarchicalTestImage()
+
+	sl, err := ParseBytesStructure(data)
+	log.PanicIf(err)
+
+	if len(sl) != 11 {
+		t.Fatalf("Segment count not correct: (%d)", len(sl))
+	} else if sl.IsHierarchical() != true {
+		t.Fatalf("Expected hierarchical image.")
+	}
+
+	frames, err := sl.Frames()
+	log.PanicIf(err)
+
+	if len(frames) != 2 {
+		t.Fatalf("Frame count not correct: (%d)", len(frames))
+	}
+
+	first := frames[0]
+	if first.MarkerId != MARKER_SOF0 || first.Sof.Width != 16 || first.ExpandHorizontally != false || len(first.ScanIndices) != 1 || first.ScanIndices[0] != 4 {
+		t.Fatalf("First frame not correct: %s", first)
+	}
+
+	second := frames[1]
+	if second.MarkerId != MARKER_SOF5 || second.Sof.Width != 32 || second.ExpandHorizontally != true || second.ExpandVertically != true || len(second.ScanIndices) != 1 || second.ScanIndices[0] != 8 {
+		t.Fatalf("Second frame not correct: %s", second)
+	}
+
+	width, height, err := sl.Dimensions()
+	log.PanicIf(err)
+
+	if width != 32 || height != 16 {
+		t.Fatalf("Dimensions not correct: (%d)x(%d)", width, height)
+	}
+
+	findings, err := sl.Lint(data, LintLevelDecoding)
+	log.PanicIf(err)
+
+	for _, lf := range findings {
+		if lf.Code == "sof-count" {
+			t.Fatalf("Multiple frames not expected to be reported: %s", lf)
+		}
+	}
+}
+
+func TestSegmentList_Frames_Single(t *testing.T) {
+	filepath := path.Join(assetsPath, testImageRelFilepath)
+
+	sl, err := ParseFileStructure(filepath)
+	log.PanicIf(err)
+
+	if sl.IsHierarchical() != false {
+		t.Fatalf("Expected non-hierarchical image.")
+	}
+
+	frames, err := sl.Frames()
+	log.PanicIf(err)
+
+	if len(frames) != 1 || len(frames[0].ScanIndices) != 1 {
+		t.Fatalf("Frames not correct: %v", frames)
+	}
+}
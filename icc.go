@@ -0,0 +1,70 @@
+package jpegstructure
+
+import (
+	"bytes"
+
+	"github.com/dsoprea/go-logging"
+)
+
+var (
+	iccPrefix = []byte("ICC_PROFILE\x00")
+)
+
+const (
+	// iccHeaderSize is the prefix plus the chunk-sequence and chunk-count
+	// bytes.
+	iccHeaderSize = 12 + 2
+)
+
+// isIccPayload indicates whether an APP2 payload is an ICC-profile chunk.
+func isIccPayload(data []byte) bool {
+	return bytes.HasPrefix(data, iccPrefix) == true && len(data) >= iccHeaderSize
+}
+
+// IccProfile reassembles the ICC profile from its APP2 chunks.
+func (sl SegmentList) IccProfile() (profile []byte, err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	var chunks [][]byte
+
+	for _, s := range sl {
+		if s.MarkerId != MARKER_APP2 || isIccPayload(s.Data) == false {
+			continue
+		}
+
+		sequence := int(s.Data[len(iccPrefix)])
+		count := int(s.Data[len(iccPrefix) + 1])
+
+		if chunks == nil {
+			chunks = make([][]byte, count)
+		} else if count != len(chunks) {
+			log.Panicf("ICC chunk-count not consistent: (%d) != (%d)", count, len(chunks))
+		}
+
+		// The sequence numbers are one-based.
+		if sequence < 1 || sequence > count {
+			log.Panicf("ICC chunk sequence out of range: (%d) of (%d)", sequence, count)
+		} else if chunks[sequence - 1] != nil {
+			log.Panicf("ICC chunk repeated: (%d)", sequence)
+		}
+
+		chunks[sequence - 1] = s.Data[iccHeaderSize:]
+	}
+
+	if chunks == nil {
+		log.Panic(ErrSegmentNotFound)
+	}
+
+	for i, chunk := range chunks {
+		if chunk == nil {
+			log.Panicf("ICC chunk missing: (%d) of (%d)", i + 1, len(chunks))
+		}
+	}
+
+	profile = bytes.Join(chunks, nil)
+	return profile, nil
+}
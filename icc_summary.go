@@ -0,0 +1,462 @@
+package jpegstructure
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"strings"
+	"unicode/utf16"
+
+	"encoding/binary"
+
+	"github.com/dsoprea/go-logging"
+)
+
+const (
+	iccProfileHeaderSize = 128
+	iccTagEntrySize = 12
+
+	// iccColorantTolerance is the largest difference in the XYZ of a primary
+	// that still counts as sRGB.
+	iccColorantTolerance = 0.01
+
+	// iccTrcTolerance is the largest difference in the (normalized) output
+	// of a tone curve that still counts as sRGB. A pure 2.2 gamma is within
+	// it.
+	iccTrcTolerance = 0.01
+
+	exifTagColorSpace = 0xa001
+	exifColorSpaceUncalibrated = 0xffff
+)
+
+const (
+	IMPLIED_COLOR_SPACE_SRGB = "sRGB"
+	IMPLIED_COLOR_SPACE_ADOBE_RGB = "AdobeRGB"
+	IMPLIED_COLOR_SPACE_UNCALIBRATED = "uncalibrated"
+	IMPLIED_COLOR_SPACE_GRAY = "gray"
+	IMPLIED_COLOR_SPACE_CMYK = "CMYK"
+	IMPLIED_COLOR_SPACE_YCCK = "YCCK"
+)
+
+var (
+	iccProfileSignature = []byte("acsp")
+
+	// srgbColorants are the (D50-adapted) XYZ values of the sRGB primaries,
+	// as found in the rXYZ, gXYZ, and bXYZ tags of sRGB profiles.
+	srgbColorants = map[string][3]float64{
+		"rXYZ": {0.4361, 0.2225, 0.0139},
+		"gXYZ": {0.3851, 0.7169, 0.0971},
+		"bXYZ": {0.1431, 0.0606, 0.7141},
+	}
+
+	iccTrcTags = []string{"rTRC", "gTRC", "bTRC"}
+)
+
+// IccSummary is the part of an ICC profile that matters when deciding what
+// to do with it.
+type IccSummary struct {
+	// Size is the size of the profile in bytes.
+	Size int
+
+	// Version is "major.minor.bugfix".
+	Version string
+
+	// DeviceClass, ColorSpace, and ConnectionSpace are the header signatures
+	// with trailing spaces removed (e.g. "mntr", "RGB", and "XYZ").
+	DeviceClass string
+	ColorSpace string
+	ConnectionSpace string
+
+	// Description is the text of the 'desc' tag, if any.
+	Description string
+
+	// SrgbEquivalent indicates that the profile describes sRGB (its primaries
+	// and tone curves match, or, for profiles without them, it is named
+	// sRGB), so removing it doesn't change how the image displays.
+	SrgbEquivalent bool
+}
+
+func (is IccSummary) String() string {
+	return fmt.Sprintf("IccSummary<SIZE=(%d) VERSION=[%s] CLASS=[%s] SPACE=[%s] PCS=[%s] DESCRIPTION=[%s] SRGB=[%v]>", is.Size, is.Version, is.DeviceClass, is.ColorSpace, is.ConnectionSpace, is.Description, is.SrgbEquivalent)
+}
+
+// iccProfile gives access to the tags of a profile.
+type iccProfile struct {
+	data []byte
+	tags map[string][]byte
+}
+
+func parseIccProfile(data []byte) *iccProfile {
+	if len(data) < iccProfileHeaderSize + 4 {
+		log.Panicf("ICC profile too short: (%d)", len(data))
+	} else if bytes.Equal(data[36:40], iccProfileSignature) == false {
+		log.Panicf("ICC profile signature not found")
+	}
+
+	ip := &iccProfile{
+		data: data,
+		tags: make(map[string][]byte),
+	}
+
+	count := int(binary.BigEndian.Uint32(data[iccProfileHeaderSize:]))
+	if iccProfileHeaderSize + 4 + count * iccTagEntrySize > len(data) {
+		log.Panicf("ICC tag table out of bounds: (%d)", count)
+	}
+
+	for i := 0; i < count; i++ {
+		entry := data[iccProfileHeaderSize + 4 + i * iccTagEntrySize:]
+
+		signature := string(entry[0:4])
+		offset := int64(binary.BigEndian.Uint32(entry[4:8]))
+		size := int64(binary.BigEndian.Uint32(entry[8:12]))
+
+		if offset + size > int64(len(data)) {
+			log.Panicf("ICC tag [%s] out of bounds", signature)
+		}
+
+		ip.tags[signature] = data[offset:offset + size]
+	}
+
+	return ip
+}
+
+// signature returns a four-byte header field without its padding.
+func (ip *iccProfile) signature(offset int) string {
+	return strings.TrimRight(string(ip.data[offset:offset + 4]), " \x00")
+}
+
+// s15Fixed16 decodes a signed 15.16 fixed-point number.
+func s15Fixed16(raw []byte) float64 {
+	return float64(int32(binary.BigEndian.Uint32(raw))) / 65536
+}
+
+// description decodes the 'desc' tag ('desc' or 'mluc' type).
+func (ip *iccProfile) description() string {
+	tag := ip.tags["desc"]
+	if len(tag) < 12 {
+		return ""
+	}
+
+	switch string(tag[0:4]) {
+	case "desc":
+		count := int(binary.BigEndian.Uint32(tag[8:12]))
+		if 12 + count > len(tag) {
+			return ""
+		}
+
+		return string(bytes.TrimRight(tag[12:12 + count], "\x00"))
+	case "mluc":
+		if len(tag) < 16 + 12 {
+			return ""
+		}
+
+		// Take the first record.
+		record := tag[16:]
+		length := int(binary.BigEndian.Uint32(record[4:8]))
+		offset := int(binary.BigEndian.Uint32(record[8:12]))
+
+		if offset + length > len(tag) || length % 2 != 0 {
+			return ""
+		}
+
+		units := make([]uint16, length / 2)
+		for i := range units {
+			units[i] = binary.BigEndian.Uint16(tag[offset + i * 2:])
+		}
+
+		return strings.TrimRight(string(utf16.Decode(units)), "\x00")
+	}
+
+	return ""
+}
+
+// colorant returns the XYZ of an 'XYZ ' tag.
+func (ip *iccProfile) colorant(signature string) (xyz [3]float64, found bool) {
+	tag := ip.tags[signature]
+	if len(tag) < 20 || string(tag[0:4]) != "XYZ " {
+		return xyz, false
+	}
+
+	for i := range xyz {
+		xyz[i] = s15Fixed16(tag[8 + i * 4:])
+	}
+
+	return xyz, true
+}
+
+// srgbTransfer is the sRGB tone curve (encoded to linear).
+func srgbTransfer(x float64) float64 {
+	if x <= 0.04045 {
+		return x / 12.92
+	}
+
+	return math.Pow((x + 0.055) / 1.055, 2.4)
+}
+
+// toneCurve returns the function of a 'curv' or 'para' tag (or nil if it
+// can't be decoded).
+func (ip *iccProfile) toneCurve(signature string) func(float64) float64 {
+	tag := ip.tags[signature]
+	if len(tag) < 12 {
+		return nil
+	}
+
+	switch string(tag[0:4]) {
+	case "curv":
+		count := int(binary.BigEndian.Uint32(tag[8:12]))
+		if 12 + count * 2 > len(tag) {
+			return nil
+		}
+
+		if count == 0 {
+			return func(x float64) float64 { return x }
+		} else if count == 1 {
+			gamma := float64(binary.BigEndian.Uint16(tag[12:14])) / 256
+			return func(x float64) float64 { return math.Pow(x, gamma) }
+		}
+
+		table := make([]float64, count)
+		for i := range table {
+			table[i] = float64(binary.BigEndian.Uint16(tag[12 + i * 2:])) / 65535
+		}
+
+		return func(x float64) float64 {
+			position := x * float64(count - 1)
+			i := int(math.Floor(position))
+			if i >= count - 1 {
+				return table[count - 1]
+			}
+
+			fraction := position - float64(i)
+			return table[i] * (1 - fraction) + table[i + 1] * fraction
+		}
+	case "para":
+		functionType := int(binary.BigEndian.Uint16(tag[8:10]))
+		parameterCounts := []int{1, 3, 4, 5, 7}
+
+		if functionType >= len(parameterCounts) || 12 + parameterCounts[functionType] * 4 > len(tag) {
+			return nil
+		}
+
+		// g, a, b, c, d, e, f
+		var p [7]float64
+		for i := 0; i < parameterCounts[functionType]; i++ {
+			p[i] = s15Fixed16(tag[12 + i * 4:])
+		}
+
+		g, a, b, c, d, e, f := p[0], p[1], p[2], p[3], p[4], p[5], p[6]
+
+		return func(x float64) float64 {
+			switch functionType {
+			case 0:
+				return math.Pow(x, g)
+			case 1:
+				if x >= -b / a {
+					return math.Pow(a * x + b, g)
+				}
+
+				return 0
+			case 2:
+				if x >= -b / a {
+					return math.Pow(a * x + b, g) + c
+				}
+
+				return c
+			case 3:
+				if x >= d {
+					return math.Pow(a * x + b, g)
+				}
+
+				return c * x
+			}
+
+			if x >= d {
+				return math.Pow(a * x + b, g) + e
+			}
+
+			return c * x + f
+		}
+	}
+
+	return nil
+}
+
+// isSrgbEquivalent compares the primaries and tone curves against sRGB. A
+// profile without primaries (e.g. one built on lookup tables) is judged by
+// its name.
+func (ip *iccProfile) isSrgbEquivalent(summary *IccSummary) bool {
+	if summary.ColorSpace != "RGB" {
+		return false
+	}
+
+	hasColorants := false
+
+	for signature, expected := range srgbColorants {
+		xyz, found := ip.colorant(signature)
+		if found == false {
+			continue
+		}
+
+		hasColorants = true
+
+		for i := range xyz {
+			if math.Abs(xyz[i] - expected[i]) > iccColorantTolerance {
+				return false
+			}
+		}
+	}
+
+	if hasColorants == false {
+		return strings.Contains(summary.Description, "sRGB") == true
+	}
+
+	for _, signature := range iccTrcTags {
+		curve := ip.toneCurve(signature)
+		if curve == nil {
+			return false
+		}
+
+		for step := 1; step <= 20; step++ {
+			x := float64(step) / 20
+			if math.Abs(curve(x) - srgbTransfer(x)) > iccTrcTolerance {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// ParseIccSummary parses the header and the tags of interest from an ICC
+// profile.
+func ParseIccSummary(profile []byte) (summary *IccSummary, err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	ip := parseIccProfile(profile)
+
+	summary = &IccSummary{
+		Size: len(profile),
+		Version: fmt.Sprintf("%d.%d.%d", profile[8], profile[9] >> 4, profile[9] & 0x0f),
+		DeviceClass: ip.signature(12),
+		ColorSpace: ip.signature(16),
+		ConnectionSpace: ip.signature(20),
+		Description: ip.description(),
+	}
+
+	summary.SrgbEquivalent = ip.isSrgbEquivalent(summary)
+
+	return summary, nil
+}
+
+// ColorProfileInfo describes how the colors of the image are to be
+// interpreted.
+type ColorProfileInfo struct {
+	// Icc summarizes the embedded profile. It is nil if there isn't one.
+	Icc *IccSummary
+
+	// ImpliedColorSpace is what a reader assumes in the absence of a profile
+	// (one of the IMPLIED_COLOR_SPACE_* values). It is only set when there is
+	// no profile.
+	ImpliedColorSpace string
+}
+
+// Strippable indicates that the image would display the same without the
+// profile: there is none, or it is equivalent to the sRGB that readers
+// assume.
+func (cpi ColorProfileInfo) Strippable() bool {
+	return cpi.Icc == nil || cpi.Icc.SrgbEquivalent == true
+}
+
+// impliedColorSpace determines the color space that readers assume for an
+// image without a profile.
+func (sl SegmentList) impliedColorSpace() string {
+	sof, err := sl.Sof()
+	log.PanicIf(err)
+
+	switch sof.ComponentCount {
+	case 1:
+		return IMPLIED_COLOR_SPACE_GRAY
+	case 4:
+		// The Adobe segment's transform flag distinguishes YCCK from CMYK.
+		for _, s := range sl.FindWithPrefix(MARKER_APP14, adobePrefix) {
+			if len(s.Data) >= 12 && s.Data[11] == 2 {
+				return IMPLIED_COLOR_SPACE_YCCK
+			}
+		}
+
+		return IMPLIED_COLOR_SPACE_CMYK
+	}
+
+	ed, err := sl.ExifDocument()
+	if err != nil {
+		if log.Is(err, ErrSegmentNotFound) == false {
+			log.Panic(err)
+		}
+
+		return IMPLIED_COLOR_SPACE_SRGB
+	}
+
+	ee, err := ed.Entry(EXIF_IFD_EXIF, exifTagColorSpace)
+	if err != nil {
+		return IMPLIED_COLOR_SPACE_SRGB
+	}
+
+	value, err := ed.Value(ee)
+	log.PanicIf(err)
+
+	values, ok := value.([]uint16)
+	if ok == false || len(values) == 0 {
+		return IMPLIED_COLOR_SPACE_SRGB
+	} else if values[0] == exifColorSpaceAdobeRgb {
+		return IMPLIED_COLOR_SPACE_ADOBE_RGB
+	} else if values[0] != exifColorSpaceUncalibrated {
+		return IMPLIED_COLOR_SPACE_SRGB
+	}
+
+	// DCF marks Adobe RGB with an uncalibrated ColorSpace and the option-file
+	// InteroperabilityIndex.
+	if ee, err := ed.Entry(EXIF_IFD_INTEROP, exifTagInteroperabilityIndex); err == nil {
+		if index, err := ed.Value(ee); err == nil && index == interopIndexAdobeRgb {
+			return IMPLIED_COLOR_SPACE_ADOBE_RGB
+		}
+	}
+
+	return IMPLIED_COLOR_SPACE_UNCALIBRATED
+}
+
+// ColorProfile summarizes the embedded ICC profile or, if there isn't one,
+// the color space that it implies. Web optimizers can use Strippable() to
+// decide whether the profile can be removed.
+func (sl SegmentList) ColorProfile() (cpi *ColorProfileInfo, err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	profile, err := sl.IccProfile()
+	if err != nil {
+		if log.Is(err, ErrSegmentNotFound) == false {
+			log.Panic(err)
+		}
+
+		cpi = &ColorProfileInfo{
+			ImpliedColorSpace: sl.impliedColorSpace(),
+		}
+
+		return cpi, nil
+	}
+
+	summary, err := ParseIccSummary(profile)
+	log.PanicIf(err)
+
+	cpi = &ColorProfileInfo{
+		Icc: summary,
+	}
+
+	return cpi, nil
+}
@@ -0,0 +1,257 @@
+package jpegstructure
+
+import (
+	"bytes"
+	"path"
+	"sort"
+	"testing"
+	"unicode/utf16"
+
+	"encoding/binary"
+
+	"github.com/dsoprea/go-logging"
+)
+
+var (
+	testAdobeRgbColorants = map[string][3]float64{
+		"rXYZ": {0.6097, 0.3111, 0.0195},
+		"gXYZ": {0.2053, 0.6257, 0.0609},
+		"bXYZ": {0.1492, 0.0632, 0.7446},
+	}
+)
+
+func putS15Fixed16(b *bytes.Buffer, value float64) {
+	err := binary.Write(b, binary.BigEndian, int32(value * 65536))
+	log.PanicIf(err)
+}
+
+// buildTestIccProfile assembles a minimal RGB display profile from the given
+// tags (signature to full tag data).
+func buildTestIccProfile(tags map[string][]byte) []byte {
+	signatures := make([]string, 0, len(tags))
+	for signature := range tags {
+		signatures = append(signatures, signature)
+	}
+
+	sort.Strings(signatures)
+
+	tagData := new(bytes.Buffer)
+	table := new(bytes.Buffer)
+
+	offset := iccProfileHeaderSize + 4 + len(tags) * iccTagEntrySize
+
+	binary.Write(table, binary.BigEndian, uint32(len(tags)))
+	for _, signature := range signatures {
+		data := tags[signature]
+
+		table.WriteString(signature)
+		binary.Write(table, binary.BigEndian, uint32(offset + tagData.Len()))
+		binary.Write(table, binary.BigEndian, uint32(len(data)))
+
+		tagData.Write(data)
+		for tagData.Len() % 4 != 0 {
+			tagData.WriteByte(0)
+		}
+	}
+
+	header := make([]byte, iccProfileHeaderSize)
+	binary.BigEndian.PutUint32(header[0:], uint32(offset + tagData.Len()))
+	header[8] = 2
+	header[9] = 0x10
+	copy(header[12:], "mntr")
+	copy(header[16:], "RGB ")
+	copy(header[20:], "XYZ ")
+	copy(header[36:], "acsp")
+
+	return append(append(header, table.Bytes()...), tagData.Bytes()...)
+}
+
+func testIccDescTag(description string) []byte {
+	b := new(bytes.Buffer)
+	b.WriteString("desc\x00\x00\x00\x00")
+	binary.Write(b, binary.BigEndian, uint32(len(description) + 1))
+	b.WriteString(description)
+	b.WriteByte(0)
+
+	return b.Bytes()
+}
+
+func testIccMlucTag(description string) []byte {
+	units := utf16.Encode([]rune(description))
+
+	b := new(bytes.Buffer)
+	b.WriteString("mluc\x00\x00\x00\x00")
+	binary.Write(b, binary.BigEndian, uint32(1))
+	binary.Write(b, binary.BigEndian, uint32(12))
+	b.WriteString("enUS")
+	binary.Write(b, binary.BigEndian, uint32(len(units) * 2))
+	binary.Write(b, binary.BigEndian, uint32(28))
+	binary.Write(b, binary.BigEndian, units)
+
+	return b.Bytes()
+}
+
+func testIccColorantTags(tags map[string][]byte, colorants map[string][3]float64) {
+	for signature, xyz := range colorants {
+		b := new(bytes.Buffer)
+		b.WriteString("XYZ \x00\x00\x00\x00")
+		for _, value := range xyz {
+			putS15Fixed16(b, value)
+		}
+
+		tags[signature] = b.Bytes()
+	}
+}
+
+func testIccTrcTags(tags map[string][]byte, trc []byte) {
+	for _, signature := range iccTrcTags {
+		tags[signature] = trc
+	}
+}
+
+func testIccGammaTrc(gamma float64) []byte {
+	b := new(bytes.Buffer)
+	b.WriteString("curv\x00\x00\x00\x00")
+	binary.Write(b, binary.BigEndian, uint32(1))
+	binary.Write(b, binary.BigEndian, uint16(gamma * 256))
+
+	return b.Bytes()
+}
+
+func testIccSrgbParaTrc() []byte {
+	b := new(bytes.Buffer)
+	b.WriteString("para\x00\x00\x00\x00")
+	binary.Write(b, binary.BigEndian, uint16(3))
+	binary.Write(b, binary.BigEndian, uint16(0))
+
+	for _, value := range []float64{2.4, 1 / 1.055, 0.055 / 1.055, 1 / 12.92, 0.04045} {
+		putS15Fixed16(b, value)
+	}
+
+	return b.Bytes()
+}
+
+func TestParseIccSummary(t *testing.T) {
+	tags := map[string][]byte{
+		"desc": testIccDescTag("sRGB IEC61966-2.1"),
+	}
+
+	testIccColorantTags(tags, srgbColorants)
+	testIccTrcTags(tags, testIccSrgbParaTrc())
+
+	profile := buildTestIccProfile(tags)
+
+	summary, err := ParseIccSummary(profile)
+	log.PanicIf(err)
+
+	expected := IccSummary{
+		Size: len(profile),
+		Version: "2.1.0",
+		DeviceClass: "mntr",
+		ColorSpace: "RGB",
+		ConnectionSpace: "XYZ",
+		Description: "sRGB IEC61966-2.1",
+		SrgbEquivalent: true,
+	}
+
+	if *summary != expected {
+		t.Fatalf("Summary not correct: %s", summary)
+	}
+
+	_, err = ParseIccSummary(profile[:100])
+	if err == nil {
+		t.Fatalf("Expected error for short profile.")
+	}
+}
+
+func TestParseIccSummary_SrgbEquivalence(t *testing.T) {
+	// Named sRGB, but with a simple gamma curve (which is close enough).
+	gamma := map[string][]byte{
+		"desc": testIccDescTag("Display"),
+	}
+
+	testIccColorantTags(gamma, srgbColorants)
+	testIccTrcTags(gamma, testIccGammaTrc(2.2))
+
+	// Different primaries.
+	adobe := map[string][]byte{
+		"desc": testIccDescTag("Adobe RGB (1998)"),
+	}
+
+	testIccColorantTags(adobe, testAdobeRgbColorants)
+	testIccTrcTags(adobe, testIccGammaTrc(2.2))
+
+	// sRGB primaries but linear.
+	linear := map[string][]byte{
+		"desc": testIccDescTag("sRGB linear"),
+	}
+
+	testIccColorantTags(linear, srgbColorants)
+	testIccTrcTags(linear, testIccGammaTrc(1.0))
+
+	// No primaries, so only the name is available.
+	named := map[string][]byte{
+		"desc": testIccMlucTag("sRGB built-in"),
+	}
+
+	cases := []struct {
+		tags map[string][]byte
+		description string
+		equivalent bool
+	}{
+		{gamma, "Display", true},
+		{adobe, "Adobe RGB (1998)", false},
+		{linear, "sRGB linear", false},
+		{named, "sRGB built-in", true},
+	}
+
+	for _, c := range cases {
+		summary, err := ParseIccSummary(buildTestIccProfile(c.tags))
+		log.PanicIf(err)
+
+		if summary.Description != c.description {
+			t.Fatalf("Description not correct: [%s] != [%s]", summary.Description, c.description)
+		} else if summary.SrgbEquivalent != c.equivalent {
+			t.Fatalf("Equivalence not correct for [%s]: [%v]", c.description, summary.SrgbEquivalent)
+		}
+	}
+}
+
+func TestSegmentList_ColorProfile(t *testing.T) {
+	filepath := path.Join(assetsPath, testImageRelFilepath)
+
+	sl, err := ParseFileStructure(filepath)
+	log.PanicIf(err)
+
+	cpi, err := sl.ColorProfile()
+	log.PanicIf(err)
+
+	if cpi.Icc != nil || cpi.ImpliedColorSpace != IMPLIED_COLOR_SPACE_SRGB || cpi.Strippable() != true {
+		t.Fatalf("Implied profile not correct: %v", cpi)
+	}
+
+	tags := map[string][]byte{
+		"desc": testIccDescTag("Adobe RGB (1998)"),
+	}
+
+	testIccColorantTags(tags, testAdobeRgbColorants)
+	testIccTrcTags(tags, testIccGammaTrc(2.2))
+
+	payload := append(append([]byte{}, iccPrefix...), 1, 1)
+	payload = append(payload, buildTestIccProfile(tags)...)
+
+	icc := Segment{
+		MarkerId: MARKER_APP2,
+		MarkerName: "APP2",
+		Data: payload,
+	}
+
+	sl = append(sl[:1], append(SegmentList{icc}, sl[1:]...)...)
+
+	cpi, err = sl.ColorProfile()
+	log.PanicIf(err)
+
+	if cpi.Icc == nil || cpi.Icc.Description != "Adobe RGB (1998)" || cpi.ImpliedColorSpace != "" || cpi.Strippable() != false {
+		t.Fatalf("Embedded profile not correct: %v", cpi)
+	}
+}
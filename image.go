@@ -0,0 +1,141 @@
+package jpegstructure
+
+import (
+	"bytes"
+	"image"
+
+	"image/jpeg"
+
+	"github.com/dsoprea/go-logging"
+)
+
+// Image writes the structure to memory and decodes it with image/jpeg. The
+// decoder honors the Adobe APP14 transform (RGB, YCbCr, and YCCK/inverted
+// CMYK images decode correctly) but doesn't apply an ICC profile; use
+// IccProfile() to get it. Only the processes that image/jpeg supports
+// (baseline and progressive Huffman) can be decoded; see ProcessInfo().
+func (sl SegmentList) Image() (img image.Image, err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	pi, err := sl.ProcessInfo()
+	log.PanicIf(err)
+
+	if pi.EntropyCoding != EntropyCodingHuffman || (pi.Mode != ProcessModeSequential && pi.Mode != ProcessModeProgressive) {
+		log.Panicf("process not supported by the decoder: %s", pi)
+	}
+
+	b := new(bytes.Buffer)
+
+	err = sl.Write(b)
+	log.PanicIf(err)
+
+	img, err = jpeg.Decode(b)
+	log.PanicIf(err)
+
+	return img, nil
+}
+
+const (
+	exifTagPixelXDimension = 0xa002
+	exifTagPixelYDimension = 0xa003
+)
+
+// isTransplantedSegment indicates whether the segment is carried over by
+// EncodeFromImage(): EXIF, XMP (standard and extended), and ICC.
+func isTransplantedSegment(s Segment) bool {
+	if s.MarkerId == MARKER_APP1 {
+		return s.Kind() != SegmentKindUnknownApp1
+	} else if s.MarkerId == MARKER_APP2 {
+		return isIccPayload(s.Data)
+	}
+
+	return false
+}
+
+// EncodeFromImage encodes the image with image/jpeg at the given quality
+// (1-100) and carries over the EXIF, XMP, and ICC segments of `original`
+// (which may be nil). This is the usual way to resize or otherwise edit the
+// pixels without losing the metadata. The EXIF pixel dimensions, if present,
+// are updated to match the new image.
+func EncodeFromImage(img image.Image, original SegmentList, quality int) (sl SegmentList, err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	b := new(bytes.Buffer)
+
+	err = jpeg.Encode(b, img, &jpeg.Options{Quality: quality})
+	log.PanicIf(err)
+
+	encoded, err := ParseBytesStructure(b.Bytes())
+	log.PanicIf(err)
+
+	sl = make(SegmentList, 0, len(encoded) + len(original))
+	sl = append(sl, encoded[0])
+
+	for _, s := range original {
+		if isTransplantedSegment(s) == true {
+			sl = append(sl, s)
+		}
+	}
+
+	sl = append(sl, encoded[1:]...)
+	sl.updateOffsets()
+
+	ed, err := sl.ExifDocument()
+	if err != nil {
+		if log.Is(err, ErrSegmentNotFound) == true {
+			return sl, nil
+		}
+
+		log.Panic(err)
+	}
+
+	bounds := img.Bounds()
+	dimensions := map[uint16]int{
+		exifTagPixelXDimension: bounds.Dx(),
+		exifTagPixelYDimension: bounds.Dy(),
+	}
+
+	changed := false
+	for tagId, dimension := range dimensions {
+		ee, err := ed.Entry(EXIF_IFD_EXIF, tagId)
+		if err != nil {
+			continue
+		}
+
+		value, err := ed.Value(ee)
+		log.PanicIf(err)
+
+		if ee.TagType == EXIF_TYPE_SHORT && dimension <= 0xffff {
+			if v, ok := value.([]uint16); ok == true && len(v) == 1 && int(v[0]) == dimension {
+				continue
+			}
+
+			err = ed.SetValue(EXIF_IFD_EXIF, tagId, EXIF_TYPE_SHORT, []uint16{uint16(dimension)})
+			log.PanicIf(err)
+		} else {
+			if v, ok := value.([]uint32); ok == true && len(v) == 1 && int(v[0]) == dimension {
+				continue
+			}
+
+			err = ed.SetValue(EXIF_IFD_EXIF, tagId, EXIF_TYPE_LONG, []uint32{uint32(dimension)})
+			log.PanicIf(err)
+		}
+
+		changed = true
+	}
+
+	if changed == true {
+		err = sl.SetExifDocument(ed)
+		log.PanicIf(err)
+	}
+
+	return sl, nil
+}
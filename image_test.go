@@ -0,0 +1,106 @@
+package jpegstructure
+
+import (
+	"bytes"
+	"image"
+	"path"
+	"testing"
+
+	"github.com/dsoprea/go-logging"
+)
+
+func TestSegmentList_Image(t *testing.T) {
+	filepath := path.Join(assetsPath, testImageRelFilepath)
+
+	sl, err := ParseFileStructure(filepath)
+	log.PanicIf(err)
+
+	img, err := sl.Image()
+	log.PanicIf(err)
+
+	width, height, err := sl.Dimensions()
+	log.PanicIf(err)
+
+	bounds := img.Bounds()
+	if bounds.Dx() != width || bounds.Dy() != height {
+		t.Fatalf("Image dimensions not correct: (%d)x(%d)", bounds.Dx(), bounds.Dy())
+	}
+
+	// The decoder can't handle arithmetic coding.
+	sl, err = NewBuilder().
+		AddQuantTables(QuantizationTable{Values: standardLuminanceQuantTable}).
+		AddFrame(MARKER_SOF9, SofSegment{BitsPerSample: 8, Width: 8, Height: 8}, []SofComponent{{ComponentId: 1, HorizontalSamplingFactor: 1, VerticalSamplingFactor: 1}}).
+		AddScanData([]byte{0x00}).
+		Build()
+
+	log.PanicIf(err)
+
+	_, err = sl.Image()
+	if err == nil {
+		t.Fatalf("Expected error for arithmetic coding.")
+	}
+}
+
+func TestEncodeFromImage(t *testing.T) {
+	filepath := path.Join(assetsPath, testImageRelFilepath)
+
+	original, err := ParseFileStructure(filepath)
+	log.PanicIf(err)
+
+	// Stand in for a resize with a small crop of the decoded pixels.
+	img, err := original.Image()
+	log.PanicIf(err)
+
+	cropped := img.(*image.YCbCr).SubImage(image.Rect(0, 0, 64, 48))
+
+	sl, err := EncodeFromImage(cropped, original, 80)
+	log.PanicIf(err)
+
+	width, height, err := sl.Dimensions()
+	log.PanicIf(err)
+
+	if width != 64 || height != 48 {
+		t.Fatalf("Dimensions not correct: (%d)x(%d)", width, height)
+	}
+
+	originalXmp, err := original.XmpData()
+	log.PanicIf(err)
+
+	xmp, err := sl.XmpData()
+	log.PanicIf(err)
+
+	if bytes.Equal(originalXmp, xmp) == false {
+		t.Fatalf("XMP not carried over.")
+	}
+
+	ed, err := sl.ExifDocument()
+	log.PanicIf(err)
+
+	ee, err := ed.Entry(EXIF_IFD_ROOT, 0x010f)
+	log.PanicIf(err)
+
+	make_, err := ed.Value(ee)
+	log.PanicIf(err)
+
+	if make_ != "Canon" {
+		t.Fatalf("EXIF not carried over: [%v]", make_)
+	}
+
+	ee, err = ed.Entry(EXIF_IFD_EXIF, exifTagPixelXDimension)
+	log.PanicIf(err)
+
+	value, err := ed.Value(ee)
+	log.PanicIf(err)
+
+	if FormatExifValue(value) != "64" {
+		t.Fatalf("EXIF width not updated: [%v]", value)
+	}
+
+	b := new(bytes.Buffer)
+
+	err = sl.Write(b)
+	log.PanicIf(err)
+
+	err = sl.Validate(b.Bytes())
+	log.PanicIf(err)
+}
@@ -0,0 +1,72 @@
+//go:build go1.23
+
+package jpegstructure
+
+import (
+	"bufio"
+	"io"
+	"iter"
+	"math"
+
+	"github.com/dsoprea/go-logging"
+)
+
+// All returns an iterator over the segments, for use with range.
+func (sl SegmentList) All() iter.Seq[Segment] {
+	return func(yield func(Segment) bool) {
+		for _, s := range sl {
+			if yield(s) == false {
+				return
+			}
+		}
+	}
+}
+
+// Segments returns an iterator over the segments of the image in the stream.
+// The stream is parsed as the loop advances, and segments are dropped once
+// they've been yielded, so the list is never built up. Breaking out of the
+// loop stops reading. If the image can't be parsed, the last iteration has
+// the error.
+func Segments(r io.Reader) iter.Seq2[Segment, error] {
+	return SegmentsWithOptions(r, ParseOptions{})
+}
+
+// SegmentsWithOptions is Segments with control over what is recorded. In
+// lenient mode, a warning for data after the EOI isn't attached to the
+// (already yielded) EOI segment.
+func SegmentsWithOptions(r io.Reader, options ParseOptions) iter.Seq2[Segment, error] {
+	return func(yield func(Segment, error) bool) {
+		s := bufio.NewScanner(r)
+		s.Buffer([]byte{}, math.MaxInt32)
+
+		js := NewJpegSplitterWithOptions(nil, options)
+		s.Split(js.Split)
+
+		// drain yields the segments parsed since the last call.
+		drain := func() bool {
+			for _, segment := range js.segments {
+				if yield(segment, nil) == false {
+					return false
+				}
+			}
+
+			js.segments = js.segments[:0]
+
+			return true
+		}
+
+		for s.Scan() == true {
+			if drain() == false {
+				return
+			}
+		}
+
+		if drain() == false {
+			return
+		}
+
+		if err := s.Err(); err != nil {
+			yield(Segment{}, log.Wrap(err))
+		}
+	}
+}
@@ -0,0 +1,115 @@
+//go:build go1.23
+
+package jpegstructure
+
+import (
+	"bytes"
+	"path"
+	"testing"
+
+	"io/ioutil"
+
+	"github.com/dsoprea/go-logging"
+)
+
+func TestSegmentList_All(t *testing.T) {
+	filepath := path.Join(assetsPath, testImageRelFilepath)
+
+	sl, err := ParseFileStructure(filepath)
+	log.PanicIf(err)
+
+	i := 0
+	for s := range sl.All() {
+		if s.MarkerId != sl[i].MarkerId || s.Offset != sl[i].Offset {
+			t.Fatalf("Segment (%d) not correct: (0x%02x) (%d)", i, s.MarkerId, s.Offset)
+		}
+
+		i++
+	}
+
+	if i != len(sl) {
+		t.Fatalf("Segment count not correct: (%d) != (%d)", i, len(sl))
+	}
+
+	// Break early.
+
+	i = 0
+	for s := range sl.All() {
+		if s.MarkerId == MARKER_DQT {
+			break
+		}
+
+		i++
+	}
+
+	if sl[i].MarkerId != MARKER_DQT {
+		t.Fatalf("Iteration did not stop at the DQT: (%d)", i)
+	}
+}
+
+func TestSegments(t *testing.T) {
+	filepath := path.Join(assetsPath, testImageRelFilepath)
+
+	data, err := ioutil.ReadFile(filepath)
+	log.PanicIf(err)
+
+	expected, err := ParseBytesStructure(data)
+	log.PanicIf(err)
+
+	i := 0
+	for s, err := range Segments(bytes.NewReader(data)) {
+		log.PanicIf(err)
+
+		if s.MarkerId != expected[i].MarkerId || s.Offset != expected[i].Offset || bytes.Equal(s.Data, expected[i].Data) == false {
+			t.Fatalf("Segment (%d) not correct: (0x%02x) (%d)", i, s.MarkerId, s.Offset)
+		}
+
+		i++
+	}
+
+	if i != len(expected) {
+		t.Fatalf("Segment count not correct: (%d) != (%d)", i, len(expected))
+	}
+}
+
+func TestSegments_Break(t *testing.T) {
+	filepath := path.Join(assetsPath, testImageRelFilepath)
+
+	data, err := ioutil.ReadFile(filepath)
+	log.PanicIf(err)
+
+	r := bytes.NewReader(data)
+
+	markers := make([]byte, 0)
+	for s, err := range Segments(r) {
+		log.PanicIf(err)
+
+		if s.MarkerId == MARKER_SOS {
+			break
+		}
+
+		markers = append(markers, s.MarkerId)
+	}
+
+	expected := []byte{MARKER_SOI, MARKER_APP1, MARKER_APP1, MARKER_DQT, MARKER_SOF0, MARKER_DHT}
+	if bytes.Equal(markers, expected) == false {
+		t.Fatalf("Markers not correct: %v", markers)
+	} else if r.Len() == 0 {
+		t.Fatalf("Whole stream read despite the break.")
+	}
+}
+
+func TestSegments_Error(t *testing.T) {
+	var lastErr error
+	count := 0
+	for _, err := range Segments(bytes.NewReader([]byte("not an image"))) {
+		lastErr = err
+		count++
+	}
+
+	if lastErr == nil {
+		t.Fatalf("Expected error.")
+	} else if count != 1 {
+		t.Fatalf("Iteration count not correct: (%d)", count)
+	}
+}
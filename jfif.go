@@ -0,0 +1,95 @@
+package jpegstructure
+
+import (
+	"bytes"
+	"fmt"
+
+	"encoding/binary"
+
+	"github.com/dsoprea/go-logging"
+)
+
+const (
+	JFIF_UNITS_NONE = 0
+	JFIF_UNITS_DPI = 1
+	JFIF_UNITS_DPCM = 2
+)
+
+var (
+	jfifPrefix = []byte{'J', 'F', 'I', 'F', 0x00}
+
+	jfifUnitNames = map[byte]string{
+		JFIF_UNITS_NONE: "aspect-ratio",
+		JFIF_UNITS_DPI: "dots-per-inch",
+		JFIF_UNITS_DPCM: "dots-per-cm",
+	}
+)
+
+// JfifSegment is the fixed header of an APP0 JFIF payload.
+type JfifSegment struct {
+	MajorVersion, MinorVersion byte
+	DensityUnits byte
+	XDensity, YDensity uint16
+	ThumbnailWidth, ThumbnailHeight byte
+}
+
+func (js JfifSegment) String() string {
+	return fmt.Sprintf("JFIF<VERSION=(%d.%02d) UNITS=[%s] X-DENSITY=(%d) Y-DENSITY=(%d) THUMBNAIL=(%dx%d)>", js.MajorVersion, js.MinorVersion, jfifUnitNames[js.DensityUnits], js.XDensity, js.YDensity, js.ThumbnailWidth, js.ThumbnailHeight)
+}
+
+// isJfifPayload indicates whether an APP0 payload carries a JFIF header.
+func isJfifPayload(data []byte) bool {
+	return bytes.HasPrefix(data, jfifPrefix) == true
+}
+
+// ParseJfifSegment parses the payload of an APP0 JFIF segment.
+func ParseJfifSegment(data []byte) (js *JfifSegment, err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	if isJfifPayload(data) == false {
+		log.Panicf("not a JFIF payload")
+	}
+
+	// prefix + version(2) + units(1) + densities(4) + thumbnail size(2)
+	if len(data) < len(jfifPrefix) + 9 {
+		log.Panicf("JFIF payload too short: (%d)", len(data))
+	}
+
+	raw := data[len(jfifPrefix):]
+
+	js = &JfifSegment{
+		MajorVersion: raw[0],
+		MinorVersion: raw[1],
+		DensityUnits: raw[2],
+		XDensity: binary.BigEndian.Uint16(raw[3:5]),
+		YDensity: binary.BigEndian.Uint16(raw[5:7]),
+		ThumbnailWidth: raw[7],
+		ThumbnailHeight: raw[8],
+	}
+
+	return js, nil
+}
+
+// Encode produces an APP0 payload (without a thumbnail).
+func (js JfifSegment) Encode() []byte {
+	data := make([]byte, len(jfifPrefix) + 9)
+	copy(data, jfifPrefix)
+
+	raw := data[len(jfifPrefix):]
+
+	raw[0] = js.MajorVersion
+	raw[1] = js.MinorVersion
+	raw[2] = js.DensityUnits
+	binary.BigEndian.PutUint16(raw[3:], js.XDensity)
+	binary.BigEndian.PutUint16(raw[5:], js.YDensity)
+
+	// A thumbnail isn't supported here, so the dimensions must be zero.
+	raw[7] = 0
+	raw[8] = 0
+
+	return data
+}
@@ -0,0 +1,215 @@
+package jpegstructure
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+
+	"image/color"
+	"image/jpeg"
+
+	"github.com/dsoprea/go-logging"
+)
+
+// The JFIF extension (JFXX) APP0 segment carries a thumbnail in one of three
+// encodings.
+const (
+	JFXX_EXTENSION_JPEG = 0x10
+
+	// JFXX_EXTENSION_PALETTE is one byte per pixel indexing a 256-entry RGB
+	// palette.
+	JFXX_EXTENSION_PALETTE = 0x11
+
+	// JFXX_EXTENSION_RGB is three bytes per pixel.
+	JFXX_EXTENSION_RGB = 0x13
+)
+
+const (
+	jfxxPaletteSize = 256 * 3
+)
+
+var (
+	jfxxPrefix = []byte{'J', 'F', 'X', 'X', 0x00}
+
+	jfxxExtensionNames = map[byte]string{
+		JFXX_EXTENSION_JPEG: "jpeg",
+		JFXX_EXTENSION_PALETTE: "palette",
+		JFXX_EXTENSION_RGB: "rgb",
+	}
+)
+
+// JfxxSegment is the thumbnail from an APP0 JFXX payload.
+type JfxxSegment struct {
+	ExtensionCode byte
+
+	// Width and Height are given for the uncompressed encodings and are
+	// otherwise zero (see the frame of the JPEG data).
+	Width, Height int
+
+	// Palette is the 256 RGB triplets of a palettized thumbnail.
+	Palette []byte
+
+	// Data is the JPEG stream, the palette indices, or the RGB pixels.
+	Data []byte
+}
+
+func (js JfxxSegment) String() string {
+	return fmt.Sprintf("JFXX<ENCODING=[%s] WIDTH=(%d) HEIGHT=(%d) SIZE=(%d)>", jfxxExtensionNames[js.ExtensionCode], js.Width, js.Height, len(js.Data))
+}
+
+// isJfxxPayload indicates whether an APP0 payload carries a JFXX extension.
+func isJfxxPayload(data []byte) bool {
+	return bytes.HasPrefix(data, jfxxPrefix) == true
+}
+
+// ParseJfxxSegment parses the payload of an APP0 JFXX segment.
+func ParseJfxxSegment(data []byte) (js *JfxxSegment, err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	if isJfxxPayload(data) == false {
+		log.Panicf("not a JFXX payload")
+	} else if len(data) < len(jfxxPrefix) + 1 {
+		log.Panicf("JFXX payload too short: (%d)", len(data))
+	}
+
+	raw := data[len(jfxxPrefix) + 1:]
+
+	js = &JfxxSegment{
+		ExtensionCode: data[len(jfxxPrefix)],
+	}
+
+	if js.ExtensionCode == JFXX_EXTENSION_JPEG {
+		js.Data = raw
+		return js, nil
+	}
+
+	if len(raw) < 2 {
+		log.Panicf("JFXX thumbnail dimensions missing")
+	}
+
+	js.Width = int(raw[0])
+	js.Height = int(raw[1])
+	raw = raw[2:]
+
+	pixelSize := 0
+
+	switch js.ExtensionCode {
+	case JFXX_EXTENSION_PALETTE:
+		if len(raw) < jfxxPaletteSize {
+			log.Panicf("JFXX palette truncated")
+		}
+
+		js.Palette = raw[:jfxxPaletteSize]
+		raw = raw[jfxxPaletteSize:]
+
+		pixelSize = 1
+	case JFXX_EXTENSION_RGB:
+		pixelSize = 3
+	default:
+		log.Panicf("JFXX extension code not valid: (0x%02x)", js.ExtensionCode)
+	}
+
+	size := js.Width * js.Height * pixelSize
+	if len(raw) < size {
+		log.Panicf("JFXX thumbnail truncated: (%d) < (%d)", len(raw), size)
+	}
+
+	js.Data = raw[:size]
+
+	return js, nil
+}
+
+// Encode produces an APP0 payload.
+func (js JfxxSegment) Encode() []byte {
+	b := new(bytes.Buffer)
+
+	b.Write(jfxxPrefix)
+	b.WriteByte(js.ExtensionCode)
+
+	if js.ExtensionCode != JFXX_EXTENSION_JPEG {
+		b.WriteByte(byte(js.Width))
+		b.WriteByte(byte(js.Height))
+	}
+
+	if js.ExtensionCode == JFXX_EXTENSION_PALETTE {
+		b.Write(js.Palette)
+	}
+
+	b.Write(js.Data)
+
+	return b.Bytes()
+}
+
+// Rgb returns the thumbnail of an uncompressed encoding as RGB pixels
+// (expanding the palette, if necessary).
+func (js JfxxSegment) Rgb() (pixels []byte, err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	switch js.ExtensionCode {
+	case JFXX_EXTENSION_RGB:
+		return js.Data, nil
+	case JFXX_EXTENSION_PALETTE:
+		pixels = make([]byte, len(js.Data) * 3)
+		for i, index := range js.Data {
+			copy(pixels[i * 3:], js.Palette[int(index) * 3:int(index) * 3 + 3])
+		}
+
+		return pixels, nil
+	}
+
+	log.Panicf("JFXX thumbnail is not uncompressed: (0x%02x)", js.ExtensionCode)
+	return nil, nil
+}
+
+// Image decodes the thumbnail.
+func (js JfxxSegment) Image() (img image.Image, err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	if js.ExtensionCode == JFXX_EXTENSION_JPEG {
+		img, err = jpeg.Decode(bytes.NewReader(js.Data))
+		log.PanicIf(err)
+
+		return img, nil
+	}
+
+	pixels, err := js.Rgb()
+	log.PanicIf(err)
+
+	rgba := image.NewRGBA(image.Rect(0, 0, js.Width, js.Height))
+	for i := 0; i < js.Width * js.Height; i++ {
+		rgba.Set(i % js.Width, i / js.Width, color.RGBA{pixels[i * 3], pixels[i * 3 + 1], pixels[i * 3 + 2], 0xff})
+	}
+
+	return rgba, nil
+}
+
+// JfxxThumbnail returns the thumbnail from the first JFXX segment.
+func (sl SegmentList) JfxxThumbnail() (js *JfxxSegment, err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	s, err := sl.findFirstWithPrefix(MARKER_APP0, jfxxPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	js, err = ParseJfxxSegment(s.Data)
+	log.PanicIf(err)
+
+	return js, nil
+}
@@ -0,0 +1,107 @@
+package jpegstructure
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"image/color"
+
+	"github.com/dsoprea/go-logging"
+)
+
+func TestParseJfxxSegment_Palette(t *testing.T) {
+	palette := make([]byte, jfxxPaletteSize)
+	palette[3] = 0x10
+	palette[4] = 0x20
+	palette[5] = 0x30
+
+	original := JfxxSegment{
+		ExtensionCode: JFXX_EXTENSION_PALETTE,
+		Width: 2,
+		Height: 1,
+		Palette: palette,
+		Data: []byte{0x00, 0x01},
+	}
+
+	js, err := ParseJfxxSegment(original.Encode())
+	log.PanicIf(err)
+
+	if reflect.DeepEqual(*js, original) == false {
+		t.Fatalf("Segment not correct: %s", js)
+	}
+
+	pixels, err := js.Rgb()
+	log.PanicIf(err)
+
+	if bytes.Equal(pixels, []byte{0x00, 0x00, 0x00, 0x10, 0x20, 0x30}) == false {
+		t.Fatalf("Pixels not correct: %v", pixels)
+	}
+
+	img, err := js.Image()
+	log.PanicIf(err)
+
+	expected := color.RGBA{0x10, 0x20, 0x30, 0xff}
+	if img.At(1, 0) != expected {
+		t.Fatalf("Image not correct.")
+	}
+}
+
+func TestParseJfxxSegment_Rgb(t *testing.T) {
+	original := JfxxSegment{
+		ExtensionCode: JFXX_EXTENSION_RGB,
+		Width: 1,
+		Height: 2,
+		Data: []byte{1, 2, 3, 4, 5, 6},
+	}
+
+	js, err := ParseJfxxSegment(original.Encode())
+	log.PanicIf(err)
+
+	if reflect.DeepEqual(*js, original) == false {
+		t.Fatalf("Segment not correct: %s", js)
+	}
+
+	// Truncated pixels.
+	_, err = ParseJfxxSegment(original.Encode()[:10])
+	if err == nil {
+		t.Fatalf("Expected error for truncated thumbnail.")
+	}
+}
+
+func TestSegmentList_JfxxThumbnail(t *testing.T) {
+	thumbnail := getTransformTestImage()
+
+	js := JfxxSegment{
+		ExtensionCode: JFXX_EXTENSION_JPEG,
+		Data: thumbnail,
+	}
+
+	sl, err := NewBuilder().
+		AddSegment(MARKER_APP0, js.Encode()).
+		AddQuantTables(QuantizationTable{Values: standardLuminanceQuantTable}).
+		AddFrame(MARKER_SOF0, SofSegment{BitsPerSample: 8, Width: 8, Height: 8}, []SofComponent{{ComponentId: 1, HorizontalSamplingFactor: 1, VerticalSamplingFactor: 1}}).
+		AddScanData([]byte{0x00}).
+		Build()
+
+	log.PanicIf(err)
+
+	found, err := sl.JfxxThumbnail()
+	log.PanicIf(err)
+
+	if found.ExtensionCode != JFXX_EXTENSION_JPEG || bytes.Equal(found.Data, thumbnail) == false {
+		t.Fatalf("Thumbnail not correct: %s", found)
+	}
+
+	img, err := found.Image()
+	log.PanicIf(err)
+
+	if img.Bounds().Dx() != 67 {
+		t.Fatalf("Thumbnail image not correct.")
+	}
+
+	_, err = sl[1:].StripMetadata(false).JfxxThumbnail()
+	if log.Is(err, ErrSegmentNotFound) == false {
+		t.Fatalf("Expected not-found error: %v", err)
+	}
+}
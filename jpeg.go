@@ -4,8 +4,13 @@ import (
 	"bytes"
 	"bufio"
 	"fmt"
+	"io"
 
+	"errors"
+
+	"crypto/sha256"
 	"encoding/binary"
+	"encoding/hex"
 
 	"github.com/dsoprea/go-logging"
 )
@@ -25,7 +30,9 @@ const (
 	MARKER_APP6  = 0xe6
 	MARKER_APP7  = 0xe7
 	MARKER_APP8  = 0xe8
+	MARKER_APP9  = 0xe9
 	MARKER_APP10 = 0xea
+	MARKER_APP11 = 0xeb
 	MARKER_APP12 = 0xec
 	MARKER_APP13 = 0xed
 	MARKER_APP14 = 0xee
@@ -37,6 +44,17 @@ const (
 	MARKER_DHT = 0xc4
 	MARKER_JPG = 0xc8
 	MARKER_DAC = 0xcc
+	MARKER_DRI = 0xdd
+	MARKER_DNL = 0xdc
+	MARKER_DHP = 0xde
+	MARKER_EXP = 0xdf
+	MARKER_TEM = 0x01
+
+	MARKER_RST0 = 0xd0
+	MARKER_RST7 = 0xd7
+
+	MARKER_JPG0 = 0xf0
+	MARKER_JPG13 = 0xfd
 
 	MARKER_SOF0 = 0xc0
 	MARKER_SOF1 = 0xc1
@@ -53,6 +71,11 @@ const (
 	MARKER_SOF15 = 0xcf
 )
 
+var (
+	// ErrSegmentNotFound indicates that a requested segment isn't present.
+	ErrSegmentNotFound = errors.New("segment not found")
+)
+
 var (
 	jpegLogger        = log.NewLogger("exifjpeg.jpeg")
 	jpegMagicStandard = []byte{0xff, MARKER_SOI, 0xff}
@@ -115,7 +138,9 @@ var (
 		MARKER_APP6: "APP6",
 		MARKER_APP7: "APP7",
 		MARKER_APP8: "APP8",
+		MARKER_APP9: "APP9",
 		MARKER_APP10: "APP10",
+		MARKER_APP11: "APP11",
 		MARKER_APP12: "APP12",
 		MARKER_APP13: "APP13",
 		MARKER_APP14: "APP14",
@@ -127,6 +152,35 @@ var (
 		MARKER_DHT: "DHT",
 		MARKER_JPG: "JPG",
 		MARKER_DAC: "DAC",
+		MARKER_DRI: "DRI",
+		MARKER_DNL: "DNL",
+		MARKER_DHP: "DHP",
+		MARKER_EXP: "EXP",
+		MARKER_TEM: "TEM",
+
+		0xd0: "RST0",
+		0xd1: "RST1",
+		0xd2: "RST2",
+		0xd3: "RST3",
+		0xd4: "RST4",
+		0xd5: "RST5",
+		0xd6: "RST6",
+		0xd7: "RST7",
+
+		0xf0: "JPG0",
+		0xf1: "JPG1",
+		0xf2: "JPG2",
+		0xf3: "JPG3",
+		0xf4: "JPG4",
+		0xf5: "JPG5",
+		0xf6: "JPG6",
+		0xf7: "JPG7",
+		0xf8: "JPG8",
+		0xf9: "JPG9",
+		0xfa: "JPG10",
+		0xfb: "JPG11",
+		0xfc: "JPG12",
+		0xfd: "JPG13",
 
 		MARKER_SOF0: "SOF0",
 		MARKER_SOF1: "SOF1",
@@ -154,10 +208,45 @@ func (ss SofSegment) String() string {
 	return fmt.Sprintf("SOF<BitsPerSample=(%d) Width=(%d) Height=(%d) ComponentCount=(%d)>", ss.BitsPerSample, ss.Width, ss.Height, ss.ComponentCount)
 }
 
+// SegmentVisitor is called for each segment as it's parsed. `counter` is the
+// number of marker segments before this one; the scan-data isn't counted, so
+// it isn't the segment's index in the list (see SegmentVisitorV2).
 type SegmentVisitor interface {
 	HandleSegment(markerId byte, markerName string, counter int, lastIsScanData bool) error
 }
 
+// SegmentInfo describes a segment passed to SegmentVisitorV2.
+type SegmentInfo struct {
+	MarkerId byte
+	MarkerName string
+
+	// Index is the position of the segment in the SegmentList.
+	Index int
+
+	// Counter is the value passed to SegmentVisitor.
+	Counter int
+
+	// Offset is the position of the segment (its 0xff, or the first byte of
+	// the scan-data) in the stream.
+	Offset int
+
+	// PayloadLength is the number of bytes after the header.
+	PayloadLength int
+
+	IsScanData bool
+}
+
+func (si SegmentInfo) String() string {
+	return fmt.Sprintf("SegmentInfo<NAME=[%s] INDEX=(%d) OFFSET=(0x%08x) PAYLOAD-LENGTH=(%d)>", si.MarkerName, si.Index, si.Offset, si.PayloadLength)
+}
+
+// SegmentVisitorV2 is called for each segment as it's parsed, with its
+// position in the stream and in the list. A visitor may implement both this
+// and SegmentVisitor.
+type SegmentVisitorV2 interface {
+	VisitSegment(si SegmentInfo) error
+}
+
 
 type SofSegmentVisitor interface {
 	HandleSof(sof *SofSegment) error
@@ -169,6 +258,70 @@ type Segment struct {
 	MarkerName string
 	Offset int
 	Data []byte
+
+	// HeaderSize is the number of bytes before the payload (0xff, the marker,
+	// and the length). It is zero for the scan-data.
+	HeaderSize int
+
+	// MarkerLength is the value of the segment's length field (which counts
+	// the length field itself), or zero for markers without one.
+	MarkerLength int
+
+	// TotalSize is the number of bytes that the segment occupies in the
+	// stream (HeaderSize plus the payload).
+	TotalSize int
+
+	// Digest is the SHA-256 of the payload as parsed. It is only set if
+	// digests were requested (see ParseOptions) and is cleared when the
+	// payload is replaced.
+	Digest []byte
+
+	// Warnings are the problems that were tolerated while parsing the
+	// segment (or the bytes just before it).
+	Warnings []ParseWarning
+
+	// Source identifies the file that the segment was copied from (see
+	// CopyMetadata and SegmentBuilder.AddSegmentFrom), and SourceOffset is
+	// where it was in that file. Source is empty for segments that belong to
+	// the image they were parsed from. Neither is written to the file, and
+	// both are cleared when the payload is replaced.
+	Source string
+	SourceOffset int
+}
+
+// EndOffset returns the offset of the byte following the segment.
+func (s Segment) EndOffset() int {
+	return s.Offset + s.TotalSize
+}
+
+// HexDump writes a canonical hex+ASCII listing of the payload. At most
+// `maxBytes` bytes are listed; zero or less lists everything.
+func (s Segment) HexDump(w io.Writer, maxBytes int) (err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	data := s.Data
+	if maxBytes > 0 && len(data) > maxBytes {
+		data = data[:maxBytes]
+	}
+
+	d := hex.Dumper(w)
+
+	_, err = d.Write(data)
+	log.PanicIf(err)
+
+	err = d.Close()
+	log.PanicIf(err)
+
+	if len(data) < len(s.Data) {
+		_, err := fmt.Fprintf(w, "... (%d more bytes)\n", len(s.Data) - len(data))
+		log.PanicIf(err)
+	}
+
+	return nil
 }
 
 type SegmentList []Segment
@@ -230,9 +383,29 @@ type JpegSplitter struct {
 	counter int
 	lastIsScanData bool
 	visitor interface{}
+	options ParseOptions
 
 	currentOffset int
 	segments SegmentList
+
+	// warnings are all of the warnings so far, and pendingWarnings are the
+	// ones that will be attached to the next segment.
+	warnings []ParseWarning
+	pendingWarnings []ParseWarning
+
+	jfifSeen bool
+	trailingSeen bool
+
+	logger Logger
+
+	// markerFilter has the markers whose segments are kept. If nil, all are
+	// kept.
+	markerFilter map[byte]bool
+
+	// handledCount is the number of segments parsed (whether kept or not),
+	// and lastHandledIsScanData describes the last of them.
+	handledCount int
+	lastHandledIsScanData bool
 }
 
 func NewJpegSplitter(visitor interface{}) *JpegSplitter {
@@ -241,6 +414,54 @@ func NewJpegSplitter(visitor interface{}) *JpegSplitter {
 	}
 }
 
+// NewJpegSplitterWithOptions is NewJpegSplitter with control over what is
+// recorded while parsing.
+func NewJpegSplitterWithOptions(visitor interface{}, options ParseOptions) *JpegSplitter {
+	js := &JpegSplitter{
+		visitor: visitor,
+		options: options,
+		logger: options.Logger,
+	}
+
+	if options.MarkerFilter != nil {
+		js.SetMarkerFilter(options.MarkerFilter...)
+	}
+
+	return js
+}
+
+// SetLogger sends the splitter's diagnostics to the logger rather than to the
+// package's go-logging logger. Use NopLogger to silence them.
+func (js *JpegSplitter) SetLogger(logger Logger) {
+	js.logger = logger
+}
+
+// SetMarkerFilter keeps only the segments with the given markers (use 0x0 for
+// the scan-data). The others are still parsed, so the offsets stay correct,
+// but their payloads aren't copied and they aren't added to the list or
+// passed to the visitor. Warnings about them are only available from
+// Warnings(). With no markers, every segment is kept again.
+func (js *JpegSplitter) SetMarkerFilter(markers ...byte) {
+	if len(markers) == 0 {
+		js.markerFilter = nil
+		return
+	}
+
+	js.markerFilter = make(map[byte]bool, len(markers))
+	for _, markerId := range markers {
+		js.markerFilter[markerId] = true
+	}
+}
+
+// activeLogger returns the logger that was set or the default.
+func (js *JpegSplitter) activeLogger() Logger {
+	if js.logger == nil {
+		return goLoggingLogger{}
+	}
+
+	return js.logger
+}
+
 func (js *JpegSplitter) Segments() SegmentList {
 	return js.segments
 }
@@ -261,7 +482,27 @@ func (js *JpegSplitter) IsScanData() bool {
 	return js.lastIsScanData
 }
 
-func (js *JpegSplitter) processScanData(data []byte) (advanceBytes int, err error) {
+// Warnings returns the problems that were tolerated so far, in the order that
+// they were found.
+func (js *JpegSplitter) Warnings() []ParseWarning {
+	return js.warnings
+}
+
+// warn records a tolerated problem. It will be attached to the next segment.
+func (js *JpegSplitter) warn(offset int, code string, format string, args ...interface{}) {
+	pw := ParseWarning{
+		Offset: offset,
+		Code: code,
+		Message: fmt.Sprintf(format, args...),
+	}
+
+	js.activeLogger().Debugf("Warning: %s", pw)
+
+	js.warnings = append(js.warnings, pw)
+	js.pendingWarnings = append(js.pendingWarnings, pw)
+}
+
+func (js *JpegSplitter) processScanData(data []byte, atEOF bool) (advanceBytes int, err error) {
 	defer func() {
 		if state := recover(); state != nil {
 			err = log.Wrap(state.(error))
@@ -270,19 +511,41 @@ func (js *JpegSplitter) processScanData(data []byte) (advanceBytes int, err erro
 
 	dataLength := len(data)
 
+	// The scan header (which includes its own length) sits at the front of
+	// the scan-data. Skip it so that its bytes can't be mistaken for a
+	// marker.
+	if dataLength < 2 {
+		js.activeLogger().Tracef("Not enough (2a)")
+		return 0, nil
+	}
+
+	headerLength := int(binary.BigEndian.Uint16(data))
+
 	found := false
-	i := 0
+	i := headerLength
 	for ; i < dataLength - 1; i++ {
-		// We read until we hit the EOI marker, which always follows (we're not
-		// processing the EOI here, however).
-		if data[i] == 0xff && data[i + 1] == MARKER_EOI {
-			found = true
-			break
+		if data[i] != 0xff {
+			continue
 		}
+
+		// Stuffed zeroes, fill bytes, and restart markers are part of the
+		// scan-data. Any other marker (usually the EOI, but also a DNL or the
+		// tables and header of the next scan) ends it. We're not processing
+		// that marker here, however.
+		next := data[i + 1]
+		if next == 0x00 || next == 0xff || IsRstMarker(next) == true {
+			continue
+		}
+
+		found = true
+		break
 	}
 
-	if found == false {
-		jpegLogger.Debugf(nil, "Not enough (2)")
+	if found == false && atEOF == true && js.options.Lenient == true && dataLength > headerLength {
+		// The stream ended in the scan-data. Keep all of it.
+		i = dataLength
+	} else if found == false {
+		js.activeLogger().Tracef("Not enough (2)")
 		return 0, nil
 	}
 
@@ -296,7 +559,7 @@ func (js *JpegSplitter) processScanData(data []byte) (advanceBytes int, err erro
 	// Note that we don't increment the counter since this isn't an actual
 	// segment.
 
-	jpegLogger.Debugf(nil, "End of scan-data.")
+	js.activeLogger().Tracef("End of scan-data.")
 
 	err = js.handleSegment(0x0, "!SCANDATA", 0x0, data[:i])
 	log.PanicIf(err)
@@ -312,42 +575,40 @@ func (js *JpegSplitter) Split(data []byte, atEOF bool) (advance int, token []byt
 	}()
 
 	if js.counter == 0 {
-		// Verify magic bytes.
+		// Verify magic bytes. Wait for enough to tell the formats apart
+		// unless the stream is shorter than that.
 
-		if len(data) < 3 {
-			jpegLogger.Debugf(nil, "Not enough (1)")
+		if len(data) < len(jpegMagicStandard) || (len(data) < detectPrefixNeeded(data[0], js.options.Detect) && atEOF == false) {
+			js.activeLogger().Tracef("Not enough (1)")
 			return 0, nil, nil
 		}
 
-		if data[0] == jpegMagic2000[0] && data[1] == jpegMagic2000[1] && data[2] == jpegMagic2000[2] {
-			// TODO(dustin): Return to JPEG2000 support.
-			log.Panicf("JPEG2000 not supported")
-		}
-
-		if data[0] != jpegMagicStandard[0] || data[1] != jpegMagicStandard[1] || data[2] != jpegMagicStandard[2] {
-			log.Panicf("file does not look like a JPEG: (%X) (%X) (%X)", data[0], data[1], data[2])
-		}
+		checkFormat(data, js.options.Detect)
 	}
 
 // TODO(dustin): !! We're assuming that ignoring atEOF and returning (0, nil, nil) when we need more data and there isn't any will raise an io.EOF (thereby delegating a redundant check to our caller). We might want to specifically run an example for this scenario.
 
 	dataLength := len(data)
 
-	jpegLogger.Debugf(nil, "SPLIT: LEN=(%d) COUNTER=(%d)", dataLength, js.counter)
+	js.activeLogger().Tracef("SPLIT: LEN=(%d) COUNTER=(%d)", dataLength, js.counter)
 
 	// If the last segment was the SOS, we're currently sitting on scan data.
-	// Search for the EOI marker aferward in order to know how much data there
-	// is. Return this as its own token.
+	// Search for the next marker aferward in order to know how much data
+	// there is. Return this as its own token.
 	//
 	// REF: https://stackoverflow.com/questions/26715684/parsing-jpeg-sos-marker
 	if js.lastMarkerId == MARKER_SOS {
-		advanceBytes, err := js.processScanData(data)
+		advanceBytes, err := js.processScanData(data, atEOF)
 		log.PanicIf(err)
 
 		// This will either return 0 and implicitly request that we need more
 		// data and then need to run again or will return an actual byte count
 		// to progress by.
-		return advanceBytes, nil, nil
+		if advanceBytes == 0 {
+			return 0, nil, nil
+		}
+
+		return advanceBytes, data[:advanceBytes], nil
 	} else {
 		js.lastIsScanData = false
 	}
@@ -355,6 +616,58 @@ func (js *JpegSplitter) Split(data []byte, atEOF bool) (advance int, token []byt
 	// If we're here, we're supposed to be sitting on the 0xff bytes at the
 	// beginning of a segment (just before the marker).
 
+	if dataLength == 0 {
+		if atEOF == true && js.options.Lenient == true && js.lastHandledIsScanData == true {
+			js.warn(js.currentOffset, "eoi-missing", "stream ended without an EOI; one was added")
+
+			js.lastMarkerId = MARKER_EOI
+			js.lastMarkerName = markerNames[MARKER_EOI]
+
+			err := js.handleSegment(MARKER_EOI, js.lastMarkerName, 2, nil)
+			log.PanicIf(err)
+
+			js.counter++
+		}
+
+		js.activeLogger().Tracef("Not enough (2b)")
+		return 0, nil, nil
+	}
+
+	if data[0] != 0xff && js.options.Lenient == true {
+		if js.lastMarkerId == MARKER_EOI {
+			if js.trailingSeen == false {
+				js.trailingSeen = true
+
+				pw := ParseWarning{
+					Offset: js.currentOffset,
+					Code: "trailing-data",
+					Message: "data after the EOI skipped",
+				}
+
+				js.warnings = append(js.warnings, pw)
+
+				if len(js.segments) > 0 && js.segments[len(js.segments) - 1].MarkerId == MARKER_EOI {
+					last := &js.segments[len(js.segments) - 1]
+					last.Warnings = append(last.Warnings, pw)
+				}
+			}
+
+			js.currentOffset += dataLength
+
+			return dataLength, data, nil
+		} else if data[0] == 0x00 {
+			padding := 1
+			for padding < dataLength && data[padding] == 0x00 {
+				padding++
+			}
+
+			js.warn(js.currentOffset, "padding", "(%d) zero bytes skipped between segments", padding)
+			js.currentOffset += padding
+
+			return padding, data[:padding], nil
+		}
+	}
+
 	if data[0] != 0xff {
 		log.Panicf("not on new segment marker: (%02X)", data[0])
 	}
@@ -362,7 +675,7 @@ func (js *JpegSplitter) Split(data []byte, atEOF bool) (advance int, token []byt
 	i := 0
 	found := false
 	for ; i < dataLength; i++ {
-		jpegLogger.Debugf(nil, "Prefix check: (%d) %02X", i, data[i])
+		js.activeLogger().Tracef("Prefix check: (%d) %02X", i, data[i])
 
 		if data[i] != 0xff {
 			found = true
@@ -370,20 +683,24 @@ func (js *JpegSplitter) Split(data []byte, atEOF bool) (advance int, token []byt
 		}
 	}
 
-	jpegLogger.Debugf(nil, "Skipped by leading 0xFF bytes: (%d)", i)
+	js.activeLogger().Tracef("Skipped by leading 0xFF bytes: (%d)", i)
 
 	if found == false || i >= dataLength {
-		jpegLogger.Debugf(nil, "Not enough (3)")
+		js.activeLogger().Tracef("Not enough (3)")
 		return 0, nil, nil
 	}
 
 	markerId := data[i]
-	jpegLogger.Debugf(nil, "MARKER-ID=%x", markerId)
+	js.activeLogger().Tracef("MARKER-ID=%x", markerId)
+
+	// Any 0xff bytes beyond the one immediately preceding the marker are fill
+	// and are not part of the segment.
+	fillBytes := i - 1
 
 	js.lastMarkerName = markerNames[markerId]
 
 	sizeLen, found := markerLen[markerId]
-	jpegLogger.Debugf(nil, "MARKER-ID=%x SIZELEN=%v FOUND=%v", markerId, sizeLen, found)
+	js.activeLogger().Tracef("MARKER-ID=%x SIZELEN=%v FOUND=%v", markerId, sizeLen, found)
 
 	i++
 
@@ -402,7 +719,7 @@ func (js *JpegSplitter) Split(data []byte, atEOF bool) (advance int, token []byt
 		headerSize = 2 + 2
 
 		if i + 2 >= dataLength {
-			jpegLogger.Debugf(nil, "Not enough (4)")
+			js.activeLogger().Tracef("Not enough (4)")
 			return 0, nil, nil
 		}
 
@@ -412,11 +729,13 @@ func (js *JpegSplitter) Split(data []byte, atEOF bool) (advance int, token []byt
 
 		if len_ <= 2 {
 			log.Panicf("length of size read for non-special marker (%02x) is unexpectedly not more than two.", markerId)
+		} else if markerId == MARKER_DNL && len_ != 2 + dnlPayloadSize {
+			log.Panicf("length of DNL segment is not four: (%d)", len_)
 		}
 
 		// (len_ includes the bytes of the length itself.)
 		payloadLength = int(len_) - 2
-		jpegLogger.Debugf(nil, "DataLength (dynamically-sized segment): (%d)", payloadLength)
+		js.activeLogger().Tracef("DataLength (dynamically-sized segment): (%d)", payloadLength)
 
 		i += 2
 	} else if sizeLen > 0 {
@@ -430,7 +749,7 @@ func (js *JpegSplitter) Split(data []byte, atEOF bool) (advance int, token []byt
 		}
 
 		if i + 4 >= dataLength {
-			jpegLogger.Debugf(nil, "Not enough (5)")
+			js.activeLogger().Tracef("Not enough (5)")
 			return 0, nil, nil
 		}
 
@@ -439,12 +758,12 @@ func (js *JpegSplitter) Split(data []byte, atEOF bool) (advance int, token []byt
 		log.PanicIf(err)
 
 		payloadLength = int(len_) - 4
-		jpegLogger.Debugf(nil, "DataLength (four-byte-length segment): (%u)", len_)
+		js.activeLogger().Tracef("DataLength (four-byte-length segment): (%d)", len_)
 
 		i += 4
 	}
 
-	jpegLogger.Debugf(nil, "PAYLOAD-LENGTH: %d", payloadLength)
+	js.activeLogger().Tracef("PAYLOAD-LENGTH: %d", payloadLength)
 
 	payload := data[i:]
 
@@ -455,23 +774,36 @@ func (js *JpegSplitter) Split(data []byte, atEOF bool) (advance int, token []byt
 	i += int(payloadLength)
 
 	if i > dataLength {
-		jpegLogger.Debugf(nil, "Not enough (6)")
+		js.activeLogger().Tracef("Not enough (6)")
 		return 0, nil, nil
 	}
 
-	jpegLogger.Debugf(nil, "Found whole segment.")
+	js.activeLogger().Tracef("Found whole segment.")
 
 	js.lastMarkerId = markerId
 
+	if fillBytes > 0 {
+		js.warn(js.currentOffset, "fill", "(%d) fill bytes skipped before the marker", fillBytes)
+	}
+
+	if js.lastMarkerName == "" {
+		js.warn(js.currentOffset + fillBytes, "unknown-marker", "unknown marker (0x%02x)", markerId)
+	}
+
+	js.currentOffset += fillBytes
+
 	payloadWindow := payload[:payloadLength]
 	err = js.handleSegment(markerId, js.lastMarkerName, headerSize, payloadWindow)
 	log.PanicIf(err)
 
 	js.counter++
 
-	jpegLogger.Debugf(nil, "Returning advance of (%d)", i)
+	js.activeLogger().Tracef("Returning advance of (%d)", i)
 
-	return i, nil, nil
+	// The raw segment is returned as the token. If we returned nothing, the
+	// scanner would go back to the reader before calling us again and would
+	// stop at EOF while segments were still buffered.
+	return i, data[:i], nil
 }
 
 func (js *JpegSplitter) parseSof(data []byte) (sof *SofSegment, err error) {
@@ -525,14 +857,66 @@ func (js *JpegSplitter) handleSegment(markerId byte, markerName string, headerSi
 		}
 	}()
 
+	js.handledCount++
+	js.lastHandledIsScanData = markerId == 0x0
+
+	if js.markerFilter != nil && js.markerFilter[markerId] == false {
+		js.activeLogger().Tracef("Segment (%s) at (0x%08x) filtered.", markerName, js.currentOffset)
+
+		js.currentOffset += headerSize + len(payload)
+		js.pendingWarnings = nil
+
+		err := js.reportProgress()
+		log.PanicIf(err)
+
+		return nil
+	}
+
 	cloned := make([]byte, len(payload))
 	copy(cloned, payload)
 
+	if js.options.Lenient == true {
+		if markerId == MARKER_APP0 && isJfifPayload(cloned) == true {
+			if js.jfifSeen == true {
+				js.warn(js.currentOffset, "jfif-repeated", "more than one JFIF segment")
+			}
+
+			js.jfifSeen = true
+		} else if markerId == MARKER_APP1 {
+			if normalized, ok := normalizeExifPreamble(cloned); ok == true {
+				js.warn(js.currentOffset, "exif-preamble", "non-standard EXIF prefix (%q) replaced", cloned[:len(cloned) - len(normalized) + len(exifPrefix)])
+				cloned = normalized
+			}
+		}
+	}
+
+	markerLength := 0
+	if headerSize > 2 {
+		markerLength = headerSize - 2 + len(payload)
+	}
+
+	if expected := expectedPayloadLength(Segment{MarkerId: markerId, Data: cloned}); expected != -1 && expected != len(cloned) {
+		js.warn(js.currentOffset, "suspect-length", "payload length does not match the content: (%d) != (%d)", len(cloned), expected)
+	}
+
+	js.activeLogger().Debugf("Segment (%s) at (0x%08x) with a (%d)-byte payload.", markerName, js.currentOffset, len(payload))
+
 	s := Segment{
 		MarkerId: markerId,
 		MarkerName: markerName,
 		Offset: js.currentOffset,
 		Data: cloned,
+		HeaderSize: headerSize,
+		MarkerLength: markerLength,
+		TotalSize: headerSize + len(payload),
+		Warnings: js.pendingWarnings,
+	}
+
+	js.pendingWarnings = nil
+
+	if js.options.ComputeDigests == true {
+		digest := sha256.Sum256(cloned)
+		s.Digest = digest[:]
 	}
 
 	js.currentOffset += headerSize + len(payload)
@@ -544,7 +928,22 @@ func (js *JpegSplitter) handleSegment(markerId byte, markerName string, headerSi
 		log.PanicIf(err)
 	}
 
-	if markerId >= MARKER_SOF0 && markerId <= MARKER_SOF15 {
+	if sv2, ok := js.visitor.(SegmentVisitorV2); ok == true {
+		si := SegmentInfo{
+			MarkerId: markerId,
+			MarkerName: markerName,
+			Index: len(js.segments) - 1,
+			Counter: js.counter,
+			Offset: s.Offset,
+			PayloadLength: len(cloned),
+			IsScanData: markerId == 0x0,
+		}
+
+		err = sv2.VisitSegment(si)
+		log.PanicIf(err)
+	}
+
+	if IsSofMarker(markerId) == true {
 		ssv, ok := js.visitor.(SofSegmentVisitor)
 		if ok == true {
 			sof, err := js.parseSof(payload)
@@ -553,10 +952,27 @@ func (js *JpegSplitter) handleSegment(markerId byte, markerName string, headerSi
 			err = ssv.HandleSof(sof)
 			log.PanicIf(err)
 		}
-	} else if markerId >= MARKER_APP0 && markerId <= MARKER_APP15 {
+	} else if IsAppMarker(markerId) == true {
 		err := js.parseAppData(markerId, payload)
 		log.PanicIf(err)
 	}
 
+	err = js.reportProgress()
+	log.PanicIf(err)
+
 	return nil
 }
+
+// reportProgress calls the progress hook, if any.
+func (js *JpegSplitter) reportProgress() (err error) {
+	if js.options.Progress == nil {
+		return nil
+	}
+
+	progress := ParseProgress{
+		BytesConsumed: js.currentOffset,
+		SegmentCount: js.handledCount,
+	}
+
+	return js.options.Progress(progress)
+}
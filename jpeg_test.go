@@ -8,6 +8,8 @@ import (
 	"bytes"
 	"reflect"
 
+	"io/ioutil"
+
 	"github.com/dsoprea/go-logging"
 )
 
@@ -96,19 +98,85 @@ func Test_JpegSplitter_Split(t *testing.T) {
 			Height: 2560,
 			ComponentCount: 3,
 		},
-		SofSegment{
-			BitsPerSample: 0,
-			Width: 1281,
-			Height: 1,
-			ComponentCount: 1,
-		},
 	}
 
+	// The DHT shares the SOFn marker range but isn't a frame header.
+
 	if reflect.DeepEqual(v.sofList, expectedSofList) == false {
 		t.Fatalf("SOF segments not equal: %v\n", v.sofList)
 	}
 }
 
+func Test_JpegSplitter_Split_Tokens(t *testing.T) {
+	filepath := path.Join(assetsPath, testImageRelFilepath)
+
+	data, err := ioutil.ReadFile(filepath)
+	log.PanicIf(err)
+
+	js := NewJpegSplitter(nil)
+
+	s := bufio.NewScanner(bytes.NewReader(data))
+	s.Buffer([]byte{}, len(data))
+	s.Split(js.Split)
+
+	// Each token is the raw segment (or scan-data), so together they're the
+	// file.
+	b := new(bytes.Buffer)
+	for s.Scan() == true {
+		b.Write(s.Bytes())
+	}
+
+	log.PanicIf(s.Err())
+
+	if bytes.Equal(b.Bytes(), data) == false {
+		t.Fatalf("Tokens do not reproduce the file: (%d) != (%d)", b.Len(), len(data))
+	}
+}
+
+func Test_JpegSplitter_Split_MultipleScans(t *testing.T) {
+	components := []SofComponent{
+		{ComponentId: 1, HorizontalSamplingFactor: 1, VerticalSamplingFactor: 1},
+	}
+
+	header := SosHeader{
+		Components: []SosComponent{{ComponentId: 1}},
+		SpectralEnd: 63,
+	}
+
+	// Stuffed zeroes, restart markers, and fill bytes don't end the scan-data;
+	// the tables and header of the next scan do.
+	firstScan := []byte{0x12, 0xff, 0x00, 0x34, 0xff, 0xd0, 0x56, 0xff, 0xff, 0xd1, 0x78}
+	secondScan := []byte{0x9a, 0xff, 0x00}
+
+	built, err := NewBuilder().
+		AddQuantTables(QuantizationTable{Values: standardLuminanceQuantTable}).
+		AddFrame(MARKER_SOF2, SofSegment{BitsPerSample: 8, Width: 8, Height: 8}, components).
+		AddScan(header, firstScan).
+		AddHuffmanTables(standardHuffmanTables[0]).
+		AddScan(header, secondScan).
+		Build()
+
+	log.PanicIf(err)
+
+	b := new(bytes.Buffer)
+
+	err = built.Write(b)
+	log.PanicIf(err)
+
+	sl, err := ParseBytesStructure(b.Bytes())
+	log.PanicIf(err)
+
+	if len(sl) != len(built) {
+		t.Fatalf("Segment count not correct: (%d) != (%d)", len(sl), len(built))
+	}
+
+	for i, s := range sl {
+		if s.MarkerId != built[i].MarkerId || bytes.Equal(s.Data, built[i].Data) == false {
+			t.Fatalf("Segment (%d) not correct: (0x%02x)", i, s.MarkerId)
+		}
+	}
+}
+
 func init() {
 	goPath := os.Getenv("GOPATH")
 	if goPath == "" {
@@ -117,3 +185,84 @@ func init() {
 
 	assetsPath = path.Join(goPath, "src", "github.com", "dsoprea", "go-jpeg-structure", "assets")
 }
+
+func TestSegment_HexDump(t *testing.T) {
+	s := Segment{
+		Data: []byte("abcdefghijklmnopqrstuvwxyz"),
+	}
+
+	b := new(bytes.Buffer)
+
+	err := s.HexDump(b, 0)
+	log.PanicIf(err)
+
+	expected := "00000000  61 62 63 64 65 66 67 68  69 6a 6b 6c 6d 6e 6f 70  |abcdefghijklmnop|\n" +
+		"00000010  71 72 73 74 75 76 77 78  79 7a                    |qrstuvwxyz|\n"
+
+	if b.String() != expected {
+		t.Fatalf("Hex dump not correct:\n%s", b.String())
+	}
+
+	b = new(bytes.Buffer)
+
+	err = s.HexDump(b, 4)
+	log.PanicIf(err)
+
+	expected = "00000000  61 62 63 64                                       |abcd|\n" +
+		"... (22 more bytes)\n"
+
+	if b.String() != expected {
+		t.Fatalf("Truncated hex dump not correct:\n%s", b.String())
+	}
+}
+
+type infoVisitor struct {
+	infos []SegmentInfo
+}
+
+func (v *infoVisitor) VisitSegment(si SegmentInfo) error {
+	v.infos = append(v.infos, si)
+	return nil
+}
+
+func TestSegmentVisitorV2(t *testing.T) {
+	filepath := path.Join(assetsPath, testImageRelFilepath)
+
+	f, err := os.Open(filepath)
+	log.PanicIf(err)
+
+	defer f.Close()
+
+	v := new(infoVisitor)
+	js := NewJpegSplitter(v)
+
+	s := bufio.NewScanner(f)
+	s.Buffer([]byte{}, 10 * 1024 * 1024)
+	s.Split(js.Split)
+
+	for ; s.Scan() != false; { }
+
+	if s.Err() != nil {
+		t.Fatalf("error while scanning: %v", s.Err())
+	}
+
+	sl := js.Segments()
+
+	if len(v.infos) != len(sl) {
+		t.Fatalf("Visitor not called for every segment: (%d) != (%d)", len(v.infos), len(sl))
+	}
+
+	for i, si := range v.infos {
+		s := sl[i]
+
+		if si.Index != i || si.MarkerId != s.MarkerId || si.Offset != s.Offset || si.PayloadLength != len(s.Data) || si.IsScanData != (s.MarkerId == 0x0) {
+			t.Fatalf("Segment info (%d) not correct: %s != %v", i, si, s)
+		}
+	}
+
+	// The scan-data isn't counted, so the counter falls behind the index.
+	last := v.infos[len(v.infos) - 1]
+	if last.MarkerId != MARKER_EOI || last.Counter != last.Index - 1 {
+		t.Fatalf("Counter not correct: %s (%d)", last, last.Counter)
+	}
+}
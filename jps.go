@@ -0,0 +1,227 @@
+package jpegstructure
+
+import (
+	"bytes"
+	"fmt"
+
+	"encoding/binary"
+
+	"github.com/dsoprea/go-logging"
+)
+
+const (
+	JPS_MEDIA_MONO = 0
+	JPS_MEDIA_STEREO = 1
+)
+
+const (
+	JPS_LAYOUT_INTERLEAVED = 1
+	JPS_LAYOUT_SIDE_BY_SIDE = 2
+	JPS_LAYOUT_OVER_UNDER = 3
+	JPS_LAYOUT_ANAGLYPH = 4
+)
+
+// Flags of the stereoscopic descriptor. Their meaning depends on the layout.
+const (
+	// JPS_FLAG_HALF_HEIGHT means that each view of an over-under image was
+	// squeezed to half of its height.
+	JPS_FLAG_HALF_HEIGHT = 0x01
+
+	// JPS_FLAG_HALF_WIDTH means that each view of a side-by-side image was
+	// squeezed to half of its width.
+	JPS_FLAG_HALF_WIDTH = 0x02
+
+	// JPS_FLAG_LEFT_FIRST means that the left view comes first (on the left
+	// or the top, or in the first field). Without it, the right view comes
+	// first, which is the usual, cross-eyed, arrangement of JPS files.
+	JPS_FLAG_LEFT_FIRST = 0x04
+)
+
+const (
+	// jpsDescriptorSize is the size of the stereoscopic descriptor block.
+	jpsDescriptorSize = 4
+)
+
+var (
+	jpsPrefix = []byte("_JPSJPS_")
+
+	jpsLayoutNames = map[byte]string{
+		JPS_LAYOUT_INTERLEAVED: "interleaved",
+		JPS_LAYOUT_SIDE_BY_SIDE: "side-by-side",
+		JPS_LAYOUT_OVER_UNDER: "over-under",
+		JPS_LAYOUT_ANAGLYPH: "anaglyph",
+	}
+)
+
+// StereoInfo is the stereoscopic descriptor of an APP3 JPS payload. The
+// payload is the "_JPSJPS_" signature, the length of the descriptor block and
+// the block (a big-endian word with the media type in the low byte, then the
+// layout, the flags, and the separation), and the length of a comment and
+// the comment.
+type StereoInfo struct {
+	// MediaType is JPS_MEDIA_MONO or JPS_MEDIA_STEREO.
+	MediaType byte
+
+	// Layout is JPS_LAYOUT_* (only for stereo images).
+	Layout byte
+
+	// Flags are JPS_FLAG_* (for anaglyphs, the color pair instead).
+	Flags byte
+
+	// Separation is the number of pixels to shift the views apart when they
+	// are displayed.
+	Separation byte
+
+	Comment string
+}
+
+func (si StereoInfo) String() string {
+	return fmt.Sprintf("StereoInfo<MEDIA=(%d) LAYOUT=[%s] FLAGS=(0x%02x) SEPARATION=(%d) COMMENT=[%s]>", si.MediaType, jpsLayoutNames[si.Layout], si.Flags, si.Separation, si.Comment)
+}
+
+// LeftFirst indicates whether the left view comes first.
+func (si StereoInfo) LeftFirst() bool {
+	return si.Flags & JPS_FLAG_LEFT_FIRST != 0
+}
+
+// isJpsPayload indicates whether an APP3 payload carries a JPS descriptor.
+func isJpsPayload(data []byte) bool {
+	return bytes.HasPrefix(data, jpsPrefix) == true
+}
+
+// ParseStereoInfo parses the payload of an APP3 JPS segment.
+func ParseStereoInfo(data []byte) (si *StereoInfo, err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	if isJpsPayload(data) == false {
+		log.Panicf("not a JPS payload")
+	}
+
+	raw := data[len(jpsPrefix):]
+	if len(raw) < 2 {
+		log.Panicf("JPS payload too short: (%d)", len(data))
+	}
+
+	blockSize := int(binary.BigEndian.Uint16(raw))
+	raw = raw[2:]
+
+	if blockSize < jpsDescriptorSize || blockSize > len(raw) {
+		log.Panicf("JPS descriptor size not valid: (%d)", blockSize)
+	}
+
+	descriptor := binary.BigEndian.Uint32(raw)
+
+	si = &StereoInfo{
+		MediaType: byte(descriptor),
+		Layout: byte(descriptor >> 8),
+		Flags: byte(descriptor >> 16),
+		Separation: byte(descriptor >> 24),
+	}
+
+	raw = raw[blockSize:]
+
+	// The comment is optional.
+	if len(raw) >= 2 {
+		commentSize := int(binary.BigEndian.Uint16(raw))
+		raw = raw[2:]
+
+		if commentSize > len(raw) {
+			log.Panicf("JPS comment size not valid: (%d)", commentSize)
+		}
+
+		si.Comment = string(bytes.TrimRight(raw[:commentSize], "\x00"))
+	}
+
+	return si, nil
+}
+
+// Encode produces an APP3 payload.
+func (si StereoInfo) Encode() []byte {
+	data := make([]byte, 0, len(jpsPrefix) + 2 + jpsDescriptorSize + 2 + len(si.Comment))
+	data = append(data, jpsPrefix...)
+
+	var raw [2 + jpsDescriptorSize + 2]byte
+	binary.BigEndian.PutUint16(raw[0:], jpsDescriptorSize)
+
+	descriptor := uint32(si.MediaType) | uint32(si.Layout) << 8 | uint32(si.Flags) << 16 | uint32(si.Separation) << 24
+	binary.BigEndian.PutUint32(raw[2:], descriptor)
+
+	binary.BigEndian.PutUint16(raw[2 + jpsDescriptorSize:], uint16(len(si.Comment)))
+
+	data = append(data, raw[:]...)
+	data = append(data, si.Comment...)
+
+	return data
+}
+
+// StereoInfo returns the descriptor from the first JPS APP3 segment.
+func (sl SegmentList) StereoInfo() (si *StereoInfo, err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	s, err := sl.findFirstWithPrefix(MARKER_APP3, jpsPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	si, err = ParseStereoInfo(s.Data)
+	log.PanicIf(err)
+
+	return si, nil
+}
+
+// SetStereoInfo replaces the JPS APP3 segment or, if there isn't one, adds
+// one after the SOI and any APP0 through APP2 segments.
+func (sl *SegmentList) SetStereoInfo(si StereoInfo) (err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	payload := si.Encode()
+	if len(payload) > maxSegmentPayloadSize {
+		log.Panicf("JPS comment too large for one segment: (%d)", len(si.Comment))
+	}
+
+	for i, s := range *sl {
+		if s.MarkerId == MARKER_APP3 && isJpsPayload(s.Data) == true {
+			(*sl)[i].setData(payload)
+			sl.updateOffsets()
+
+			return nil
+		}
+	}
+
+	position := 0
+	for i, s := range *sl {
+		if s.MarkerId == MARKER_SOI || s.MarkerId == MARKER_APP0 || s.MarkerId == MARKER_APP1 || s.MarkerId == MARKER_APP2 {
+			position = i + 1
+		} else {
+			break
+		}
+	}
+
+	s := Segment{
+		MarkerId: MARKER_APP3,
+		MarkerName: markerNames[MARKER_APP3],
+		Data: payload,
+	}
+
+	updated := make(SegmentList, 0, len(*sl) + 1)
+	updated = append(updated, (*sl)[:position]...)
+	updated = append(updated, s)
+	updated = append(updated, (*sl)[position:]...)
+
+	updated.updateOffsets()
+
+	*sl = updated
+	return nil
+}
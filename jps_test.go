@@ -0,0 +1,96 @@
+package jpegstructure
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/dsoprea/go-logging"
+)
+
+func TestParseStereoInfo(t *testing.T) {
+	// A side-by-side descriptor as written by stereo cameras: stereo media,
+	// half-width views, a separation of 10, and a comment.
+	data := []byte("_JPSJPS_\x00\x04\x0a\x02\x02\x01\x00\x05hello")
+
+	si, err := ParseStereoInfo(data)
+	log.PanicIf(err)
+
+	expected := StereoInfo{
+		MediaType: JPS_MEDIA_STEREO,
+		Layout: JPS_LAYOUT_SIDE_BY_SIDE,
+		Flags: JPS_FLAG_HALF_WIDTH,
+		Separation: 10,
+		Comment: "hello",
+	}
+
+	if *si != expected {
+		t.Fatalf("Descriptor not correct: %s", si)
+	} else if si.LeftFirst() == true {
+		t.Fatalf("Right view should come first.")
+	} else if bytes.Equal(si.Encode(), data) == false {
+		t.Fatalf("Encoded descriptor not correct: %x", si.Encode())
+	}
+
+	// The comment is optional.
+	si, err = ParseStereoInfo(data[:len(jpsPrefix) + 2 + jpsDescriptorSize])
+	log.PanicIf(err)
+
+	if si.Layout != JPS_LAYOUT_SIDE_BY_SIDE || si.Comment != "" {
+		t.Fatalf("Descriptor without comment not correct: %s", si)
+	}
+
+	_, err = ParseStereoInfo([]byte("_JPSJPS_\x00\x08\x00\x00"))
+	if err == nil {
+		t.Fatalf("Expected error for a truncated descriptor.")
+	}
+}
+
+func TestSegmentList_SetStereoInfo(t *testing.T) {
+	sl, err := ParseBytesStructure(getTransformTestImage())
+	log.PanicIf(err)
+
+	_, err = sl.StereoInfo()
+	if log.Is(err, ErrSegmentNotFound) == false {
+		t.Fatalf("Expected no descriptor: %v", err)
+	}
+
+	si := StereoInfo{
+		MediaType: JPS_MEDIA_STEREO,
+		Layout: JPS_LAYOUT_OVER_UNDER,
+		Flags: JPS_FLAG_LEFT_FIRST,
+		Separation: 4,
+	}
+
+	err = sl.SetStereoInfo(si)
+	log.PanicIf(err)
+
+	si.Separation = 6
+
+	// Replaced rather than added.
+	err = sl.SetStereoInfo(si)
+	log.PanicIf(err)
+
+	if len(sl.FindWithPrefix(MARKER_APP3, jpsPrefix)) != 1 {
+		t.Fatalf("Expected one JPS segment.")
+	}
+
+	b := new(bytes.Buffer)
+
+	err = sl.Write(b)
+	log.PanicIf(err)
+
+	parsed, err := ParseBytesStructure(b.Bytes())
+	log.PanicIf(err)
+
+	// The image has no other APPn segments.
+	if i := parsed.Index(MARKER_APP3); i != 1 {
+		t.Fatalf("JPS segment not after the SOI: (%d)", i)
+	}
+
+	recovered, err := parsed.StereoInfo()
+	log.PanicIf(err)
+
+	if *recovered != si || recovered.LeftFirst() == false {
+		t.Fatalf("Descriptor not correct: %s", recovered)
+	}
+}
@@ -0,0 +1,558 @@
+package jpegstructure
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"encoding/binary"
+
+	"github.com/dsoprea/go-logging"
+)
+
+// LintLevel selects how thorough Lint() is. Each level includes the checks of
+// the levels below it.
+type LintLevel int
+
+const (
+	// LintLevelStructure checks the marker layout (offsets, SOI, EOI).
+	LintLevelStructure LintLevel = iota
+
+	// LintLevelDecoding also checks that a decoder has what it needs (frame
+	// before scan, referenced tables and components exist).
+	LintLevelDecoding
+
+	// LintLevelMetadata also checks the metadata segments (APPn ordering, ICC
+	// chunking, EXIF headers).
+	LintLevelMetadata
+
+	// LintLevelStrict also reports things that decoders tolerate but the
+	// standard doesn't allow (or that are suspect): stray segments after the
+	// scan, payload lengths that disagree with their content, unrecognized
+	// APPn segments, and out-of-order ICC chunks. See CheckStrict().
+	LintLevelStrict
+)
+
+var (
+	// ErrStrictViolation is returned by CheckStrict() when the image has any
+	// findings.
+	ErrStrictViolation = errors.New("image violates the strict profile")
+)
+
+var (
+	// strictAppSignatures are the APPn payload prefixes that the strict
+	// profile recognizes. This is deliberately narrower than Identify() (and
+	// isn't affected by RegisterAppSignature()), so that what's accepted
+	// doesn't change as signatures are added.
+	strictAppSignatures = [][]byte{
+		jfifPrefix,
+		jfxxPrefix,
+		exifPrefix,
+		xmpPrefix,
+		extendedXmpPrefix,
+		iccPrefix,
+		mpfPrefix,
+		adobePrefix,
+		duckyPrefix,
+		jpsPrefix,
+		[]byte("Photoshop 3.0\x00"),
+	}
+)
+
+// LintSeverity indicates how serious a finding is.
+type LintSeverity int
+
+const (
+	LintWarning LintSeverity = iota
+	LintError
+)
+
+func (ls LintSeverity) String() string {
+	if ls == LintError {
+		return "error"
+	}
+
+	return "warning"
+}
+
+// LintFinding is one problem found by Lint().
+type LintFinding struct {
+	Severity LintSeverity
+
+	// Code is a short, stable identifier for the check (e.g. "soi-count").
+	Code string
+
+	// SegmentIndex is the index of the segment in question or -1 if the
+	// finding concerns the whole image.
+	SegmentIndex int
+
+	Message string
+}
+
+func (lf LintFinding) String() string {
+	return fmt.Sprintf("LintFinding<SEVERITY=[%s] CODE=[%s] SEGMENT=(%d) MESSAGE=[%s]>", lf.Severity, lf.Code, lf.SegmentIndex, lf.Message)
+}
+
+type linter struct {
+	sl SegmentList
+	data []byte
+	findings []LintFinding
+}
+
+func (l *linter) add(severity LintSeverity, code string, segmentIndex int, format string, args ...interface{}) {
+	lf := LintFinding{
+		Severity: severity,
+		Code: code,
+		SegmentIndex: segmentIndex,
+		Message: fmt.Sprintf(format, args...),
+	}
+
+	l.findings = append(l.findings, lf)
+}
+
+func (l *linter) checkStructure() {
+	soiCount := 0
+	eoiCount := 0
+	lastOffset := -1
+
+	for i, s := range l.sl {
+		if s.MarkerId == MARKER_SOI {
+			soiCount++
+
+			if i != 0 {
+				l.add(LintError, "soi-position", i, "SOI is not the first segment")
+			}
+		} else if s.MarkerId == MARKER_EOI {
+			eoiCount++
+
+			if i != len(l.sl) - 1 {
+				l.add(LintError, "eoi-position", i, "EOI is not the last segment")
+			}
+		}
+
+		if s.Offset <= lastOffset {
+			l.add(LintError, "offset-order", i, "offset (0x%08x) not greater than the last (0x%08x)", s.Offset, lastOffset)
+		}
+
+		lastOffset = s.Offset
+
+		if l.data == nil || s.MarkerId == 0x0 {
+			continue
+		}
+
+		o := s.Offset
+		if o + 2 > len(l.data) || bytes.Compare(l.data[o:o + 2], []byte{0xff, s.MarkerId}) != 0 {
+			l.add(LintError, "offset-marker", i, "offset (0x%08x) does not point to the marker", s.Offset)
+		}
+	}
+
+	if soiCount != 1 {
+		l.add(LintError, "soi-count", -1, "expected exactly one SOI: (%d)", soiCount)
+	}
+
+	if eoiCount != 1 {
+		l.add(LintError, "eoi-count", -1, "expected exactly one EOI: (%d)", eoiCount)
+	}
+}
+
+func (l *linter) checkDecoding() {
+	var frameComponents []SofComponent
+	frameMarkerId := byte(0)
+
+	quantTables := make(map[byte]bool)
+	huffmanTables := make(map[[2]byte]bool)
+
+	scanCount := 0
+
+	// Hierarchical images have a frame per resolution.
+	isHierarchical := l.sl.IsHierarchical()
+
+	for i, s := range l.sl {
+		switch {
+		case s.MarkerId == MARKER_DQT:
+			tables, err := ParseQuantizationTables(s.Data)
+			if err != nil {
+				l.add(LintError, "dqt-invalid", i, "DQT not valid: %s", err.Error())
+				continue
+			}
+
+			for _, qt := range tables {
+				quantTables[qt.TableId] = true
+			}
+		case s.MarkerId == MARKER_DHT:
+			tables, err := ParseHuffmanTables(s.Data)
+			if err != nil {
+				l.add(LintError, "dht-invalid", i, "DHT not valid: %s", err.Error())
+				continue
+			}
+
+			for _, ht := range tables {
+				huffmanTables[[2]byte{ht.Class, ht.TableId}] = true
+			}
+		case s.MarkerId == MARKER_DAC:
+			_, err := ParseArithmeticConditioning(s.Data)
+			if err != nil {
+				l.add(LintError, "dac-invalid", i, "DAC not valid: %s", err.Error())
+				continue
+			}
+		case IsSofMarker(s.MarkerId) == true:
+			if frameComponents != nil && isHierarchical == false {
+				l.add(LintError, "sof-count", i, "more than one frame header")
+				continue
+			}
+
+			components, err := ParseSofComponents(s.Data)
+			if err != nil {
+				l.add(LintError, "sof-invalid", i, "SOF not valid: %s", err.Error())
+				continue
+			}
+
+			frameComponents = components
+			frameMarkerId = s.MarkerId
+		case s.MarkerId == MARKER_SOS:
+			scanCount++
+
+			if frameComponents == nil {
+				l.add(LintError, "sos-before-sof", i, "scan appears before any frame header")
+				continue
+			}
+
+			for _, fc := range frameComponents {
+				if quantTables[fc.QuantizationTableId] == false {
+					l.add(LintError, "dqt-missing", i, "quantization table (%d) for component (%d) not defined before the scan", fc.QuantizationTableId, fc.ComponentId)
+				}
+			}
+
+			// The scan header is carried at the front of the scan-data.
+			if i + 1 >= len(l.sl) || l.sl[i + 1].MarkerId != 0x0 {
+				l.add(LintError, "sos-no-data", i, "scan has no data")
+				continue
+			}
+
+			header, _, err := splitScanData(l.sl[i + 1].Data)
+			if err != nil {
+				l.add(LintError, "sos-invalid", i, "scan header not valid: %s", err.Error())
+				continue
+			}
+
+			sh, err := ParseSosHeader(header)
+			if err != nil {
+				l.add(LintError, "sos-invalid", i, "scan header not valid: %s", err.Error())
+				continue
+			}
+
+			l.checkScanTables(i, sh, frameComponents, frameMarkerId, huffmanTables)
+		}
+	}
+
+	if frameComponents == nil {
+		l.add(LintError, "sof-missing", -1, "no frame header")
+	}
+
+	if scanCount == 0 {
+		l.add(LintError, "sos-missing", -1, "no scans")
+	}
+}
+
+func (l *linter) checkScanTables(i int, sh *SosHeader, frameComponents []SofComponent, frameMarkerId byte, huffmanTables map[[2]byte]bool) {
+	// Arithmetic-coded processes use conditioning tables rather than Huffman
+	// tables.
+	isArithmetic := frameMarkerId >= MARKER_SOF9
+
+	// Progressive scans only need the tables of the coefficients that they
+	// carry.
+	needsDc := sh.SpectralStart == 0
+	needsAc := sh.SpectralEnd > 0
+
+	for _, sc := range sh.Components {
+		found := false
+		for _, fc := range frameComponents {
+			if fc.ComponentId == sc.ComponentId {
+				found = true
+				break
+			}
+		}
+
+		if found == false {
+			l.add(LintError, "sos-component", i, "scan references component (%d) not in the frame", sc.ComponentId)
+		}
+
+		if isArithmetic == true {
+			continue
+		}
+
+		// A DC refinement scan doesn't need a table.
+		if needsDc == true && sh.SuccessiveHigh == 0 && huffmanTables[[2]byte{HUFFMAN_CLASS_DC, sc.DcTableId}] == false {
+			l.add(LintError, "dht-missing", i, "DC Huffman table (%d) for component (%d) not defined before the scan", sc.DcTableId, sc.ComponentId)
+		}
+
+		if needsAc == true && huffmanTables[[2]byte{HUFFMAN_CLASS_AC, sc.AcTableId}] == false {
+			l.add(LintError, "dht-missing", i, "AC Huffman table (%d) for component (%d) not defined before the scan", sc.AcTableId, sc.ComponentId)
+		}
+	}
+}
+
+func (l *linter) checkMetadata() {
+	jfifAt := -1
+	exifAt := -1
+
+	iccCount := -1
+	iccSeen := make(map[int]bool)
+
+	for i, s := range l.sl {
+		if s.MarkerId == MARKER_APP0 && isJfifPayload(s.Data) == true {
+			if jfifAt != -1 {
+				l.add(LintWarning, "jfif-repeated", i, "more than one JFIF segment")
+				continue
+			}
+
+			jfifAt = i
+
+			if i != 1 {
+				l.add(LintWarning, "jfif-position", i, "JFIF segment does not immediately follow the SOI")
+			}
+		} else if s.Kind() == SegmentKindExifApp1 {
+			if exifAt != -1 {
+				l.add(LintWarning, "exif-repeated", i, "more than one EXIF segment")
+				continue
+			}
+
+			exifAt = i
+
+			expected := 1
+			if jfifAt != -1 {
+				expected = jfifAt + 1
+			}
+
+			if i != expected {
+				l.add(LintWarning, "exif-position", i, "EXIF segment does not immediately follow the SOI (or JFIF)")
+			}
+
+			l.checkExif(i, s.Data[len(exifPrefix):])
+		} else if s.MarkerId == MARKER_APP2 && isIccPayload(s.Data) == true {
+			sequence := int(s.Data[len(iccPrefix)])
+			count := int(s.Data[len(iccPrefix) + 1])
+
+			if iccCount == -1 {
+				iccCount = count
+			} else if count != iccCount {
+				l.add(LintError, "icc-count", i, "ICC chunk-count not consistent: (%d) != (%d)", count, iccCount)
+			}
+
+			if sequence < 1 || sequence > count {
+				l.add(LintError, "icc-sequence", i, "ICC chunk sequence out of range: (%d) of (%d)", sequence, count)
+			} else if iccSeen[sequence] == true {
+				l.add(LintError, "icc-repeated", i, "ICC chunk repeated: (%d)", sequence)
+			}
+
+			iccSeen[sequence] = true
+		}
+	}
+
+	if exifAt != -1 && jfifAt > exifAt {
+		l.add(LintWarning, "app-order", jfifAt, "JFIF segment follows the EXIF segment")
+	}
+
+	for sequence := 1; sequence <= iccCount; sequence++ {
+		if iccSeen[sequence] == false {
+			l.add(LintError, "icc-missing", -1, "ICC chunk missing: (%d) of (%d)", sequence, iccCount)
+		}
+	}
+}
+
+// expectedPayloadLength returns the payload length that the content of a table
+// or frame segment implies, or -1 if it isn't one of those (or can't be
+// parsed).
+func expectedPayloadLength(s Segment) int {
+	switch {
+	case s.MarkerId == MARKER_DQT:
+		tables, err := ParseQuantizationTables(s.Data)
+		if err != nil {
+			return -1
+		}
+
+		length := 0
+		for _, qt := range tables {
+			length += 1 + 64 * (1 + int(qt.Precision))
+		}
+
+		return length
+	case s.MarkerId == MARKER_DHT:
+		tables, err := ParseHuffmanTables(s.Data)
+		if err != nil {
+			return -1
+		}
+
+		length := 0
+		for _, ht := range tables {
+			length += 1 + 16 + len(ht.Symbols)
+		}
+
+		return length
+	case s.MarkerId == MARKER_DAC:
+		return len(s.Data) - len(s.Data) % dacEntrySize
+	case s.MarkerId == MARKER_DRI:
+		return 2
+	case IsSofMarker(s.MarkerId) == true:
+		if len(s.Data) < 6 {
+			return -1
+		}
+
+		return 6 + 3 * int(s.Data[5])
+	}
+
+	return -1
+}
+
+func (l *linter) checkStrict() {
+	scanCount := 0
+	for _, s := range l.sl {
+		if s.MarkerId == MARKER_SOS {
+			scanCount++
+		}
+	}
+
+	isHierarchical := l.sl.IsHierarchical()
+
+	afterScan := false
+	lastIccSequence := 0
+
+	for i, s := range l.sl {
+		// Offsets are only meaningful for the original image.
+		if l.data != nil && i > 0 && s.Offset != l.sl[i - 1].EndOffset() {
+			l.add(LintError, "segment-gap", i, "segment does not start where the last ended: (0x%08x) != (0x%08x)", s.Offset, l.sl[i - 1].EndOffset())
+		}
+
+		if afterScan == true && isHierarchical == false {
+			allowed := s.MarkerId == 0x0 || s.MarkerId == MARKER_DNL || s.MarkerId == MARKER_EOI || IsRstMarker(s.MarkerId) == true
+
+			// Images with several scans (e.g. progressive ones) define the
+			// tables of each scan before it.
+			if scanCount > 1 && (s.MarkerId == MARKER_SOS || s.MarkerId == MARKER_DHT || s.MarkerId == MARKER_DQT || s.MarkerId == MARKER_DRI || s.MarkerId == MARKER_DAC) {
+				allowed = true
+			}
+
+			if allowed == false {
+				l.add(LintError, "after-scan", i, "segment (%s) not allowed after the scan", markerNames[s.MarkerId])
+			}
+		}
+
+		if s.MarkerId == MARKER_SOS {
+			afterScan = true
+		}
+
+		if expected := expectedPayloadLength(s); expected != -1 && expected != len(s.Data) {
+			l.add(LintError, "length-mismatch", i, "payload length does not match the content: (%d) != (%d)", len(s.Data), expected)
+		}
+
+		if IsAppMarker(s.MarkerId) == true {
+			recognized := false
+			for _, signature := range strictAppSignatures {
+				if bytes.HasPrefix(s.Data, signature) == true {
+					recognized = true
+					break
+				}
+			}
+
+			if recognized == false {
+				l.add(LintError, "app-signature", i, "APPn segment has no recognized signature")
+			}
+		}
+
+		if s.MarkerId == MARKER_APP2 && isIccPayload(s.Data) == true {
+			sequence := int(s.Data[len(iccPrefix)])
+			if sequence != lastIccSequence + 1 {
+				l.add(LintError, "icc-order", i, "ICC chunk out of order: (%d) follows (%d)", sequence, lastIccSequence)
+			}
+
+			lastIccSequence = sequence
+		}
+	}
+}
+
+// CheckStrict runs Lint() at LintLevelStrict and fails with
+// ErrStrictViolation if there are any findings at all, warnings included. The
+// findings are returned either way. This is for ingestion that must only
+// accept clean files. `data` may be nil (see Lint()).
+func (sl SegmentList) CheckStrict(data []byte) (findings []LintFinding, err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	findings, err = sl.Lint(data, LintLevelStrict)
+	log.PanicIf(err)
+
+	if len(findings) > 0 {
+		return findings, ErrStrictViolation
+	}
+
+	return findings, nil
+}
+
+func (l *linter) checkExif(i int, data []byte) {
+	if len(data) < 8 {
+		l.add(LintError, "exif-header", i, "EXIF data too short for a TIFF header")
+		return
+	}
+
+	var byteOrder binary.ByteOrder
+	if data[0] == 'I' && data[1] == 'I' {
+		byteOrder = binary.LittleEndian
+	} else if data[0] == 'M' && data[1] == 'M' {
+		byteOrder = binary.BigEndian
+	} else {
+		l.add(LintError, "exif-byte-order", i, "TIFF byte-order not valid: (%02x) (%02x)", data[0], data[1])
+		return
+	}
+
+	if byteOrder.Uint16(data[2:]) != 0x2a {
+		l.add(LintError, "exif-byte-order", i, "TIFF magic not valid for the declared byte-order")
+		return
+	}
+
+	ifdOffset := byteOrder.Uint32(data[4:])
+	if ifdOffset < 8 || int(ifdOffset) >= len(data) {
+		l.add(LintError, "exif-ifd-offset", i, "IFD0 offset out of range: (%d)", ifdOffset)
+		return
+	}
+
+	if _, err := ParseExifDocument(data); err != nil {
+		l.add(LintError, "exif-invalid", i, "EXIF data not valid: %s", err.Error())
+	}
+}
+
+// Lint checks the structure for problems and returns every finding (rather
+// than stopping at the first). `data` is the original image and is used to
+// confirm the offsets; it may be nil to skip that check. The returned error
+// is only for failures of the linting itself.
+func (sl SegmentList) Lint(data []byte, level LintLevel) (findings []LintFinding, err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	l := &linter{
+		sl: sl,
+		data: data,
+		findings: make([]LintFinding, 0),
+	}
+
+	l.checkStructure()
+
+	if level >= LintLevelDecoding {
+		l.checkDecoding()
+	}
+
+	if level >= LintLevelMetadata {
+		l.checkMetadata()
+	}
+
+	if level >= LintLevelStrict {
+		l.checkStrict()
+	}
+
+	return l.findings, nil
+}
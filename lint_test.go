@@ -0,0 +1,186 @@
+package jpegstructure
+
+import (
+	"path"
+	"testing"
+
+	"io/ioutil"
+
+	"github.com/dsoprea/go-logging"
+)
+
+func lintCodes(findings []LintFinding) map[string]bool {
+	codes := make(map[string]bool)
+	for _, lf := range findings {
+		codes[lf.Code] = true
+	}
+
+	return codes
+}
+
+func TestSegmentList_Lint(t *testing.T) {
+	for _, filename := range []string { testImageRelFilepath, "20180428_212314.jpg" } {
+		filepath := path.Join(assetsPath, filename)
+
+		data, err := ioutil.ReadFile(filepath)
+		log.PanicIf(err)
+
+		sl, err := ParseBytesStructure(data)
+		log.PanicIf(err)
+
+		findings, err := sl.Lint(data, LintLevelMetadata)
+		log.PanicIf(err)
+
+		if len(findings) != 0 {
+			t.Fatalf("Unexpected findings for [%s]: %v", filename, findings)
+		}
+	}
+}
+
+func TestSegmentList_Lint_Problems(t *testing.T) {
+	filepath := path.Join(assetsPath, "20180428_212314.jpg")
+
+	sl, err := ParseFileStructure(filepath)
+	log.PanicIf(err)
+
+	// Drop the DHT segments, put the EXIF before the JFIF, and repeat the EOI.
+	broken := SegmentList { sl[0], sl[2], sl[1], sl[3], sl[4], sl[9], sl[10], sl[11], sl[11] }
+
+	findings, err := broken.Lint(nil, LintLevelStructure)
+	log.PanicIf(err)
+
+	codes := lintCodes(findings)
+	if codes["eoi-count"] == false || codes["eoi-position"] == false || codes["dht-missing"] == true {
+		t.Fatalf("Structure findings not correct: %v", findings)
+	}
+
+	findings, err = broken.Lint(nil, LintLevelMetadata)
+	log.PanicIf(err)
+
+	codes = lintCodes(findings)
+	for _, code := range []string { "dht-missing", "jfif-position", "app-order" } {
+		if codes[code] == false {
+			t.Fatalf("Expected finding [%s]: %v", code, findings)
+		}
+	}
+
+	if codes["sof-missing"] == true || codes["exif-position"] == true {
+		t.Fatalf("Unexpected findings: %v", findings)
+	}
+}
+
+func TestSegmentList_CheckStrict(t *testing.T) {
+	for _, filename := range []string { testImageRelFilepath, "20180428_212314.jpg" } {
+		filepath := path.Join(assetsPath, filename)
+
+		data, err := ioutil.ReadFile(filepath)
+		log.PanicIf(err)
+
+		sl, err := ParseBytesStructure(data)
+		log.PanicIf(err)
+
+		findings, err := sl.CheckStrict(data)
+		if err != nil {
+			t.Fatalf("Clean image [%s] rejected: %v", filename, findings)
+		}
+	}
+}
+
+func TestSegmentList_CheckStrict_Violations(t *testing.T) {
+	filepath := path.Join(assetsPath, "20180428_212314.jpg")
+
+	sl, err := ParseFileStructure(filepath)
+	log.PanicIf(err)
+
+	iccChunk := func(sequence byte) Segment {
+		data := append([]byte{}, iccPrefix...)
+		data = append(data, sequence, 2, 0x00)
+
+		return Segment{MarkerId: MARKER_APP2, Data: data}
+	}
+
+	// SOF with a trailing byte.
+	sof := sl[4]
+	sof.Data = append(append([]byte{}, sof.Data...), 0x00)
+
+	broken := SegmentList {
+		sl[0],
+		sl[1],
+		sl[2],
+		iccChunk(2),
+		iccChunk(1),
+		{MarkerId: MARKER_APP5, Data: []byte("unknown")},
+		sl[3],
+		sof,
+		sl[5], sl[6], sl[7], sl[8], sl[9], sl[10],
+		{MarkerId: MARKER_COM, Data: []byte("after the scan")},
+		sl[11],
+	}
+
+	findings, err := broken.CheckStrict(nil)
+	if err == nil {
+		t.Fatalf("Expected error.")
+	} else if log.Is(err, ErrStrictViolation) == false {
+		t.Fatalf("Error not correct: %v", err)
+	}
+
+	codes := lintCodes(findings)
+	for _, code := range []string { "icc-order", "app-signature", "length-mismatch", "after-scan" } {
+		if codes[code] == false {
+			t.Fatalf("Finding [%s] expected: %v", code, findings)
+		}
+	}
+
+	// The other levels don't report these.
+
+	findings, err = broken.Lint(nil, LintLevelMetadata)
+	log.PanicIf(err)
+
+	codes = lintCodes(findings)
+	if codes["icc-order"] == true || codes["app-signature"] == true || codes["after-scan"] == true {
+		t.Fatalf("Strict findings reported at a lower level: %v", findings)
+	}
+}
+
+func TestSegmentList_CheckStrict_AppSignatures(t *testing.T) {
+	filepath := path.Join(assetsPath, "20180428_212314.jpg")
+
+	sl, err := ParseFileStructure(filepath)
+	log.PanicIf(err)
+
+	// Signatures that Identify() knows but the strict profile doesn't accept,
+	// including one registered at runtime.
+	RegisterAppSignature(AppSignature{MARKER_APP10, []byte("StrictTest\x00"), "StrictTest", "test"})
+
+	extra := []Segment{
+		{MarkerId: MARKER_APP2, Data: []byte("FPXR\x00\x00\x01")},
+		{MarkerId: MARKER_APP10, Data: []byte("StrictTest\x00data")},
+	}
+
+	for _, s := range extra {
+		if _, found := Identify(s); found == false {
+			t.Fatalf("Signature not identified: (0x%02x)", s.MarkerId)
+		}
+	}
+
+	edited := make(SegmentList, 0, len(sl) + len(extra))
+	edited = append(edited, sl[:3]...)
+	edited = append(edited, extra...)
+	edited = append(edited, sl[3:]...)
+
+	findings, err := edited.CheckStrict(nil)
+	if err == nil {
+		t.Fatalf("Expected error.")
+	}
+
+	flagged := make(map[int]bool)
+	for _, lf := range findings {
+		if lf.Code == "app-signature" {
+			flagged[lf.SegmentIndex] = true
+		}
+	}
+
+	if flagged[3] == false || flagged[4] == false {
+		t.Fatalf("Signatures not rejected by the strict profile: %v", findings)
+	}
+}
@@ -0,0 +1,30 @@
+package jpegstructure
+
+// Logger receives the diagnostics of a JpegSplitter. Debugf is called for
+// each segment and for each warning, and Tracef for each decision made while
+// finding the markers (which is very verbose).
+type Logger interface {
+	Debugf(format string, args ...interface{})
+	Tracef(format string, args ...interface{})
+}
+
+// goLoggingLogger sends everything to the package's go-logging logger at the
+// debug level. It's the default.
+type goLoggingLogger struct{}
+
+func (goLoggingLogger) Debugf(format string, args ...interface{}) {
+	jpegLogger.Debugf(nil, format, args...)
+}
+
+func (goLoggingLogger) Tracef(format string, args ...interface{}) {
+	jpegLogger.Debugf(nil, format, args...)
+}
+
+// NopLogger discards everything.
+type NopLogger struct{}
+
+func (NopLogger) Debugf(format string, args ...interface{}) {
+}
+
+func (NopLogger) Tracef(format string, args ...interface{}) {
+}
@@ -0,0 +1,47 @@
+//go:build go1.21
+
+package jpegstructure
+
+import (
+	"context"
+	"fmt"
+
+	"log/slog"
+)
+
+const (
+	// SlogLevelTrace is the level that SlogLogger logs the trace messages at.
+	SlogLevelTrace = slog.LevelDebug - 4
+)
+
+// SlogLogger sends the diagnostics to a slog logger.
+type SlogLogger struct {
+	l *slog.Logger
+}
+
+// NewSlogLogger returns a Logger that logs debug messages at
+// slog.LevelDebug and trace messages at SlogLevelTrace.
+func NewSlogLogger(l *slog.Logger) *SlogLogger {
+	return &SlogLogger{
+		l: l,
+	}
+}
+
+func (sl *SlogLogger) logf(level slog.Level, format string, args ...interface{}) {
+	ctx := context.Background()
+
+	// Skip the formatting if nothing will be logged.
+	if sl.l.Enabled(ctx, level) == false {
+		return
+	}
+
+	sl.l.Log(ctx, level, fmt.Sprintf(format, args...))
+}
+
+func (sl *SlogLogger) Debugf(format string, args ...interface{}) {
+	sl.logf(slog.LevelDebug, format, args...)
+}
+
+func (sl *SlogLogger) Tracef(format string, args ...interface{}) {
+	sl.logf(SlogLevelTrace, format, args...)
+}
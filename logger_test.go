@@ -0,0 +1,71 @@
+package jpegstructure
+
+import (
+	"fmt"
+	"path"
+	"testing"
+
+	"io/ioutil"
+
+	"github.com/dsoprea/go-logging"
+)
+
+type recordingLogger struct {
+	debug []string
+	trace []string
+}
+
+func (rl *recordingLogger) Debugf(format string, args ...interface{}) {
+	rl.debug = append(rl.debug, fmt.Sprintf(format, args...))
+}
+
+func (rl *recordingLogger) Tracef(format string, args ...interface{}) {
+	rl.trace = append(rl.trace, fmt.Sprintf(format, args...))
+}
+
+func TestParseOptions_Logger(t *testing.T) {
+	filepath := path.Join(assetsPath, testImageRelFilepath)
+
+	data, err := ioutil.ReadFile(filepath)
+	log.PanicIf(err)
+
+	rl := new(recordingLogger)
+
+	options := ParseOptions{
+		Logger: rl,
+	}
+
+	sl, err := ParseBytesStructureWithOptions(data, options)
+	log.PanicIf(err)
+
+	if len(rl.debug) != len(sl) {
+		t.Fatalf("Debug message count not correct: (%d) != (%d)", len(rl.debug), len(sl))
+	} else if len(rl.trace) == 0 {
+		t.Fatalf("No trace messages.")
+	}
+
+	expected := "Segment (SOI) at (0x00000000) with a (0)-byte payload."
+	if rl.debug[0] != expected {
+		t.Fatalf("First debug message not correct: [%s]", rl.debug[0])
+	}
+}
+
+func TestJpegSplitter_SetLogger(t *testing.T) {
+	filepath := path.Join(assetsPath, testImageRelFilepath)
+
+	data, err := ioutil.ReadFile(filepath)
+	log.PanicIf(err)
+
+	rl := new(recordingLogger)
+
+	js := NewJpegSplitter(nil)
+	js.SetLogger(rl)
+	js.SetLogger(NopLogger{})
+
+	_, _, err = js.Split(data, true)
+	log.PanicIf(err)
+
+	if len(rl.debug) != 0 || len(rl.trace) != 0 {
+		t.Fatalf("Replaced logger was called.")
+	}
+}
@@ -0,0 +1,122 @@
+package jpegstructure
+
+import (
+	"bytes"
+
+	"encoding/binary"
+)
+
+const (
+	exifTagMakerNote = 0x927c
+
+	// makerNoteMaxEntries bounds the IFD entry-count that we'll believe when
+	// probing a MakerNote.
+	makerNoteMaxEntries = 1000
+)
+
+var (
+	// makerNoteSelfRelativePrefixes identify MakerNotes whose offsets are
+	// relative to the MakerNote itself (or to an embedded TIFF header) and
+	// that can therefore be moved freely.
+	makerNoteSelfRelativePrefixes = [][]byte{
+		[]byte("Nikon\x00\x02"),
+		[]byte("FUJIFILM"),
+		[]byte("OLYMPUS\x00"),
+		[]byte("OM SYSTEM\x00"),
+		[]byte("Apple iOS\x00"),
+	}
+
+	// makerNoteIfdHeaders identify MakerNotes that have a fixed header in
+	// front of an IFD whose offsets are relative to the TIFF header. Other
+	// MakerNotes (e.g. Canon) are probed for an IFD at the very start.
+	makerNoteIfdHeaders = map[string]int{
+		"Panasonic\x00\x00\x00": 12,
+		"SONY DSC \x00\x00\x00": 12,
+		"SONY CAM \x00\x00\x00": 12,
+	}
+)
+
+// preservedMakerNote returns the MakerNote entry if it should be preserved
+// during encoding. A MakerNote that was replaced since parsing is treated
+// like any other value.
+func (ed *ExifDocument) preservedMakerNote() *ExifEntry {
+	if ed.PreserveMakerNote == false || ed.makerNoteOffset == 0 {
+		return nil
+	}
+
+	exifIfd := ed.Ifd(EXIF_IFD_EXIF)
+	if exifIfd == nil {
+		return nil
+	}
+
+	makerNote, err := exifIfd.Entry(exifTagMakerNote)
+	if err != nil || bytes.Equal(makerNote.RawValue, ed.makerNoteValue) == false {
+		return nil
+	}
+
+	return makerNote
+}
+
+// relocateMakerNote adjusts the internal offsets of a MakerNote (in place)
+// that has been moved by `delta` bytes from `originalOffset`. It returns
+// false if the format isn't understood, in which case nothing is changed.
+func relocateMakerNote(data []byte, byteOrder binary.ByteOrder, originalOffset uint32, delta int64) bool {
+	for _, prefix := range makerNoteSelfRelativePrefixes {
+		if bytes.HasPrefix(data, prefix) == true {
+			return true
+		}
+	}
+
+	ifdAt := 0
+	for header, size := range makerNoteIfdHeaders {
+		if bytes.HasPrefix(data, []byte(header)) == true {
+			ifdAt = size
+			break
+		}
+	}
+
+	if ifdAt + 2 > len(data) {
+		return false
+	}
+
+	count := int(byteOrder.Uint16(data[ifdAt:]))
+	if count == 0 || count > makerNoteMaxEntries || ifdAt + 2 + count * 12 > len(data) {
+		return false
+	}
+
+	// Confirm that this is an IFD whose values are addressed from the TIFF
+	// header by requiring every out-of-line value to fall within the
+	// MakerNote as originally placed.
+	start := int64(originalOffset)
+	end := start + int64(len(data))
+
+	pointers := make([]int, 0, count)
+
+	for i := 0; i < count; i++ {
+		entry := data[ifdAt + 2 + i * 12:]
+
+		unitSize, found := exifTypeSizes[byteOrder.Uint16(entry[2:4])]
+		if found == false {
+			return false
+		}
+
+		size := int64(byteOrder.Uint32(entry[4:8])) * int64(unitSize)
+		if size <= 4 {
+			continue
+		}
+
+		valueOffset := int64(byteOrder.Uint32(entry[8:12]))
+		if valueOffset < start || valueOffset + size > end {
+			return false
+		}
+
+		pointers = append(pointers, ifdAt + 2 + i * 12 + 8)
+	}
+
+	for _, position := range pointers {
+		valueOffset := int64(byteOrder.Uint32(data[position:])) + delta
+		byteOrder.PutUint32(data[position:], uint32(valueOffset))
+	}
+
+	return true
+}
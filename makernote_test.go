@@ -0,0 +1,135 @@
+package jpegstructure
+
+import (
+	"bytes"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/dsoprea/go-logging"
+)
+
+func getMakerNoteTestData() []byte {
+	filepath := path.Join(assetsPath, testImageRelFilepath)
+
+	sl, err := ParseFileStructure(filepath)
+	log.PanicIf(err)
+
+	exifData, err := sl.ExifData()
+	log.PanicIf(err)
+
+	return exifData
+}
+
+// makerNoteValues returns the out-of-line values of the IFD at the start of
+// the (Canon) MakerNote, resolved against the TIFF header.
+func makerNoteValues(exifData []byte) [][]byte {
+	ed, err := ParseExifDocument(exifData)
+	log.PanicIf(err)
+
+	bo := ed.ByteOrder
+	ifd := exifData[ed.makerNoteOffset:]
+
+	values := make([][]byte, 0)
+
+	count := int(bo.Uint16(ifd))
+	for i := 0; i < count; i++ {
+		entry := ifd[2 + i * 12:]
+
+		size := int(bo.Uint32(entry[4:8])) * exifTypeSizes[bo.Uint16(entry[2:4])]
+		if size <= 4 {
+			continue
+		}
+
+		valueOffset := int(bo.Uint32(entry[8:12]))
+		values = append(values, exifData[valueOffset:valueOffset + size])
+	}
+
+	return values
+}
+
+func TestExifDocument_Encode_MakerNoteStable(t *testing.T) {
+	exifData := getMakerNoteTestData()
+
+	ed, err := ParseExifDocument(exifData)
+	log.PanicIf(err)
+
+	if ed.makerNoteOffset == 0 {
+		t.Fatalf("MakerNote not found.")
+	}
+
+	err = ed.SetValue(EXIF_IFD_ROOT, 0x0112, EXIF_TYPE_SHORT, []uint16 { 6 })
+	log.PanicIf(err)
+
+	encoded, err := ed.Encode()
+	log.PanicIf(err)
+
+	reparsed, err := ParseExifDocument(encoded)
+	log.PanicIf(err)
+
+	if reparsed.makerNoteOffset != ed.makerNoteOffset {
+		t.Fatalf("MakerNote moved: (0x%x) != (0x%x)", reparsed.makerNoteOffset, ed.makerNoteOffset)
+	} else if bytes.Equal(reparsed.makerNoteValue, ed.makerNoteValue) == false {
+		t.Fatalf("MakerNote bytes changed.")
+	}
+}
+
+func TestExifDocument_Encode_MakerNoteRelocated(t *testing.T) {
+	exifData := getMakerNoteTestData()
+
+	ed, err := ParseExifDocument(exifData)
+	log.PanicIf(err)
+
+	// Grow IFD0 past the original position of the MakerNote.
+	description := strings.Repeat("x", int(ed.makerNoteOffset))
+
+	err = ed.SetValue(EXIF_IFD_ROOT, 0x010e, EXIF_TYPE_ASCII, description)
+	log.PanicIf(err)
+
+	encoded, err := ed.Encode()
+	log.PanicIf(err)
+
+	reparsed, err := ParseExifDocument(encoded)
+	log.PanicIf(err)
+
+	if reparsed.makerNoteOffset <= ed.makerNoteOffset {
+		t.Fatalf("MakerNote expected to move: (0x%x)", reparsed.makerNoteOffset)
+	}
+
+	original := makerNoteValues(exifData)
+	relocated := makerNoteValues(encoded)
+
+	if len(original) == 0 || len(relocated) != len(original) {
+		t.Fatalf("MakerNote value count not correct: (%d) != (%d)", len(relocated), len(original))
+	}
+
+	for i, value := range original {
+		if bytes.Equal(relocated[i], value) != true {
+			t.Fatalf("MakerNote value (%d) not preserved.", i)
+		}
+	}
+}
+
+func TestExifDocument_Encode_MakerNoteNotPreserved(t *testing.T) {
+	exifData := getMakerNoteTestData()
+
+	ed, err := ParseExifDocument(exifData)
+	log.PanicIf(err)
+
+	ed.PreserveMakerNote = false
+
+	description := strings.Repeat("x", int(ed.makerNoteOffset))
+
+	err = ed.SetValue(EXIF_IFD_ROOT, 0x010e, EXIF_TYPE_ASCII, description)
+	log.PanicIf(err)
+
+	encoded, err := ed.Encode()
+	log.PanicIf(err)
+
+	reparsed, err := ParseExifDocument(encoded)
+	log.PanicIf(err)
+
+	if bytes.Equal(reparsed.makerNoteValue, ed.makerNoteValue) == false {
+		t.Fatalf("MakerNote bytes expected to be copied verbatim.")
+	}
+}
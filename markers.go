@@ -0,0 +1,23 @@
+package jpegstructure
+
+// IsAppMarker indicates whether the marker is one of the application segments
+// (APP0 through APP15).
+func IsAppMarker(markerId byte) bool {
+	return markerId >= MARKER_APP0 && markerId <= MARKER_APP15
+}
+
+// IsSofMarker indicates whether the marker starts a frame. The SOFn range is
+// shared with DHT, JPG, and DAC, which are excluded.
+func IsSofMarker(markerId byte) bool {
+	if markerId < MARKER_SOF0 || markerId > MARKER_SOF15 {
+		return false
+	}
+
+	return markerId != MARKER_DHT && markerId != MARKER_JPG && markerId != MARKER_DAC
+}
+
+// IsRstMarker indicates whether the marker is one of the restart markers
+// (RST0 through RST7), which only appear within the scan-data.
+func IsRstMarker(markerId byte) bool {
+	return markerId >= MARKER_RST0 && markerId <= MARKER_RST7
+}
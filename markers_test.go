@@ -0,0 +1,49 @@
+package jpegstructure
+
+import (
+	"testing"
+)
+
+func TestMarkerNames_Complete(t *testing.T) {
+	for markerId := 0xc0; markerId <= 0xfe; markerId++ {
+		if markerNames[byte(markerId)] == "" {
+			t.Fatalf("Marker has no name: (0x%02x)", markerId)
+		}
+	}
+
+	if markerNames[MARKER_TEM] != "TEM" {
+		t.Fatalf("TEM marker has no name.")
+	}
+}
+
+func TestIsAppMarker(t *testing.T) {
+	if IsAppMarker(MARKER_APP0) != true || IsAppMarker(MARKER_APP15) != true {
+		t.Fatalf("APPn markers not recognized.")
+	} else if IsAppMarker(MARKER_COM) != false || IsAppMarker(MARKER_EXP) != false {
+		t.Fatalf("Non-APPn marker recognized.")
+	}
+}
+
+func TestIsSofMarker(t *testing.T) {
+	if IsSofMarker(MARKER_SOF0) != true || IsSofMarker(MARKER_SOF2) != true || IsSofMarker(MARKER_SOF15) != true {
+		t.Fatalf("SOFn markers not recognized.")
+	}
+
+	for _, markerId := range []byte{MARKER_DHT, MARKER_JPG, MARKER_DAC, MARKER_SOS} {
+		if IsSofMarker(markerId) != false {
+			t.Fatalf("Non-SOF marker recognized: (0x%02x)", markerId)
+		}
+	}
+}
+
+func TestIsRstMarker(t *testing.T) {
+	for markerId := MARKER_RST0; markerId <= MARKER_RST7; markerId++ {
+		if IsRstMarker(byte(markerId)) != true {
+			t.Fatalf("RSTn marker not recognized: (0x%02x)", markerId)
+		}
+	}
+
+	if IsRstMarker(MARKER_SOI) != false || IsRstMarker(MARKER_DNL) != false {
+		t.Fatalf("Non-RST marker recognized.")
+	}
+}
@@ -0,0 +1,222 @@
+package jpegstructure
+
+import (
+	"bytes"
+	"io"
+
+	"encoding/binary"
+	"io/ioutil"
+
+	"github.com/dsoprea/go-logging"
+)
+
+// MP type codes of the individual images (CIPA DC-007, 5.2.3.3.1).
+const (
+	MPO_TYPE_UNDEFINED = 0x000000
+	MPO_TYPE_LARGE_THUMBNAIL_VGA = 0x010001
+	MPO_TYPE_LARGE_THUMBNAIL_FULL_HD = 0x010002
+	MPO_TYPE_PANORAMA = 0x020001
+	MPO_TYPE_DISPARITY = 0x020002
+	MPO_TYPE_MULTI_ANGLE = 0x020003
+	MPO_TYPE_BASELINE_PRIMARY = 0x030000
+)
+
+const (
+	mpfTagVersion = 0xb000
+	mpfTagNumberOfImages = 0xb001
+	mpfTagIndividualNum = 0xb101
+
+	// mpEntryRepresentative flags the image that should be shown by
+	// readers that only show one.
+	mpEntryRepresentative = 0x20000000
+)
+
+var (
+	mpfVersion = []byte("0100")
+)
+
+// MpoImage is one of the images written by WriteMpo.
+type MpoImage struct {
+	// Segments is the whole image (SOI through EOI).
+	Segments SegmentList
+
+	// Type is the MP type code (MPO_TYPE_*).
+	Type uint32
+
+	// IndividualNum is the viewpoint number (starting at one, from left to
+	// right) of disparity and multi-angle images. It's omitted if zero.
+	IndividualNum uint32
+}
+
+// mpfSegmentIndex returns the position of the MPF APP2 segment, or -1.
+func (sl SegmentList) mpfSegmentIndex() int {
+	for i, s := range sl {
+		if s.MarkerId == MARKER_APP2 && bytes.HasPrefix(s.Data, mpfPrefix) == true {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// withMpfSegment returns a copy of the list with its MPF segment replaced by
+// `payload` (or, if there isn't one, with the payload inserted after the
+// leading APP0 and APP1 segments) and the position of the segment.
+func (sl SegmentList) withMpfSegment(payload []byte) (updated SegmentList, index int) {
+	s := Segment{
+		MarkerId: MARKER_APP2,
+		MarkerName: markerNames[MARKER_APP2],
+		Data: payload,
+	}
+
+	updated = sl.Fork()
+
+	index = updated.mpfSegmentIndex()
+	if index >= 0 {
+		updated[index] = s
+		return updated, index
+	}
+
+	index = 1
+	for index < len(updated) && (updated[index].MarkerId == MARKER_APP0 || updated[index].MarkerId == MARKER_APP1) {
+		index++
+	}
+
+	updated = append(updated[:index], append(SegmentList{s}, updated[index:]...)...)
+	return updated, index
+}
+
+// encodeMpfPayload returns an MPF APP2 payload. The index IFD is only
+// written if `entries` isn't nil (the first image); the attribute IFD follows
+// it.
+func encodeMpfPayload(entries []byte, count int, individualNum uint32) []byte {
+	ed := NewExifDocument(binary.LittleEndian)
+
+	attributeIfd := EXIF_IFD_ROOT
+
+	if entries != nil {
+		err := ed.SetValue(EXIF_IFD_ROOT, mpfTagVersion, EXIF_TYPE_UNDEFINED, mpfVersion)
+		log.PanicIf(err)
+
+		err = ed.SetValue(EXIF_IFD_ROOT, mpfTagNumberOfImages, EXIF_TYPE_LONG, []uint32{uint32(count)})
+		log.PanicIf(err)
+
+		err = ed.SetValue(EXIF_IFD_ROOT, mpfTagMpEntry, EXIF_TYPE_UNDEFINED, entries)
+		log.PanicIf(err)
+
+		attributeIfd = EXIF_IFD_THUMBNAIL
+	}
+
+	if entries == nil || individualNum != 0 {
+		err := ed.SetValue(attributeIfd, mpfTagVersion, EXIF_TYPE_UNDEFINED, mpfVersion)
+		log.PanicIf(err)
+	}
+
+	if individualNum != 0 {
+		err := ed.SetValue(attributeIfd, mpfTagIndividualNum, EXIF_TYPE_LONG, []uint32{individualNum})
+		log.PanicIf(err)
+	}
+
+	encoded, err := ed.Encode()
+	log.PanicIf(err)
+
+	payload := make([]byte, 0, len(mpfPrefix) + len(encoded))
+	payload = append(payload, mpfPrefix...)
+	payload = append(payload, encoded...)
+
+	return payload
+}
+
+// WriteMpo writes a Multi-Picture Object: the images one after another, with
+// an MPF APP2 segment in each. The segment of the first (primary) image
+// indexes all of them with their types, sizes, and offsets, and the primary
+// image is flagged as the representative one. Any MPF segments that the
+// images already have are replaced. The images themselves aren't modified.
+func WriteMpo(w io.Writer, images []MpoImage) (err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	if len(images) == 0 {
+		log.Panicf("no images")
+	}
+
+	for i, image := range images {
+		sl := image.Segments
+		if len(sl) < 2 || sl[0].MarkerId != MARKER_SOI || sl[len(sl) - 1].MarkerId != MARKER_EOI {
+			log.Panicf("image (%d) is not a whole image", i)
+		} else if image.Type > 0xffffff {
+			log.Panicf("image (%d) type not valid: (0x%x)", i, image.Type)
+		}
+	}
+
+	entries := make([]byte, mpEntrySize * len(images))
+
+	// The payloads don't change size once the entries are filled in, so the
+	// layout can be determined first.
+	lists := make([]SegmentList, len(images))
+	sizes := make([]int64, len(images))
+	mpfIndex := 0
+
+	for i, image := range images {
+		var payload []byte
+		if i == 0 {
+			payload = encodeMpfPayload(entries, len(images), image.IndividualNum)
+		} else {
+			payload = encodeMpfPayload(nil, 0, image.IndividualNum)
+		}
+
+		var index int
+		lists[i], index = image.Segments.withMpfSegment(payload)
+
+		if i == 0 {
+			mpfIndex = index
+		}
+
+		sizes[i], err = lists[i].WriteTo(ioutil.Discard)
+		log.PanicIf(err)
+	}
+
+	// Offsets are relative to the TIFF header of the primary image's MPF
+	// segment.
+	base, err := lists[0][:mpfIndex].WriteTo(ioutil.Discard)
+	log.PanicIf(err)
+
+	base += 2 + 2 + int64(len(mpfPrefix))
+
+	position := int64(0)
+	for i, image := range images {
+		raw := entries[i * mpEntrySize:]
+
+		attribute := image.Type
+		if i == 0 {
+			attribute |= mpEntryRepresentative
+		}
+
+		offset := int64(0)
+		if i > 0 {
+			offset = position - base
+		}
+
+		if sizes[i] > 0xffffffff || offset > 0xffffffff {
+			log.Panicf("image (%d) too large for an MPO", i)
+		}
+
+		binary.LittleEndian.PutUint32(raw[0:], attribute)
+		binary.LittleEndian.PutUint32(raw[4:], uint32(sizes[i]))
+		binary.LittleEndian.PutUint32(raw[8:], uint32(offset))
+
+		position += sizes[i]
+	}
+
+	lists[0][mpfIndex].setData(encodeMpfPayload(entries, len(images), images[0].IndividualNum))
+
+	for _, sl := range lists {
+		err := sl.Write(w)
+		log.PanicIf(err)
+	}
+
+	return nil
+}
@@ -0,0 +1,123 @@
+package jpegstructure
+
+import (
+	"bytes"
+	"path"
+	"testing"
+
+	"encoding/binary"
+
+	"github.com/dsoprea/go-logging"
+)
+
+func TestWriteMpo(t *testing.T) {
+	left, err := ParseBytesStructure(getTransformTestImage())
+	log.PanicIf(err)
+
+	filepath := path.Join(assetsPath, testImageRelFilepath)
+
+	right, err := ParseFileStructure(filepath)
+	log.PanicIf(err)
+
+	images := []MpoImage{
+		{Segments: left, Type: MPO_TYPE_DISPARITY, IndividualNum: 1},
+		{Segments: right, Type: MPO_TYPE_DISPARITY, IndividualNum: 2},
+	}
+
+	b := new(bytes.Buffer)
+
+	err = WriteMpo(b, images)
+	log.PanicIf(err)
+
+	data := b.Bytes()
+
+	all, err := ParseBytesStructure(data)
+	log.PanicIf(err)
+
+	// The images follow one another.
+	primary := all
+	for i, s := range all {
+		if s.MarkerId == MARKER_EOI {
+			primary = all[:i + 1]
+			break
+		}
+	}
+
+	primarySize := primary[len(primary) - 1].EndOffset()
+
+	previews, err := primary.Previews(data)
+	log.PanicIf(err)
+
+	p := previews[len(previews) - 1]
+	if p.Source != PREVIEW_SOURCE_MPF || bytes.Equal(p.Data, data[primarySize:]) == false {
+		t.Fatalf("Dependent image not indexed: %s", p)
+	}
+
+	dependent, err := ParseBytesStructure(p.Data)
+	log.PanicIf(err)
+
+	// The index describes both images.
+	mpf := primary[primary.mpfSegmentIndex()]
+
+	ed, err := ParseExifDocument(mpf.Data[len(mpfPrefix):])
+	log.PanicIf(err)
+
+	ee, err := ed.Root.Entry(mpfTagMpEntry)
+	log.PanicIf(err)
+
+	if len(ee.RawValue) != mpEntrySize * 2 {
+		t.Fatalf("MP entries not correct: (%d)", len(ee.RawValue))
+	} else if attribute := binary.LittleEndian.Uint32(ee.RawValue); attribute != mpEntryRepresentative | MPO_TYPE_DISPARITY {
+		t.Fatalf("Primary attribute not correct: (0x%08x)", attribute)
+	} else if size := binary.LittleEndian.Uint32(ee.RawValue[4:]); int(size) != primarySize {
+		t.Fatalf("Primary size not correct: (%d) != (%d)", size, primarySize)
+	}
+
+	// Each image carries its viewpoint number.
+	for i, sl := range []SegmentList{primary, dependent} {
+		mpf := sl[sl.mpfSegmentIndex()]
+
+		ed, err := ParseExifDocument(mpf.Data[len(mpfPrefix):])
+		log.PanicIf(err)
+
+		attributeIfd := ed.Root
+		if i == 0 {
+			attributeIfd = ed.ThumbnailIfd
+		}
+
+		ee, err := attributeIfd.Entry(mpfTagIndividualNum)
+		log.PanicIf(err)
+
+		if num := ed.ByteOrder.Uint32(ee.RawValue); int(num) != i + 1 {
+			t.Fatalf("Individual number of image (%d) not correct: (%d)", i, num)
+		}
+	}
+
+	// Writing the images again replaces their MPF segments rather than
+	// adding more.
+	images[0].Segments = primary
+	images[1].Segments = dependent
+
+	rewritten := new(bytes.Buffer)
+
+	err = WriteMpo(rewritten, images)
+	log.PanicIf(err)
+
+	if bytes.Equal(rewritten.Bytes(), data) == false {
+		t.Fatalf("Rewritten MPO not identical.")
+	}
+}
+
+func TestWriteMpo_NotWholeImage(t *testing.T) {
+	sl, err := ParseBytesStructure(getTransformTestImage())
+	log.PanicIf(err)
+
+	images := []MpoImage{
+		{Segments: sl[:len(sl) - 1], Type: MPO_TYPE_BASELINE_PRIMARY},
+	}
+
+	err = WriteMpo(new(bytes.Buffer), images)
+	if err == nil {
+		t.Fatalf("Expected error for a truncated image.")
+	}
+}
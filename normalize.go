@@ -0,0 +1,144 @@
+package jpegstructure
+
+import (
+	"github.com/dsoprea/go-logging"
+)
+
+const (
+	xmpNamespaceXmp = "http://ns.adobe.com/xap/1.0/"
+	xmpNamespaceXmpMM = "http://ns.adobe.com/xap/1.0/mm/"
+	xmpNamespacePhotoshop = "http://ns.adobe.com/photoshop/1.0/"
+	xmpNamespaceExif = "http://ns.adobe.com/exif/1.0/"
+	xmpNamespaceTiff = "http://ns.adobe.com/tiff/1.0/"
+)
+
+const (
+	// ICC header fields that vary between otherwise-identical profiles.
+	iccDateTimeOffset = 24
+	iccDateTimeSize = 12
+	iccProfileIdOffset = 84
+	iccProfileIdSize = 16
+)
+
+var (
+	// normalizedExifTags are removed by Normalize().
+	normalizedExifTags = map[string][]uint16{
+		EXIF_IFD_ROOT: {
+			0x0131, // Software
+			0x0132, // DateTime
+		},
+		EXIF_IFD_EXIF: {
+			0x9003, // DateTimeOriginal
+			0x9004, // DateTimeDigitized
+			0x9010, // OffsetTime
+			0x9011, // OffsetTimeOriginal
+			0x9012, // OffsetTimeDigitized
+			0x9290, // SubSecTime
+			0x9291, // SubSecTimeOriginal
+			0x9292, // SubSecTimeDigitized
+			0xa420, // ImageUniqueID
+		},
+		EXIF_IFD_GPS: {
+			0x0007, // GPSTimeStamp
+			0x001d, // GPSDateStamp
+		},
+		EXIF_IFD_THUMBNAIL: {
+			0x0131, // Software
+			0x0132, // DateTime
+		},
+	}
+
+	// normalizedXmpProperties are removed by Normalize().
+	normalizedXmpProperties = []XmpPropertyName{
+		{xmpNamespaceXmp, "CreateDate"},
+		{xmpNamespaceXmp, "ModifyDate"},
+		{xmpNamespaceXmp, "MetadataDate"},
+		{xmpNamespaceXmp, "CreatorTool"},
+		{xmpNamespaceXmpMM, "DocumentID"},
+		{xmpNamespaceXmpMM, "InstanceID"},
+		{xmpNamespaceXmpMM, "OriginalDocumentID"},
+		{xmpNamespaceXmpMM, "History"},
+		{xmpNamespaceXmpMM, "DerivedFrom"},
+		{xmpNamespacePhotoshop, "DateCreated"},
+		{xmpNamespaceExif, "DateTimeOriginal"},
+		{xmpNamespaceExif, "DateTimeDigitized"},
+		{xmpNamespaceTiff, "DateTime"},
+		{xmpNamespaceTiff, "Software"},
+	}
+)
+
+// Normalize returns a copy of the list with the metadata that varies between
+// runs over the same pixels removed, so that the output is byte-identical:
+// EXIF timestamps, software, and unique-ID tags; XMP dates, creator-tool, and
+// document/instance IDs (and history); and the ICC profile's creation date and
+// profile ID (which is zeroed, meaning "not computed").
+func (sl SegmentList) Normalize() (normalized SegmentList, err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	normalized = make(SegmentList, len(sl))
+	copy(normalized, sl)
+
+	for i, s := range normalized {
+		if s.Kind() == SegmentKindExifApp1 {
+			ed, err := ParseExifDocument(s.Data[len(exifPrefix):])
+			log.PanicIf(err)
+
+			for ifdName, tagIds := range normalizedExifTags {
+				ifd := ed.Ifd(ifdName)
+				if ifd == nil {
+					continue
+				}
+
+				for _, tagId := range tagIds {
+					ifd.DeleteEntry(tagId)
+				}
+			}
+
+			exifData, err := ed.Encode()
+			log.PanicIf(err)
+
+			data := make([]byte, 0, len(exifPrefix) + len(exifData))
+			data = append(data, exifPrefix...)
+			data = append(data, exifData...)
+
+			normalized[i].setData(data)
+		} else if s.Kind() == SegmentKindXmpApp1 {
+			packet, err := RemoveXmpProperties(s.Data[len(xmpPrefix):], normalizedXmpProperties)
+			log.PanicIf(err)
+
+			data := make([]byte, 0, len(xmpPrefix) + len(packet))
+			data = append(data, xmpPrefix...)
+			data = append(data, packet...)
+
+			normalized[i].setData(data)
+		} else if s.MarkerId == MARKER_APP2 && isIccPayload(s.Data) == true && s.Data[len(iccPrefix)] == 1 {
+			// The profile header is in the first chunk.
+			profile := s.Data[iccHeaderSize:]
+			if len(profile) < iccProfileIdOffset + iccProfileIdSize {
+				continue
+			}
+
+			data := make([]byte, len(s.Data))
+			copy(data, s.Data)
+
+			header := data[iccHeaderSize:]
+			for j := 0; j < iccDateTimeSize; j++ {
+				header[iccDateTimeOffset + j] = 0
+			}
+
+			for j := 0; j < iccProfileIdSize; j++ {
+				header[iccProfileIdOffset + j] = 0
+			}
+
+			normalized[i].setData(data)
+		}
+	}
+
+	normalized.updateOffsets()
+
+	return normalized, nil
+}
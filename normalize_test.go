@@ -0,0 +1,93 @@
+package jpegstructure
+
+import (
+	"bytes"
+	"path"
+	"testing"
+
+	"github.com/dsoprea/go-logging"
+)
+
+func TestSegmentList_Normalize(t *testing.T) {
+	filepath := path.Join(assetsPath, "20180428_212314.jpg")
+
+	sl, err := ParseFileStructure(filepath)
+	log.PanicIf(err)
+
+	normalized, err := sl.Normalize()
+	log.PanicIf(err)
+
+	ed, err := normalized.ExifDocument()
+	log.PanicIf(err)
+
+	for _, tagId := range []uint16 { 0x0131, 0x0132 } {
+		if _, err := ed.Entry(EXIF_IFD_ROOT, tagId); err != ErrExifTagNotFound {
+			t.Fatalf("Tag (0x%04x) was not removed.", tagId)
+		}
+	}
+
+	if _, err := ed.Entry(EXIF_IFD_EXIF, 0x9003); err != ErrExifTagNotFound {
+		t.Fatalf("DateTimeOriginal was not removed.")
+	}
+
+	// Unrelated tags survive.
+	if _, err := ed.Entry(EXIF_IFD_ROOT, 0x010f); err != nil {
+		t.Fatalf("Make was removed.")
+	}
+
+	// Two files that differ only in their timestamps normalize identically.
+	altered, err := sl.ExifDocument()
+	log.PanicIf(err)
+
+	err = altered.SetValue(EXIF_IFD_EXIF, 0x9003, EXIF_TYPE_ASCII, "2001:01:01 00:00:00")
+	log.PanicIf(err)
+
+	err = altered.SetValue(EXIF_IFD_ROOT, 0x0131, EXIF_TYPE_ASCII, "some other software")
+	log.PanicIf(err)
+
+	other := make(SegmentList, len(sl))
+	copy(other, sl)
+
+	err = other.SetExifDocument(altered)
+	log.PanicIf(err)
+
+	otherNormalized, err := other.Normalize()
+	log.PanicIf(err)
+
+	b1 := new(bytes.Buffer)
+
+	err = normalized.Write(b1)
+	log.PanicIf(err)
+
+	b2 := new(bytes.Buffer)
+
+	err = otherNormalized.Write(b2)
+	log.PanicIf(err)
+
+	if bytes.Compare(b1.Bytes(), b2.Bytes()) != 0 {
+		t.Fatalf("Normalized outputs differ.")
+	}
+}
+
+func TestRemoveXmpProperties(t *testing.T) {
+	packet := []byte(`<x:xmpmeta xmlns:x="adobe:ns:meta/"><rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">
+<rdf:Description rdf:about="" xmlns:xmp="http://ns.adobe.com/xap/1.0/" xmlns:xmpMM="http://ns.adobe.com/xap/1.0/mm/" xmp:Rating="3" xmpMM:InstanceID="xmp.iid:1234">
+<xmp:ModifyDate>2018-04-28T21:23:14</xmp:ModifyDate>
+<xmp:Label>Red</xmp:Label>
+</rdf:Description>
+</rdf:RDF></x:xmpmeta>`)
+
+	updated, err := RemoveXmpProperties(packet, normalizedXmpProperties)
+	log.PanicIf(err)
+
+	expected := `<x:xmpmeta xmlns:x="adobe:ns:meta/"><rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">
+<rdf:Description rdf:about="" xmlns:xmp="http://ns.adobe.com/xap/1.0/" xmlns:xmpMM="http://ns.adobe.com/xap/1.0/mm/" xmp:Rating="3">
+
+<xmp:Label>Red</xmp:Label>
+</rdf:Description>
+</rdf:RDF></x:xmpmeta>`
+
+	if string(updated) != expected {
+		t.Fatalf("Updated packet not correct:\n%s", string(updated))
+	}
+}
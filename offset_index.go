@@ -0,0 +1,106 @@
+package jpegstructure
+
+import (
+	"fmt"
+	"sort"
+)
+
+const (
+	OFFSET_RANGE_SEGMENT = iota
+	OFFSET_RANGE_SCAN_DATA
+	OFFSET_RANGE_FILL
+	OFFSET_RANGE_TRAILER
+)
+
+// OffsetRange is one contiguous run of bytes of the file.
+type OffsetRange struct {
+	// Start is the offset of the first byte and End is the offset of the
+	// byte following the last.
+	Start, End int
+
+	// Kind is one of the OFFSET_RANGE_* constants.
+	Kind int
+
+	// SegmentIndex is the position of the segment in the list, or -1 for
+	// fill and trailer ranges.
+	SegmentIndex int
+
+	// Name is the name of the marker (or describes the range if it's not a
+	// segment).
+	Name string
+}
+
+func (or OffsetRange) String() string {
+	return fmt.Sprintf("OffsetRange<START=(0x%08x) END=(0x%08x) SEGMENT=(%d) NAME=[%s]>", or.Start, or.End, or.SegmentIndex, or.Name)
+}
+
+// Contains indicates whether the offset falls in the range.
+func (or OffsetRange) Contains(offset int) bool {
+	return offset >= or.Start && offset < or.End
+}
+
+// OffsetIndex is a list of ranges that covers a file, sorted by offset.
+type OffsetIndex []OffsetRange
+
+// OffsetIndex maps the bytes of the file to the segments, using the offsets
+// recorded when it was parsed. Gaps between segments (e.g. fill bytes before
+// a marker) are included as fill ranges. If `fileSize` is larger than the
+// end of the last segment, the remainder is included as a trailer range (so
+// the index covers the whole file).
+func (sl SegmentList) OffsetIndex(fileSize int) OffsetIndex {
+	oi := make(OffsetIndex, 0, len(sl))
+
+	offset := 0
+	for i, s := range sl {
+		if s.Offset > offset {
+			oi = append(oi, OffsetRange{
+				Start: offset,
+				End: s.Offset,
+				Kind: OFFSET_RANGE_FILL,
+				SegmentIndex: -1,
+				Name: "fill",
+			})
+		}
+
+		or := OffsetRange{
+			Start: s.Offset,
+			End: s.EndOffset(),
+			Kind: OFFSET_RANGE_SEGMENT,
+			SegmentIndex: i,
+			Name: s.MarkerName,
+		}
+
+		if s.MarkerId == 0x0 {
+			or.Kind = OFFSET_RANGE_SCAN_DATA
+			or.Name = "!SCANDATA"
+		}
+
+		oi = append(oi, or)
+		offset = or.End
+	}
+
+	if fileSize > offset {
+		oi = append(oi, OffsetRange{
+			Start: offset,
+			End: fileSize,
+			Kind: OFFSET_RANGE_TRAILER,
+			SegmentIndex: -1,
+			Name: "trailer",
+		})
+	}
+
+	return oi
+}
+
+// LookupOffset returns the range that contains the offset.
+func (oi OffsetIndex) LookupOffset(offset int) (or OffsetRange, found bool) {
+	i := sort.Search(len(oi), func(i int) bool {
+		return oi[i].End > offset
+	})
+
+	if i >= len(oi) || oi[i].Contains(offset) == false {
+		return OffsetRange{}, false
+	}
+
+	return oi[i], true
+}
@@ -0,0 +1,85 @@
+package jpegstructure
+
+import (
+	"path"
+	"testing"
+
+	"io/ioutil"
+
+	"github.com/dsoprea/go-logging"
+)
+
+func TestSegmentList_OffsetIndex(t *testing.T) {
+	filepath := path.Join(assetsPath, testImageRelFilepath)
+
+	data, err := ioutil.ReadFile(filepath)
+	log.PanicIf(err)
+
+	sl, err := ParseBytesStructure(data)
+	log.PanicIf(err)
+
+	fileSize := len(data) + 10
+	oi := sl.OffsetIndex(fileSize)
+
+	if len(oi) != len(sl) + 1 {
+		t.Fatalf("Range count not correct: (%d)", len(oi))
+	} else if oi[0].Start != 0 || oi[len(oi) - 1].End != fileSize {
+		t.Fatalf("Index doesn't cover the file.")
+	}
+
+	for i := 1; i < len(oi); i++ {
+		if oi[i].Start != oi[i - 1].End {
+			t.Fatalf("Range (%d) not contiguous: %s", i, oi[i])
+		}
+	}
+
+	or, found := oi.LookupOffset(sl[1].Offset + 5)
+	if found == false || or.SegmentIndex != 1 || or.Kind != OFFSET_RANGE_SEGMENT || or.Name != "APP1" {
+		t.Fatalf("Lookup in segment not correct: %s", or)
+	}
+
+	scanIndex := sl.Index(0x0)
+
+	or, found = oi.LookupOffset(sl[scanIndex].Offset + 1000)
+	if found == false || or.SegmentIndex != scanIndex || or.Kind != OFFSET_RANGE_SCAN_DATA {
+		t.Fatalf("Lookup in scan-data not correct: %s", or)
+	}
+
+	or, found = oi.LookupOffset(len(data) - 1)
+	if found == false || or.SegmentIndex != len(sl) - 1 || or.Name != "EOI" {
+		t.Fatalf("Lookup of last byte not correct: %s", or)
+	}
+
+	or, found = oi.LookupOffset(len(data))
+	if found == false || or.Kind != OFFSET_RANGE_TRAILER || or.SegmentIndex != -1 {
+		t.Fatalf("Lookup in trailer not correct: %s", or)
+	}
+
+	_, found = oi.LookupOffset(fileSize)
+	if found != false {
+		t.Fatalf("Offset past the end not expected to be found.")
+	}
+}
+
+func TestSegmentList_OffsetIndex_Fill(t *testing.T) {
+	data := []byte{
+		0xff, 0xd8,
+		0xff, 0xff, 0xfe, 0x00, 0x04, 'h', 'i',
+		0xff, 0xd9,
+	}
+
+	sl, err := ParseBytesStructure(data)
+	log.PanicIf(err)
+
+	oi := sl.OffsetIndex(len(data))
+
+	or, found := oi.LookupOffset(2)
+	if found == false || or.Kind != OFFSET_RANGE_FILL || or.Start != 2 || or.End != 3 {
+		t.Fatalf("Fill range not correct: %s", or)
+	}
+
+	or, found = oi.LookupOffset(3)
+	if found == false || or.SegmentIndex != 1 || or.Name != "COM" {
+		t.Fatalf("Segment after fill not correct: %s", or)
+	}
+}
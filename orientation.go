@@ -0,0 +1,170 @@
+package jpegstructure
+
+import (
+	"encoding/binary"
+
+	"github.com/dsoprea/go-logging"
+)
+
+const (
+	exifTagOrientation = 0x0112
+)
+
+const (
+	// The EXIF orientations, named for where the first row and column of the
+	// stored image should be displayed.
+	ORIENTATION_TOP_LEFT = 1
+	ORIENTATION_TOP_RIGHT = 2
+	ORIENTATION_BOTTOM_RIGHT = 3
+	ORIENTATION_BOTTOM_LEFT = 4
+	ORIENTATION_LEFT_TOP = 5
+	ORIENTATION_RIGHT_TOP = 6
+	ORIENTATION_RIGHT_BOTTOM = 7
+	ORIENTATION_LEFT_BOTTOM = 8
+)
+
+// Orientation returns the EXIF orientation (1-8). If there is no EXIF data or
+// no orientation tag, the default of ORIENTATION_TOP_LEFT is returned.
+func (sl SegmentList) Orientation() (orientation int, err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	ed, err := sl.ExifDocument()
+	if err != nil {
+		if log.Is(err, ErrSegmentNotFound) == true {
+			return ORIENTATION_TOP_LEFT, nil
+		}
+
+		log.Panic(err)
+	}
+
+	ee, err := ed.Entry(EXIF_IFD_ROOT, exifTagOrientation)
+	if err != nil {
+		if log.Is(err, ErrExifTagNotFound) == true {
+			return ORIENTATION_TOP_LEFT, nil
+		}
+
+		log.Panic(err)
+	}
+
+	value, err := ed.Value(ee)
+	log.PanicIf(err)
+
+	switch v := value.(type) {
+	case []uint16:
+		if len(v) > 0 {
+			orientation = int(v[0])
+		}
+	case []uint32:
+		if len(v) > 0 {
+			orientation = int(v[0])
+		}
+	default:
+		log.Panicf("orientation type not valid: (%d)", ee.TagType)
+	}
+
+	if orientation < ORIENTATION_TOP_LEFT || orientation > ORIENTATION_LEFT_BOTTOM {
+		log.Panicf("orientation not valid: (%d)", orientation)
+	}
+
+	return orientation, nil
+}
+
+// DisplayDimensions returns the dimensions of the image as it should be
+// displayed. The stored width and height are swapped for the orientations
+// that transpose the image (5-8).
+func (sl SegmentList) DisplayDimensions() (width, height int, err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	width, height, err = sl.Dimensions()
+	log.PanicIf(err)
+
+	orientation, err := sl.Orientation()
+	log.PanicIf(err)
+
+	if orientation >= ORIENTATION_LEFT_TOP {
+		width, height = height, width
+	}
+
+	return width, height, nil
+}
+
+// SetOrientation sets the EXIF orientation, adding an EXIF segment if there
+// isn't one.
+func (sl *SegmentList) SetOrientation(orientation int) (err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	if orientation < ORIENTATION_TOP_LEFT || orientation > ORIENTATION_LEFT_BOTTOM {
+		log.Panicf("orientation not valid: (%d)", orientation)
+	}
+
+	ed, err := sl.ExifDocument()
+	if err != nil {
+		if log.Is(err, ErrSegmentNotFound) == false {
+			log.Panic(err)
+		}
+
+		ed = NewExifDocument(binary.BigEndian)
+	}
+
+	err = ed.SetValue(EXIF_IFD_ROOT, exifTagOrientation, EXIF_TYPE_SHORT, []uint16{uint16(orientation)})
+	log.PanicIf(err)
+
+	err = sl.SetExifDocument(ed)
+	log.PanicIf(err)
+
+	return nil
+}
+
+var (
+	// orientationTransforms are the transforms that make images with each
+	// orientation display correctly without the tag.
+	orientationTransforms = map[int]Transform{
+		ORIENTATION_TOP_LEFT: TransformNone,
+		ORIENTATION_TOP_RIGHT: TransformFlipHorizontal,
+		ORIENTATION_BOTTOM_RIGHT: TransformRotate180,
+		ORIENTATION_BOTTOM_LEFT: TransformFlipVertical,
+		ORIENTATION_LEFT_TOP: TransformTranspose,
+		ORIENTATION_RIGHT_TOP: TransformRotate90,
+		ORIENTATION_RIGHT_BOTTOM: TransformTransverse,
+		ORIENTATION_LEFT_BOTTOM: TransformRotate270,
+	}
+)
+
+// AutoOrient losslessly rotates and/or flips the image as its EXIF
+// orientation requires and then resets the orientation to
+// ORIENTATION_TOP_LEFT, so that it displays correctly in viewers that ignore
+// the tag. An image that is already upright is returned as-is.
+func (sl SegmentList) AutoOrient() (oriented SegmentList, err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	orientation, err := sl.Orientation()
+	log.PanicIf(err)
+
+	if orientation == ORIENTATION_TOP_LEFT {
+		return sl, nil
+	}
+
+	oriented, err = sl.Transform(orientationTransforms[orientation])
+	log.PanicIf(err)
+
+	err = oriented.SetOrientation(ORIENTATION_TOP_LEFT)
+	log.PanicIf(err)
+
+	return oriented, nil
+}
@@ -0,0 +1,122 @@
+package jpegstructure
+
+import (
+	"path"
+	"testing"
+
+	"github.com/dsoprea/go-logging"
+)
+
+func TestSegmentList_Orientation(t *testing.T) {
+	filepath := path.Join(assetsPath, testImageRelFilepath)
+
+	sl, err := ParseFileStructure(filepath)
+	log.PanicIf(err)
+
+	orientation, err := sl.Orientation()
+	log.PanicIf(err)
+
+	if orientation != ORIENTATION_TOP_LEFT {
+		t.Fatalf("Orientation not correct: (%d)", orientation)
+	}
+
+	width, height, err := sl.Dimensions()
+	log.PanicIf(err)
+
+	err = sl.SetOrientation(ORIENTATION_RIGHT_TOP)
+	log.PanicIf(err)
+
+	orientation, err = sl.Orientation()
+	log.PanicIf(err)
+
+	if orientation != ORIENTATION_RIGHT_TOP {
+		t.Fatalf("Orientation not updated: (%d)", orientation)
+	}
+
+	displayWidth, displayHeight, err := sl.DisplayDimensions()
+	log.PanicIf(err)
+
+	if displayWidth != height || displayHeight != width {
+		t.Fatalf("Display dimensions not swapped: (%d)x(%d)", displayWidth, displayHeight)
+	}
+
+	err = sl.SetOrientation(9)
+	if err == nil {
+		t.Fatalf("Expected error for invalid orientation.")
+	}
+}
+
+func TestSegmentList_SetOrientation_NoExif(t *testing.T) {
+	filepath := path.Join(assetsPath, testImageRelFilepath)
+
+	original, err := ParseFileStructure(filepath)
+	log.PanicIf(err)
+
+	sl := original.StripMetadata(false)
+
+	orientation, err := sl.Orientation()
+	log.PanicIf(err)
+
+	if orientation != ORIENTATION_TOP_LEFT {
+		t.Fatalf("Default orientation not correct: (%d)", orientation)
+	}
+
+	err = sl.SetOrientation(ORIENTATION_BOTTOM_RIGHT)
+	log.PanicIf(err)
+
+	if len(sl) != len(original.StripMetadata(false)) + 1 {
+		t.Fatalf("EXIF segment not added.")
+	}
+
+	orientation, err = sl.Orientation()
+	log.PanicIf(err)
+
+	if orientation != ORIENTATION_BOTTOM_RIGHT {
+		t.Fatalf("Orientation not correct: (%d)", orientation)
+	}
+}
+
+func TestSegmentList_AutoOrient(t *testing.T) {
+	sl, err := ParseBytesStructure(getTransformTestImage())
+	log.PanicIf(err)
+
+	original := decodeLuma(sl)
+
+	err = sl.SetOrientation(ORIENTATION_RIGHT_TOP)
+	log.PanicIf(err)
+
+	oriented, err := sl.AutoOrient()
+	log.PanicIf(err)
+
+	orientation, err := oriented.Orientation()
+	log.PanicIf(err)
+
+	if orientation != ORIENTATION_TOP_LEFT {
+		t.Fatalf("Orientation not reset: (%d)", orientation)
+	}
+
+	width, height, err := oriented.Dimensions()
+	log.PanicIf(err)
+
+	// The original is 67x45 and the trimmed height becomes the width.
+	if width != 32 || height != 67 {
+		t.Fatalf("Dimensions not correct: (%d)x(%d)", width, height)
+	}
+
+	actual := decodeLuma(oriented)
+
+	// The top-left pixel of the displayed image is the bottom-left pixel of
+	// the (trimmed) stored image.
+	difference := int(actual.Y[actual.YOffset(0, 0)]) - int(original.Y[original.YOffset(0, 31)])
+	if difference < -2 || difference > 2 {
+		t.Fatalf("Image not rotated: (%d)", difference)
+	}
+
+	// An upright image is left alone.
+	unchanged, err := oriented.AutoOrient()
+	log.PanicIf(err)
+
+	if len(unchanged) != len(oriented) || unchanged[len(unchanged) - 2].Offset != oriented[len(oriented) - 2].Offset {
+		t.Fatalf("Upright image was changed.")
+	}
+}
@@ -0,0 +1,120 @@
+package jpegstructure
+
+import (
+	"runtime"
+	"sync"
+
+	"github.com/dsoprea/go-logging"
+)
+
+// ParseResult is the outcome of parsing one of the files given to ParseMany
+// or ParseEach.
+type ParseResult struct {
+	// Index is the position of the file in the list that was given.
+	Index int
+
+	Filepath string
+	Segments SegmentList
+
+	// Err is set if the file could not be parsed.
+	Err error
+}
+
+// ParseCallback receives each result of ParseEach. The callback is never
+// called concurrently. Returning an error stops the parsing and ParseEach
+// returns it.
+type ParseCallback func(result ParseResult) error
+
+// ParseEach parses the files using a pool of `workers` goroutines (defaulting
+// to the number of CPUs) and passes each result to the callback as it becomes
+// available, in no particular order. At most `workers` files are held in
+// memory at a time (plus whatever the callback keeps), so this is suitable
+// for large libraries.
+func ParseEach(filepaths []string, workers int, cb ParseCallback) (err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+
+	jobs := make(chan int)
+	results := make(chan ParseResult)
+	done := make(chan struct{})
+
+	go func() {
+		defer close(jobs)
+
+		for i := range filepaths {
+			select {
+			case jobs <- i:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	wg := new(sync.WaitGroup)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for index := range jobs {
+				result := ParseResult{
+					Index: index,
+					Filepath: filepaths[index],
+				}
+
+				result.Segments, result.Err = ParseFileStructure(result.Filepath)
+
+				select {
+				case results <- result:
+				case <-done:
+					return
+				}
+			}
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	for result := range results {
+		err := cb(result)
+		if err != nil {
+			close(done)
+
+			// Drain so that the workers and the feeder can exit.
+			for _ = range results {
+			}
+
+			log.Panic(err)
+		}
+	}
+
+	return nil
+}
+
+// ParseMany parses the files concurrently (see ParseEach) and returns the
+// results in the same order as the files. Failures are recorded in the
+// results rather than returned.
+func ParseMany(filepaths []string, workers int) []ParseResult {
+	results := make([]ParseResult, len(filepaths))
+
+	err := ParseEach(filepaths, workers, func(result ParseResult) error {
+		results[result.Index] = result
+		return nil
+	})
+
+	// The callback never fails.
+	log.PanicIf(err)
+
+	return results
+}
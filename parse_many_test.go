@@ -0,0 +1,60 @@
+package jpegstructure
+
+import (
+	"errors"
+	"path"
+	"testing"
+
+	"github.com/dsoprea/go-logging"
+)
+
+func TestParseMany(t *testing.T) {
+	filepaths := []string{
+		path.Join(assetsPath, testImageRelFilepath),
+		path.Join(assetsPath, "does-not-exist.jpg"),
+		path.Join(assetsPath, "20180428_212314.jpg"),
+	}
+
+	results := ParseMany(filepaths, 2)
+
+	if len(results) != len(filepaths) {
+		t.Fatalf("Result count not correct: (%d)", len(results))
+	}
+
+	for i, result := range results {
+		if result.Index != i || result.Filepath != filepaths[i] {
+			t.Fatalf("Result (%d) out of order: [%s]", i, result.Filepath)
+		}
+	}
+
+	if results[0].Err != nil || len(results[0].Segments) == 0 {
+		t.Fatalf("First file not parsed: %v", results[0].Err)
+	} else if results[1].Err == nil || results[1].Segments != nil {
+		t.Fatalf("Missing file expected to fail.")
+	} else if results[2].Err != nil || len(results[2].Segments) == 0 {
+		t.Fatalf("Third file not parsed: %v", results[2].Err)
+	}
+}
+
+func TestParseEach_CallbackError(t *testing.T) {
+	filepaths := make([]string, 20)
+	for i := range filepaths {
+		filepaths[i] = path.Join(assetsPath, testImageRelFilepath)
+	}
+
+	stopErr := errors.New("stop")
+
+	count := 0
+	err := ParseEach(filepaths, 4, func(result ParseResult) error {
+		count++
+		return stopErr
+	})
+
+	if err == nil {
+		t.Fatalf("Expected error.")
+	} else if log.Is(err, stopErr) == false {
+		t.Fatalf("Error not correct: %v", err)
+	} else if count != 1 {
+		t.Fatalf("Callback not expected after an error: (%d)", count)
+	}
+}
@@ -0,0 +1,104 @@
+package jpegstructure
+
+import (
+	"bufio"
+	"io"
+
+	"github.com/dsoprea/go-logging"
+)
+
+const (
+	// streamBufferSize fits the largest (two-byte-length) segment so that
+	// payloads can be peeked rather than copied.
+	streamBufferSize = 0x10000 + 16
+)
+
+// streamSource reads forward through a stream for parseSegmentHeaders().
+// Reads may overlap (within the buffer) but may not go backwards past bytes
+// that were already consumed.
+type streamSource struct {
+	br *bufio.Reader
+
+	// position is the offset of the next byte of the reader.
+	position int64
+}
+
+// read returns `size` bytes from `offset`. The bytes are only valid until the
+// next read.
+func (ss *streamSource) read(offset int64, size int) []byte {
+	if offset < ss.position {
+		log.Panicf("stream can not be read backwards: (%d) < (%d)", offset, ss.position)
+	}
+
+	if offset > ss.position {
+		_, err := ss.br.Discard(int(offset - ss.position))
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+
+		log.PanicIf(err)
+
+		ss.position = offset
+	}
+
+	if size <= ss.br.Size() {
+		data, err := ss.br.Peek(size)
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+
+		log.PanicIf(err)
+
+		return data
+	}
+
+	data := make([]byte, size)
+
+	_, err := io.ReadFull(ss.br, data)
+	log.PanicIf(err)
+
+	ss.position += int64(size)
+
+	return data
+}
+
+// RewriteStream copies an image from `r` to `w`, passing the segments before
+// the scan (through the SOS) to `rewrite`, which may change them. The scan-
+// data and everything after it are copied from the reader to the writer
+// without being held in memory, so memory use doesn't depend on the size of
+// the image. This suits pipelines that strip or edit metadata but never touch
+// the pixels. The rewrite must leave the SOS as the last segment.
+func RewriteStream(r io.Reader, w io.Writer, rewrite func(sl *SegmentList) error) (err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	ss := &streamSource{
+		br: bufio.NewReaderSize(r, streamBufferSize),
+	}
+
+	sl := parseSegmentHeaders(ss, nil)
+
+	last := sl[len(sl) - 1]
+	end := last.EndOffset()
+
+	err = rewrite(&sl)
+	log.PanicIf(err)
+
+	if last.MarkerId == MARKER_SOS && (len(sl) == 0 || sl[len(sl) - 1].MarkerId != MARKER_SOS) {
+		log.Panicf("rewrite must leave the SOS as the last segment")
+	}
+
+	err = sl.Write(w)
+	log.PanicIf(err)
+
+	// Consume the rest of the last segment, which may only have been peeked.
+	ss.read(int64(end), 0)
+
+	_, err = io.Copy(w, ss.br)
+	log.PanicIf(err)
+
+	return nil
+}
@@ -0,0 +1,74 @@
+package jpegstructure
+
+import (
+	"bytes"
+	"path"
+	"testing"
+
+	"io/ioutil"
+
+	"github.com/dsoprea/go-logging"
+)
+
+func TestRewriteStream(t *testing.T) {
+	filepath := path.Join(assetsPath, testImageRelFilepath)
+
+	data, err := ioutil.ReadFile(filepath)
+	log.PanicIf(err)
+
+	sl, err := ParseBytesStructure(data)
+	log.PanicIf(err)
+
+	expected := new(bytes.Buffer)
+
+	err = sl.StripMetadata(false).Write(expected)
+	log.PanicIf(err)
+
+	b := new(bytes.Buffer)
+
+	err = RewriteStream(bytes.NewReader(data), b, func(headers *SegmentList) error {
+		if (*headers)[len(*headers) - 1].MarkerId != MARKER_SOS {
+			t.Fatalf("Headers don't end with the SOS.")
+		}
+
+		for _, s := range *headers {
+			if s.MarkerId == 0x0 {
+				t.Fatalf("Scan-data passed to the rewrite.")
+			}
+		}
+
+		*headers = headers.StripMetadata(false)
+		return nil
+	})
+
+	log.PanicIf(err)
+
+	if bytes.Equal(b.Bytes(), expected.Bytes()) == false {
+		t.Fatalf("Rewritten image not correct: (%d) != (%d)", b.Len(), expected.Len())
+	}
+
+	// Unchanged.
+
+	b.Reset()
+
+	err = RewriteStream(bytes.NewReader(data), b, func(headers *SegmentList) error {
+		return nil
+	})
+
+	log.PanicIf(err)
+
+	if bytes.Equal(b.Bytes(), data) == false {
+		t.Fatalf("Passed-through image not correct.")
+	}
+
+	// Removing the SOS is not allowed.
+
+	err = RewriteStream(bytes.NewReader(data), b, func(headers *SegmentList) error {
+		*headers = (*headers)[:len(*headers) - 1]
+		return nil
+	})
+
+	if err == nil {
+		t.Fatalf("Expected error when the SOS is removed.")
+	}
+}
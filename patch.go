@@ -0,0 +1,116 @@
+package jpegstructure
+
+import (
+	"bytes"
+	"errors"
+	"os"
+
+	"github.com/dsoprea/go-logging"
+)
+
+const (
+	// minimumFillerSize is the size of the smallest COM segment (the header
+	// and a one-byte payload).
+	minimumFillerSize = 2 + 2 + 1
+)
+
+var (
+	// ErrPatchNotPossible is returned by WritePatch() when the changes can't
+	// be written in place. The whole file needs to be rewritten instead (see
+	// UpdateFile()).
+	ErrPatchNotPossible = errors.New("changes can not be patched in place")
+)
+
+// filePatch is a run of bytes to write at an offset.
+type filePatch struct {
+	offset int64
+	data []byte
+}
+
+// WritePatch writes the changes made to a list that was parsed from the file
+// back into the file in place, rewriting only the segments that changed. The
+// segments up to the first SOS are read from the file again (with
+// ParseSparse(), so the scan-data isn't read) and matched to the list's in
+// order, so the list's offsets don't matter (the setters recalculate them).
+// Only APPn and COM payloads among them may have changed and none may have
+// grown. The segments after the first SOS aren't compared with the file and
+// are never patched. A segment that shrank is followed by a COM segment that
+// fills the freed space (or by 0xff fill bytes, if there's not enough room
+// for a COM segment), so nothing else moves. If anything else changed,
+// ErrPatchNotPossible is returned and the file isn't touched. The list doesn't
+// describe the patched file, so it should be parsed again afterward.
+func (sl SegmentList) WritePatch(f *os.File) (err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	current, err := ParseSparse(f, SparseOptions{})
+	log.PanicIf(err)
+
+	if len(sl) < len(current) || (len(sl) > len(current) && current[len(current) - 1].MarkerId != MARKER_SOS) {
+		jpegLogger.Debugf(nil, "Segments were added or removed: (%d) (%d)", len(sl), len(current))
+		log.Panic(ErrPatchNotPossible)
+	}
+
+	patches := make([]filePatch, 0)
+
+	for i, original := range current {
+		s := sl[i]
+
+		if s.MarkerId != original.MarkerId {
+			jpegLogger.Debugf(nil, "Segment (%d) does not match the file.", i)
+			log.Panic(ErrPatchNotPossible)
+		} else if bytes.Equal(s.Data, original.Data) == true {
+			continue
+		}
+
+		originalLength := len(original.Data)
+
+		if isMetadataMarker(s.MarkerId) == false || len(s.Data) > originalLength {
+			jpegLogger.Debugf(nil, "Segment (%d) changed in a way that can't be patched.", i)
+			log.Panic(ErrPatchNotPossible)
+		}
+
+		b := new(bytes.Buffer)
+
+		replacement := Segment{
+			MarkerId: s.MarkerId,
+			Data: s.Data,
+		}
+
+		err := replacement.Write(b)
+		log.PanicIf(err)
+
+		freed := originalLength - len(s.Data)
+		if freed >= minimumFillerSize {
+			filler := Segment{
+				MarkerId: MARKER_COM,
+				Data: make([]byte, freed - 4),
+			}
+
+			err := filler.Write(b)
+			log.PanicIf(err)
+		} else if freed > 0 {
+			b.Write(bytes.Repeat([]byte{0xff}, freed))
+		}
+
+		patches = append(patches, filePatch{
+			offset: int64(original.Offset),
+			data: b.Bytes(),
+		})
+	}
+
+	for _, fp := range patches {
+		_, err := f.WriteAt(fp.data, fp.offset)
+		log.PanicIf(err)
+	}
+
+	if len(patches) > 0 {
+		err := f.Sync()
+		log.PanicIf(err)
+	}
+
+	return nil
+}
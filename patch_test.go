@@ -0,0 +1,239 @@
+package jpegstructure
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"crypto/sha256"
+	"io/ioutil"
+
+	"github.com/dsoprea/go-logging"
+)
+
+// patchTestFile parses the copy of the test image, lets `edit` change the
+// list, and patches the file.
+func patchTestFile(filepath string, edit func(sl SegmentList)) (err error) {
+	sl, err := ParseFileStructure(filepath)
+	log.PanicIf(err)
+
+	edit(sl)
+
+	f, err := os.OpenFile(filepath, os.O_RDWR, 0)
+	log.PanicIf(err)
+
+	defer f.Close()
+
+	return sl.WritePatch(f)
+}
+
+func TestSegmentList_WritePatch(t *testing.T) {
+	tempPath, filepath := getUpdateTestFilepath()
+	defer os.RemoveAll(tempPath)
+
+	original, err := ioutil.ReadFile(filepath)
+	log.PanicIf(err)
+
+	originalSl, err := ParseBytesStructure(original)
+	log.PanicIf(err)
+
+	originalDigest, err := originalSl.ImageDigest(sha256.New())
+	log.PanicIf(err)
+
+	xmpIndex := 2
+
+	// Shrink by enough for a COM filler, then by too little for one, then
+	// keep the size.
+	for _, shrinkBy := range []int { 100, 3, 0 } {
+		var payload []byte
+		before := 0
+
+		err := patchTestFile(filepath, func(sl SegmentList) {
+			before = len(sl)
+
+			payload = append([]byte{}, sl[xmpIndex].Data[:len(sl[xmpIndex].Data) - shrinkBy]...)
+			payload[len(payload) - 1] = 'X'
+
+			sl[xmpIndex].Data = payload
+		})
+
+		log.PanicIf(err)
+
+		data, err := ioutil.ReadFile(filepath)
+		log.PanicIf(err)
+
+		if len(data) != len(original) {
+			t.Fatalf("File size changed: (%d) != (%d)", len(data), len(original))
+		}
+
+		sl, err := ParseBytesStructure(data)
+		log.PanicIf(err)
+
+		if bytes.Equal(sl[xmpIndex].Data, payload) == false {
+			t.Fatalf("Payload not patched (shrunk by %d).", shrinkBy)
+		}
+
+		if shrinkBy >= minimumFillerSize {
+			if sl[xmpIndex + 1].MarkerId != MARKER_COM || len(sl) != before + 1 {
+				t.Fatalf("Filler not written.")
+			}
+		} else if len(sl) != before {
+			t.Fatalf("Segment count not correct (shrunk by %d): (%d)", shrinkBy, len(sl))
+		}
+
+		digest, err := sl.ImageDigest(sha256.New())
+		log.PanicIf(err)
+
+		if bytes.Equal(digest, originalDigest) == false {
+			t.Fatalf("Image changed.")
+		}
+	}
+}
+
+func TestSegmentList_WritePatch_NotPossible(t *testing.T) {
+	tempPath, filepath := getUpdateTestFilepath()
+	defer os.RemoveAll(tempPath)
+
+	original, err := ioutil.ReadFile(filepath)
+	log.PanicIf(err)
+
+	edits := []func(sl SegmentList) {
+		// Grown.
+		func(sl SegmentList) {
+			sl[2].Data = append(append([]byte{}, sl[2].Data...), ' ')
+		},
+
+		// Not metadata.
+		func(sl SegmentList) {
+			i := sl.Index(MARKER_DQT)
+			sl[i].Data = append([]byte{}, sl[i].Data...)
+			sl[i].Data[1]++
+		},
+
+		// Removed.
+		func(sl SegmentList) {
+			copy(sl[2:], sl[3:])
+		},
+	}
+
+	for i, edit := range edits {
+		err := patchTestFile(filepath, edit)
+		if err == nil {
+			t.Fatalf("Expected error for edit (%d).", i)
+		} else if log.Is(err, ErrPatchNotPossible) == false {
+			t.Fatalf("Error not correct for edit (%d): %v", i, err)
+		}
+
+		data, err := ioutil.ReadFile(filepath)
+		log.PanicIf(err)
+
+		if bytes.Equal(data, original) == false {
+			t.Fatalf("File changed by edit (%d).", i)
+		}
+	}
+}
+
+// patchTestFileWithSetter is patchTestFile for edits that go through the
+// setters, which may replace the list.
+func patchTestFileWithSetter(filepath string, edit func(sl *SegmentList)) (err error) {
+	sl, err := ParseFileStructure(filepath)
+	log.PanicIf(err)
+
+	edit(&sl)
+
+	f, err := os.OpenFile(filepath, os.O_RDWR, 0)
+	log.PanicIf(err)
+
+	defer f.Close()
+
+	return sl.WritePatch(f)
+}
+
+func TestSegmentList_WritePatch_Setters(t *testing.T) {
+	tempPath, filepath := getUpdateTestFilepath()
+	defer os.RemoveAll(tempPath)
+
+	// Remove an IFD0 tag through the EXIF document.
+
+	err := patchTestFileWithSetter(filepath, func(sl *SegmentList) {
+		ed, err := sl.ExifDocument()
+		log.PanicIf(err)
+
+		ed.Root.Entries = ed.Root.Entries[1:]
+
+		err = sl.SetExifDocument(ed)
+		log.PanicIf(err)
+	})
+
+	log.PanicIf(err)
+
+	sl, err := ParseFileStructure(filepath)
+	log.PanicIf(err)
+
+	ed, err := sl.ExifDocument()
+	log.PanicIf(err)
+
+	expected := getTestExifDocument(testImageRelFilepath)
+	if len(ed.Root.Entries) != len(expected.Root.Entries) - 1 {
+		t.Fatalf("EXIF edit not patched: (%d)", len(ed.Root.Entries))
+	}
+
+	// Replace the XMP packet.
+
+	xmpBody := []byte(`<x:xmpmeta xmlns:x="adobe:ns:meta/"><rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#"><rdf:Description rdf:about="" xmlns:xmp="http://ns.adobe.com/xap/1.0/" xmp:Rating="5"/></rdf:RDF></x:xmpmeta>`)
+
+	err = patchTestFileWithSetter(filepath, func(sl *SegmentList) {
+		err := sl.SetXmp(xmpBody)
+		log.PanicIf(err)
+	})
+
+	log.PanicIf(err)
+
+	sl, err = ParseFileStructure(filepath)
+	log.PanicIf(err)
+
+	found := false
+	for _, s := range sl {
+		if s.Kind() == SegmentKindXmpApp1 && bytes.Contains(s.Data, []byte(`xmp:Rating="5"`)) == true {
+			found = true
+		}
+	}
+
+	if found == false {
+		t.Fatalf("XMP edit not patched.")
+	}
+}
+
+func TestSegmentList_WritePatch_ScanDataNotRead(t *testing.T) {
+	tempPath, filepath := getUpdateTestFilepath()
+	defer os.RemoveAll(tempPath)
+
+	sl, err := ParseFileStructure(filepath)
+	log.PanicIf(err)
+
+	// Cut the file off inside the scan-data. Only the headers are read again,
+	// so it can still be patched.
+	sos := sl[sl.Index(MARKER_SOS)]
+
+	err = os.Truncate(filepath, int64(sos.EndOffset() + 10))
+	log.PanicIf(err)
+
+	xmpIndex := 2
+	payload := append([]byte{}, sl[xmpIndex].Data[:len(sl[xmpIndex].Data) - 100]...)
+	sl[xmpIndex].Data = payload
+
+	f, err := os.OpenFile(filepath, os.O_RDWR, 0)
+	log.PanicIf(err)
+
+	defer f.Close()
+
+	err = sl.WritePatch(f)
+	log.PanicIf(err)
+
+	patched, err := ParseSparse(f, SparseOptions{})
+	log.PanicIf(err)
+
+	if bytes.Equal(patched[xmpIndex].Data, payload) == false {
+		t.Fatalf("Payload not patched.")
+	}
+}
@@ -0,0 +1,250 @@
+package jpegstructure
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/dsoprea/go-logging"
+)
+
+const (
+	PREVIEW_SOURCE_EXIF = "exif"
+	PREVIEW_SOURCE_JFIF = "jfif"
+	PREVIEW_SOURCE_JFXX = "jfxx"
+	PREVIEW_SOURCE_MPF = "mpf"
+)
+
+const (
+	PREVIEW_FORMAT_JPEG = "jpeg"
+
+	// PREVIEW_FORMAT_RGB is uncompressed, 24-bit, row-major pixels.
+	PREVIEW_FORMAT_RGB = "rgb"
+)
+
+const (
+	mpfTagMpEntry = 0xb002
+
+	// mpEntrySize is the size of each record of the MP Entry tag.
+	mpEntrySize = 16
+)
+
+var (
+	mpfPrefix = []byte{'M', 'P', 'F', 0x00}
+)
+
+// Preview is a reduced-size image embedded in the file.
+type Preview struct {
+	// Source is where the preview was found (PREVIEW_SOURCE_*).
+	Source string
+
+	// SegmentIndex is the segment that carries (or, for MPF, indexes) the
+	// preview.
+	SegmentIndex int
+
+	// Format is PREVIEW_FORMAT_JPEG or PREVIEW_FORMAT_RGB.
+	Format string
+
+	// Width and Height are zero if they couldn't be determined.
+	Width, Height int
+
+	Data []byte
+}
+
+func (p Preview) String() string {
+	return fmt.Sprintf("Preview<SOURCE=[%s] SEGMENT=(%d) FORMAT=[%s] WIDTH=(%d) HEIGHT=(%d) SIZE=(%d)>", p.Source, p.SegmentIndex, p.Format, p.Width, p.Height, len(p.Data))
+}
+
+// newJpegPreview fills the dimensions from the preview's own frame header.
+func newJpegPreview(source string, segmentIndex int, data []byte) Preview {
+	p := Preview{
+		Source: source,
+		SegmentIndex: segmentIndex,
+		Format: PREVIEW_FORMAT_JPEG,
+		Data: data,
+	}
+
+	sl, err := ParseBytesStructure(data)
+	if err == nil {
+		p.Width, p.Height, _ = sl.Dimensions()
+	}
+
+	return p
+}
+
+// mpfImage is an image indexed by an MPF segment.
+type mpfImage struct {
+	// attribute is the individual image attribute (the flags, format, and
+	// type code).
+	attribute uint32
+
+	// start is the position of the image in the file.
+	start int
+	size int
+}
+
+// mpfImages returns the images other than the primary one that are listed by
+// an MPF APP2 segment. Their offsets are relative to the TIFF header within
+// the segment and refer to the rest of the file.
+func (sl SegmentList) mpfImages(segmentIndex int, data []byte) (images []mpfImage, err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	s := sl[segmentIndex]
+
+	ed, err := ParseExifDocument(s.Data[len(mpfPrefix):])
+	log.PanicIf(err)
+
+	images = make([]mpfImage, 0)
+
+	// Only the first image of an MPO has the index. The others only have
+	// their attributes.
+	ee, err := ed.Root.Entry(mpfTagMpEntry)
+	if log.Is(err, ErrExifTagNotFound) == true {
+		return images, nil
+	}
+
+	log.PanicIf(err)
+
+	base := s.Offset + s.HeaderSize + len(mpfPrefix)
+
+	for i := 0; i + mpEntrySize <= len(ee.RawValue); i += mpEntrySize {
+		raw := ee.RawValue[i:]
+
+		attribute := ed.ByteOrder.Uint32(raw[0:])
+		size := int(ed.ByteOrder.Uint32(raw[4:]))
+		offset := int(ed.ByteOrder.Uint32(raw[8:]))
+
+		// The primary image has an offset of zero.
+		if offset == 0 {
+			continue
+		}
+
+		start := base + offset
+		if start < 0 || start + size > len(data) {
+			log.Panicf("MPF image out of bounds: (%d) (%d)", start, size)
+		}
+
+		mi := mpfImage{
+			attribute: attribute,
+			start: start,
+			size: size,
+		}
+
+		images = append(images, mi)
+	}
+
+	return images, nil
+}
+
+// mpfPreviews returns the images indexed by an MPF APP2 segment.
+func (sl SegmentList) mpfPreviews(segmentIndex int, data []byte) (previews []Preview, err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	images, err := sl.mpfImages(segmentIndex, data)
+	log.PanicIf(err)
+
+	previews = make([]Preview, 0)
+	for _, mi := range images {
+		previews = append(previews, newJpegPreview(PREVIEW_SOURCE_MPF, segmentIndex, data[mi.start:mi.start + mi.size]))
+	}
+
+	return previews, nil
+}
+
+// Previews returns every embedded reduced-size image: the EXIF (IFD1)
+// thumbnail, the JFIF and JFXX thumbnails, and the images indexed by an MPF
+// segment. The MPF images are stored after the primary image, so they're only
+// found if `data` (the whole file) is given; it may be nil. Thumbnails and MPF
+// indices that can't be parsed are skipped.
+func (sl SegmentList) Previews(data []byte) (previews []Preview, err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	previews = make([]Preview, 0)
+
+	for i, s := range sl {
+		switch {
+		case s.Kind() == SegmentKindExifApp1:
+			ed, err := ParseExifDocument(s.Data[len(exifPrefix):])
+			if err != nil || ed.ThumbnailIfd == nil || ed.ThumbnailIfd.Thumbnail == nil {
+				continue
+			}
+
+			previews = append(previews, newJpegPreview(PREVIEW_SOURCE_EXIF, i, ed.ThumbnailIfd.Thumbnail))
+		case s.MarkerId == MARKER_APP0 && isJfifPayload(s.Data) == true:
+			jfif, err := ParseJfifSegment(s.Data)
+			if err != nil || jfif.ThumbnailWidth == 0 || jfif.ThumbnailHeight == 0 {
+				continue
+			}
+
+			// The thumbnail follows the fixed header.
+			pixels := s.Data[len(jfifPrefix) + 9:]
+			size := int(jfif.ThumbnailWidth) * int(jfif.ThumbnailHeight) * 3
+			if len(pixels) < size {
+				continue
+			}
+
+			p := Preview{
+				Source: PREVIEW_SOURCE_JFIF,
+				SegmentIndex: i,
+				Format: PREVIEW_FORMAT_RGB,
+				Width: int(jfif.ThumbnailWidth),
+				Height: int(jfif.ThumbnailHeight),
+				Data: pixels[:size],
+			}
+
+			previews = append(previews, p)
+		case s.MarkerId == MARKER_APP0 && isJfxxPayload(s.Data) == true:
+			js, err := ParseJfxxSegment(s.Data)
+			if err != nil {
+				continue
+			}
+
+			if js.ExtensionCode == JFXX_EXTENSION_JPEG {
+				previews = append(previews, newJpegPreview(PREVIEW_SOURCE_JFXX, i, js.Data))
+				continue
+			}
+
+			pixels, err := js.Rgb()
+			if err != nil {
+				continue
+			}
+
+			p := Preview{
+				Source: PREVIEW_SOURCE_JFXX,
+				SegmentIndex: i,
+				Format: PREVIEW_FORMAT_RGB,
+				Width: js.Width,
+				Height: js.Height,
+				Data: pixels,
+			}
+
+			previews = append(previews, p)
+		case s.MarkerId == MARKER_APP2 && bytes.HasPrefix(s.Data, mpfPrefix) == true:
+			if data == nil {
+				continue
+			}
+
+			// A bad index doesn't hide the other previews.
+			mpfPreviews, err := sl.mpfPreviews(i, data)
+			if err != nil {
+				jpegLogger.Debugf(nil, "MPF segment (%d) skipped: %v", i, err)
+				continue
+			}
+
+			previews = append(previews, mpfPreviews...)
+		}
+	}
+
+	return previews, nil
+}
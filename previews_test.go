@@ -0,0 +1,137 @@
+package jpegstructure
+
+import (
+	"bytes"
+	"path"
+	"testing"
+
+	"encoding/binary"
+
+	"github.com/dsoprea/go-logging"
+)
+
+func TestSegmentList_Previews_Exif(t *testing.T) {
+	filepath := path.Join(assetsPath, testImageRelFilepath)
+
+	sl, err := ParseFileStructure(filepath)
+	log.PanicIf(err)
+
+	previews, err := sl.Previews(nil)
+	log.PanicIf(err)
+
+	if len(previews) != 1 {
+		t.Fatalf("Preview count not correct: (%d)", len(previews))
+	}
+
+	p := previews[0]
+	if p.Source != PREVIEW_SOURCE_EXIF || p.Format != PREVIEW_FORMAT_JPEG || p.SegmentIndex != 1 || p.Width == 0 || p.Height == 0 {
+		t.Fatalf("Preview not correct: %s", p)
+	} else if bytes.HasPrefix(p.Data, []byte{0xff, MARKER_SOI}) == false {
+		t.Fatalf("Preview data not a JPEG.")
+	}
+}
+
+func TestSegmentList_Previews_BadMpf(t *testing.T) {
+	filepath := path.Join(assetsPath, testImageRelFilepath)
+
+	sl, err := ParseFileStructure(filepath)
+	log.PanicIf(err)
+
+	// An MPF segment whose index is truncated.
+	mpf := append(append([]byte{}, mpfPrefix...), 'M', 'M', 0x00)
+
+	bad := make(SegmentList, 0, len(sl) + 1)
+	bad = append(bad, sl[:2]...)
+	bad = append(bad, Segment{MarkerId: MARKER_APP2, MarkerName: "APP2", Data: mpf})
+	bad = append(bad, sl[2:]...)
+
+	b := new(bytes.Buffer)
+
+	err = bad.Write(b)
+	log.PanicIf(err)
+
+	previews, err := bad.Previews(b.Bytes())
+	log.PanicIf(err)
+
+	if len(previews) != 1 || previews[0].Source != PREVIEW_SOURCE_EXIF {
+		t.Fatalf("EXIF preview not returned alongside the bad MPF index: (%d)", len(previews))
+	}
+}
+
+func TestSegmentList_Previews_JfxxAndMpf(t *testing.T) {
+	thumbnail := getTransformTestImage()
+
+	jfxx := append(append([]byte{}, jfxxPrefix...), JFXX_EXTENSION_JPEG)
+	jfxx = append(jfxx, thumbnail...)
+
+	// Index the primary image and one more that's appended after it. The
+	// offset is filled in once the layout is known.
+	ed := NewExifDocument(binary.LittleEndian)
+
+	err := ed.SetValue(EXIF_IFD_ROOT, 0xb000, EXIF_TYPE_UNDEFINED, []byte("0100"))
+	log.PanicIf(err)
+
+	err = ed.SetValue(EXIF_IFD_ROOT, 0xb001, EXIF_TYPE_LONG, []uint32{2})
+	log.PanicIf(err)
+
+	entries := make([]byte, mpEntrySize * 2)
+	binary.LittleEndian.PutUint32(entries[0:], 0x20030000)
+	binary.LittleEndian.PutUint32(entries[mpEntrySize:], 0x00010001)
+	binary.LittleEndian.PutUint32(entries[mpEntrySize + 4:], uint32(len(thumbnail)))
+
+	mpfPayload := func() []byte {
+		err := ed.SetValue(EXIF_IFD_ROOT, mpfTagMpEntry, EXIF_TYPE_UNDEFINED, entries)
+		log.PanicIf(err)
+
+		encoded, err := ed.Encode()
+		log.PanicIf(err)
+
+		return append(append([]byte{}, mpfPrefix...), encoded...)
+	}
+
+	sl, err := NewBuilder().
+		AddSegment(MARKER_APP0, jfxx).
+		AddSegment(MARKER_APP2, mpfPayload()).
+		AddQuantTables(QuantizationTable{Values: standardLuminanceQuantTable}).
+		AddFrame(MARKER_SOF0, SofSegment{BitsPerSample: 8, Width: 8, Height: 8}, []SofComponent{{ComponentId: 1, HorizontalSamplingFactor: 1, VerticalSamplingFactor: 1}}).
+		AddScanData([]byte{0x00}).
+		Build()
+
+	log.PanicIf(err)
+
+	mpf := sl[2]
+	end := sl[len(sl) - 1].EndOffset()
+	binary.LittleEndian.PutUint32(entries[mpEntrySize + 8:], uint32(end - (mpf.Offset + mpf.HeaderSize + len(mpfPrefix))))
+
+	sl[2].Data = mpfPayload()
+
+	b := new(bytes.Buffer)
+
+	err = sl.Write(b)
+	log.PanicIf(err)
+
+	b.Write(thumbnail)
+
+	previews, err := sl.Previews(b.Bytes())
+	log.PanicIf(err)
+
+	if len(previews) != 2 {
+		t.Fatalf("Preview count not correct: (%d)", len(previews))
+	}
+
+	for i, source := range []string{PREVIEW_SOURCE_JFXX, PREVIEW_SOURCE_MPF} {
+		p := previews[i]
+
+		if p.Source != source || p.Width != 67 || p.Height != 45 || bytes.Equal(p.Data, thumbnail) == false {
+			t.Fatalf("Preview (%d) not correct: %s", i, p)
+		}
+	}
+
+	// Without the file data, the MPF images can't be found.
+	previews, err = sl.Previews(nil)
+	log.PanicIf(err)
+
+	if len(previews) != 1 {
+		t.Fatalf("Preview count without data not correct: (%d)", len(previews))
+	}
+}
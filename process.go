@@ -0,0 +1,119 @@
+package jpegstructure
+
+import (
+	"fmt"
+
+	"github.com/dsoprea/go-logging"
+)
+
+// EntropyCoding is how the scan-data is coded.
+type EntropyCoding int
+
+const (
+	EntropyCodingHuffman EntropyCoding = iota
+	EntropyCodingArithmetic
+)
+
+func (ec EntropyCoding) String() string {
+	if ec == EntropyCodingArithmetic {
+		return "arithmetic"
+	}
+
+	return "huffman"
+}
+
+// ProcessMode is the coding process of the frame(s).
+type ProcessMode int
+
+const (
+	ProcessModeSequential ProcessMode = iota
+	ProcessModeProgressive
+	ProcessModeLossless
+	ProcessModeHierarchical
+)
+
+func (pm ProcessMode) String() string {
+	switch pm {
+	case ProcessModeProgressive:
+		return "progressive"
+	case ProcessModeLossless:
+		return "lossless"
+	case ProcessModeHierarchical:
+		return "hierarchical"
+	}
+
+	return "sequential"
+}
+
+// ProcessInfo describes how an image was coded, which is enough for a
+// decoder to decide whether it can handle it before doing any work.
+type ProcessInfo struct {
+	// MarkerId is the marker of the (first) frame header.
+	MarkerId byte
+
+	EntropyCoding EntropyCoding
+	Mode ProcessMode
+
+	// Precision is the number of bits per sample.
+	Precision byte
+
+	// Baseline is true for the baseline process (SOF0), which every decoder
+	// supports.
+	Baseline bool
+}
+
+func (pi ProcessInfo) String() string {
+	return fmt.Sprintf("ProcessInfo<MARKER=[%s] CODING=[%s] MODE=[%s] PRECISION=(%d) BASELINE=[%v]>", markerNames[pi.MarkerId], pi.EntropyCoding, pi.Mode, pi.Precision, pi.Baseline)
+}
+
+// ProcessInfo returns the coding process from the SOFn variant of the first
+// frame. The presence of a DAC segment also indicates arithmetic coding and
+// the presence of a DHP segment indicates hierarchical mode.
+func (sl SegmentList) ProcessInfo() (pi *ProcessInfo, err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	for _, s := range sl {
+		if IsSofMarker(s.MarkerId) == false {
+			continue
+		}
+
+		sof, err := new(JpegSplitter).parseSof(s.Data)
+		log.PanicIf(err)
+
+		pi = &ProcessInfo{
+			MarkerId: s.MarkerId,
+			Precision: sof.BitsPerSample,
+			Baseline: s.MarkerId == MARKER_SOF0,
+		}
+
+		// The low two bits of the marker select the process within each
+		// group of four (SOF0-3, SOF5-7, SOF9-11, SOF13-15). The groups
+		// starting at SOF5 and SOF13 are differential (hierarchical).
+		switch (s.MarkerId - MARKER_SOF0) & 0x03 {
+		case 0, 1:
+			pi.Mode = ProcessModeSequential
+		case 2:
+			pi.Mode = ProcessModeProgressive
+		case 3:
+			pi.Mode = ProcessModeLossless
+		}
+
+		offset := (s.MarkerId - MARKER_SOF0) & 0x0c
+		if offset == 0x04 || offset == 0x0c || sl.IsHierarchical() == true {
+			pi.Mode = ProcessModeHierarchical
+		}
+
+		if s.MarkerId >= MARKER_SOF9 || sl.Index(MARKER_DAC) != -1 {
+			pi.EntropyCoding = EntropyCodingArithmetic
+		}
+
+		return pi, nil
+	}
+
+	log.Panic(ErrSegmentNotFound)
+	return nil, nil
+}
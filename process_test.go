@@ -0,0 +1,94 @@
+package jpegstructure
+
+import (
+	"path"
+	"testing"
+
+	"github.com/dsoprea/go-logging"
+)
+
+func TestSegmentList_ProcessInfo(t *testing.T) {
+	filepath := path.Join(assetsPath, testImageRelFilepath)
+
+	sl, err := ParseFileStructure(filepath)
+	log.PanicIf(err)
+
+	pi, err := sl.ProcessInfo()
+	log.PanicIf(err)
+
+	if pi.MarkerId != MARKER_SOF0 || pi.EntropyCoding != EntropyCodingHuffman || pi.Mode != ProcessModeSequential || pi.Precision != 8 || pi.Baseline != true {
+		t.Fatalf("Process not correct: %s", pi)
+	}
+}
+
+func TestSegmentList_ProcessInfo_Variants(t *testing.T) {
+	components := []SofComponent{
+		{ComponentId: 1, HorizontalSamplingFactor: 1, VerticalSamplingFactor: 1},
+	}
+
+	sof := SofSegment{
+		BitsPerSample: 12,
+		Width: 8,
+		Height: 8,
+	}
+
+	type variant struct {
+		markerId byte
+		entropyCoding EntropyCoding
+		mode ProcessMode
+	}
+
+	variants := []variant{
+		{MARKER_SOF1, EntropyCodingHuffman, ProcessModeSequential},
+		{MARKER_SOF2, EntropyCodingHuffman, ProcessModeProgressive},
+		{MARKER_SOF3, EntropyCodingHuffman, ProcessModeLossless},
+		{MARKER_SOF7, EntropyCodingHuffman, ProcessModeHierarchical},
+		{MARKER_SOF10, EntropyCodingArithmetic, ProcessModeProgressive},
+		{MARKER_SOF13, EntropyCodingArithmetic, ProcessModeHierarchical},
+	}
+
+	for _, v := range variants {
+		sl, err := NewBuilder().
+			AddQuantTables(QuantizationTable{Values: standardLuminanceQuantTable}).
+			AddFrame(v.markerId, sof, components).
+			AddScanData([]byte{0x00}).
+			Build()
+
+		log.PanicIf(err)
+
+		pi, err := sl.ProcessInfo()
+		log.PanicIf(err)
+
+		if pi.EntropyCoding != v.entropyCoding || pi.Mode != v.mode || pi.Precision != 12 || pi.Baseline != false {
+			t.Fatalf("Process not correct for (0x%02x): %s", v.markerId, pi)
+		}
+	}
+
+	// A DAC segment implies arithmetic coding.
+	sl, err := NewBuilder().
+		AddQuantTables(QuantizationTable{Values: standardLuminanceQuantTable}).
+		AddSegment(MARKER_DAC, []byte{0x00, 0x10}).
+		AddFrame(MARKER_SOF0, sof, components).
+		AddScanData([]byte{0x00}).
+		Build()
+
+	log.PanicIf(err)
+
+	pi, err := sl.ProcessInfo()
+	log.PanicIf(err)
+
+	if pi.EntropyCoding != EntropyCodingArithmetic {
+		t.Fatalf("Expected arithmetic coding with DAC: %s", pi)
+	}
+
+	// Hierarchical images are detected by their DHP segment.
+	sl, err = ParseBytesStructure(getHierarchicalTestImage())
+	log.PanicIf(err)
+
+	pi, err = sl.ProcessInfo()
+	log.PanicIf(err)
+
+	if pi.Mode != ProcessModeHierarchical {
+		t.Fatalf("Expected hierarchical mode: %s", pi)
+	}
+}
@@ -0,0 +1,69 @@
+package jpegstructure
+
+// attributed returns the segment with its provenance recorded as `source` and
+// its current offset. A segment that was already copied keeps its original
+// provenance.
+func (s Segment) attributed(source string) Segment {
+	if s.Source != "" {
+		return s
+	}
+
+	s.Source = source
+	s.SourceOffset = s.Offset
+
+	return s
+}
+
+// setData replaces the payload. The segment no longer holds the block that it
+// was copied from or the payload that its digest describes, so its provenance
+// and digest are cleared.
+func (s *Segment) setData(data []byte) {
+	s.Data = data
+	s.Digest = nil
+	s.Source = ""
+	s.SourceOffset = 0
+}
+
+// WithSource returns a copy of the list with every segment attributed to
+// `source` (e.g. the path of the file it was parsed from), so that the
+// segments can be traced after they're copied into other images.
+func (sl SegmentList) WithSource(source string) SegmentList {
+	attributed := make(SegmentList, len(sl))
+	for i, s := range sl {
+		attributed[i] = s.attributed(source)
+	}
+
+	return attributed
+}
+
+// CopyMetadata returns a copy of the list with its metadata (the segments that
+// StripMetadata() removes, including the ICC profile) replaced by that of
+// `from`. The copied segments follow the SOI (and JFIF header), in their
+// original order, and are attributed to `source` (which identifies `from`,
+// e.g. its path) unless they were copied from somewhere else to begin with.
+// The provenance is only kept in the list (and shown by DumpText()); it isn't
+// written to the file, so an audit has to record it before the list is
+// written. Edits that replace a payload clear it.
+func (sl SegmentList) CopyMetadata(from SegmentList, source string) SegmentList {
+	stripped := sl.StripMetadata(false)
+
+	merged := make(SegmentList, 0, len(stripped) + len(from))
+
+	// The JFIF header has to stay directly after the SOI.
+	i := 0
+	for i < len(stripped) && (stripped[i].MarkerId == MARKER_SOI || (stripped[i].MarkerId == MARKER_APP0 && isJfifPayload(stripped[i].Data) == true)) {
+		merged = append(merged, stripped[i])
+		i++
+	}
+
+	for _, s := range from {
+		if isMetadataMarker(s.MarkerId) == true && isDecodingSegment(s) == false {
+			merged = append(merged, s.attributed(source))
+		}
+	}
+
+	merged = append(merged, stripped[i:]...)
+	merged.updateOffsets()
+
+	return merged
+}
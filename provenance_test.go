@@ -0,0 +1,113 @@
+package jpegstructure
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/dsoprea/go-logging"
+)
+
+func TestSegmentList_CopyMetadata(t *testing.T) {
+	fromFilepath := path.Join(assetsPath, testImageRelFilepath)
+
+	from, err := ParseFileStructure(fromFilepath)
+	log.PanicIf(err)
+
+	toFilepath := path.Join(assetsPath, "20180428_212314.jpg")
+
+	to, err := ParseFileStructure(toFilepath)
+	log.PanicIf(err)
+
+	merged := to.CopyMetadata(from, fromFilepath)
+
+	if merged[0].MarkerId != MARKER_SOI || merged[0].Source != "" {
+		t.Fatalf("First segment not correct: (0x%02x) [%s]", merged[0].MarkerId, merged[0].Source)
+	} else if merged[1].MarkerId != MARKER_APP0 || merged[1].Source != "" {
+		t.Fatalf("JFIF header not kept after the SOI: (0x%02x) [%s]", merged[1].MarkerId, merged[1].Source)
+	}
+
+	copied := 0
+	for _, s := range merged {
+		if isMetadataMarker(s.MarkerId) == false || isDecodingSegment(s) == true {
+			if s.Source != "" {
+				t.Fatalf("Image segment attributed: (0x%02x) [%s]", s.MarkerId, s.Source)
+			}
+
+			continue
+		}
+
+		if s.Source != fromFilepath {
+			t.Fatalf("Metadata segment not attributed: (0x%02x) [%s]", s.MarkerId, s.Source)
+		}
+
+		original := from[1 + copied]
+		if s.SourceOffset != original.Offset || bytes.Equal(s.Data, original.Data) == false {
+			t.Fatalf("Source offset not correct: (%d) != (%d)", s.SourceOffset, original.Offset)
+		}
+
+		copied++
+	}
+
+	if copied != 2 {
+		t.Fatalf("Copied count not correct: (%d)", copied)
+	}
+
+	// Copying again keeps the original provenance.
+
+	remerged := to.CopyMetadata(merged, "intermediate")
+	for _, s := range remerged {
+		if s.Source == "intermediate" {
+			t.Fatalf("Provenance overwritten: (0x%02x)", s.MarkerId)
+		}
+	}
+
+	b := new(bytes.Buffer)
+
+	err = merged.DumpText(b, false)
+	log.PanicIf(err)
+
+	expected := fmt.Sprintf("|- SOURCE: [%s] OFFSET=(0x%08x %d)", fromFilepath, from[1].Offset, from[1].Offset)
+	if strings.Contains(b.String(), expected) == false {
+		t.Fatalf("Dump does not show the source:\n%s", b.String())
+	}
+}
+
+func TestSegmentBuilder_AddSegmentFrom(t *testing.T) {
+	filepath := path.Join(assetsPath, testImageRelFilepath)
+
+	original, err := ParseFileStructure(filepath)
+	log.PanicIf(err)
+
+	sb := NewBuilder().AddSegmentFrom(original[1], filepath)
+
+	s := sb.segments[0]
+	if s.Source != filepath || s.SourceOffset != original[1].Offset {
+		t.Fatalf("Provenance not correct: [%s] (%d)", s.Source, s.SourceOffset)
+	}
+}
+
+func TestSegmentList_CopyMetadata_Edited(t *testing.T) {
+	fromFilepath := path.Join(assetsPath, testImageRelFilepath)
+
+	from, err := ParseFileStructure(fromFilepath)
+	log.PanicIf(err)
+
+	to, err := ParseFileStructure(path.Join(assetsPath, "20180428_212314.jpg"))
+	log.PanicIf(err)
+
+	merged := to.CopyMetadata(from, fromFilepath)
+
+	err = merged.SetOrientation(ORIENTATION_LEFT_BOTTOM)
+	log.PanicIf(err)
+
+	for _, s := range merged {
+		if s.Kind() == SegmentKindExifApp1 && (s.Source != "" || s.SourceOffset != 0) {
+			t.Fatalf("Provenance of the edited EXIF segment not cleared: [%s] (%d)", s.Source, s.SourceOffset)
+		} else if s.Kind() == SegmentKindXmpApp1 && s.Source != fromFilepath {
+			t.Fatalf("Provenance of the unedited XMP segment not kept: [%s]", s.Source)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package jpegstructure
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// ParseWarning is a problem that was tolerated while parsing. These are
+// recorded regardless of the options:
+//
+//   "fill"           - 0xff fill bytes before a marker were skipped
+//   "unknown-marker" - a marker that isn't defined by the standard
+//   "suspect-length" - the length of a table or frame segment doesn't agree
+//                      with its content
+//
+// These are only tolerated in lenient mode (see ParseOptions):
+//
+//   "padding"        - zero bytes between segments were skipped
+//   "trailing-data"  - bytes after the EOI were skipped
+//   "eoi-missing"    - the stream ended without an EOI, so one was added
+//   "jfif-repeated"  - more than one JFIF segment
+//   "exif-preamble"  - a non-standard EXIF prefix was replaced with the
+//                      standard one
+type ParseWarning struct {
+	// Offset is the position in the stream that the warning concerns.
+	Offset int
+
+	Code string
+	Message string
+}
+
+func (pw ParseWarning) String() string {
+	return fmt.Sprintf("ParseWarning<OFFSET=(0x%08x) CODE=[%s] MESSAGE=[%s]>", pw.Offset, pw.Code, pw.Message)
+}
+
+var (
+	tiffHeaderLittleEndian = []byte{'I', 'I', 0x2a, 0x00}
+	tiffHeaderBigEndian = []byte{'M', 'M', 0x00, 0x2a}
+)
+
+// normalizeExifPreamble recognizes EXIF payloads whose prefix isn't the
+// standard "Exif\0\0" (e.g. with the wrong case or with missing or other
+// padding before the TIFF header) and returns the payload with the standard
+// prefix. `ok` is false if the payload isn't one of these.
+func normalizeExifPreamble(payload []byte) (normalized []byte, ok bool) {
+	if len(payload) < 4 || bytes.EqualFold(payload[:4], exifPrefix[:4]) == false {
+		return nil, false
+	} else if isExifPayload(payload) == true {
+		return nil, false
+	}
+
+	for padding := 0; padding <= 2 && 4 + padding + 4 <= len(payload); padding++ {
+		if padding > 0 {
+			b := payload[4 + padding - 1]
+			if b != 0x00 && b != 0xff {
+				break
+			}
+		}
+
+		tiff := payload[4 + padding:]
+		if bytes.HasPrefix(tiff, tiffHeaderLittleEndian) == false && bytes.HasPrefix(tiff, tiffHeaderBigEndian) == false {
+			continue
+		}
+
+		normalized = make([]byte, 0, len(exifPrefix) + len(tiff))
+		normalized = append(normalized, exifPrefix...)
+		normalized = append(normalized, tiff...)
+
+		return normalized, true
+	}
+
+	return nil, false
+}
+
+// Warnings returns the warnings recorded on all of the segments while
+// parsing, in order.
+func (sl SegmentList) Warnings() []ParseWarning {
+	warnings := make([]ParseWarning, 0)
+	for _, s := range sl {
+		warnings = append(warnings, s.Warnings...)
+	}
+
+	return warnings
+}
@@ -0,0 +1,192 @@
+package jpegstructure
+
+import (
+	"bufio"
+	"bytes"
+	"path"
+	"testing"
+
+	"github.com/dsoprea/go-logging"
+)
+
+func warningCodes(sl SegmentList) map[string]int {
+	codes := make(map[string]int)
+	for _, s := range sl {
+		for _, pw := range s.Warnings {
+			codes[pw.Code]++
+		}
+	}
+
+	return codes
+}
+
+// getQuirkyTestImage returns the transform test-image with zero padding, a
+// repeated JFIF segment, an EXIF segment with an odd prefix, and data after
+// the EOI.
+func getQuirkyTestImage() []byte {
+	sl, err := ParseBytesStructure(getTransformTestImage())
+	log.PanicIf(err)
+
+	jfif := Segment{
+		MarkerId: MARKER_APP0,
+		Data: append(append([]byte{}, jfifPrefix...), 0x01, 0x01, 0x00, 0x00, 0x01, 0x00, 0x01, 0x00, 0x00),
+	}
+
+	exif := Segment{
+		MarkerId: MARKER_APP1,
+		Data: []byte{'E', 'x', 'i', 'f', 0x00, 'I', 'I', 0x2a, 0x00, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+	}
+
+	b := new(bytes.Buffer)
+
+	for _, s := range (SegmentList{sl[0], jfif}) {
+		err := s.Write(b)
+		log.PanicIf(err)
+	}
+
+	b.Write([]byte{0x00, 0x00, 0x00})
+
+	for _, s := range append((SegmentList{jfif, exif}), sl[1:]...) {
+		err := s.Write(b)
+		log.PanicIf(err)
+	}
+
+	b.Write([]byte("trailing"))
+
+	return b.Bytes()
+}
+
+func TestParseOptions_Lenient(t *testing.T) {
+	data := getQuirkyTestImage()
+
+	_, err := ParseBytesStructure(data)
+	if err == nil {
+		t.Fatalf("Expected error without the lenient option.")
+	}
+
+	options := ParseOptions{
+		Lenient: true,
+	}
+
+	sl, err := ParseBytesStructureWithOptions(data, options)
+	log.PanicIf(err)
+
+	codes := warningCodes(sl)
+	if codes["padding"] != 1 || codes["jfif-repeated"] != 1 || codes["exif-preamble"] != 1 || codes["trailing-data"] != 1 || len(codes) != 4 {
+		t.Fatalf("Warnings not correct: %v", codes)
+	}
+
+	if sl[2].Warnings[0].Code != "padding" || sl[2].Warnings[0].Offset != sl[1].EndOffset() {
+		t.Fatalf("Padding warning not correct: %v", sl[2].Warnings)
+	} else if sl[2].Offset != sl[1].EndOffset() + 3 {
+		t.Fatalf("Offset after padding not correct: (%d)", sl[2].Offset)
+	}
+
+	exifIndex := sl.Index(MARKER_APP1)
+	if isExifPayload(sl[exifIndex].Data) == false {
+		t.Fatalf("EXIF prefix not normalized: %q", sl[exifIndex].Data[:8])
+	} else if sl[exifIndex].TotalSize != sl[exifIndex].HeaderSize + 19 {
+		t.Fatalf("EXIF extent not the original: (%d)", sl[exifIndex].TotalSize)
+	}
+
+	last := sl[len(sl) - 1]
+	if last.MarkerId != MARKER_EOI || last.Warnings[0].Code != "trailing-data" {
+		t.Fatalf("Trailing data not recorded on the EOI.")
+	}
+}
+
+func TestParseOptions_Lenient_MissingEoi(t *testing.T) {
+	original := getTransformTestImage()
+	data := original[:len(original) - 2]
+
+	options := ParseOptions{
+		Lenient: true,
+	}
+
+	sl, err := ParseBytesStructureWithOptions(data, options)
+	log.PanicIf(err)
+
+	last := sl[len(sl) - 1]
+	if last.MarkerId != MARKER_EOI || len(last.Warnings) != 1 || last.Warnings[0].Code != "eoi-missing" {
+		t.Fatalf("Missing EOI not added: (0x%02x) %v", last.MarkerId, last.Warnings)
+	}
+
+	b := new(bytes.Buffer)
+
+	err = sl.Write(b)
+	log.PanicIf(err)
+
+	if bytes.Equal(b.Bytes(), original) == false {
+		t.Fatalf("Repaired image not correct.")
+	}
+}
+
+func TestNormalizeExifPreamble(t *testing.T) {
+	tiff := []byte{'M', 'M', 0x00, 0x2a, 0x00, 0x00, 0x00, 0x08}
+
+	for _, prefix := range []string { "Exif", "Exif\x00", "EXIF\x00\x00", "Exif\xff\xff" } {
+		normalized, ok := normalizeExifPreamble(append([]byte(prefix), tiff...))
+		if ok != true {
+			t.Fatalf("Prefix [%q] not recognized.", prefix)
+		} else if bytes.Equal(normalized, append(append([]byte{}, exifPrefix...), tiff...)) == false {
+			t.Fatalf("Prefix [%q] not normalized: %q", prefix, normalized)
+		}
+	}
+
+	for _, prefix := range []string { "Exif\x00\x00", "Exif\x01\x00", "Other\x00" } {
+		if _, ok := normalizeExifPreamble(append([]byte(prefix), tiff...)); ok != false {
+			t.Fatalf("Prefix [%q] not expected to be normalized.", prefix)
+		}
+	}
+}
+
+func TestSegmentList_Warnings(t *testing.T) {
+	for _, filename := range []string { testImageRelFilepath, "20180428_212314.jpg" } {
+		sl, err := ParseFileStructure(path.Join(assetsPath, filename))
+		log.PanicIf(err)
+
+		if warnings := sl.Warnings(); len(warnings) != 0 {
+			t.Fatalf("Unexpected warnings for [%s]: %v", filename, warnings)
+		}
+	}
+
+	data := []byte{
+		0xff, 0xd8,
+		0xff, 0xff, 0xff, 0xfe, 0x00, 0x04, 'h', 'i',
+		0xff, 0x02, 0x00, 0x03, 0x00,
+		0xff, 0xdd, 0x00, 0x05, 0x00, 0x04, 0x00,
+		0xff, 0xd9,
+	}
+
+	js := NewJpegSplitter(nil)
+
+	s := bufio.NewScanner(bytes.NewReader(data))
+	s.Split(js.Split)
+
+	for ; s.Scan() != false; { }
+	log.PanicIf(s.Err())
+
+	sl := js.Segments()
+
+	warnings := sl.Warnings()
+	if len(warnings) != 3 {
+		t.Fatalf("Warning count not correct: %v", warnings)
+	} else if warnings[0].Code != "fill" || warnings[0].Offset != 2 || sl[1].Warnings[0] != warnings[0] {
+		t.Fatalf("Fill warning not correct: %s", warnings[0])
+	} else if warnings[1].Code != "unknown-marker" || warnings[1].Offset != sl[2].Offset {
+		t.Fatalf("Unknown-marker warning not correct: %s", warnings[1])
+	} else if warnings[2].Code != "suspect-length" || sl[3].Warnings[0] != warnings[2] {
+		t.Fatalf("Suspect-length warning not correct: %s", warnings[2])
+	}
+
+	splitterWarnings := js.Warnings()
+	if len(splitterWarnings) != len(warnings) {
+		t.Fatalf("Splitter warnings not correct: %v", splitterWarnings)
+	}
+
+	for i, pw := range splitterWarnings {
+		if pw != warnings[i] {
+			t.Fatalf("Splitter warning (%d) not correct: %s", i, pw)
+		}
+	}
+}
@@ -0,0 +1,225 @@
+package jpegstructure
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/dsoprea/go-logging"
+)
+
+const (
+	// RECOMMEND_ACTION_SKIP means that neither re-encoding nor a lossless
+	// optimization is likely to be worth the work.
+	RECOMMEND_ACTION_SKIP = "skip"
+
+	// RECOMMEND_ACTION_OPTIMIZE means that the image should be optimized
+	// losslessly (optimized Huffman tables, progressive coding, or stripped
+	// metadata) but not re-encoded.
+	RECOMMEND_ACTION_OPTIMIZE = "optimize"
+
+	// RECOMMEND_ACTION_REENCODE means that re-encoding at the target quality
+	// is likely to save a worthwhile amount.
+	RECOMMEND_ACTION_REENCODE = "reencode"
+)
+
+const (
+	defaultRecommendTargetQuality = 85
+	defaultRecommendMinSavings = 0.1
+
+	// recommendMetadataRatio is the share of the file taken by metadata above
+	// which stripping it is worthwhile.
+	recommendMetadataRatio = 0.1
+
+	// recommendLosslessSavings is the typical saving of optimizing the
+	// Huffman tables (and switching to progressive coding) of an image coded
+	// with the standard tables.
+	recommendLosslessSavings = 0.1
+)
+
+var (
+	// qualityBitrates are typical bits per pixel of 4:2:0 photographs
+	// encoded by libjpeg at each quality. Only the ratios matter.
+	qualityBitrates = []struct {
+		quality int
+		bitsPerPixel float64
+	}{
+		{1, 0.15},
+		{10, 0.3},
+		{25, 0.5},
+		{50, 0.8},
+		{60, 0.9},
+		{70, 1.05},
+		{75, 1.2},
+		{80, 1.35},
+		{85, 1.6},
+		{90, 2.0},
+		{95, 3.0},
+		{100, 6.0},
+	}
+)
+
+// RecommendOptions controls Recommend.
+type RecommendOptions struct {
+	// TargetQuality is the libjpeg quality that the image would be
+	// re-encoded at. Defaults to 85.
+	TargetQuality int
+
+	// MinSavings is the smallest estimated saving (as a fraction of the
+	// size) that's worth acting on. Defaults to 0.1.
+	MinSavings float64
+}
+
+// Recommendation is the advice returned by Recommend.
+type Recommendation struct {
+	// Action is RECOMMEND_ACTION_*.
+	Action string
+
+	// Reasons explain the action.
+	Reasons []string
+
+	// EstimatedSavings is the expected saving (as a fraction of the size) of
+	// the action. It's zero when skipping.
+	EstimatedSavings float64
+
+	// Quality is the estimated libjpeg quality of the image.
+	Quality int
+
+	Progressive bool
+	Subsampling string
+	BitsPerPixel float64
+	MetadataRatio float64
+}
+
+func (r Recommendation) String() string {
+	return fmt.Sprintf("Recommendation<ACTION=[%s] SAVINGS=(%.2f) QUALITY=(%d) PROGRESSIVE=[%v] SUBSAMPLING=[%s] BPP=(%.3f) METADATA=(%.2f) REASONS=[%s]>", r.Action, r.EstimatedSavings, r.Quality, r.Progressive, r.Subsampling, r.BitsPerPixel, r.MetadataRatio, strings.Join(r.Reasons, "; "))
+}
+
+// Summary returns the reasons and the action on one line (e.g. "quality (72)
+// is at or near the target (85), progressive, 4:2:0 - skip").
+func (r Recommendation) Summary() string {
+	return fmt.Sprintf("%s - %s", strings.Join(r.Reasons, ", "), r.Action)
+}
+
+// qualityBitrate interpolates the typical bits per pixel at the quality.
+func qualityBitrate(quality int) float64 {
+	for i := 1; i < len(qualityBitrates); i++ {
+		high := qualityBitrates[i]
+		if quality > high.quality {
+			continue
+		}
+
+		low := qualityBitrates[i - 1]
+		if quality <= low.quality {
+			return low.bitsPerPixel
+		}
+
+		fraction := float64(quality - low.quality) / float64(high.quality - low.quality)
+		return low.bitsPerPixel + fraction * (high.bitsPerPixel - low.bitsPerPixel)
+	}
+
+	return qualityBitrates[len(qualityBitrates) - 1].bitsPerPixel
+}
+
+// Recommend combines the estimated quality, the coding process, the chroma
+// subsampling, and the size of the scan-data and the metadata into advice on
+// whether re-encoding (or a lossless optimization) is likely to help, so that
+// an optimization service can skip images that wouldn't shrink. Nothing is
+// decoded. Only 8-bit, Huffman-coded, sequential or progressive images are
+// considered for re-encoding.
+func (sl SegmentList) Recommend(options RecommendOptions) (r *Recommendation, err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	targetQuality := options.TargetQuality
+	if targetQuality <= 0 {
+		targetQuality = defaultRecommendTargetQuality
+	}
+
+	minSavings := options.MinSavings
+	if minSavings <= 0 {
+		minSavings = defaultRecommendMinSavings
+	}
+
+	pi, err := sl.ProcessInfo()
+	log.PanicIf(err)
+
+	stats, err := sl.Stats()
+	log.PanicIf(err)
+
+	r = &Recommendation{
+		Action: RECOMMEND_ACTION_SKIP,
+		Reasons: make([]string, 0),
+		Progressive: pi.Mode == ProcessModeProgressive,
+		BitsPerPixel: stats.BitsPerPixel,
+		MetadataRatio: stats.MetadataRatio(),
+	}
+
+	// The subsampling is only informational.
+	if subsampling, err := sl.ChromaSubsampling(); err == nil {
+		r.Subsampling = subsampling
+	}
+
+	if pi.Precision != 8 || pi.EntropyCoding != EntropyCodingHuffman || (pi.Mode != ProcessModeSequential && pi.Mode != ProcessModeProgressive) {
+		r.Reasons = append(r.Reasons, fmt.Sprintf("%s %s process isn't supported", pi.EntropyCoding, pi.Mode))
+		return r, nil
+	}
+
+	luminance, _ := sl.frameQuantizationTables()
+	if luminance != nil {
+		r.Quality = luminance.EstimateQuality()
+	}
+
+	// Re-encoding at the target quality saves roughly the difference in the
+	// typical bitrates.
+	if r.Quality > targetQuality {
+		savings := 1 - qualityBitrate(targetQuality) / qualityBitrate(r.Quality)
+
+		if savings >= minSavings {
+			r.Action = RECOMMEND_ACTION_REENCODE
+			r.EstimatedSavings = savings
+			r.Reasons = append(r.Reasons, fmt.Sprintf("quality (%d) is above the target (%d)", r.Quality, targetQuality))
+
+			if r.Subsampling == "4:4:4" {
+				r.Reasons = append(r.Reasons, "4:4:4 (subsampling the chroma would save more)")
+			}
+
+			return r, nil
+		}
+	}
+
+	r.Reasons = append(r.Reasons, fmt.Sprintf("quality (%d) is at or near the target (%d)", r.Quality, targetQuality))
+
+	// A lossless optimization is still worthwhile if the Huffman tables
+	// weren't optimized or if there's a lot of metadata.
+	savings := 0.0
+
+	if r.Progressive == false && sl.hasStandardHuffmanTables() == true {
+		savings += recommendLosslessSavings
+		r.Reasons = append(r.Reasons, "standard Huffman tables")
+	}
+
+	if r.MetadataRatio > recommendMetadataRatio {
+		savings += r.MetadataRatio
+		r.Reasons = append(r.Reasons, fmt.Sprintf("metadata is (%.0f%%) of the file", r.MetadataRatio * 100))
+	}
+
+	if savings >= minSavings {
+		r.Action = RECOMMEND_ACTION_OPTIMIZE
+		r.EstimatedSavings = savings
+
+		return r, nil
+	}
+
+	if r.Progressive == true {
+		r.Reasons = append(r.Reasons, "progressive")
+	}
+
+	if r.Subsampling != "" {
+		r.Reasons = append(r.Reasons, r.Subsampling)
+	}
+
+	return r, nil
+}
@@ -0,0 +1,46 @@
+package jpegstructure
+
+import (
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/dsoprea/go-logging"
+)
+
+func TestSegmentList_Recommend_Reencode(t *testing.T) {
+	filepath := path.Join(assetsPath, testImageRelFilepath)
+
+	sl, err := ParseFileStructure(filepath)
+	log.PanicIf(err)
+
+	r, err := sl.Recommend(RecommendOptions{})
+	log.PanicIf(err)
+
+	if r.Action != RECOMMEND_ACTION_REENCODE || r.Quality != 97 || r.EstimatedSavings < defaultRecommendMinSavings {
+		t.Fatalf("Recommendation not correct: %s", r)
+	}
+}
+
+func TestSegmentList_Recommend_OptimizeAndSkip(t *testing.T) {
+	// Encoded at q90 with the standard Huffman tables.
+	sl, err := ParseBytesStructure(getTransformTestImage())
+	log.PanicIf(err)
+
+	r, err := sl.Recommend(RecommendOptions{TargetQuality: 90})
+	log.PanicIf(err)
+
+	if r.Action != RECOMMEND_ACTION_OPTIMIZE || r.Quality != 90 || r.Progressive == true {
+		t.Fatalf("Recommendation not correct: %s", r)
+	}
+
+	// Nothing saves enough.
+	r, err = sl.Recommend(RecommendOptions{TargetQuality: 90, MinSavings: 0.5})
+	log.PanicIf(err)
+
+	if r.Action != RECOMMEND_ACTION_SKIP || r.EstimatedSavings != 0 {
+		t.Fatalf("Recommendation not correct: %s", r)
+	} else if strings.HasSuffix(r.Summary(), r.Subsampling + " - skip") == false {
+		t.Fatalf("Summary not correct: [%s]", r.Summary())
+	}
+}
@@ -0,0 +1,239 @@
+package jpegstructure
+
+import (
+	"errors"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+
+	"io/ioutil"
+	"path/filepath"
+
+	"github.com/dsoprea/go-logging"
+)
+
+var (
+	defaultScanExtensions = []string{".jpg", ".jpeg", ".jpe", ".jfif"}
+
+	// errScanStopped stops the walk once the scan has been stopped. (SkipDir
+	// would only skip the rest of the current directory.)
+	errScanStopped = errors.New("scan stopped")
+)
+
+// ScanOptions controls ScanTree.
+type ScanOptions struct {
+	// Workers is the number of files parsed concurrently. Defaults to the
+	// number of CPUs.
+	Workers int
+
+	// Extensions are the (case-insensitive) filename extensions to parse.
+	// Defaults to the common JPEG extensions.
+	Extensions []string
+}
+
+// ScanResult describes one file found by ScanTree.
+type ScanResult struct {
+	Filepath string
+	Size int64
+
+	Width, Height int
+	SegmentCount int
+
+	HasJfif bool
+	HasExif bool
+	HasXmp bool
+	HasIcc bool
+
+	// ParseError is set if the file could not be read or parsed, or if the
+	// path (e.g. an unreadable directory) couldn't be walked. The other
+	// structural fields will be empty.
+	ParseError error
+
+	// ValidationError is set if the file parsed but failed validation.
+	ValidationError error
+}
+
+// ScanCallback receives each result. The callback is never called
+// concurrently. Returning an error stops the scan and ScanTree returns it.
+type ScanCallback func(result ScanResult) error
+
+// scanFile parses one file into a result. Failures are recorded in the
+// result rather than returned.
+func scanFile(filepath string, size int64) (result ScanResult) {
+	result = ScanResult{
+		Filepath: filepath,
+		Size: size,
+	}
+
+	data, err := ioutil.ReadFile(filepath)
+	if err != nil {
+		result.ParseError = err
+		return result
+	}
+
+	sl, err := ParseBytesStructure(data)
+	if err != nil {
+		result.ParseError = err
+		return result
+	}
+
+	result.SegmentCount = len(sl)
+	result.ValidationError = sl.Validate(data)
+
+	if width, height, err := sl.Dimensions(); err == nil {
+		result.Width = width
+		result.Height = height
+	}
+
+	for _, s := range sl {
+		if s.MarkerId == MARKER_APP0 && isJfifPayload(s.Data) == true {
+			result.HasJfif = true
+		} else if s.Kind() == SegmentKindExifApp1 {
+			result.HasExif = true
+		} else if s.Kind() == SegmentKindXmpApp1 {
+			result.HasXmp = true
+		} else if s.MarkerId == MARKER_APP2 && isIccPayload(s.Data) == true {
+			result.HasIcc = true
+		}
+	}
+
+	return result
+}
+
+type scanJob struct {
+	filepath string
+	size int64
+
+	// walkErr is the error that the walk reported for the path, if any.
+	walkErr error
+}
+
+// ScanTree parses every JPEG under the root directory using a bounded pool of
+// workers and passes each result to the callback as it becomes available.
+// Results are not in any particular order. A path below the root that can't
+// be walked (e.g. an unreadable directory) is reported as a result with its
+// error, and the scan continues.
+func ScanTree(root string, options ScanOptions, cb ScanCallback) (err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	workers := options.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+
+	extensions := options.Extensions
+	if len(extensions) == 0 {
+		extensions = defaultScanExtensions
+	}
+
+	jobs := make(chan scanJob)
+	results := make(chan ScanResult)
+	done := make(chan struct{})
+
+	var walkErr error
+
+	go func() {
+		defer close(jobs)
+
+		walkErr = filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				if path == root {
+					return err
+				}
+
+				jpegLogger.Debugf(nil, "Walk failed at [%s]: %v", path, err)
+
+				select {
+				case jobs <- scanJob{filepath: path, walkErr: err}:
+					return nil
+				case <-done:
+					return errScanStopped
+				}
+			}
+
+			if info.Mode().IsRegular() == false {
+				return nil
+			}
+
+			extension := strings.ToLower(filepath.Ext(path))
+
+			matched := false
+			for _, candidate := range extensions {
+				if strings.ToLower(candidate) == extension {
+					matched = true
+					break
+				}
+			}
+
+			if matched == false {
+				return nil
+			}
+
+			select {
+			case jobs <- scanJob{filepath: path, size: info.Size()}:
+				return nil
+			case <-done:
+				return errScanStopped
+			}
+		})
+
+		if walkErr == errScanStopped {
+			walkErr = nil
+		}
+	}()
+
+	wg := new(sync.WaitGroup)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for job := range jobs {
+				result := ScanResult{
+					Filepath: job.filepath,
+					ParseError: job.walkErr,
+				}
+
+				if job.walkErr == nil {
+					result = scanFile(job.filepath, job.size)
+				}
+
+				select {
+				case results <- result:
+				case <-done:
+					return
+				}
+			}
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	for result := range results {
+		err := cb(result)
+		if err != nil {
+			close(done)
+
+			// Drain so that the workers and the walker can exit.
+			for _ = range results {
+			}
+
+			log.Panic(err)
+		}
+	}
+
+	if walkErr != nil {
+		log.Panic(walkErr)
+	}
+
+	return nil
+}
@@ -0,0 +1,221 @@
+package jpegstructure
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+
+	"encoding/binary"
+
+	"github.com/dsoprea/go-logging"
+)
+
+const (
+	// defaultScanDataChunkSize is the default bound on the chunks produced
+	// by ChunkScanData.
+	defaultScanDataChunkSize = 64 * 1024
+
+	// minScanDataChunkSize is large enough for any scan header.
+	minScanDataChunkSize = 256
+)
+
+// ScanDataChunkOptions controls ChunkScanData.
+type ScanDataChunkOptions struct {
+	// ChunkSize is the largest chunk produced. Defaults to 64K (and is at
+	// least 256).
+	ChunkSize int
+}
+
+// ScanDataChunk is a piece of the scan-data of one scan. Concatenating the
+// chunks of a scan gives the payload of its scan-data segment (the scan
+// header followed by the entropy-coded data).
+type ScanDataChunk struct {
+	// ScanIndex is the number of scans before this one.
+	ScanIndex int
+
+	// Offset is the position of the chunk in the stream.
+	Offset int64
+
+	Data []byte
+
+	// Last indicates that the chunk ends the scan.
+	Last bool
+}
+
+func (sdc ScanDataChunk) String() string {
+	return fmt.Sprintf("ScanDataChunk<SCAN=(%d) OFFSET=(0x%08x) SIZE=(%d) LAST=[%v]>", sdc.ScanIndex, sdc.Offset, len(sdc.Data), sdc.Last)
+}
+
+// ChunkedSegmentCallback receives each segment other than the scan-data.
+// Returning an error stops ChunkScanData, which returns it.
+type ChunkedSegmentCallback func(s Segment) error
+
+// ScanDataChunkCallback receives each chunk of scan-data. Returning an error
+// stops ChunkScanData, which returns it.
+type ScanDataChunkCallback func(chunk ScanDataChunk) error
+
+// scanChunker reads the scan-data of one scan from the stream.
+type scanChunker struct {
+	ss *streamSource
+	chunkSize int
+	scanIndex int
+	cb ScanDataChunkCallback
+
+	// chunk is the data that hasn't been passed to the callback, starting at
+	// chunkOffset. restartEnd is the length of the chunk up to the end of
+	// its last RSTn marker (or zero).
+	chunk []byte
+	chunkOffset int64
+	restartEnd int
+}
+
+// emit passes `size` bytes of the pending chunk to the callback.
+func (sc *scanChunker) emit(size int, last bool) {
+	sdc := ScanDataChunk{
+		ScanIndex: sc.scanIndex,
+		Offset: sc.chunkOffset,
+		Data: append([]byte{}, sc.chunk[:size]...),
+		Last: last,
+	}
+
+	err := sc.cb(sdc)
+	log.PanicIf(err)
+
+	sc.chunk = append(sc.chunk[:0], sc.chunk[size:]...)
+	sc.chunkOffset += int64(size)
+	sc.restartEnd = 0
+}
+
+// add appends bytes that mustn't be separated (e.g. a stuffed 0xff and its
+// zero), first emitting the pending chunk if they wouldn't fit. Chunks end
+// after an RSTn marker where possible so that each can be decoded on its
+// own.
+func (sc *scanChunker) add(b ...byte) {
+	if len(sc.chunk) + len(b) > sc.chunkSize {
+		if sc.restartEnd > 0 {
+			sc.emit(sc.restartEnd, false)
+		} else {
+			sc.emit(len(sc.chunk), false)
+		}
+	}
+
+	sc.chunk = append(sc.chunk, b...)
+}
+
+// readByte reads the next byte of the stream.
+func (sc *scanChunker) readByte() byte {
+	b, err := sc.ss.br.ReadByte()
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+
+	log.PanicIf(err)
+
+	sc.ss.position++
+
+	return b
+}
+
+// peekPair returns the next two bytes of the stream without reading them.
+func (sc *scanChunker) peekPair() []byte {
+	pair, err := sc.ss.br.Peek(2)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+
+	log.PanicIf(err)
+
+	return pair
+}
+
+// run reads the scan at `offset` and returns the offset of the marker that
+// ends it.
+func (sc *scanChunker) run(offset int64) int64 {
+	headerLength := int(binary.BigEndian.Uint16(sc.ss.read(offset, 2)))
+	header := sc.ss.read(offset, headerLength)
+
+	sc.chunk = append(sc.chunk[:0], header...)
+	sc.chunkOffset = offset
+	sc.restartEnd = 0
+
+	// Consume the header, which was only peeked.
+	sc.ss.read(offset + int64(headerLength), 0)
+
+	for {
+		pair := sc.peekPair()
+		if pair[0] != 0xff {
+			sc.add(sc.readByte())
+			continue
+		}
+
+		next := pair[1]
+
+		if next == 0x00 || IsRstMarker(next) == true {
+			sc.add(sc.readByte(), sc.readByte())
+
+			if IsRstMarker(next) == true {
+				sc.restartEnd = len(sc.chunk)
+			}
+		} else if next == 0xff {
+			// A fill byte.
+			sc.add(sc.readByte())
+		} else {
+			// Any other marker ends the scan. It hasn't been consumed.
+			sc.emit(len(sc.chunk), true)
+
+			return sc.ss.position
+		}
+	}
+}
+
+// ChunkScanData parses the image from the stream, passing each segment to
+// `segmentCb` and the scan-data to `chunkCb` in chunks of at most
+// ChunkSize bytes. Chunks never split a stuffed byte or an RSTn marker
+// from its 0xff and, where there are restart markers, end after one. Only
+// one chunk is held at a time, so memory use is proportional to the chunk
+// size rather than to the image. This suits incremental hashing and
+// partial decoding. Parsing stops at the EOI; trailing data isn't read.
+func ChunkScanData(r io.Reader, options ScanDataChunkOptions, segmentCb ChunkedSegmentCallback, chunkCb ScanDataChunkCallback) (err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	chunkSize := options.ChunkSize
+	if chunkSize <= 0 {
+		chunkSize = defaultScanDataChunkSize
+	} else if chunkSize < minScanDataChunkSize {
+		chunkSize = minScanDataChunkSize
+	}
+
+	ss := &streamSource{
+		br: bufio.NewReaderSize(r, streamBufferSize),
+	}
+
+	checkFormat(ss.read(0, len(jpegMagicStandard)), DetectOptions{})
+
+	sc := &scanChunker{
+		ss: ss,
+		chunkSize: chunkSize,
+		cb: chunkCb,
+		chunk: make([]byte, 0, chunkSize),
+	}
+
+	offset := int64(0)
+
+	for {
+		s := readSegment(ss, offset, nil)
+		offset = int64(s.EndOffset())
+
+		err := segmentCb(s)
+		log.PanicIf(err)
+
+		if s.MarkerId == MARKER_EOI {
+			return nil
+		} else if s.MarkerId == MARKER_SOS {
+			offset = sc.run(offset)
+			sc.scanIndex++
+		}
+	}
+}
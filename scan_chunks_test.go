@@ -0,0 +1,132 @@
+package jpegstructure
+
+import (
+	"bytes"
+	"path"
+	"testing"
+
+	"io/ioutil"
+
+	"github.com/dsoprea/go-logging"
+)
+
+func TestChunkScanData(t *testing.T) {
+	filepath := path.Join(assetsPath, testImageRelFilepath)
+
+	data, err := ioutil.ReadFile(filepath)
+	log.PanicIf(err)
+
+	sl, err := ParseBytesStructure(data)
+	log.PanicIf(err)
+
+	var expectedScanData []byte
+	expectedMarkers := make([]byte, 0)
+	for _, s := range sl {
+		if s.MarkerId == 0x0 {
+			expectedScanData = s.Data
+		} else {
+			expectedMarkers = append(expectedMarkers, s.MarkerId)
+		}
+	}
+
+	chunkSize := 4096
+
+	markers := make([]byte, 0)
+	scanData := new(bytes.Buffer)
+	chunks := make([]ScanDataChunk, 0)
+
+	segmentCb := func(s Segment) error {
+		markers = append(markers, s.MarkerId)
+		return nil
+	}
+
+	chunkCb := func(chunk ScanDataChunk) error {
+		if len(chunk.Data) > chunkSize {
+			t.Fatalf("Chunk too large: %s", chunk)
+		} else if int(chunk.Offset) != sl[len(sl) - 2].Offset + scanData.Len() {
+			t.Fatalf("Chunk offset not correct: %s", chunk)
+		}
+
+		// A stuffed byte or RSTn marker is never split from its 0xff.
+		last := chunk.Data[len(chunk.Data) - 1]
+		if last == 0xff && chunk.Last == false {
+			next := data[int(chunk.Offset) + len(chunk.Data)]
+			if next == 0x00 || IsRstMarker(next) == true {
+				t.Fatalf("Chunk splits a marker: %s", chunk)
+			}
+		}
+
+		scanData.Write(chunk.Data)
+		chunks = append(chunks, chunk)
+
+		return nil
+	}
+
+	options := ScanDataChunkOptions{
+		ChunkSize: chunkSize,
+	}
+
+	err = ChunkScanData(bytes.NewReader(data), options, segmentCb, chunkCb)
+	log.PanicIf(err)
+
+	if bytes.Equal(markers, expectedMarkers) == false {
+		t.Fatalf("Segments not correct: %v != %v", markers, expectedMarkers)
+	} else if bytes.Equal(scanData.Bytes(), expectedScanData) == false {
+		t.Fatalf("Scan-data not correct: (%d) != (%d)", scanData.Len(), len(expectedScanData))
+	} else if len(chunks) < len(expectedScanData) / chunkSize {
+		t.Fatalf("Too few chunks: (%d)", len(chunks))
+	}
+
+	for i, chunk := range chunks {
+		if chunk.Last != (i == len(chunks) - 1) {
+			t.Fatalf("Last flag not correct: (%d) %s", i, chunk)
+		}
+	}
+}
+
+func TestChunkScanData_Restarts(t *testing.T) {
+	// Two restart intervals with a stuffed byte in the second.
+	scan := []byte{
+		0xff, MARKER_SOI,
+		0xff, MARKER_SOS, 0x00, 0x08, 0x01, 0x01, 0x00, 0x00, 0x3f, 0x00,
+	}
+
+	interval := bytes.Repeat([]byte{0x11}, 200)
+
+	scan = append(scan, interval...)
+	scan = append(scan, 0xff, MARKER_RST0)
+	scan = append(scan, interval...)
+	scan = append(scan, 0xff, 0x00)
+	scan = append(scan, interval...)
+	scan = append(scan, 0xff, MARKER_EOI)
+
+	chunks := make([]ScanDataChunk, 0)
+
+	segmentCb := func(s Segment) error {
+		return nil
+	}
+
+	chunkCb := func(chunk ScanDataChunk) error {
+		chunks = append(chunks, chunk)
+		return nil
+	}
+
+	options := ScanDataChunkOptions{
+		ChunkSize: 300,
+	}
+
+	err := ChunkScanData(bytes.NewReader(scan), options, segmentCb, chunkCb)
+	log.PanicIf(err)
+
+	if len(chunks) != 3 {
+		t.Fatalf("Chunk count not correct: (%d)", len(chunks))
+	}
+
+	// The first chunk ends after the RST0 rather than at the limit.
+	first := chunks[0].Data
+	if first[len(first) - 2] != 0xff || first[len(first) - 1] != MARKER_RST0 {
+		t.Fatalf("First chunk doesn't end after the restart marker: %s", chunks[0])
+	} else if chunks[0].Offset != 4 {
+		t.Fatalf("First chunk offset not correct: %s", chunks[0])
+	}
+}
@@ -0,0 +1,294 @@
+package jpegstructure
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/dsoprea/go-logging"
+)
+
+const (
+	// maxComponentsInScan is the most components that one scan may have.
+	maxComponentsInScan = 4
+)
+
+// ScanScriptEntry is one scan of a scan script, in the form of libjpeg's
+// jpeg_scan_info.
+type ScanScriptEntry struct {
+	// Components are the positions of the scan's components in the frame
+	// (e.g. 0 for Y, 1 for Cb, and 2 for Cr).
+	Components []int
+
+	// SpectralStart and SpectralEnd select the coefficients (Ss, Se).
+	SpectralStart, SpectralEnd byte
+
+	// SuccessiveHigh and SuccessiveLow are the approximation bits (Ah, Al).
+	SuccessiveHigh, SuccessiveLow byte
+}
+
+// String formats the entry as a line of a libjpeg (cjpeg -scans) script.
+func (sse ScanScriptEntry) String() string {
+	components := make([]string, len(sse.Components))
+	for i, position := range sse.Components {
+		components[i] = fmt.Sprintf("%d", position)
+	}
+
+	return fmt.Sprintf("%s: %d-%d, %d, %d;", strings.Join(components, ","), sse.SpectralStart, sse.SpectralEnd, sse.SuccessiveHigh, sse.SuccessiveLow)
+}
+
+// Equal indicates whether the entries describe the same scan.
+func (sse ScanScriptEntry) Equal(other ScanScriptEntry) bool {
+	if len(sse.Components) != len(other.Components) {
+		return false
+	}
+
+	for i, position := range sse.Components {
+		if position != other.Components[i] {
+			return false
+		}
+	}
+
+	return sse.SpectralStart == other.SpectralStart && sse.SpectralEnd == other.SpectralEnd && sse.SuccessiveHigh == other.SuccessiveHigh && sse.SuccessiveLow == other.SuccessiveLow
+}
+
+// ScanScript is the sequence of scans of an image.
+type ScanScript []ScanScriptEntry
+
+// String formats the script as a libjpeg (cjpeg -scans) script, one scan per
+// line.
+func (ss ScanScript) String() string {
+	lines := make([]string, len(ss))
+	for i, sse := range ss {
+		lines[i] = sse.String()
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// Equal indicates whether the scripts have the same scans in the same order.
+func (ss ScanScript) Equal(other ScanScript) bool {
+	return len(ss.Diff(other)) == 0
+}
+
+// Diff describes each scan that differs between the scripts (including scans
+// that only one of them has).
+func (ss ScanScript) Diff(other ScanScript) (differences []string) {
+	differences = make([]string, 0)
+
+	count := len(ss)
+	if len(other) > count {
+		count = len(other)
+	}
+
+	for i := 0; i < count; i++ {
+		switch {
+		case i >= len(other):
+			differences = append(differences, fmt.Sprintf("scan (%d): [%s] is extra", i, ss[i]))
+		case i >= len(ss):
+			differences = append(differences, fmt.Sprintf("scan (%d): [%s] is missing", i, other[i]))
+		case ss[i].Equal(other[i]) == false:
+			differences = append(differences, fmt.Sprintf("scan (%d): [%s] != [%s]", i, ss[i], other[i]))
+		}
+	}
+
+	return differences
+}
+
+// ScanScript returns the parameters of every scan, in order. The components
+// are identified by their position in the (most recent) frame header, as in
+// libjpeg's scripts, so that scripts can be compared across images.
+func (sl SegmentList) ScanScript() (ss ScanScript, err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	ss = make(ScanScript, 0)
+
+	var frameComponents []SofComponent
+
+	for _, s := range sl {
+		if IsSofMarker(s.MarkerId) == true {
+			frameComponents, err = ParseSofComponents(s.Data)
+			log.PanicIf(err)
+
+			continue
+		} else if s.MarkerId != 0x0 {
+			continue
+		}
+
+		if frameComponents == nil {
+			log.Panicf("scan before frame")
+		}
+
+		header, _, err := splitScanData(s.Data)
+		log.PanicIf(err)
+
+		sh, err := ParseSosHeader(header)
+		log.PanicIf(err)
+
+		sse := ScanScriptEntry{
+			Components: make([]int, len(sh.Components)),
+			SpectralStart: sh.SpectralStart,
+			SpectralEnd: sh.SpectralEnd,
+			SuccessiveHigh: sh.SuccessiveHigh,
+			SuccessiveLow: sh.SuccessiveLow,
+		}
+
+		for i, sc := range sh.Components {
+			position := -1
+			for j, fc := range frameComponents {
+				if fc.ComponentId == sc.ComponentId {
+					position = j
+					break
+				}
+			}
+
+			if position == -1 {
+				log.Panicf("scan references component not in frame: (%d)", sc.ComponentId)
+			}
+
+			sse.Components[i] = position
+		}
+
+		ss = append(ss, sse)
+	}
+
+	return ss, nil
+}
+
+// scanScriptBuilder accumulates scans as libjpeg's jcparam.c does.
+type scanScriptBuilder struct {
+	ss ScanScript
+	componentCount int
+}
+
+// fillScan adds a scan of one component.
+func (ssb *scanScriptBuilder) fillScan(position int, ss, se, ah, al byte) {
+	sse := ScanScriptEntry{
+		Components: []int{position},
+		SpectralStart: ss,
+		SpectralEnd: se,
+		SuccessiveHigh: ah,
+		SuccessiveLow: al,
+	}
+
+	ssb.ss = append(ssb.ss, sse)
+}
+
+// fillScans adds a scan of each component.
+func (ssb *scanScriptBuilder) fillScans(ss, se, ah, al byte) {
+	for position := 0; position < ssb.componentCount; position++ {
+		ssb.fillScan(position, ss, se, ah, al)
+	}
+}
+
+// fillDcScans adds one interleaved DC scan or, if there are too many
+// components to interleave, a DC scan of each component.
+func (ssb *scanScriptBuilder) fillDcScans(ah, al byte) {
+	if ssb.componentCount > maxComponentsInScan {
+		ssb.fillScans(0, 0, ah, al)
+		return
+	}
+
+	sse := ScanScriptEntry{
+		Components: make([]int, ssb.componentCount),
+		SuccessiveHigh: ah,
+		SuccessiveLow: al,
+	}
+
+	for position := range sse.Components {
+		sse.Components[position] = position
+	}
+
+	ssb.ss = append(ssb.ss, sse)
+}
+
+// StandardProgressiveScanScript returns the script that libjpeg uses for
+// progressive images (jpeg_simple_progression) with the given number of
+// components. YCbCr images (three components) get the script tuned for
+// them; other images get the generic one.
+func StandardProgressiveScanScript(componentCount int) ScanScript {
+	ssb := &scanScriptBuilder{
+		ss: make(ScanScript, 0),
+		componentCount: componentCount,
+	}
+
+	if componentCount == 3 {
+		ssb.fillDcScans(0, 1)
+		ssb.fillScan(0, 1, 5, 0, 2)
+		ssb.fillScan(2, 1, 63, 0, 1)
+		ssb.fillScan(1, 1, 63, 0, 1)
+		ssb.fillScan(0, 6, 63, 0, 2)
+		ssb.fillScan(0, 1, 63, 2, 1)
+		ssb.fillDcScans(1, 0)
+		ssb.fillScan(2, 1, 63, 1, 0)
+		ssb.fillScan(1, 1, 63, 1, 0)
+		ssb.fillScan(0, 1, 63, 1, 0)
+	} else {
+		ssb.fillDcScans(0, 1)
+		ssb.fillScans(1, 5, 0, 2)
+		ssb.fillScans(6, 63, 0, 2)
+		ssb.fillScans(1, 63, 2, 1)
+		ssb.fillDcScans(1, 0)
+		ssb.fillScans(1, 63, 1, 0)
+	}
+
+	return ssb.ss
+}
+
+// StandardSequentialScanScript returns the script that libjpeg uses for
+// sequential images: one scan of all of the components (or, if there are too
+// many to interleave, one scan of each).
+func StandardSequentialScanScript(componentCount int) ScanScript {
+	if componentCount > maxComponentsInScan {
+		ssb := &scanScriptBuilder{
+			ss: make(ScanScript, 0),
+			componentCount: componentCount,
+		}
+
+		ssb.fillScans(0, 63, 0, 0)
+
+		return ssb.ss
+	}
+
+	sse := ScanScriptEntry{
+		Components: make([]int, componentCount),
+		SpectralEnd: 63,
+	}
+
+	for position := range sse.Components {
+		sse.Components[position] = position
+	}
+
+	return ScanScript{sse}
+}
+
+// StandardScanScriptDiff compares the scan script of the image with the one
+// that libjpeg would use for the same coding process and number of
+// components (see Diff). The image follows libjpeg's layout if there are no
+// differences.
+func (sl SegmentList) StandardScanScriptDiff() (differences []string, err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	ss, err := sl.ScanScript()
+	log.PanicIf(err)
+
+	sof, err := sl.Sof()
+	log.PanicIf(err)
+
+	pi, err := sl.ProcessInfo()
+	log.PanicIf(err)
+
+	standard := StandardSequentialScanScript(int(sof.ComponentCount))
+	if pi.Mode == ProcessModeProgressive {
+		standard = StandardProgressiveScanScript(int(sof.ComponentCount))
+	}
+
+	return ss.Diff(standard), nil
+}
@@ -0,0 +1,117 @@
+package jpegstructure
+
+import (
+	"path"
+	"testing"
+
+	"github.com/dsoprea/go-logging"
+)
+
+func getProgressiveTestSegments(script ScanScript) SegmentList {
+	components := []SofComponent{
+		{ComponentId: 1, HorizontalSamplingFactor: 2, VerticalSamplingFactor: 2},
+		{ComponentId: 2, HorizontalSamplingFactor: 1, VerticalSamplingFactor: 1, QuantizationTableId: 1},
+		{ComponentId: 3, HorizontalSamplingFactor: 1, VerticalSamplingFactor: 1, QuantizationTableId: 1},
+	}
+
+	sb := NewBuilder().
+		AddQuantTables(StandardQuantizationTable(0, 75), StandardQuantizationTable(1, 75)).
+		AddFrame(MARKER_SOF2, SofSegment{BitsPerSample: 8, Width: 16, Height: 16}, components)
+
+	for _, sse := range script {
+		header := SosHeader{
+			SpectralStart: sse.SpectralStart,
+			SpectralEnd: sse.SpectralEnd,
+			SuccessiveHigh: sse.SuccessiveHigh,
+			SuccessiveLow: sse.SuccessiveLow,
+		}
+
+		for _, position := range sse.Components {
+			header.Components = append(header.Components, SosComponent{ComponentId: components[position].ComponentId})
+		}
+
+		sb.AddScan(header, []byte{0x00})
+	}
+
+	sl, err := sb.Build()
+	log.PanicIf(err)
+
+	return sl
+}
+
+func TestSegmentList_ScanScript_Progressive(t *testing.T) {
+	standard := StandardProgressiveScanScript(3)
+
+	if len(standard) != 10 {
+		t.Fatalf("Standard YCbCr script not correct:\n%s", standard)
+	} else if standard[0].String() != "0,1,2: 0-0, 0, 1;" || standard[9].String() != "0: 1-63, 1, 0;" {
+		t.Fatalf("Standard YCbCr script not correct:\n%s", standard)
+	}
+
+	sl := getProgressiveTestSegments(standard)
+
+	ss, err := sl.ScanScript()
+	log.PanicIf(err)
+
+	if ss.Equal(standard) == false {
+		t.Fatalf("Scan script not correct:\n%s", ss)
+	}
+
+	differences, err := sl.StandardScanScriptDiff()
+	log.PanicIf(err)
+
+	if len(differences) != 0 {
+		t.Fatalf("Standard script not matched: %v", differences)
+	}
+
+	// Swap the chroma scans and drop the last one.
+	reordered := append(ScanScript{}, standard[:9]...)
+	reordered[2], reordered[3] = reordered[3], reordered[2]
+
+	sl = getProgressiveTestSegments(reordered)
+
+	differences, err = sl.StandardScanScriptDiff()
+	log.PanicIf(err)
+
+	expected := []string{
+		"scan (2): [1: 1-63, 0, 1;] != [2: 1-63, 0, 1;]",
+		"scan (3): [2: 1-63, 0, 1;] != [1: 1-63, 0, 1;]",
+		"scan (9): [0: 1-63, 1, 0;] is missing",
+	}
+
+	if len(differences) != len(expected) {
+		t.Fatalf("Differences not correct: %v", differences)
+	}
+
+	for i, difference := range differences {
+		if difference != expected[i] {
+			t.Fatalf("Difference (%d) not correct: [%s]", i, difference)
+		}
+	}
+}
+
+func TestSegmentList_ScanScript_Sequential(t *testing.T) {
+	filepath := path.Join(assetsPath, testImageRelFilepath)
+
+	sl, err := ParseFileStructure(filepath)
+	log.PanicIf(err)
+
+	ss, err := sl.ScanScript()
+	log.PanicIf(err)
+
+	if len(ss) != 1 || ss[0].String() != "0,1,2: 0-63, 0, 0;" {
+		t.Fatalf("Scan script not correct:\n%s", ss)
+	}
+
+	differences, err := sl.StandardScanScriptDiff()
+	log.PanicIf(err)
+
+	if len(differences) != 0 {
+		t.Fatalf("Standard script not matched: %v", differences)
+	}
+
+	// The generic script is used for other component counts.
+	if generic := StandardProgressiveScanScript(1); len(generic) != 6 || generic[0].String() != "0: 0-0, 0, 1;" {
+		t.Fatalf("Generic script not correct:\n%s", generic)
+	}
+}
@@ -0,0 +1,102 @@
+package jpegstructure
+
+import (
+	"errors"
+	"os"
+	"path"
+	"testing"
+
+	"io/ioutil"
+
+	"github.com/dsoprea/go-logging"
+)
+
+func TestScanTree(t *testing.T) {
+	results := make(map[string]ScanResult)
+
+	options := ScanOptions{
+		Workers: 2,
+	}
+
+	err := ScanTree(assetsPath, options, func(result ScanResult) error {
+		results[result.Filepath[len(assetsPath) + 1:]] = result
+		return nil
+	})
+
+	log.PanicIf(err)
+
+	if len(results) != 2 {
+		t.Fatalf("Expected two results: %v", results)
+	}
+
+	result := results[testImageRelFilepath]
+	if result.ParseError != nil || result.ValidationError != nil {
+		t.Fatalf("Unexpected errors: %v", result)
+	} else if result.Width != 3840 || result.Height != 2560 {
+		t.Fatalf("Dimensions not correct: (%d) (%d)", result.Width, result.Height)
+	} else if result.HasExif != true || result.HasXmp != true || result.HasJfif != false || result.HasIcc != false {
+		t.Fatalf("Metadata flags not correct: %v", result)
+	}
+
+	result = results["20180428_212314.jpg"]
+	if result.HasJfif != true || result.HasXmp != false {
+		t.Fatalf("Metadata flags not correct: %v", result)
+	}
+}
+
+func TestScanTree_CallbackError(t *testing.T) {
+	expectedErr := errors.New("stop")
+
+	calls := 0
+	err := ScanTree(assetsPath, ScanOptions{Workers: 1}, func(result ScanResult) error {
+		calls++
+		return expectedErr
+	})
+
+	if err == nil || err.Error() != "stop" {
+		t.Fatalf("Expected callback error: %v", err)
+	} else if calls != 1 {
+		t.Fatalf("Callback should have been called once: (%d)", calls)
+	}
+}
+
+func TestScanTree_WalkError(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("Directory permissions aren't enforced for root.")
+	}
+
+	tempPath, err := ioutil.TempDir("", "")
+	log.PanicIf(err)
+
+	defer os.RemoveAll(tempPath)
+
+	data, err := ioutil.ReadFile(path.Join(assetsPath, testImageRelFilepath))
+	log.PanicIf(err)
+
+	err = ioutil.WriteFile(path.Join(tempPath, "a.jpg"), data, 0644)
+	log.PanicIf(err)
+
+	unreadablePath := path.Join(tempPath, "unreadable")
+
+	err = os.Mkdir(unreadablePath, 0)
+	log.PanicIf(err)
+
+	defer os.Chmod(unreadablePath, 0755)
+
+	results := make(map[string]ScanResult)
+
+	err = ScanTree(tempPath, ScanOptions{Workers: 1}, func(result ScanResult) error {
+		results[result.Filepath] = result
+		return nil
+	})
+
+	log.PanicIf(err)
+
+	if len(results) != 2 {
+		t.Fatalf("Expected two results: %v", results)
+	} else if results[unreadablePath].ParseError == nil {
+		t.Fatalf("Walk error not recorded: %v", results[unreadablePath])
+	} else if results[path.Join(tempPath, "a.jpg")].ParseError != nil {
+		t.Fatalf("File not scanned: %v", results[path.Join(tempPath, "a.jpg")])
+	}
+}
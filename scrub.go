@@ -0,0 +1,125 @@
+package jpegstructure
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+
+	"github.com/dsoprea/go-logging"
+)
+
+// ScrubPolicy selects the segments that NewScrubbingReader removes. The
+// segments that affect decoding (the JFIF header and the Adobe APP14 segment)
+// are always kept.
+type ScrubPolicy struct {
+	// Markers are the APPn and COM markers to remove. If empty, every APPn
+	// and COM segment is removed.
+	Markers []byte
+
+	// KeepIcc keeps the ICC profile even if APP2 is being removed.
+	KeepIcc bool
+}
+
+// removes indicates whether the policy removes the segment.
+func (sp ScrubPolicy) removes(s Segment) bool {
+	if isMetadataMarker(s.MarkerId) == false || isDecodingSegment(s) == true {
+		return false
+	} else if sp.KeepIcc == true && s.MarkerId == MARKER_APP2 && isIccPayload(s.Data) == true {
+		return false
+	} else if len(sp.Markers) == 0 {
+		return true
+	}
+
+	for _, markerId := range sp.Markers {
+		if markerId == s.MarkerId {
+			return true
+		}
+	}
+
+	return false
+}
+
+// scrubbingReader removes segments from the stream as it's read.
+type scrubbingReader struct {
+	ss *streamSource
+	policy ScrubPolicy
+
+	// offset is the position of the next segment in the original stream.
+	offset int64
+
+	// pending is output that hasn't been read yet.
+	pending []byte
+
+	// passthrough is set once the SOS has been written. Everything after it
+	// is copied as is.
+	passthrough bool
+
+	err error
+}
+
+// NewScrubbingReader returns a reader that produces the image from `r`
+// without the segments that the policy removes. Segments are read one at a
+// time, and the scan-data and everything after it are copied through, so
+// memory use doesn't depend on the size of the image. This suits proxies and
+// upload handlers. Only the segments before the scan are scrubbed; anything
+// appended after the EOI (e.g. the other images of an MPO) is passed through
+// unchanged. Fill bytes between segments are dropped.
+func NewScrubbingReader(r io.Reader, policy ScrubPolicy) io.Reader {
+	return &scrubbingReader{
+		ss: &streamSource{
+			br: bufio.NewReaderSize(r, streamBufferSize),
+		},
+		policy: policy,
+	}
+}
+
+func (sr *scrubbingReader) Read(p []byte) (n int, err error) {
+	for len(sr.pending) == 0 {
+		if sr.err != nil {
+			return 0, sr.err
+		} else if sr.passthrough == true {
+			return sr.ss.br.Read(p)
+		}
+
+		sr.err = sr.next()
+	}
+
+	n = copy(p, sr.pending)
+	sr.pending = sr.pending[n:]
+
+	return n, nil
+}
+
+// next reads the next segment and queues it unless it's removed.
+func (sr *scrubbingReader) next() (err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	if sr.offset == 0 {
+		checkFormat(sr.ss.read(0, len(jpegMagicStandard)), DetectOptions{})
+	}
+
+	s := readSegment(sr.ss, sr.offset, nil)
+	sr.offset = int64(s.EndOffset())
+
+	if s.MarkerId == MARKER_SOS || s.MarkerId == MARKER_EOI {
+		// Consume the rest of the segment, which may only have been peeked.
+		sr.ss.read(sr.offset, 0)
+		sr.passthrough = true
+	} else if sr.policy.removes(s) == true {
+		jpegLogger.Debugf(nil, "Scrubbing (%s) at (0x%08x).", s.MarkerName, s.Offset)
+		return nil
+	}
+
+	b := new(bytes.Buffer)
+
+	err = s.Write(b)
+	log.PanicIf(err)
+
+	sr.pending = b.Bytes()
+
+	return nil
+}
@@ -0,0 +1,88 @@
+package jpegstructure
+
+import (
+	"bytes"
+	"path"
+	"strings"
+	"testing"
+
+	"io/ioutil"
+	"testing/iotest"
+
+	"github.com/dsoprea/go-logging"
+)
+
+func TestNewScrubbingReader(t *testing.T) {
+	filepath := path.Join(assetsPath, testImageRelFilepath)
+
+	data, err := ioutil.ReadFile(filepath)
+	log.PanicIf(err)
+
+	sl, err := ParseBytesStructure(data)
+	log.PanicIf(err)
+
+	expected := new(bytes.Buffer)
+
+	err = sl.StripMetadata(false).Write(expected)
+	log.PanicIf(err)
+
+	// Read a byte at a time so that segments are split across reads.
+	r := NewScrubbingReader(iotest.OneByteReader(bytes.NewReader(data)), ScrubPolicy{})
+
+	scrubbed, err := ioutil.ReadAll(r)
+	log.PanicIf(err)
+
+	if bytes.Equal(scrubbed, expected.Bytes()) == false {
+		t.Fatalf("Scrubbed image not correct: (%d) != (%d)", len(scrubbed), expected.Len())
+	}
+}
+
+func TestNewScrubbingReader_Markers(t *testing.T) {
+	filepath := path.Join(assetsPath, testImageRelFilepath)
+
+	data, err := ioutil.ReadFile(filepath)
+	log.PanicIf(err)
+
+	sl, err := ParseBytesStructure(data)
+	log.PanicIf(err)
+
+	r := NewScrubbingReader(bytes.NewReader(data), ScrubPolicy{Markers: []byte{MARKER_APP1}})
+
+	scrubbed, err := ioutil.ReadAll(r)
+	log.PanicIf(err)
+
+	parsed, err := ParseBytesStructure(scrubbed)
+	log.PanicIf(err)
+
+	if len(sl.FindAll(MARKER_APP1)) == 0 {
+		t.Fatalf("Test image has no APP1 segments.")
+	} else if len(parsed.FindAll(MARKER_APP1)) != 0 {
+		t.Fatalf("APP1 segments not removed.")
+	} else if len(parsed) != len(sl) - len(sl.FindAll(MARKER_APP1)) {
+		t.Fatalf("Other segments not kept: (%d)", len(parsed))
+	}
+}
+
+func TestNewScrubbingReader_NotJpeg(t *testing.T) {
+	inputs := [][]byte{
+		[]byte("not an image"),
+		{0xff, MARKER_SOI, 0x00, 0x00},
+	}
+
+	for i, data := range inputs {
+		r := NewScrubbingReader(bytes.NewReader(data), ScrubPolicy{})
+
+		_, err := ioutil.ReadAll(r)
+		if err == nil {
+			t.Fatalf("Expected error for input (%d).", i)
+		} else if strings.Contains(err.Error(), "does not look like a JPEG") == false {
+			t.Fatalf("Input (%d) not rejected by the format check: %v", i, err)
+		}
+
+		// The other entry points reject it the same way.
+		_, err = ParseSparse(bytes.NewReader(data), SparseOptions{})
+		if err == nil || strings.Contains(err.Error(), "does not look like a JPEG") == false {
+			t.Fatalf("Input (%d) not rejected by ParseSparse: %v", i, err)
+		}
+	}
+}
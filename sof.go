@@ -0,0 +1,216 @@
+package jpegstructure
+
+import (
+	"fmt"
+
+	"github.com/dsoprea/go-logging"
+)
+
+var (
+	// chromaSubsamplingNames maps the ratio of the luma sampling factors to
+	// the chroma sampling factors (horizontal, vertical) to the conventional
+	// J:a:b notation.
+	chromaSubsamplingNames = map[[2]byte]string{
+		{1, 1}: "4:4:4",
+		{2, 1}: "4:2:2",
+		{2, 2}: "4:2:0",
+		{4, 1}: "4:1:1",
+		{1, 2}: "4:4:0",
+		{4, 2}: "4:1:0",
+	}
+)
+
+const (
+	// sofComponentsOffset is the position of the first component record in a
+	// SOF payload (precision + height + width + component-count).
+	sofComponentsOffset = 6
+
+	// sofComponentSize is the size of each component record.
+	sofComponentSize = 3
+)
+
+// SofComponent describes one of the components declared by a frame header.
+type SofComponent struct {
+	ComponentId byte
+	HorizontalSamplingFactor byte
+	VerticalSamplingFactor byte
+	QuantizationTableId byte
+}
+
+func (sc SofComponent) String() string {
+	return fmt.Sprintf("SofComponent<ID=(%d) H=(%d) V=(%d) QT=(%d)>", sc.ComponentId, sc.HorizontalSamplingFactor, sc.VerticalSamplingFactor, sc.QuantizationTableId)
+}
+
+// ParseSofComponents returns the component records that follow the fixed
+// header of a SOF payload.
+func ParseSofComponents(data []byte) (components []SofComponent, err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	if len(data) < sofComponentsOffset {
+		log.Panicf("SOF payload too short: (%d)", len(data))
+	}
+
+	componentCount := int(data[sofComponentsOffset - 1])
+	required := sofComponentsOffset + componentCount * sofComponentSize
+
+	if len(data) < required {
+		log.Panicf("SOF payload too short for (%d) components: (%d) < (%d)", componentCount, len(data), required)
+	}
+
+	components = make([]SofComponent, componentCount)
+	for i := 0; i < componentCount; i++ {
+		raw := data[sofComponentsOffset + i * sofComponentSize:]
+
+		components[i] = SofComponent{
+			ComponentId: raw[0],
+			HorizontalSamplingFactor: raw[1] >> 4,
+			VerticalSamplingFactor: raw[1] & 0x0f,
+			QuantizationTableId: raw[2],
+		}
+	}
+
+	return components, nil
+}
+
+// Sof returns the header of the first frame. If the frame defers its height
+// to a DNL segment, the height from the DNL segment is returned.
+func (sl SegmentList) Sof() (sof *SofSegment, err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	for _, s := range sl {
+		if IsSofMarker(s.MarkerId) == false {
+			continue
+		}
+
+		js := new(JpegSplitter)
+
+		sof, err := js.parseSof(s.Data)
+		log.PanicIf(err)
+
+		if sof.Height == 0 {
+			dnl, err := sl.FindFirst(MARKER_DNL)
+			if err == nil {
+				sof.Height, err = ParseDnl(dnl.Data)
+				log.PanicIf(err)
+			} else if err != ErrSegmentNotFound {
+				log.Panic(err)
+			}
+		}
+
+		return sof, nil
+	}
+
+	log.Panic(ErrSegmentNotFound)
+	return nil, nil
+}
+
+// Dimensions returns the width and height declared by the first frame (or by
+// the DNL segment, if the frame defers its height, or by the DHP segment of a
+// hierarchical image).
+func (sl SegmentList) Dimensions() (width, height int, err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	// The first frame of a hierarchical image is the lowest resolution. The
+	// final dimensions are given by the DHP segment.
+	if sl.IsHierarchical() == true {
+		dhp, err := sl.Dhp()
+		log.PanicIf(err)
+
+		return int(dhp.Width), int(dhp.Height), nil
+	}
+
+	sof, err := sl.Sof()
+	log.PanicIf(err)
+
+	return int(sof.Width), int(sof.Height), nil
+}
+
+// ChromaSubsampling returns the subsampling of the first frame in J:a:b
+// notation (e.g. "4:2:0"), or "gray" for single-component images. The chroma
+// components must share the same sampling factors.
+func (sl SegmentList) ChromaSubsampling() (subsampling string, err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	for _, s := range sl {
+		if IsSofMarker(s.MarkerId) == false {
+			continue
+		}
+
+		components, err := ParseSofComponents(s.Data)
+		log.PanicIf(err)
+
+		if len(components) == 1 {
+			return "gray", nil
+		} else if len(components) < 3 {
+			log.Panicf("component count not supported: (%d)", len(components))
+		}
+
+		// A fourth component (the K of YCCK) is sampled like the luma and
+		// doesn't affect the notation.
+		luma := components[0]
+		cb := components[1]
+		cr := components[2]
+
+		if cb.HorizontalSamplingFactor != cr.HorizontalSamplingFactor || cb.VerticalSamplingFactor != cr.VerticalSamplingFactor {
+			log.Panicf("chroma components not sampled alike: (%dx%d) (%dx%d)", cb.HorizontalSamplingFactor, cb.VerticalSamplingFactor, cr.HorizontalSamplingFactor, cr.VerticalSamplingFactor)
+		} else if cb.HorizontalSamplingFactor == 0 || cb.VerticalSamplingFactor == 0 {
+			log.Panicf("chroma sampling factor is zero")
+		} else if luma.HorizontalSamplingFactor % cb.HorizontalSamplingFactor != 0 || luma.VerticalSamplingFactor % cb.VerticalSamplingFactor != 0 {
+			log.Panicf("luma sampling not a multiple of chroma sampling: (%dx%d) (%dx%d)", luma.HorizontalSamplingFactor, luma.VerticalSamplingFactor, cb.HorizontalSamplingFactor, cb.VerticalSamplingFactor)
+		}
+
+		ratio := [2]byte{
+			luma.HorizontalSamplingFactor / cb.HorizontalSamplingFactor,
+			luma.VerticalSamplingFactor / cb.VerticalSamplingFactor,
+		}
+
+		name, found := chromaSubsamplingNames[ratio]
+		if found == false {
+			log.Panicf("subsampling not recognized: (%d) (%d)", ratio[0], ratio[1])
+		}
+
+		return name, nil
+	}
+
+	log.Panic(ErrSegmentNotFound)
+	return "", nil
+}
+
+// EncodeSof produces a SOF payload for the given header and components. The
+// component-count of the header is taken from the components.
+func EncodeSof(sof SofSegment, components []SofComponent) []byte {
+	data := make([]byte, sofComponentsOffset + len(components) * sofComponentSize)
+
+	data[0] = sof.BitsPerSample
+	data[1] = byte(sof.Height >> 8)
+	data[2] = byte(sof.Height)
+	data[3] = byte(sof.Width >> 8)
+	data[4] = byte(sof.Width)
+	data[5] = byte(len(components))
+
+	for i, sc := range components {
+		raw := data[sofComponentsOffset + i * sofComponentSize:]
+
+		raw[0] = sc.ComponentId
+		raw[1] = sc.HorizontalSamplingFactor << 4 | sc.VerticalSamplingFactor
+		raw[2] = sc.QuantizationTableId
+	}
+
+	return data
+}
@@ -0,0 +1,87 @@
+package jpegstructure
+
+import (
+	"path"
+	"testing"
+
+	"github.com/dsoprea/go-logging"
+)
+
+func TestSegmentList_ChromaSubsampling(t *testing.T) {
+	filepath := path.Join(assetsPath, testImageRelFilepath)
+
+	sl, err := ParseFileStructure(filepath)
+	log.PanicIf(err)
+
+	subsampling, err := sl.ChromaSubsampling()
+	log.PanicIf(err)
+
+	if subsampling != "4:2:2" {
+		t.Fatalf("Subsampling not correct: [%s]", subsampling)
+	}
+
+	filepath = path.Join(assetsPath, "20180428_212314.jpg")
+
+	sl, err = ParseFileStructure(filepath)
+	log.PanicIf(err)
+
+	subsampling, err = sl.ChromaSubsampling()
+	log.PanicIf(err)
+
+	if subsampling != "4:2:0" {
+		t.Fatalf("Subsampling not correct: [%s]", subsampling)
+	}
+}
+
+func TestSegmentList_ChromaSubsampling_Factors(t *testing.T) {
+	sof := SofSegment{
+		BitsPerSample: 8,
+		Width: 8,
+		Height: 8,
+	}
+
+	cases := map[string][]SofComponent{
+		"gray": {
+			{ComponentId: 1, HorizontalSamplingFactor: 1, VerticalSamplingFactor: 1},
+		},
+		"4:4:4": {
+			{ComponentId: 1, HorizontalSamplingFactor: 1, VerticalSamplingFactor: 1},
+			{ComponentId: 2, HorizontalSamplingFactor: 1, VerticalSamplingFactor: 1},
+			{ComponentId: 3, HorizontalSamplingFactor: 1, VerticalSamplingFactor: 1},
+		},
+		"4:1:1": {
+			{ComponentId: 1, HorizontalSamplingFactor: 4, VerticalSamplingFactor: 1},
+			{ComponentId: 2, HorizontalSamplingFactor: 1, VerticalSamplingFactor: 1},
+			{ComponentId: 3, HorizontalSamplingFactor: 1, VerticalSamplingFactor: 1},
+		},
+	}
+
+	for expected, components := range cases {
+		sl := SegmentList{
+			{MarkerId: MARKER_SOF0, Data: EncodeSof(sof, components)},
+		}
+
+		subsampling, err := sl.ChromaSubsampling()
+		log.PanicIf(err)
+
+		if subsampling != expected {
+			t.Fatalf("Subsampling not correct: [%s] != [%s]", subsampling, expected)
+		}
+	}
+
+	// The chroma components must be sampled alike.
+	components := []SofComponent{
+		{ComponentId: 1, HorizontalSamplingFactor: 2, VerticalSamplingFactor: 2},
+		{ComponentId: 2, HorizontalSamplingFactor: 1, VerticalSamplingFactor: 1},
+		{ComponentId: 3, HorizontalSamplingFactor: 2, VerticalSamplingFactor: 1},
+	}
+
+	sl := SegmentList{
+		{MarkerId: MARKER_SOF0, Data: EncodeSof(sof, components)},
+	}
+
+	_, err := sl.ChromaSubsampling()
+	if err == nil {
+		t.Fatalf("Expected error for mismatched chroma sampling.")
+	}
+}
@@ -0,0 +1,128 @@
+package jpegstructure
+
+import (
+	"bytes"
+	"fmt"
+
+	"encoding/binary"
+
+	"github.com/dsoprea/go-logging"
+)
+
+// Note that the splitter treats SOS as a standalone marker, so the SOS
+// segment has no payload and the scan header (length included) is found at
+// the front of the scan-data that follows it.
+
+// SosComponent selects a component and its entropy tables for a scan.
+type SosComponent struct {
+	ComponentId byte
+	DcTableId byte
+	AcTableId byte
+}
+
+// SosHeader is the header of a scan.
+type SosHeader struct {
+	Components []SosComponent
+
+	// SpectralStart and SpectralEnd select the coefficients (Ss, Se).
+	SpectralStart, SpectralEnd byte
+
+	// SuccessiveHigh and SuccessiveLow are the approximation bits (Ah, Al).
+	SuccessiveHigh, SuccessiveLow byte
+}
+
+func (sh SosHeader) String() string {
+	return fmt.Sprintf("SosHeader<COMPONENTS=(%d) Ss=(%d) Se=(%d) Ah=(%d) Al=(%d)>", len(sh.Components), sh.SpectralStart, sh.SpectralEnd, sh.SuccessiveHigh, sh.SuccessiveLow)
+}
+
+// Encode produces the scan-header payload (without the length).
+func (sh SosHeader) Encode() []byte {
+	b := new(bytes.Buffer)
+
+	b.WriteByte(byte(len(sh.Components)))
+
+	for _, sc := range sh.Components {
+		b.WriteByte(sc.ComponentId)
+		b.WriteByte(sc.DcTableId << 4 | sc.AcTableId)
+	}
+
+	b.WriteByte(sh.SpectralStart)
+	b.WriteByte(sh.SpectralEnd)
+	b.WriteByte(sh.SuccessiveHigh << 4 | sh.SuccessiveLow)
+
+	return b.Bytes()
+}
+
+// ParseSosHeader parses a scan-header payload (without the length).
+func ParseSosHeader(data []byte) (sh *SosHeader, err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	if len(data) < 1 {
+		log.Panicf("SOS header empty")
+	}
+
+	count := int(data[0])
+	if len(data) < 1 + count * 2 + 3 {
+		log.Panicf("SOS header too short for (%d) components: (%d)", count, len(data))
+	}
+
+	sh = &SosHeader{
+		Components: make([]SosComponent, count),
+	}
+
+	for i := 0; i < count; i++ {
+		raw := data[1 + i * 2:]
+
+		sh.Components[i] = SosComponent{
+			ComponentId: raw[0],
+			DcTableId: raw[1] >> 4,
+			AcTableId: raw[1] & 0x0f,
+		}
+	}
+
+	raw := data[1 + count * 2:]
+
+	sh.SpectralStart = raw[0]
+	sh.SpectralEnd = raw[1]
+	sh.SuccessiveHigh = raw[2] >> 4
+	sh.SuccessiveLow = raw[2] & 0x0f
+
+	return sh, nil
+}
+
+// splitScanData separates the scan header (as carried at the front of a
+// scan-data segment) from the entropy-coded data.
+func splitScanData(data []byte) (header []byte, entropyData []byte, err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	if len(data) < 2 {
+		log.Panicf("scan-data too short for a scan header")
+	}
+
+	length := int(binary.BigEndian.Uint16(data))
+	if length < 2 || length > len(data) {
+		log.Panicf("scan-header length not valid: (%d)", length)
+	}
+
+	return data[2:length], data[length:], nil
+}
+
+// joinScanData builds the contents of a scan-data segment from a scan header
+// and the entropy-coded data.
+func joinScanData(header []byte, entropyData []byte) []byte {
+	data := make([]byte, 2 + len(header) + len(entropyData))
+
+	binary.BigEndian.PutUint16(data, uint16(2 + len(header)))
+	copy(data[2:], header)
+	copy(data[2 + len(header):], entropyData)
+
+	return data
+}
@@ -0,0 +1,190 @@
+package jpegstructure
+
+import (
+	"io"
+
+	"encoding/binary"
+
+	"github.com/dsoprea/go-logging"
+)
+
+const (
+	// defaultSparseReadAheadSize is the default size of the reads made by
+	// ParseSparse.
+	defaultSparseReadAheadSize = 16 * 1024
+)
+
+// SparseOptions controls ParseSparse.
+type SparseOptions struct {
+	// ReadAheadSize is the size of each read made for segment headers (and
+	// the payloads that fit). Consecutive small segments are served from the
+	// same read. Defaults to 16K.
+	ReadAheadSize int
+
+	// SkipPayload, if set, is called with the marker and payload length of
+	// each segment. Returning true leaves the payload unread (the segment's
+	// Data will be nil but its sizes are recorded).
+	SkipPayload func(markerId byte, payloadLength int) bool
+}
+
+// segmentSource provides the bytes of the stream to parseSegmentHeaders().
+type segmentSource interface {
+	// read returns `size` bytes from `offset`. It panics on failure.
+	read(offset int64, size int) []byte
+}
+
+// sparseReader serves small reads from a block that was read ahead and
+// passes large reads straight through.
+type sparseReader struct {
+	r io.ReaderAt
+	blockSize int
+
+	blockOffset int64
+	block []byte
+}
+
+// read returns `size` bytes from `offset`.
+func (sr *sparseReader) read(offset int64, size int) []byte {
+	if offset >= sr.blockOffset && offset + int64(size) <= sr.blockOffset + int64(len(sr.block)) {
+		start := int(offset - sr.blockOffset)
+		return sr.block[start:start + size]
+	}
+
+	if size >= sr.blockSize {
+		data := make([]byte, size)
+
+		n, err := sr.r.ReadAt(data, offset)
+		if n < size {
+			log.PanicIf(err)
+		}
+
+		return data
+	}
+
+	block := make([]byte, sr.blockSize)
+
+	n, err := sr.r.ReadAt(block, offset)
+	if n < size {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+
+		log.PanicIf(err)
+	}
+
+	sr.blockOffset = offset
+	sr.block = block[:n]
+
+	return sr.block[:size]
+}
+
+// ParseSparse parses the segments up to and including the SOS by reading the
+// segment headers and then only the payloads that are wanted. The scan-data
+// (and anything after it) is never read. With a ReaderAt that issues range
+// requests (e.g. against S3 or HTTP), the metadata of a large image can be
+// read with a few small requests.
+func ParseSparse(r io.ReaderAt, options SparseOptions) (sl SegmentList, err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	blockSize := options.ReadAheadSize
+	if blockSize <= 0 {
+		blockSize = defaultSparseReadAheadSize
+	}
+
+	sr := &sparseReader{
+		r: r,
+		blockSize: blockSize,
+	}
+
+	sl = parseSegmentHeaders(sr, options.SkipPayload)
+
+	return sl, nil
+}
+
+// parseSegmentHeaders parses the segments up to and including the SOS (or
+// EOI), reading the payloads that aren't skipped. It panics on failure.
+func parseSegmentHeaders(sr segmentSource, skipPayload func(markerId byte, payloadLength int) bool) (sl SegmentList) {
+	checkFormat(sr.read(0, len(jpegMagicStandard)), DetectOptions{})
+
+	sl = make(SegmentList, 0)
+	offset := int64(0)
+
+	for {
+		s := readSegment(sr, offset, skipPayload)
+
+		sl = append(sl, s)
+		offset = int64(s.EndOffset())
+
+		if s.MarkerId == MARKER_SOS || s.MarkerId == MARKER_EOI {
+			break
+		}
+	}
+
+	return sl
+}
+
+// readSegment reads the segment at `offset` (after any fill bytes), reading
+// the payload unless it's skipped. It panics on failure.
+func readSegment(sr segmentSource, offset int64, skipPayload func(markerId byte, payloadLength int) bool) Segment {
+	if sr.read(offset, 1)[0] != 0xff {
+		log.Panicf("not on new segment marker: (0x%08x)", offset)
+	}
+
+	// Skip fill bytes.
+	for sr.read(offset + 1, 1)[0] == 0xff {
+		offset++
+	}
+
+	markerId := sr.read(offset + 1, 1)[0]
+
+	headerSize := 2
+	payloadLength := 0
+
+	sizeLen, found := markerLen[markerId]
+	if found == false {
+		headerSize = 2 + 2
+
+		length := int(binary.BigEndian.Uint16(sr.read(offset + 2, 2)))
+		if length <= 2 {
+			log.Panicf("length of size read for non-special marker (%02x) is unexpectedly not more than two.", markerId)
+		}
+
+		payloadLength = length - 2
+	} else if sizeLen == 4 {
+		headerSize = 2 + 4
+
+		length := int(binary.BigEndian.Uint32(sr.read(offset + 2, 4)))
+		if length < 4 {
+			log.Panicf("length of four-byte-length marker (%02x) is unexpectedly less than four.", markerId)
+		}
+
+		payloadLength = length - 4
+	}
+
+	markerLength := 0
+	if headerSize > 2 {
+		markerLength = headerSize - 2 + payloadLength
+	}
+
+	s := Segment{
+		MarkerId: markerId,
+		MarkerName: markerNames[markerId],
+		Offset: int(offset),
+		HeaderSize: headerSize,
+		MarkerLength: markerLength,
+		TotalSize: headerSize + payloadLength,
+	}
+
+	if skipPayload == nil || skipPayload(markerId, payloadLength) == false {
+		payload := sr.read(offset + int64(headerSize), payloadLength)
+
+		s.Data = make([]byte, payloadLength)
+		copy(s.Data, payload)
+	}
+
+	return s
+}
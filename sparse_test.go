@@ -0,0 +1,103 @@
+package jpegstructure
+
+import (
+	"bytes"
+	"io"
+	"path"
+	"testing"
+
+	"io/ioutil"
+
+	"github.com/dsoprea/go-logging"
+)
+
+// countingReaderAt tallies the reads made against it.
+type countingReaderAt struct {
+	r io.ReaderAt
+	reads int
+	bytesRead int
+}
+
+func (cra *countingReaderAt) ReadAt(p []byte, offset int64) (n int, err error) {
+	n, err = cra.r.ReadAt(p, offset)
+
+	cra.reads++
+	cra.bytesRead += n
+
+	return n, err
+}
+
+func TestParseSparse(t *testing.T) {
+	filepath := path.Join(assetsPath, testImageRelFilepath)
+
+	data, err := ioutil.ReadFile(filepath)
+	log.PanicIf(err)
+
+	expected, err := ParseBytesStructure(data)
+	log.PanicIf(err)
+
+	cra := &countingReaderAt{
+		r: bytes.NewReader(data),
+	}
+
+	sl, err := ParseSparse(cra, SparseOptions{})
+	log.PanicIf(err)
+
+	sosIndex := expected.Index(MARKER_SOS)
+	if len(sl) != sosIndex + 1 {
+		t.Fatalf("Segment count not correct: (%d) != (%d)", len(sl), sosIndex + 1)
+	}
+
+	for i, s := range sl {
+		e := expected[i]
+		if s.MarkerId != e.MarkerId || s.Offset != e.Offset || s.TotalSize != e.TotalSize || bytes.Equal(s.Data, e.Data) == false {
+			t.Fatalf("Segment (%d) not correct: (0x%02x) (%d)", i, s.MarkerId, s.Offset)
+		}
+	}
+
+	if cra.bytesRead >= len(data) / 2 {
+		t.Fatalf("Too much read: (%d) of (%d)", cra.bytesRead, len(data))
+	}
+}
+
+func TestParseSparse_SkipPayload(t *testing.T) {
+	filepath := path.Join(assetsPath, testImageRelFilepath)
+
+	data, err := ioutil.ReadFile(filepath)
+	log.PanicIf(err)
+
+	cra := &countingReaderAt{
+		r: bytes.NewReader(data),
+	}
+
+	options := SparseOptions{
+		ReadAheadSize: 64,
+		SkipPayload: func(markerId byte, payloadLength int) bool {
+			return markerId == MARKER_APP1
+		},
+	}
+
+	sl, err := ParseSparse(cra, options)
+	log.PanicIf(err)
+
+	skipped := 0
+	for _, s := range sl {
+		if s.MarkerId == MARKER_APP1 {
+			if s.Data != nil || s.TotalSize <= s.HeaderSize {
+				t.Fatalf("APP1 payload not skipped correctly.")
+			}
+
+			skipped += s.TotalSize - s.HeaderSize
+		} else if len(s.Data) != s.TotalSize - s.HeaderSize {
+			t.Fatalf("Payload not read: (0x%02x)", s.MarkerId)
+		}
+	}
+
+	if skipped == 0 {
+		t.Fatalf("No APP1 segments found.")
+	} else if cra.bytesRead >= skipped {
+		t.Fatalf("Skipped payloads were read: (%d) >= (%d)", cra.bytesRead, skipped)
+	} else if cra.reads > len(sl) * 4 {
+		t.Fatalf("Too many reads: (%d)", cra.reads)
+	}
+}
@@ -0,0 +1,333 @@
+package jpegstructure
+
+import (
+	"bytes"
+	"math"
+	"sort"
+
+	"encoding/binary"
+
+	"github.com/dsoprea/go-logging"
+)
+
+const (
+	STANDARD_PROFILE_SRGB = "sRGB"
+	STANDARD_PROFILE_ADOBE_RGB = "AdobeRGB"
+)
+
+const (
+	exifTagInteroperabilityIndex = 0x0001
+
+	// exifColorSpaceAdobeRgb is the (unofficial) ColorSpace value that some
+	// cameras write for Adobe RGB.
+	exifColorSpaceAdobeRgb = 2
+
+	// interopIndexAdobeRgb is the DCF option-file InteroperabilityIndex,
+	// which, with an uncalibrated ColorSpace, indicates Adobe RGB.
+	interopIndexAdobeRgb = "R03"
+
+	// srgbTrcEntries is the size of the table that describes the sRGB tone
+	// curve ('curv' can only express a pure gamma otherwise).
+	srgbTrcEntries = 1024
+
+	// adobeRgbGamma is the Adobe RGB (1998) gamma as a u8Fixed8Number
+	// (563/256, or about 2.2).
+	adobeRgbGamma = 0x0233
+)
+
+var (
+	// iccD50 is the profile connection space illuminant.
+	iccD50 = [3]float64{0.9642, 1.0, 0.8249}
+
+	// iccD65 is the media white point of both of the standard profiles.
+	iccD65 = [3]float64{0.9505, 1.0, 1.0891}
+
+	// adobeRgbColorants are the (D50-adapted) XYZ values of the Adobe RGB
+	// (1998) primaries.
+	adobeRgbColorants = map[string][3]float64{
+		"rXYZ": {0.6097, 0.3111, 0.0195},
+		"gXYZ": {0.2053, 0.6257, 0.0609},
+		"bXYZ": {0.1492, 0.0632, 0.7446},
+	}
+)
+
+// iccXyzNumber encodes an XYZNumber (three s15Fixed16Numbers).
+func iccXyzNumber(xyz [3]float64) []byte {
+	raw := make([]byte, 12)
+	for i, value := range xyz {
+		binary.BigEndian.PutUint32(raw[i * 4:], uint32(int32(math.Round(value * 65536))))
+	}
+
+	return raw
+}
+
+// iccXyzTag encodes an 'XYZ ' tag.
+func iccXyzTag(xyz [3]float64) []byte {
+	return append([]byte("XYZ \x00\x00\x00\x00"), iccXyzNumber(xyz)...)
+}
+
+// iccTextTag encodes a 'text' tag.
+func iccTextTag(text string) []byte {
+	return append(append([]byte("text\x00\x00\x00\x00"), text...), 0)
+}
+
+// iccDescTag encodes a (version 2) 'desc' tag with only the ASCII
+// description.
+func iccDescTag(description string) []byte {
+	b := new(bytes.Buffer)
+	b.WriteString("desc\x00\x00\x00\x00")
+
+	binary.Write(b, binary.BigEndian, uint32(len(description) + 1))
+	b.WriteString(description)
+	b.WriteByte(0)
+
+	// The (empty) Unicode and ScriptCode descriptions.
+	b.Write(make([]byte, 4 + 4 + 2 + 1 + 67))
+
+	return b.Bytes()
+}
+
+// iccCurveTag encodes a 'curv' tag from its entries (one entry is a gamma).
+func iccCurveTag(entries []uint16) []byte {
+	b := new(bytes.Buffer)
+	b.WriteString("curv\x00\x00\x00\x00")
+
+	binary.Write(b, binary.BigEndian, uint32(len(entries)))
+	binary.Write(b, binary.BigEndian, entries)
+
+	return b.Bytes()
+}
+
+// srgbCurveTag returns a 'curv' tag that tabulates the sRGB tone curve.
+func srgbCurveTag() []byte {
+	entries := make([]uint16, srgbTrcEntries)
+	for i := range entries {
+		x := float64(i) / float64(srgbTrcEntries - 1)
+		entries[i] = uint16(math.Round(srgbTransfer(x) * 65535))
+	}
+
+	return iccCurveTag(entries)
+}
+
+// buildIccProfile assembles a version 2.1 RGB display profile from its tags
+// (signature to the full tag data).
+func buildIccProfile(tags map[string][]byte) []byte {
+	signatures := make([]string, 0, len(tags))
+	for signature := range tags {
+		signatures = append(signatures, signature)
+	}
+
+	sort.Strings(signatures)
+
+	table := new(bytes.Buffer)
+	tagData := new(bytes.Buffer)
+
+	offset := iccProfileHeaderSize + 4 + len(tags) * iccTagEntrySize
+
+	binary.Write(table, binary.BigEndian, uint32(len(tags)))
+	for _, signature := range signatures {
+		data := tags[signature]
+
+		table.WriteString(signature)
+		binary.Write(table, binary.BigEndian, uint32(offset + tagData.Len()))
+		binary.Write(table, binary.BigEndian, uint32(len(data)))
+
+		// Tags are aligned on four bytes.
+		tagData.Write(data)
+		for tagData.Len() % 4 != 0 {
+			tagData.WriteByte(0)
+		}
+	}
+
+	header := make([]byte, iccProfileHeaderSize)
+	binary.BigEndian.PutUint32(header[0:], uint32(offset + tagData.Len()))
+	header[8] = 2
+	header[9] = 0x10
+	copy(header[12:], "mntr")
+	copy(header[16:], "RGB ")
+	copy(header[20:], "XYZ ")
+	copy(header[36:], iccProfileSignature)
+	copy(header[68:], iccXyzNumber(iccD50))
+
+	profile := make([]byte, 0, offset + tagData.Len())
+	profile = append(profile, header...)
+	profile = append(profile, table.Bytes()...)
+	profile = append(profile, tagData.Bytes()...)
+
+	return profile
+}
+
+// StandardIccProfile returns a compact display profile for one of the
+// STANDARD_PROFILE_* color spaces, suitable for embedding with
+// SetIccProfile().
+func StandardIccProfile(name string) (profile []byte, err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	var description string
+	var colorants map[string][3]float64
+	var trc []byte
+
+	switch name {
+	case STANDARD_PROFILE_SRGB:
+		description = "sRGB IEC61966-2.1"
+		colorants = srgbColorants
+		trc = srgbCurveTag()
+	case STANDARD_PROFILE_ADOBE_RGB:
+		description = "Adobe RGB (1998)"
+		colorants = adobeRgbColorants
+		trc = iccCurveTag([]uint16{adobeRgbGamma})
+	default:
+		log.Panicf("standard profile not known: [%s]", name)
+	}
+
+	tags := map[string][]byte{
+		"desc": iccDescTag(description),
+		"cprt": iccTextTag("No copyright, use freely"),
+		"wtpt": iccXyzTag(iccD65),
+	}
+
+	for signature, xyz := range colorants {
+		tags[signature] = iccXyzTag(xyz)
+	}
+
+	for _, signature := range iccTrcTags {
+		tags[signature] = trc
+	}
+
+	return buildIccProfile(tags), nil
+}
+
+// SetIccProfile replaces the ICC profile in the image, splitting it into as
+// many APP2 segments as necessary. The new segments take the place of the old
+// profile or, if there wasn't one, follow the SOI and any APP0 and APP1
+// segments.
+func (sl *SegmentList) SetIccProfile(profile []byte) (err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	_, err = ParseIccSummary(profile)
+	log.PanicIf(err)
+
+	chunkSize := maxSegmentPayloadSize - iccHeaderSize
+	count := (len(profile) + chunkSize - 1) / chunkSize
+	if count > 255 {
+		log.Panicf("ICC profile too large: (%d)", len(profile))
+	}
+
+	segments := make(SegmentList, count)
+	for i := range segments {
+		end := (i + 1) * chunkSize
+		if end > len(profile) {
+			end = len(profile)
+		}
+
+		payload := make([]byte, 0, iccHeaderSize + end - i * chunkSize)
+		payload = append(payload, iccPrefix...)
+		payload = append(payload, byte(i + 1), byte(count))
+		payload = append(payload, profile[i * chunkSize:end]...)
+
+		segments[i] = Segment{
+			MarkerId: MARKER_APP2,
+			MarkerName: markerNames[MARKER_APP2],
+			Data: payload,
+		}
+	}
+
+	updated := make(SegmentList, 0, len(*sl) + count)
+
+	position := -1
+	afterHeaders := 0
+
+	for _, s := range *sl {
+		if s.MarkerId == MARKER_APP2 && isIccPayload(s.Data) == true {
+			if position == -1 {
+				position = len(updated)
+			}
+
+			continue
+		}
+
+		updated = append(updated, s)
+
+		isHeader := s.MarkerId == MARKER_SOI || s.MarkerId == MARKER_APP0 || s.MarkerId == MARKER_APP1
+		if isHeader == true && afterHeaders == len(updated) - 1 {
+			afterHeaders = len(updated)
+		}
+	}
+
+	if position == -1 {
+		position = afterHeaders
+	}
+
+	final := make(SegmentList, 0, len(updated) + count)
+	final = append(final, updated[:position]...)
+	final = append(final, segments...)
+	final = append(final, updated[position:]...)
+
+	final.updateOffsets()
+
+	*sl = final
+	return nil
+}
+
+// IsUntaggedAdobeRgb indicates whether the EXIF data declares Adobe RGB (a
+// ColorSpace of 2, or an uncalibrated ColorSpace with the "R03"
+// InteroperabilityIndex) while there's no ICC profile. Most browsers render
+// such images as sRGB, which desaturates them.
+func (sl SegmentList) IsUntaggedAdobeRgb() (untagged bool, err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	cpi, err := sl.ColorProfile()
+	log.PanicIf(err)
+
+	return cpi.ImpliedColorSpace == IMPLIED_COLOR_SPACE_ADOBE_RGB, nil
+}
+
+// EmbedStandardProfile adds a standard profile to an image that doesn't have
+// one, so that it displays the same everywhere: Adobe RGB if that's what the
+// EXIF data declares (see IsUntaggedAdobeRgb) or, if `tagSrgb` is true, sRGB
+// for images that are implicitly sRGB. Images with a profile (or that are
+// gray, CMYK, or uncalibrated) are left alone. The name of the embedded
+// profile is returned, or an empty string if nothing was done.
+func (sl *SegmentList) EmbedStandardProfile(tagSrgb bool) (name string, err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	cpi, err := sl.ColorProfile()
+	log.PanicIf(err)
+
+	switch {
+	case cpi.Icc != nil:
+		return "", nil
+	case cpi.ImpliedColorSpace == IMPLIED_COLOR_SPACE_ADOBE_RGB:
+		name = STANDARD_PROFILE_ADOBE_RGB
+	case cpi.ImpliedColorSpace == IMPLIED_COLOR_SPACE_SRGB && tagSrgb == true:
+		name = STANDARD_PROFILE_SRGB
+	default:
+		return "", nil
+	}
+
+	profile, err := StandardIccProfile(name)
+	log.PanicIf(err)
+
+	err = sl.SetIccProfile(profile)
+	log.PanicIf(err)
+
+	jpegLogger.Debugf(nil, "Embedded standard profile: [%s]", name)
+
+	return name, nil
+}
@@ -0,0 +1,156 @@
+package jpegstructure
+
+import (
+	"bytes"
+	"path"
+	"testing"
+
+	"encoding/binary"
+
+	"github.com/dsoprea/go-logging"
+)
+
+func TestStandardIccProfile(t *testing.T) {
+	cases := []struct {
+		name string
+		description string
+		equivalent bool
+	}{
+		{STANDARD_PROFILE_SRGB, "sRGB IEC61966-2.1", true},
+		{STANDARD_PROFILE_ADOBE_RGB, "Adobe RGB (1998)", false},
+	}
+
+	for _, c := range cases {
+		profile, err := StandardIccProfile(c.name)
+		log.PanicIf(err)
+
+		summary, err := ParseIccSummary(profile)
+		log.PanicIf(err)
+
+		if summary.Description != c.description || summary.SrgbEquivalent != c.equivalent || summary.Version != "2.1.0" || summary.DeviceClass != "mntr" {
+			t.Fatalf("Profile [%s] not correct: %s", c.name, summary)
+		}
+	}
+
+	_, err := StandardIccProfile("ProPhoto")
+	if err == nil {
+		t.Fatalf("Expected error for unknown profile.")
+	}
+}
+
+// getTestImageWithColorSpace returns an image without a profile whose EXIF
+// data has the given ColorSpace and (if not empty) InteroperabilityIndex.
+func getTestImageWithColorSpace(colorSpace uint16, interopIndex string) SegmentList {
+	sl, err := ParseBytesStructure(getTransformTestImage())
+	log.PanicIf(err)
+
+	ed := NewExifDocument(binary.BigEndian)
+
+	err = ed.SetValue(EXIF_IFD_EXIF, exifTagColorSpace, EXIF_TYPE_SHORT, []uint16{colorSpace})
+	log.PanicIf(err)
+
+	if interopIndex != "" {
+		err = ed.SetValue(EXIF_IFD_INTEROP, exifTagInteroperabilityIndex, EXIF_TYPE_ASCII, interopIndex)
+		log.PanicIf(err)
+	}
+
+	err = sl.SetExifDocument(ed)
+	log.PanicIf(err)
+
+	return sl
+}
+
+func TestSegmentList_IsUntaggedAdobeRgb(t *testing.T) {
+	cases := []struct {
+		colorSpace uint16
+		interopIndex string
+		expected bool
+	}{
+		{1, "R98", false},
+		{2, "", true},
+		{exifColorSpaceUncalibrated, "R03", true},
+		{exifColorSpaceUncalibrated, "", false},
+	}
+
+	for _, c := range cases {
+		sl := getTestImageWithColorSpace(c.colorSpace, c.interopIndex)
+
+		untagged, err := sl.IsUntaggedAdobeRgb()
+		log.PanicIf(err)
+
+		if untagged != c.expected {
+			t.Fatalf("Detection not correct for (%d) [%s]: [%v]", c.colorSpace, c.interopIndex, untagged)
+		}
+	}
+
+	// A photo without the EXIF hints is implicitly sRGB.
+	sl, err := ParseFileStructure(path.Join(assetsPath, testImageRelFilepath))
+	log.PanicIf(err)
+
+	untagged, err := sl.IsUntaggedAdobeRgb()
+	log.PanicIf(err)
+
+	if untagged == true {
+		t.Fatalf("Test image should not be untagged Adobe RGB.")
+	}
+}
+
+func TestSegmentList_EmbedStandardProfile(t *testing.T) {
+	sl := getTestImageWithColorSpace(exifColorSpaceUncalibrated, "R03")
+
+	name, err := sl.EmbedStandardProfile(false)
+	log.PanicIf(err)
+
+	if name != STANDARD_PROFILE_ADOBE_RGB {
+		t.Fatalf("Profile not embedded: [%s]", name)
+	}
+
+	b := new(bytes.Buffer)
+
+	err = sl.Write(b)
+	log.PanicIf(err)
+
+	parsed, err := ParseBytesStructure(b.Bytes())
+	log.PanicIf(err)
+
+	cpi, err := parsed.ColorProfile()
+	log.PanicIf(err)
+
+	if cpi.Icc == nil || cpi.Icc.Description != "Adobe RGB (1998)" {
+		t.Fatalf("Embedded profile not correct: %v", cpi)
+	} else if parsed[1].Kind() != SegmentKindExifApp1 || parsed[2].MarkerId != MARKER_APP2 {
+		t.Fatalf("Profile not placed after the EXIF segment: [%s]", parsed[2].MarkerName)
+	}
+
+	// Tagged images are left alone.
+	name, err = parsed.EmbedStandardProfile(true)
+	log.PanicIf(err)
+
+	if name != "" {
+		t.Fatalf("Tagged image changed: [%s]", name)
+	}
+
+	// sRGB is only embedded on request.
+	sl = getTestImageWithColorSpace(1, "R98")
+
+	name, err = sl.EmbedStandardProfile(false)
+	log.PanicIf(err)
+
+	if name != "" {
+		t.Fatalf("sRGB embedded without being requested.")
+	}
+
+	name, err = sl.EmbedStandardProfile(true)
+	log.PanicIf(err)
+
+	if name != STANDARD_PROFILE_SRGB {
+		t.Fatalf("sRGB not embedded: [%s]", name)
+	}
+
+	cpi, err = sl.ColorProfile()
+	log.PanicIf(err)
+
+	if cpi.Icc == nil || cpi.Icc.SrgbEquivalent == false {
+		t.Fatalf("Embedded sRGB profile not correct: %v", cpi)
+	}
+}
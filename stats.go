@@ -0,0 +1,117 @@
+package jpegstructure
+
+import (
+	"fmt"
+
+	"github.com/dsoprea/go-logging"
+)
+
+// Stats breaks down where the bytes of an image go.
+type Stats struct {
+	// TotalBytes is the size of the image as it would be written.
+	TotalBytes int
+
+	SegmentCount int
+	ScanCount int
+
+	// ScanDataBytes is the size of the entropy-coded data of all scans (not
+	// including the scan headers).
+	ScanDataBytes int
+
+	// RestartInterval is the interval (in MCUs) set by the first DRI
+	// segment, or zero if restart intervals aren't used.
+	RestartInterval int
+
+	// RestartMarkerCount is the number of RSTn markers in the scan-data.
+	RestartMarkerCount int
+
+	// MetadataBytes is the size of the APPn and COM segments (including
+	// their headers) and ImageBytes is the size of everything else.
+	MetadataBytes int
+	ImageBytes int
+
+	Width, Height int
+
+	// BitsPerPixel is the size of the scan-data in bits per pixel, or zero if
+	// the dimensions aren't known.
+	BitsPerPixel float64
+}
+
+// MetadataRatio returns the fraction of the image taken up by metadata.
+func (s Stats) MetadataRatio() float64 {
+	if s.TotalBytes == 0 {
+		return 0
+	}
+
+	return float64(s.MetadataBytes) / float64(s.TotalBytes)
+}
+
+func (s Stats) String() string {
+	return fmt.Sprintf("Stats<TOTAL=(%d) SEGMENTS=(%d) SCANS=(%d) SCAN-DATA=(%d) RESTART-INTERVAL=(%d) RESTART-MARKERS=(%d) METADATA=(%d) IMAGE=(%d) DIMENSIONS=(%dx%d) BPP=(%.3f)>", s.TotalBytes, s.SegmentCount, s.ScanCount, s.ScanDataBytes, s.RestartInterval, s.RestartMarkerCount, s.MetadataBytes, s.ImageBytes, s.Width, s.Height, s.BitsPerPixel)
+}
+
+// countRestartMarkers counts the RSTn markers in entropy-coded data.
+func countRestartMarkers(entropyData []byte) int {
+	count := 0
+	for i := 0; i + 1 < len(entropyData); i++ {
+		if entropyData[i] == 0xff && IsRstMarker(entropyData[i + 1]) == true {
+			count++
+			i++
+		}
+	}
+
+	return count
+}
+
+// Stats totals the scan-data, metadata, and structural bytes of the image.
+func (sl SegmentList) Stats() (stats *Stats, err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	stats = &Stats{
+		SegmentCount: len(sl),
+	}
+
+	for _, s := range sl {
+		size := segmentHeaderSize(s.MarkerId) + len(s.Data)
+		stats.TotalBytes += size
+
+		if isMetadataMarker(s.MarkerId) == true {
+			stats.MetadataBytes += size
+		} else {
+			stats.ImageBytes += size
+		}
+
+		switch s.MarkerId {
+		case MARKER_SOS:
+			stats.ScanCount++
+		case MARKER_DRI:
+			if stats.RestartInterval == 0 && len(s.Data) == 2 {
+				stats.RestartInterval = int(s.Data[0]) << 8 | int(s.Data[1])
+			}
+		case 0x0:
+			_, entropyData, err := splitScanData(s.Data)
+			log.PanicIf(err)
+
+			stats.ScanDataBytes += len(entropyData)
+			stats.RestartMarkerCount += countRestartMarkers(entropyData)
+		}
+	}
+
+	width, height, err := sl.Dimensions()
+	if err == nil {
+		stats.Width = width
+		stats.Height = height
+
+		if width > 0 && height > 0 {
+			stats.BitsPerPixel = float64(stats.ScanDataBytes) * 8 / float64(width * height)
+		}
+	} else if log.Is(err, ErrSegmentNotFound) == false {
+		log.Panic(err)
+	}
+
+	return stats, nil
+}
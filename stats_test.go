@@ -0,0 +1,72 @@
+package jpegstructure
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"github.com/dsoprea/go-logging"
+)
+
+func TestSegmentList_Stats(t *testing.T) {
+	filepath := path.Join(assetsPath, testImageRelFilepath)
+
+	sl, err := ParseFileStructure(filepath)
+	log.PanicIf(err)
+
+	stats, err := sl.Stats()
+	log.PanicIf(err)
+
+	fi, err := os.Stat(filepath)
+	log.PanicIf(err)
+
+	if int64(stats.TotalBytes) != fi.Size() {
+		t.Fatalf("Total not correct: (%d) != (%d)", stats.TotalBytes, fi.Size())
+	} else if stats.MetadataBytes + stats.ImageBytes != stats.TotalBytes {
+		t.Fatalf("Breakdown doesn't add up: %s", stats)
+	} else if stats.SegmentCount != len(sl) || stats.ScanCount != 1 {
+		t.Fatalf("Counts not correct: %s", stats)
+	} else if stats.ScanDataBytes == 0 || stats.ScanDataBytes >= stats.ImageBytes {
+		t.Fatalf("Scan-data size not correct: %s", stats)
+	} else if stats.Width != 3840 || stats.Height != 2560 {
+		t.Fatalf("Dimensions not correct: %s", stats)
+	} else if stats.MetadataRatio() <= 0 || stats.MetadataRatio() >= 1 {
+		t.Fatalf("Metadata ratio not correct: (%f)", stats.MetadataRatio())
+	}
+
+	expectedBpp := float64(stats.ScanDataBytes) * 8 / (3840 * 2560)
+	if stats.BitsPerPixel != expectedBpp {
+		t.Fatalf("Bits-per-pixel not correct: (%f)", stats.BitsPerPixel)
+	}
+}
+
+func TestSegmentList_Stats_Restarts(t *testing.T) {
+	header := SosHeader{
+		Components: []SosComponent{{ComponentId: 1}},
+		SpectralEnd: 63,
+	}
+
+	entropyData := []byte{0x12, 0xff, 0x00, 0x34, 0xff, 0xd0, 0x56, 0xff, 0xd1, 0x78}
+
+	sl := SegmentList{
+		{MarkerId: MARKER_SOI},
+		{MarkerId: MARKER_COM, Data: []byte("comment")},
+		{MarkerId: MARKER_DRI, Data: []byte{0x00, 0x04}},
+		{MarkerId: MARKER_SOS},
+		{MarkerId: 0x0, Data: joinScanData(header.Encode(), entropyData)},
+		{MarkerId: MARKER_EOI},
+	}
+
+	stats, err := sl.Stats()
+	log.PanicIf(err)
+
+	if stats.RestartInterval != 4 || stats.RestartMarkerCount != 2 {
+		t.Fatalf("Restart usage not correct: %s", stats)
+	} else if stats.ScanDataBytes != len(entropyData) {
+		t.Fatalf("Scan-data size not correct: %s", stats)
+	} else if stats.MetadataBytes != 4 + 7 {
+		t.Fatalf("Metadata size not correct: %s", stats)
+	} else if stats.Width != 0 || stats.BitsPerPixel != 0 {
+		t.Fatalf("Dimensions expected to be unknown: %s", stats)
+	}
+}
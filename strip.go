@@ -0,0 +1,43 @@
+package jpegstructure
+
+import (
+	"bytes"
+)
+
+var (
+	adobePrefix = []byte("Adobe")
+)
+
+// isDecodingSegment indicates whether a metadata-type segment affects how the
+// image is decoded and must survive a strip. The JFIF header and the Adobe
+// APP14 segment (color-transform) are such.
+func isDecodingSegment(s Segment) bool {
+	if s.MarkerId == MARKER_APP0 && isJfifPayload(s.Data) == true {
+		return true
+	} else if s.MarkerId == MARKER_APP14 && bytes.HasPrefix(s.Data, adobePrefix) == true {
+		return true
+	}
+
+	return false
+}
+
+// StripMetadata returns a copy of the list without the APPn and COM segments
+// that don't affect decoding. If `keepIcc` is true, the ICC profile is kept as
+// well.
+func (sl SegmentList) StripMetadata(keepIcc bool) SegmentList {
+	stripped := make(SegmentList, 0, len(sl))
+
+	for _, s := range sl {
+		if isMetadataMarker(s.MarkerId) == true && isDecodingSegment(s) == false {
+			if keepIcc == false || s.MarkerId != MARKER_APP2 || isIccPayload(s.Data) == false {
+				continue
+			}
+		}
+
+		stripped = append(stripped, s)
+	}
+
+	stripped.updateOffsets()
+
+	return stripped
+}
@@ -0,0 +1,602 @@
+package jpegstructure
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"encoding/binary"
+	"encoding/xml"
+
+	"github.com/dsoprea/go-logging"
+)
+
+// SyncDirection selects which block SyncMetadata() treats as authoritative.
+type SyncDirection int
+
+const (
+	// SyncExifToXmp copies the EXIF values into the XMP packet.
+	SyncExifToXmp SyncDirection = iota
+
+	// SyncXmpToExif copies the XMP values into the EXIF data.
+	SyncXmpToExif
+)
+
+func (sd SyncDirection) String() string {
+	if sd == SyncXmpToExif {
+		return "xmp-to-exif"
+	}
+
+	return "exif-to-xmp"
+}
+
+// SyncFields is a set of the fields that SyncMetadata() reconciles.
+type SyncFields int
+
+const (
+	// SyncDates covers DateTime, DateTimeOriginal, and DateTimeDigitized
+	// (xmp:ModifyDate, photoshop:DateCreated, and xmp:CreateDate).
+	SyncDates SyncFields = 1 << iota
+
+	// SyncOrientation covers Orientation (tiff:Orientation).
+	SyncOrientation
+
+	// SyncCreator covers Artist (dc:creator).
+	SyncCreator
+
+	// SyncDescription covers ImageDescription (dc:description).
+	SyncDescription
+
+	// SyncGps covers the GPS position and altitude (exif:GPSLatitude,
+	// exif:GPSLongitude, exif:GPSAltitude, and exif:GPSAltitudeRef).
+	SyncGps
+
+	SyncAll = SyncDates | SyncOrientation | SyncCreator | SyncDescription | SyncGps
+)
+
+const (
+	xmpBasicNamespace = "http://ns.adobe.com/xap/1.0/"
+	xmpPhotoshopNamespace = "http://ns.adobe.com/photoshop/1.0/"
+	xmpTiffNamespace = "http://ns.adobe.com/tiff/1.0/"
+	xmpExifNamespace = "http://ns.adobe.com/exif/1.0/"
+	xmpDcNamespace = "http://purl.org/dc/elements/1.1/"
+
+	xmpTimestampLayout = "2006-01-02T15:04:05"
+
+	// syncDegreesTolerance and syncAltitudeTolerance are the differences
+	// below which GPS values are considered to agree (about a centimeter).
+	syncDegreesTolerance = 0.0000001
+	syncAltitudeTolerance = 0.01
+)
+
+const (
+	exifTagImageDescription = 0x010e
+	exifTagArtist = 0x013b
+)
+
+// syncKind is how a value is represented in each block.
+type syncKind int
+
+const (
+	syncKindText syncKind = iota
+	syncKindDate
+	syncKindShort
+
+	// syncKindSeq is an ordered XMP array. In EXIF, the items are separated
+	// by semicolons.
+	syncKindSeq
+
+	// syncKindAlt is a language-alternative XMP array. Only the default
+	// language is written.
+	syncKindAlt
+)
+
+// syncMapping pairs an EXIF tag with an XMP property.
+type syncMapping struct {
+	fields SyncFields
+	ifdName string
+	tagId uint16
+	xmpName XmpPropertyName
+	kind syncKind
+}
+
+// xmpUpdate is a property to (re)write.
+type xmpUpdate struct {
+	name XmpPropertyName
+	kind syncKind
+	items []string
+}
+
+var (
+	syncMappings = []syncMapping{
+		{SyncDates, EXIF_IFD_ROOT, 0x0132, XmpPropertyName{xmpBasicNamespace, "ModifyDate"}, syncKindDate},
+		{SyncDates, EXIF_IFD_EXIF, 0x9003, XmpPropertyName{xmpPhotoshopNamespace, "DateCreated"}, syncKindDate},
+		{SyncDates, EXIF_IFD_EXIF, 0x9004, XmpPropertyName{xmpBasicNamespace, "CreateDate"}, syncKindDate},
+		{SyncOrientation, EXIF_IFD_ROOT, exifTagOrientation, XmpPropertyName{xmpTiffNamespace, "Orientation"}, syncKindShort},
+		{SyncCreator, EXIF_IFD_ROOT, exifTagArtist, XmpPropertyName{xmpDcNamespace, "creator"}, syncKindSeq},
+		{SyncDescription, EXIF_IFD_ROOT, exifTagImageDescription, XmpPropertyName{xmpDcNamespace, "description"}, syncKindAlt},
+	}
+
+	xmpGpsLatitude = XmpPropertyName{xmpExifNamespace, "GPSLatitude"}
+	xmpGpsLongitude = XmpPropertyName{xmpExifNamespace, "GPSLongitude"}
+	xmpGpsAltitude = XmpPropertyName{xmpExifNamespace, "GPSAltitude"}
+	xmpGpsAltitudeRef = XmpPropertyName{xmpExifNamespace, "GPSAltitudeRef"}
+
+	// xmpSyncPrefixes are the prefixes that we bind when writing properties.
+	xmpSyncPrefixes = map[string]string{
+		xmpBasicNamespace: "xmp",
+		xmpPhotoshopNamespace: "photoshop",
+		xmpTiffNamespace: "tiff",
+		xmpExifNamespace: "exif",
+		xmpDcNamespace: "dc",
+	}
+
+	xmpTimestampLayouts = []string{
+		"2006-01-02T15:04:05Z07:00",
+		"2006-01-02T15:04:05",
+		"2006-01-02T15:04Z07:00",
+		"2006-01-02T15:04",
+		"2006-01-02",
+	}
+
+	rdfCloseRegex = regexp.MustCompile(`</(\w+:)?RDF\s*>`)
+
+	emptyXmpPacket = []byte(`<x:xmpmeta xmlns:x="adobe:ns:meta/"><rdf:RDF xmlns:rdf="` + rdfNamespace + `"></rdf:RDF></x:xmpmeta>`)
+)
+
+// parseXmpTimestamp parses an XMP date. The clock time is kept as written;
+// any zone is dropped, since EXIF times are local.
+func parseXmpTimestamp(s string) (time.Time, bool) {
+	for _, layout := range xmpTimestampLayouts {
+		t, err := time.Parse(layout, strings.TrimSpace(s))
+		if err == nil {
+			return t, true
+		}
+	}
+
+	return time.Time{}, false
+}
+
+// exifSyncItems returns the value of the mapped EXIF tag in its XMP form.
+func exifSyncItems(ed *ExifDocument, m syncMapping) (items []string, found bool) {
+	ee, err := ed.Entry(m.ifdName, m.tagId)
+	if err != nil {
+		return nil, false
+	}
+
+	value, err := ed.Value(ee)
+	if err != nil {
+		return nil, false
+	}
+
+	switch m.kind {
+	case syncKindShort:
+		values, ok := value.([]uint16)
+		if ok == false || len(values) == 0 {
+			return nil, false
+		}
+
+		return []string{strconv.Itoa(int(values[0]))}, true
+	case syncKindDate:
+		s, ok := value.(string)
+		if ok == false {
+			return nil, false
+		}
+
+		t, err := time.Parse(exifTimestampLayout, strings.TrimSpace(s))
+		if err != nil {
+			return nil, false
+		}
+
+		return []string{t.Format(xmpTimestampLayout)}, true
+	}
+
+	s, ok := value.(string)
+	if ok == false || strings.TrimSpace(s) == "" {
+		return nil, false
+	}
+
+	if m.kind == syncKindSeq {
+		items = make([]string, 0)
+		for _, item := range strings.Split(s, ";") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+
+		return items, true
+	}
+
+	return []string{strings.TrimSpace(s)}, true
+}
+
+// canonicalXmpValue normalizes an XMP value (as returned by
+// ParseXmpProperties) for comparison.
+func canonicalXmpValue(value string, kind syncKind) string {
+	if kind == syncKindDate {
+		if t, ok := parseXmpTimestamp(value); ok == true {
+			return t.Format(xmpTimestampLayout)
+		}
+	}
+
+	return strings.TrimSpace(value)
+}
+
+// formatXmpCoordinate renders decimal degrees as XMP's "DDD,MM.mmk".
+func formatXmpCoordinate(degrees float64, positiveRef, negativeRef byte) string {
+	ref := positiveRef
+	if degrees < 0 {
+		ref = negativeRef
+	}
+
+	degrees = math.Abs(degrees)
+	whole := math.Floor(degrees)
+
+	return fmt.Sprintf("%d,%.8f%c", int(whole), (degrees - whole) * 60, ref)
+}
+
+// parseXmpCoordinate parses XMP's "DDD,MM,SSk" or "DDD,MM.mmk".
+func parseXmpCoordinate(s string) (degrees float64, ok bool) {
+	s = strings.TrimSpace(s)
+	if len(s) < 2 {
+		return 0, false
+	}
+
+	ref := s[len(s) - 1]
+	parts := strings.Split(s[:len(s) - 1], ",")
+
+	if len(parts) != 2 && len(parts) != 3 {
+		return 0, false
+	}
+
+	for i, part := range parts {
+		n, err := strconv.ParseFloat(part, 64)
+		if err != nil {
+			return 0, false
+		}
+
+		degrees += n / math.Pow(60, float64(i))
+	}
+
+	switch ref {
+	case 'N', 'E':
+		return degrees, true
+	case 'S', 'W':
+		return -degrees, true
+	}
+
+	return 0, false
+}
+
+// parseXmpAltitude returns the altitude from the XMP properties (NaN if not
+// present).
+func parseXmpAltitude(xmpValues map[XmpPropertyName]string) float64 {
+	value, found := xmpValues[xmpGpsAltitude]
+	if found == false {
+		return math.NaN()
+	}
+
+	parts := strings.SplitN(strings.TrimSpace(value), "/", 2)
+
+	altitude, err := strconv.ParseFloat(parts[0], 64)
+	if err != nil {
+		return math.NaN()
+	}
+
+	if len(parts) == 2 {
+		denominator, err := strconv.ParseFloat(parts[1], 64)
+		if err != nil || denominator == 0 {
+			return math.NaN()
+		}
+
+		altitude /= denominator
+	}
+
+	if strings.TrimSpace(xmpValues[xmpGpsAltitudeRef]) == "1" {
+		altitude = -altitude
+	}
+
+	return altitude
+}
+
+// gpsAgrees indicates whether two positions are the same.
+func gpsAgrees(latitude1, longitude1, altitude1, latitude2, longitude2, altitude2 float64) bool {
+	if math.Abs(latitude1 - latitude2) > syncDegreesTolerance || math.Abs(longitude1 - longitude2) > syncDegreesTolerance {
+		return false
+	}
+
+	if math.IsNaN(altitude1) == true || math.IsNaN(altitude2) == true {
+		return math.IsNaN(altitude1) == math.IsNaN(altitude2)
+	}
+
+	return math.Abs(altitude1 - altitude2) <= syncAltitudeTolerance
+}
+
+// encodeXmpDescription renders the properties as an rdf:Description.
+func encodeXmpDescription(updates []xmpUpdate) []byte {
+	namespaces := make([]string, 0)
+	seen := make(map[string]bool)
+
+	for _, u := range updates {
+		if seen[u.name.Namespace] == false {
+			seen[u.name.Namespace] = true
+			namespaces = append(namespaces, u.name.Namespace)
+		}
+	}
+
+	sort.Strings(namespaces)
+
+	escape := func(s string) string {
+		b := new(bytes.Buffer)
+
+		err := xml.EscapeText(b, []byte(s))
+		log.PanicIf(err)
+
+		return b.String()
+	}
+
+	b := new(bytes.Buffer)
+	fmt.Fprintf(b, `<rdf:Description rdf:about="" xmlns:rdf="%s"`, rdfNamespace)
+
+	for _, namespace := range namespaces {
+		fmt.Fprintf(b, ` xmlns:%s="%s"`, xmpSyncPrefixes[namespace], namespace)
+	}
+
+	b.WriteString(">\n")
+
+	for _, u := range updates {
+		qualifiedName := xmpSyncPrefixes[u.name.Namespace] + ":" + u.name.Name
+
+		switch u.kind {
+		case syncKindSeq:
+			fmt.Fprintf(b, "<%s><rdf:Seq>", qualifiedName)
+			for _, item := range u.items {
+				fmt.Fprintf(b, "<rdf:li>%s</rdf:li>", escape(item))
+			}
+
+			fmt.Fprintf(b, "</rdf:Seq></%s>\n", qualifiedName)
+		case syncKindAlt:
+			fmt.Fprintf(b, "<%s><rdf:Alt><rdf:li xml:lang=\"x-default\">%s</rdf:li></rdf:Alt></%s>\n", qualifiedName, escape(u.items[0]), qualifiedName)
+		default:
+			fmt.Fprintf(b, "<%s>%s</%s>\n", qualifiedName, escape(u.items[0]), qualifiedName)
+		}
+	}
+
+	b.WriteString("</rdf:Description>\n")
+
+	return b.Bytes()
+}
+
+// syncExifToXmp rewrites the XMP properties that disagree with the EXIF.
+func (sl *SegmentList) syncExifToXmp(ed *ExifDocument, packet []byte, xmpValues map[XmpPropertyName]string, fields SyncFields) int {
+	updates := make([]xmpUpdate, 0)
+	removals := make([]XmpPropertyName, 0)
+
+	for _, m := range syncMappings {
+		if fields & m.fields == 0 {
+			continue
+		}
+
+		items, found := exifSyncItems(ed, m)
+		if found == false {
+			continue
+		}
+
+		if existing, found := xmpValues[m.xmpName]; found == true && canonicalXmpValue(existing, m.kind) == strings.Join(items, ", ") {
+			continue
+		}
+
+		updates = append(updates, xmpUpdate{m.xmpName, m.kind, items})
+		removals = append(removals, m.xmpName)
+	}
+
+	if fields & SyncGps != 0 {
+		gi, err := sl.GpsInfo()
+		if err == nil {
+			xmpLatitude, latitudeFound := parseXmpCoordinate(xmpValues[xmpGpsLatitude])
+			xmpLongitude, longitudeFound := parseXmpCoordinate(xmpValues[xmpGpsLongitude])
+			xmpAltitude := parseXmpAltitude(xmpValues)
+
+			if latitudeFound == false || longitudeFound == false || gpsAgrees(gi.Latitude, gi.Longitude, gi.Altitude, xmpLatitude, xmpLongitude, xmpAltitude) == false {
+				updates = append(updates, xmpUpdate{xmpGpsLatitude, syncKindText, []string{formatXmpCoordinate(gi.Latitude, 'N', 'S')}})
+				updates = append(updates, xmpUpdate{xmpGpsLongitude, syncKindText, []string{formatXmpCoordinate(gi.Longitude, 'E', 'W')}})
+
+				if math.IsNaN(gi.Altitude) == false {
+					altitudeRef := "0"
+					if gi.Altitude < 0 {
+						altitudeRef = "1"
+					}
+
+					altitude := fmt.Sprintf("%d/%d", int64(math.Round(math.Abs(gi.Altitude) * gpsAltitudeDenominator)), gpsAltitudeDenominator)
+
+					updates = append(updates, xmpUpdate{xmpGpsAltitude, syncKindText, []string{altitude}})
+					updates = append(updates, xmpUpdate{xmpGpsAltitudeRef, syncKindText, []string{altitudeRef}})
+				}
+
+				removals = append(removals, xmpGpsLatitude, xmpGpsLongitude, xmpGpsAltitude, xmpGpsAltitudeRef)
+			}
+		} else if log.Is(err, ErrExifTagNotFound) == false {
+			log.Panic(err)
+		}
+	}
+
+	if len(updates) == 0 {
+		return 0
+	}
+
+	if _, found := xmpValues[XmpPropertyName{xmpNoteNamespace, "HasExtendedXMP"}]; found == true {
+		log.Panicf("XMP with an extended packet can not be updated")
+	}
+
+	if packet == nil {
+		packet = emptyXmpPacket
+	}
+
+	body, err := RemoveXmpProperties(unwrapXmpPacket(packet), removals)
+	log.PanicIf(err)
+
+	locations := rdfCloseRegex.FindAllIndex(body, -1)
+	if locations == nil {
+		log.Panicf("XMP packet has no rdf:RDF element")
+	}
+
+	at := locations[len(locations) - 1][0]
+
+	updated := make([]byte, 0, len(body) + 1024)
+	updated = append(updated, body[:at]...)
+	updated = append(updated, encodeXmpDescription(updates)...)
+	updated = append(updated, body[at:]...)
+
+	err = sl.SetXmp(updated)
+	log.PanicIf(err)
+
+	return len(updates)
+}
+
+// syncXmpToExif sets the EXIF tags that disagree with the XMP.
+func (sl *SegmentList) syncXmpToExif(ed *ExifDocument, xmpValues map[XmpPropertyName]string, fields SyncFields) int {
+	if ed == nil {
+		ed = NewExifDocument(binary.BigEndian)
+	}
+
+	changed := 0
+
+	for _, m := range syncMappings {
+		if fields & m.fields == 0 {
+			continue
+		}
+
+		value, found := xmpValues[m.xmpName]
+		if found == false {
+			continue
+		}
+
+		value = canonicalXmpValue(value, m.kind)
+
+		if items, found := exifSyncItems(ed, m); found == true && strings.Join(items, ", ") == value {
+			continue
+		}
+
+		switch m.kind {
+		case syncKindDate:
+			t, ok := parseXmpTimestamp(value)
+			if ok == false {
+				continue
+			}
+
+			err := ed.SetValue(m.ifdName, m.tagId, EXIF_TYPE_ASCII, t.Format(exifTimestampLayout))
+			log.PanicIf(err)
+		case syncKindShort:
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 || n > math.MaxUint16 {
+				continue
+			}
+
+			err = ed.SetValue(m.ifdName, m.tagId, EXIF_TYPE_SHORT, []uint16{uint16(n)})
+			log.PanicIf(err)
+		case syncKindSeq:
+			err := ed.SetValue(m.ifdName, m.tagId, EXIF_TYPE_ASCII, strings.Replace(value, ", ", "; ", -1))
+			log.PanicIf(err)
+		default:
+			err := ed.SetValue(m.ifdName, m.tagId, EXIF_TYPE_ASCII, value)
+			log.PanicIf(err)
+		}
+
+		changed++
+	}
+
+	if changed > 0 {
+		err := sl.SetExifDocument(ed)
+		log.PanicIf(err)
+	}
+
+	if fields & SyncGps != 0 {
+		latitude, latitudeFound := parseXmpCoordinate(xmpValues[xmpGpsLatitude])
+		longitude, longitudeFound := parseXmpCoordinate(xmpValues[xmpGpsLongitude])
+
+		if latitudeFound == true && longitudeFound == true {
+			altitude := parseXmpAltitude(xmpValues)
+
+			// The XMP position doesn't carry the GPS time, so keep ours.
+			timestamp := time.Time{}
+
+			gi, err := sl.GpsInfo()
+			if err == nil {
+				timestamp = gi.Timestamp
+			} else if log.Is(err, ErrExifTagNotFound) == false && log.Is(err, ErrSegmentNotFound) == false {
+				log.Panic(err)
+			}
+
+			if err != nil || gpsAgrees(gi.Latitude, gi.Longitude, gi.Altitude, latitude, longitude, altitude) == false {
+				err := sl.SetGps(latitude, longitude, altitude, timestamp)
+				log.PanicIf(err)
+
+				changed++
+			}
+		}
+	}
+
+	return changed
+}
+
+// SyncMetadata reconciles the fields that EXIF and XMP both carry (dates,
+// orientation, creator, description, and GPS position) so that they don't
+// contradict each other. Values in the authoritative block overwrite those in
+// the other; fields that the authoritative block doesn't have are left alone.
+// The missing block is created if necessary. The number of tags or properties
+// written is returned (zero if everything already agreed).
+//
+// Only the standard XMP packet is read, and a packet that refers to Extended
+// XMP can't be updated.
+func (sl *SegmentList) SyncMetadata(direction SyncDirection, fields SyncFields) (changed int, err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	ed, err := sl.ExifDocument()
+	if err != nil {
+		if log.Is(err, ErrSegmentNotFound) == false {
+			log.Panic(err)
+		}
+
+		ed = nil
+	}
+
+	packet, err := sl.XmpData()
+	if err != nil {
+		if log.Is(err, ErrSegmentNotFound) == false {
+			log.Panic(err)
+		}
+
+		packet = nil
+	}
+
+	xmpValues := make(map[XmpPropertyName]string)
+
+	if packet != nil {
+		properties, err := ParseXmpProperties(packet)
+		log.PanicIf(err)
+
+		for _, xp := range properties {
+			name := XmpPropertyName{xp.Namespace, xp.Name}
+			if _, found := xmpValues[name]; found == false {
+				xmpValues[name] = xp.Value
+			}
+		}
+	}
+
+	if direction == SyncXmpToExif {
+		changed = sl.syncXmpToExif(ed, xmpValues, fields)
+	} else if ed != nil {
+		changed = sl.syncExifToXmp(ed, packet, xmpValues, fields)
+	}
+
+	return changed, nil
+}
@@ -0,0 +1,140 @@
+package jpegstructure
+
+import (
+	"math"
+	"path"
+	"testing"
+
+	"github.com/dsoprea/go-logging"
+)
+
+func getSyncTestXmpValues(sl SegmentList) map[XmpPropertyName]string {
+	packet, err := sl.XmpData()
+	log.PanicIf(err)
+
+	properties, err := ParseXmpProperties(packet)
+	log.PanicIf(err)
+
+	values := make(map[XmpPropertyName]string)
+	for _, xp := range properties {
+		values[XmpPropertyName{xp.Namespace, xp.Name}] = xp.Value
+	}
+
+	return values
+}
+
+func TestSegmentList_SyncMetadata_ExifToXmp(t *testing.T) {
+	filepath := path.Join(assetsPath, "20180428_212314.jpg")
+
+	sl, err := ParseFileStructure(filepath)
+	log.PanicIf(err)
+
+	changed, err := sl.SyncMetadata(SyncExifToXmp, SyncAll)
+	log.PanicIf(err)
+
+	if changed == 0 {
+		t.Fatalf("Expected changes.")
+	}
+
+	values := getSyncTestXmpValues(sl)
+
+	if values[XmpPropertyName{xmpPhotoshopNamespace, "DateCreated"}] != "2018-04-28T21:23:14" {
+		t.Fatalf("DateCreated not correct: %v", values)
+	}
+
+	gi, err := sl.GpsInfo()
+	log.PanicIf(err)
+
+	latitude, ok := parseXmpCoordinate(values[xmpGpsLatitude])
+	if ok == false || math.Abs(latitude - gi.Latitude) > syncDegreesTolerance {
+		t.Fatalf("GPSLatitude not correct: [%s]", values[xmpGpsLatitude])
+	}
+
+	// Everything agrees now.
+	changed, err = sl.SyncMetadata(SyncExifToXmp, SyncAll)
+	log.PanicIf(err)
+
+	if changed != 0 {
+		t.Fatalf("Expected no changes: (%d)", changed)
+	}
+
+	changed, err = sl.SyncMetadata(SyncXmpToExif, SyncAll)
+	log.PanicIf(err)
+
+	if changed != 0 {
+		t.Fatalf("Expected no changes in the other direction: (%d)", changed)
+	}
+}
+
+func TestSegmentList_SyncMetadata_XmpToExif(t *testing.T) {
+	filepath := path.Join(assetsPath, testImageRelFilepath)
+
+	sl, err := ParseFileStructure(filepath)
+	log.PanicIf(err)
+
+	packet := []byte(`<x:xmpmeta xmlns:x="adobe:ns:meta/">
+<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">
+<rdf:Description rdf:about=""
+ xmlns:xmp="http://ns.adobe.com/xap/1.0/"
+ xmlns:tiff="http://ns.adobe.com/tiff/1.0/"
+ xmlns:exif="http://ns.adobe.com/exif/1.0/"
+ xmlns:dc="http://purl.org/dc/elements/1.1/"
+ xmp:ModifyDate="2019-01-02T03:04:05+02:00"
+ tiff:Orientation="6"
+ exif:GPSLatitude="37,46.5N"
+ exif:GPSLongitude="122,25.2W">
+<dc:creator><rdf:Seq><rdf:li>Alice</rdf:li><rdf:li>Bob</rdf:li></rdf:Seq></dc:creator>
+<dc:description><rdf:Alt><rdf:li xml:lang="x-default">A &amp; B</rdf:li></rdf:Alt></dc:description>
+</rdf:Description>
+</rdf:RDF>
+</x:xmpmeta>`)
+
+	err = sl.SetXmp(packet)
+	log.PanicIf(err)
+
+	// Only the orientation is selected at first.
+	changed, err := sl.SyncMetadata(SyncXmpToExif, SyncOrientation)
+	log.PanicIf(err)
+
+	if changed != 1 {
+		t.Fatalf("Expected one change: (%d)", changed)
+	}
+
+	orientation, err := sl.Orientation()
+	log.PanicIf(err)
+
+	if orientation != ORIENTATION_RIGHT_TOP {
+		t.Fatalf("Orientation not correct: (%d)", orientation)
+	} else if getExifString(sl, EXIF_IFD_ROOT, 0x0132) == "2019:01:02 03:04:05" {
+		t.Fatalf("DateTime not expected to change.")
+	}
+
+	changed, err = sl.SyncMetadata(SyncXmpToExif, SyncAll)
+	log.PanicIf(err)
+
+	if changed != 4 {
+		t.Fatalf("Expected four changes: (%d)", changed)
+	}
+
+	if value := getExifString(sl, EXIF_IFD_ROOT, 0x0132); value != "2019:01:02 03:04:05" {
+		t.Fatalf("DateTime not correct: [%s]", value)
+	} else if value := getExifString(sl, EXIF_IFD_ROOT, exifTagArtist); value != "Alice; Bob" {
+		t.Fatalf("Artist not correct: [%s]", value)
+	} else if value := getExifString(sl, EXIF_IFD_ROOT, exifTagImageDescription); value != "A & B" {
+		t.Fatalf("ImageDescription not correct: [%s]", value)
+	}
+
+	gi, err := sl.GpsInfo()
+	log.PanicIf(err)
+
+	if math.Abs(gi.Latitude - 37.775) > syncDegreesTolerance || math.Abs(gi.Longitude + 122.42) > syncDegreesTolerance {
+		t.Fatalf("GPS not correct: %s", gi)
+	}
+
+	changed, err = sl.SyncMetadata(SyncXmpToExif, SyncAll)
+	log.PanicIf(err)
+
+	if changed != 0 {
+		t.Fatalf("Expected no changes: (%d)", changed)
+	}
+}
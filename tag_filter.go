@@ -0,0 +1,81 @@
+package jpegstructure
+
+import (
+	"strings"
+)
+
+// TagFilter selects metadata tags by name or group, in the manner of
+// exiftool's "-TAG" and "--TAG" arguments. Each pattern is one of "Name"
+// (the tag in any group), "Group:Name", "Group:all" (every tag in the group),
+// or "all". "*" may be used in place of "all". Matching is case-insensitive.
+type TagFilter struct {
+	// Include selects the tags to keep. If empty, every tag is kept.
+	Include []string
+
+	// Exclude removes tags, even if they were included.
+	Exclude []string
+}
+
+// IsEmpty indicates whether the filter keeps every tag.
+func (tf TagFilter) IsEmpty() bool {
+	return len(tf.Include) == 0 && len(tf.Exclude) == 0
+}
+
+// xmpTagGroup returns the group of the XMP properties with the namespace
+// prefix, named as exiftool names it (e.g. "XMP-dc").
+func xmpTagGroup(prefix string) string {
+	return "XMP-" + prefix
+}
+
+// tagPatternMatches indicates whether the pattern selects the tag in any of
+// the groups.
+func tagPatternMatches(pattern string, groups []string, name string) bool {
+	isAll := func(s string) bool {
+		return s == "*" || strings.EqualFold(s, "all") == true
+	}
+
+	patternGroup := ""
+	patternName := pattern
+
+	if i := strings.Index(pattern, ":"); i != -1 {
+		patternGroup = pattern[:i]
+		patternName = pattern[i + 1:]
+	}
+
+	if isAll(patternName) == false && strings.EqualFold(patternName, name) == false {
+		return false
+	} else if patternGroup == "" || isAll(patternGroup) == true {
+		return true
+	}
+
+	for _, group := range groups {
+		if strings.EqualFold(patternGroup, group) == true {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Matches indicates whether the filter keeps the tag. A tag may belong to
+// more than one group (e.g. "EXIF" and the name of its IFD); a group pattern
+// matches if it names any of them.
+func (tf TagFilter) Matches(name string, groups ...string) bool {
+	for _, pattern := range tf.Exclude {
+		if tagPatternMatches(pattern, groups, name) == true {
+			return false
+		}
+	}
+
+	if len(tf.Include) == 0 {
+		return true
+	}
+
+	for _, pattern := range tf.Include {
+		if tagPatternMatches(pattern, groups, name) == true {
+			return true
+		}
+	}
+
+	return false
+}
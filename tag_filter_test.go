@@ -0,0 +1,52 @@
+package jpegstructure
+
+import (
+	"testing"
+)
+
+func TestTagFilter_Matches(t *testing.T) {
+	tf := TagFilter{
+		Include: []string{"EXIF:Make", "gps:all", "Rating"},
+		Exclude: []string{"GPS:GPSVersionID"},
+	}
+
+	cases := []struct {
+		name string
+		groups []string
+		expected bool
+	}{
+		{"Make", []string{"EXIF", "IFD0"}, true},
+		{"make", []string{"EXIF", "IFD0"}, true},
+		{"Make", []string{"XMP"}, false},
+		{"Model", []string{"EXIF", "IFD0"}, false},
+		{"GPSLatitude", []string{"EXIF", "GPS"}, true},
+		{"GPSVersionID", []string{"EXIF", "GPS"}, false},
+		{"Rating", []string{"XMP", "XMP-xmp"}, true},
+	}
+
+	for _, c := range cases {
+		if tf.Matches(c.name, c.groups...) != c.expected {
+			t.Fatalf("Match of [%s] %v not correct: expected (%v)", c.name, c.groups, c.expected)
+		}
+	}
+}
+
+func TestTagFilter_Matches_Empty(t *testing.T) {
+	tf := TagFilter{}
+
+	if tf.IsEmpty() == false {
+		t.Fatalf("Filter should be empty.")
+	} else if tf.Matches("Make", "EXIF") == false {
+		t.Fatalf("Empty filter should match everything.")
+	}
+
+	tf = TagFilter{
+		Exclude: []string{"XMP:*"},
+	}
+
+	if tf.Matches("Rating", "XMP") == true {
+		t.Fatalf("Excluded group matched.")
+	} else if tf.Matches("Make", "EXIF") == false {
+		t.Fatalf("Unexcluded group not matched.")
+	}
+}
@@ -0,0 +1,142 @@
+package jpegstructure
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dsoprea/go-logging"
+)
+
+const (
+	exifTimestampLayout = "2006:01:02 15:04:05"
+)
+
+// exifTimestampTag is a date/time tag and the tag that holds its fractional
+// seconds.
+type exifTimestampTag struct {
+	ifdName string
+	tagId uint16
+	subSecTagId uint16
+}
+
+var (
+	exifTimestampTags = []exifTimestampTag{
+		{EXIF_IFD_ROOT, 0x0132, 0x9290},
+		{EXIF_IFD_EXIF, 0x9003, 0x9291},
+		{EXIF_IFD_EXIF, 0x9004, 0x9292},
+	}
+)
+
+// parseSubSec returns the fractional seconds represented by the digits of a
+// SubSecTime tag.
+func parseSubSec(digits string) (time.Duration, bool) {
+	digits = strings.TrimSpace(digits)
+	if digits == "" || len(digits) > 9 {
+		return 0, false
+	}
+
+	n, err := strconv.Atoi(digits)
+	if err != nil {
+		return 0, false
+	}
+
+	for i := len(digits); i < 9; i++ {
+		n *= 10
+	}
+
+	return time.Duration(n), true
+}
+
+// formatSubSec renders the fractional seconds with the given number of
+// digits.
+func formatSubSec(fraction time.Duration, digitCount int) string {
+	n := int(fraction)
+	for i := digitCount; i < 9; i++ {
+		n /= 10
+	}
+
+	return fmt.Sprintf("%0*d", digitCount, n)
+}
+
+// ShiftTimestamps moves DateTime, DateTimeOriginal, and DateTimeDigitized by
+// the given duration (e.g. to correct a camera clock that was off). The
+// SubSecTime tags are adjusted with them when present (one is added if the
+// shift has a fractional part). The OffsetTime tags are kept, since the
+// corrected times are in the same zone. Tags that are missing or that don't
+// hold a valid time are skipped. The number of tags changed is returned.
+func (sl *SegmentList) ShiftTimestamps(d time.Duration) (shifted int, err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	ed, err := sl.ExifDocument()
+	log.PanicIf(err)
+
+	for _, ett := range exifTimestampTags {
+		ee, err := ed.Entry(ett.ifdName, ett.tagId)
+		if err != nil {
+			continue
+		}
+
+		value, err := ed.Value(ee)
+		if err != nil {
+			continue
+		}
+
+		text, ok := value.(string)
+		if ok == false {
+			continue
+		}
+
+		t, err := time.Parse(exifTimestampLayout, strings.TrimSpace(text))
+		if err != nil {
+			continue
+		}
+
+		digitCount := 0
+
+		subSecEntry, err := ed.Entry(EXIF_IFD_EXIF, ett.subSecTagId)
+		if err == nil {
+			subSecValue, err := ed.Value(subSecEntry)
+			if err == nil {
+				if digits, ok := subSecValue.(string); ok == true {
+					if fraction, ok := parseSubSec(digits); ok == true {
+						t = t.Add(fraction)
+						digitCount = len(strings.TrimSpace(digits))
+					}
+				}
+			}
+		}
+
+		t = t.Add(d)
+
+		err = ed.SetValue(ett.ifdName, ett.tagId, EXIF_TYPE_ASCII, t.Format(exifTimestampLayout))
+		log.PanicIf(err)
+
+		fraction := time.Duration(t.Nanosecond())
+
+		if digitCount == 0 && fraction != 0 {
+			// Record the fraction introduced by the shift (to millisecond
+			// precision).
+			digitCount = 3
+		}
+
+		if digitCount > 0 {
+			err = ed.SetValue(EXIF_IFD_EXIF, ett.subSecTagId, EXIF_TYPE_ASCII, formatSubSec(fraction, digitCount))
+			log.PanicIf(err)
+		}
+
+		shifted++
+	}
+
+	if shifted > 0 {
+		err = sl.SetExifDocument(ed)
+		log.PanicIf(err)
+	}
+
+	return shifted, nil
+}
@@ -0,0 +1,81 @@
+package jpegstructure
+
+import (
+	"path"
+	"testing"
+	"time"
+
+	"github.com/dsoprea/go-logging"
+)
+
+func getExifString(sl SegmentList, ifdName string, tagId uint16) string {
+	ed, err := sl.ExifDocument()
+	log.PanicIf(err)
+
+	ee, err := ed.Entry(ifdName, tagId)
+	log.PanicIf(err)
+
+	value, err := ed.Value(ee)
+	log.PanicIf(err)
+
+	return value.(string)
+}
+
+func TestSegmentList_ShiftTimestamps(t *testing.T) {
+	filepath := path.Join(assetsPath, testImageRelFilepath)
+
+	sl, err := ParseFileStructure(filepath)
+	log.PanicIf(err)
+
+	before := make(map[uint16]time.Time)
+	for _, ett := range exifTimestampTags {
+		t, err := time.Parse(exifTimestampLayout, getExifString(sl, ett.ifdName, ett.tagId))
+		log.PanicIf(err)
+
+		before[ett.tagId] = t
+	}
+
+	shifted, err := sl.ShiftTimestamps(-90 * time.Minute)
+	log.PanicIf(err)
+
+	if shifted != 3 {
+		t.Fatalf("Shifted count not correct: (%d)", shifted)
+	}
+
+	for _, ett := range exifTimestampTags {
+		after, err := time.Parse(exifTimestampLayout, getExifString(sl, ett.ifdName, ett.tagId))
+		log.PanicIf(err)
+
+		if after.Sub(before[ett.tagId]) != -90 * time.Minute {
+			t.Fatalf("Tag (0x%04x) not shifted: [%s] -> [%s]", ett.tagId, before[ett.tagId], after)
+		}
+	}
+}
+
+func TestSegmentList_ShiftTimestamps_SubSec(t *testing.T) {
+	filepath := path.Join(assetsPath, testImageRelFilepath)
+
+	sl, err := ParseFileStructure(filepath)
+	log.PanicIf(err)
+
+	ed, err := sl.ExifDocument()
+	log.PanicIf(err)
+
+	err = ed.SetValue(EXIF_IFD_EXIF, 0x9003, EXIF_TYPE_ASCII, "2019:12:31 23:59:59")
+	log.PanicIf(err)
+
+	err = ed.SetValue(EXIF_IFD_EXIF, 0x9291, EXIF_TYPE_ASCII, "75")
+	log.PanicIf(err)
+
+	err = sl.SetExifDocument(ed)
+	log.PanicIf(err)
+
+	_, err = sl.ShiftTimestamps(500 * time.Millisecond)
+	log.PanicIf(err)
+
+	if value := getExifString(sl, EXIF_IFD_EXIF, 0x9003); value != "2020:01:01 00:00:00" {
+		t.Fatalf("DateTimeOriginal not correct: [%s]", value)
+	} else if value := getExifString(sl, EXIF_IFD_EXIF, 0x9291); value != "25" {
+		t.Fatalf("SubSecTimeOriginal not correct: [%s]", value)
+	}
+}
@@ -0,0 +1,350 @@
+package jpegstructure
+
+import (
+	"fmt"
+
+	"github.com/dsoprea/go-logging"
+)
+
+// Transform is a lossless rearrangement of the image.
+type Transform int
+
+const (
+	TransformNone Transform = iota
+	TransformFlipHorizontal
+	TransformFlipVertical
+
+	// TransformTranspose mirrors across the top-left to bottom-right
+	// diagonal.
+	TransformTranspose
+
+	// TransformRotate90 rotates clockwise.
+	TransformRotate90
+	TransformRotate180
+	TransformRotate270
+
+	// TransformTransverse mirrors across the top-right to bottom-left
+	// diagonal.
+	TransformTransverse
+)
+
+var (
+	transformNames = map[Transform]string{
+		TransformNone: "none",
+		TransformFlipHorizontal: "flip-horizontal",
+		TransformFlipVertical: "flip-vertical",
+		TransformTranspose: "transpose",
+		TransformRotate90: "rotate-90",
+		TransformRotate180: "rotate-180",
+		TransformRotate270: "rotate-270",
+		TransformTransverse: "transverse",
+	}
+)
+
+func (t Transform) String() string {
+	name, found := transformNames[t]
+	if found == false {
+		return fmt.Sprintf("Transform(%d)", int(t))
+	}
+
+	return name
+}
+
+// steps decomposes the transform into an optional transpose followed by
+// optional flips (in the transposed orientation).
+func (t Transform) steps() (transpose, flipX, flipY bool) {
+	switch t {
+	case TransformNone:
+		return false, false, false
+	case TransformFlipHorizontal:
+		return false, true, false
+	case TransformFlipVertical:
+		return false, false, true
+	case TransformTranspose:
+		return true, false, false
+	case TransformRotate90:
+		return true, true, false
+	case TransformRotate180:
+		return false, true, true
+	case TransformRotate270:
+		return true, false, true
+	case TransformTransverse:
+		return true, true, true
+	}
+
+	log.Panicf("transform not valid: (%d)", int(t))
+	return false, false, false
+}
+
+// transposeBlock mirrors the coefficients across the diagonal.
+func transposeBlock(block *coefficientBlock) {
+	for v := 0; v < 8; v++ {
+		for u := v + 1; u < 8; u++ {
+			block[v * 8 + u], block[u * 8 + v] = block[u * 8 + v], block[v * 8 + u]
+		}
+	}
+}
+
+// flipBlock mirrors the content of the block by negating the odd horizontal
+// (flipX) or vertical (flipY) frequencies.
+func flipBlock(block *coefficientBlock, flipX, flipY bool) {
+	for v := 0; v < 8; v++ {
+		for u := 0; u < 8; u++ {
+			if (flipX == true && u % 2 == 1) != (flipY == true && v % 2 == 1) {
+				block[v * 8 + u] = -block[v * 8 + u]
+			}
+		}
+	}
+}
+
+// transposeQuantizationTable mirrors the table across the diagonal (the
+// values are in zigzag order).
+func transposeQuantizationTable(qt QuantizationTable) QuantizationTable {
+	var natural [64]uint16
+	for k, i := range zigzagOrder {
+		natural[i] = qt.Values[k]
+	}
+
+	transposed := qt
+	for k, i := range zigzagOrder {
+		transposed.Values[k] = natural[(i % 8) * 8 + i / 8]
+	}
+
+	return transposed
+}
+
+// apply performs the transform on the coefficients. Flipping a dimension
+// that isn't a multiple of the MCU size would move the padding into view, so
+// such a dimension is trimmed to whole MCUs first (like "jpegtran -trim").
+func (ci *coefficientImage) apply(t Transform) {
+	transpose, flipX, flipY := t.steps()
+
+	if transpose == true {
+		for _, cc := range ci.components {
+			transposed := make([]coefficientBlock, len(cc.blocks))
+
+			for y := 0; y < cc.blocksHigh; y++ {
+				for x := 0; x < cc.blocksWide; x++ {
+					block := *cc.block(x, y)
+					transposeBlock(&block)
+
+					transposed[x * cc.blocksHigh + y] = block
+				}
+			}
+
+			cc.blocks = transposed
+			cc.blocksWide, cc.blocksHigh = cc.blocksHigh, cc.blocksWide
+			cc.HorizontalSamplingFactor, cc.VerticalSamplingFactor = cc.VerticalSamplingFactor, cc.HorizontalSamplingFactor
+		}
+
+		ci.width, ci.height = ci.height, ci.width
+	}
+
+	if flipX == false && flipY == false {
+		return
+	}
+
+	mcuWidth, mcuHeight := ci.mcuSize()
+
+	if flipX == true {
+		ci.width -= ci.width % mcuWidth
+	}
+
+	if flipY == true {
+		ci.height -= ci.height % mcuHeight
+	}
+
+	if ci.width == 0 || ci.height == 0 {
+		log.Panicf("image is smaller than one MCU and can't be flipped")
+	}
+
+	mcusAcross, mcusDown := ci.mcuCount()
+
+	for _, cc := range ci.components {
+		blocksWide := mcusAcross * int(cc.HorizontalSamplingFactor)
+		blocksHigh := mcusDown * int(cc.VerticalSamplingFactor)
+
+		flipped := make([]coefficientBlock, blocksWide * blocksHigh)
+
+		for y := 0; y < blocksHigh; y++ {
+			for x := 0; x < blocksWide; x++ {
+				fromX := x
+				if flipX == true {
+					fromX = blocksWide - 1 - x
+				}
+
+				fromY := y
+				if flipY == true {
+					fromY = blocksHigh - 1 - y
+				}
+
+				block := *cc.block(fromX, fromY)
+				flipBlock(&block, flipX, flipY)
+
+				flipped[y * blocksWide + x] = block
+			}
+		}
+
+		cc.blocks = flipped
+		cc.blocksWide = blocksWide
+		cc.blocksHigh = blocksHigh
+	}
+}
+
+// crop keeps the given region. The left and top edges must fall on MCU
+// boundaries.
+func (ci *coefficientImage) crop(x, y, width, height int) {
+	mcuWidth, mcuHeight := ci.mcuSize()
+
+	if x < 0 || y < 0 || width <= 0 || height <= 0 || x + width > ci.width || y + height > ci.height {
+		log.Panicf("crop region not within the image: (%d, %d) (%d x %d)", x, y, width, height)
+	} else if x % mcuWidth != 0 || y % mcuHeight != 0 {
+		log.Panicf("crop origin not aligned to the MCU size (%d x %d): (%d, %d)", mcuWidth, mcuHeight, x, y)
+	}
+
+	ci.width = width
+	ci.height = height
+
+	mcusAcross, mcusDown := ci.mcuCount()
+
+	for _, cc := range ci.components {
+		h := int(cc.HorizontalSamplingFactor)
+		v := int(cc.VerticalSamplingFactor)
+
+		offsetX := x / mcuWidth * h
+		offsetY := y / mcuHeight * v
+
+		blocksWide := mcusAcross * h
+		blocksHigh := mcusDown * v
+
+		cropped := make([]coefficientBlock, blocksWide * blocksHigh)
+
+		for by := 0; by < blocksHigh; by++ {
+			for bx := 0; bx < blocksWide; bx++ {
+				cropped[by * blocksWide + bx] = *cc.block(offsetX + bx, offsetY + by)
+			}
+		}
+
+		cc.blocks = cropped
+		cc.blocksWide = blocksWide
+		cc.blocksHigh = blocksHigh
+	}
+}
+
+// rebuild produces a new structure from the original with the frame header,
+// Huffman tables, and scan replaced by those of the (transformed)
+// coefficients. The image is written as a single scan without restart
+// intervals.
+func (ci *coefficientImage) rebuild(sl SegmentList, transposeTables bool) (rebuilt SegmentList, err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	tables, header, entropyData := ci.encode()
+
+	sof := SofSegment{
+		BitsPerSample: ci.precision,
+		Width: uint16(ci.width),
+		Height: uint16(ci.height),
+	}
+
+	components := make([]SofComponent, len(ci.components))
+	for i, cc := range ci.components {
+		components[i] = cc.SofComponent
+	}
+
+	rebuilt = make(SegmentList, 0, len(sl))
+	scanWritten := false
+
+	for _, s := range sl {
+		switch {
+		case s.MarkerId == MARKER_DHT || s.MarkerId == MARKER_DRI || s.MarkerId == MARKER_DNL || s.MarkerId == 0x0:
+			continue
+		case s.MarkerId == MARKER_DQT && transposeTables == true:
+			quantTables, err := ParseQuantizationTables(s.Data)
+			log.PanicIf(err)
+
+			for i, qt := range quantTables {
+				quantTables[i] = transposeQuantizationTable(qt)
+			}
+
+			s.setData(EncodeQuantizationTables(quantTables))
+		case IsSofMarker(s.MarkerId) == true:
+			s.setData(EncodeSof(sof, components))
+		case s.MarkerId == MARKER_SOS:
+			if scanWritten == true {
+				continue
+			}
+
+			dht := Segment{
+				MarkerId: MARKER_DHT,
+				MarkerName: markerNames[MARKER_DHT],
+				Data: EncodeHuffmanTables(tables),
+			}
+
+			scanData := Segment{
+				MarkerId: 0x0,
+				MarkerName: "!SCANDATA",
+				Data: joinScanData(header.Encode(), entropyData),
+			}
+
+			rebuilt = append(rebuilt, dht, s, scanData)
+			scanWritten = true
+
+			continue
+		}
+
+		rebuilt = append(rebuilt, s)
+	}
+
+	rebuilt.updateOffsets()
+
+	return rebuilt, nil
+}
+
+// Transform rotates or flips the image without re-encoding it (the way
+// jpegtran does) by rearranging the DCT blocks. Only sequential,
+// Huffman-coded images are supported. Metadata segments are carried over
+// unchanged.
+func (sl SegmentList) Transform(t Transform) (transformed SegmentList, err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	transpose, _, _ := t.steps()
+
+	ci, err := decodeCoefficients(sl)
+	log.PanicIf(err)
+
+	ci.apply(t)
+
+	transformed, err = ci.rebuild(sl, transpose)
+	log.PanicIf(err)
+
+	return transformed, nil
+}
+
+// Crop keeps the given region of the image without re-encoding it. The left
+// and top edges must be multiples of the MCU size (8 or 16 pixels, depending
+// on the subsampling).
+func (sl SegmentList) Crop(x, y, width, height int) (cropped SegmentList, err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	ci, err := decodeCoefficients(sl)
+	log.PanicIf(err)
+
+	ci.crop(x, y, width, height)
+
+	cropped, err = ci.rebuild(sl, false)
+	log.PanicIf(err)
+
+	return cropped, nil
+}
@@ -0,0 +1,181 @@
+package jpegstructure
+
+import (
+	"bytes"
+	"image"
+	"path"
+	"testing"
+
+	"image/color"
+	"image/jpeg"
+
+	"github.com/dsoprea/go-logging"
+)
+
+// getTransformTestImage encodes a small image whose dimensions aren't
+// multiples of the MCU size.
+func getTransformTestImage() []byte {
+	img := image.NewRGBA(image.Rect(0, 0, 67, 45))
+	for y := 0; y < 45; y++ {
+		for x := 0; x < 67; x++ {
+			img.Set(x, y, color.RGBA{uint8(x * 3), uint8(y * 5), uint8((x * y) % 256), 0xff})
+		}
+	}
+
+	b := new(bytes.Buffer)
+
+	err := jpeg.Encode(b, img, &jpeg.Options{Quality: 90})
+	log.PanicIf(err)
+
+	return b.Bytes()
+}
+
+func decodeLuma(sl SegmentList) *image.YCbCr {
+	b := new(bytes.Buffer)
+
+	err := sl.Write(b)
+	log.PanicIf(err)
+
+	img, err := jpeg.Decode(b)
+	log.PanicIf(err)
+
+	return img.(*image.YCbCr)
+}
+
+func TestSegmentList_Transform(t *testing.T) {
+	sl, err := ParseBytesStructure(getTransformTestImage())
+	log.PanicIf(err)
+
+	original := decodeLuma(sl)
+
+	for transform := TransformNone; transform <= TransformTransverse; transform++ {
+		transformed, err := sl.Transform(transform)
+		log.PanicIf(err)
+
+		findings, err := transformed.Lint(nil, LintLevelDecoding)
+		log.PanicIf(err)
+
+		if len(findings) != 0 {
+			t.Fatalf("Transform (%s) produced findings: %v", transform, findings)
+		}
+
+		actual := decodeLuma(transformed)
+
+		transpose, flipX, flipY := transform.steps()
+
+		width, height := 67, 45
+		if transpose == true {
+			width, height = height, width
+		}
+
+		// The MCUs are 16x16 and flipped dimensions are trimmed.
+		if flipX == true {
+			width -= width % 16
+		}
+
+		if flipY == true {
+			height -= height % 16
+		}
+
+		bounds := actual.Bounds()
+		if bounds.Dx() != width || bounds.Dy() != height {
+			t.Fatalf("Dimensions for (%s) not correct: (%d)x(%d) != (%d)x(%d)", transform, bounds.Dx(), bounds.Dy(), width, height)
+		}
+
+		totalDifference := 0
+		for y := 0; y < height; y++ {
+			for x := 0; x < width; x++ {
+				px, py := x, y
+				if flipX == true {
+					px = width - 1 - x
+				}
+
+				if flipY == true {
+					py = height - 1 - y
+				}
+
+				if transpose == true {
+					px, py = py, px
+				}
+
+				difference := int(actual.Y[actual.YOffset(x, y)]) - int(original.Y[original.YOffset(px, py)])
+				if difference < 0 {
+					difference = -difference
+				}
+
+				// Only the rounding of the inverse DCT may differ.
+				if difference > 2 {
+					t.Fatalf("Pixel (%d, %d) for (%s) not correct: (%d)", x, y, transform, difference)
+				}
+
+				totalDifference += difference
+			}
+		}
+
+		if transform == TransformNone && totalDifference != 0 {
+			t.Fatalf("Re-encoding without a transform changed the image.")
+		}
+	}
+}
+
+func TestSegmentList_Crop(t *testing.T) {
+	sl, err := ParseBytesStructure(getTransformTestImage())
+	log.PanicIf(err)
+
+	original := decodeLuma(sl)
+
+	cropped, err := sl.Crop(16, 16, 40, 20)
+	log.PanicIf(err)
+
+	actual := decodeLuma(cropped)
+
+	bounds := actual.Bounds()
+	if bounds.Dx() != 40 || bounds.Dy() != 20 {
+		t.Fatalf("Dimensions not correct: (%d)x(%d)", bounds.Dx(), bounds.Dy())
+	}
+
+	for y := 0; y < 20; y++ {
+		for x := 0; x < 40; x++ {
+			if actual.Y[actual.YOffset(x, y)] != original.Y[original.YOffset(x + 16, y + 16)] {
+				t.Fatalf("Pixel (%d, %d) not correct.", x, y)
+			}
+		}
+	}
+
+	_, err = sl.Crop(8, 0, 16, 16)
+	if err == nil {
+		t.Fatalf("Expected error for unaligned crop.")
+	}
+
+	_, err = sl.Crop(0, 0, 100, 16)
+	if err == nil {
+		t.Fatalf("Expected error for crop outside the image.")
+	}
+}
+
+func TestSegmentList_Transform_File(t *testing.T) {
+	filepath := path.Join(assetsPath, testImageRelFilepath)
+
+	sl, err := ParseFileStructure(filepath)
+	log.PanicIf(err)
+
+	transformed, err := sl.Transform(TransformNone)
+	log.PanicIf(err)
+
+	originalExif, err := sl.ExifData()
+	log.PanicIf(err)
+
+	transformedExif, err := transformed.ExifData()
+	log.PanicIf(err)
+
+	if bytes.Equal(originalExif, transformedExif) == false {
+		t.Fatalf("EXIF not carried over.")
+	}
+
+	original := decodeLuma(sl)
+	actual := decodeLuma(transformed)
+
+	if bytes.Equal(original.Y, actual.Y) == false || bytes.Equal(original.Cb, actual.Cb) == false {
+		t.Fatalf("Re-encoded image not identical.")
+	}
+}
@@ -0,0 +1,79 @@
+package jpegstructure
+
+import (
+	"bufio"
+	"os"
+
+	"io/ioutil"
+	"path/filepath"
+
+	"github.com/dsoprea/go-logging"
+)
+
+// UpdateFile parses the image, passes the segments to `mutate`, and replaces
+// the file with the result. The image is written to a temporary file in the
+// same directory, synced, and renamed over the original, so the file is never
+// left partially written. The original's permissions are kept. If `mutate`
+// returns an error, the file isn't touched and the error is returned.
+func UpdateFile(filename string, mutate func(sl *SegmentList) error) (err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	fi, err := os.Stat(filename)
+	log.PanicIf(err)
+
+	sl, err := ParseFileStructure(filename)
+	log.PanicIf(err)
+
+	err = mutate(&sl)
+	log.PanicIf(err)
+
+	directory := filepath.Dir(filename)
+
+	f, err := ioutil.TempFile(directory, "." + filepath.Base(filename) + ".*.tmp")
+	log.PanicIf(err)
+
+	tempFilepath := f.Name()
+	renamed := false
+
+	defer func() {
+		if renamed == false {
+			f.Close()
+			os.Remove(tempFilepath)
+		}
+	}()
+
+	b := bufio.NewWriter(f)
+
+	err = sl.Write(b)
+	log.PanicIf(err)
+
+	err = b.Flush()
+	log.PanicIf(err)
+
+	err = f.Chmod(fi.Mode().Perm())
+	log.PanicIf(err)
+
+	err = f.Sync()
+	log.PanicIf(err)
+
+	err = f.Close()
+	log.PanicIf(err)
+
+	err = os.Rename(tempFilepath, filename)
+	log.PanicIf(err)
+
+	renamed = true
+
+	// Sync the directory so that the rename itself is durable. Not every
+	// platform supports this, so failures are ignored.
+	if d, err := os.Open(directory); err == nil {
+		d.Sync()
+		d.Close()
+	}
+
+	return nil
+}
@@ -0,0 +1,98 @@
+package jpegstructure
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path"
+	"testing"
+
+	"io/ioutil"
+
+	"github.com/dsoprea/go-logging"
+)
+
+func getUpdateTestFilepath() (tempPath, filepath string) {
+	tempPath, err := ioutil.TempDir("", "jpegstructure")
+	log.PanicIf(err)
+
+	data, err := ioutil.ReadFile(path.Join(assetsPath, testImageRelFilepath))
+	log.PanicIf(err)
+
+	filepath = path.Join(tempPath, "image.jpg")
+
+	err = ioutil.WriteFile(filepath, data, 0640)
+	log.PanicIf(err)
+
+	return tempPath, filepath
+}
+
+func TestUpdateFile(t *testing.T) {
+	tempPath, filepath := getUpdateTestFilepath()
+	defer os.RemoveAll(tempPath)
+
+	comment := []byte("updated in place")
+
+	err := UpdateFile(filepath, func(sl *SegmentList) error {
+		*sl = append((*sl)[:1], append(SegmentList{{MarkerId: MARKER_COM, Data: comment}}, (*sl)[1:]...)...)
+		return nil
+	})
+
+	log.PanicIf(err)
+
+	sl, err := ParseFileStructure(filepath)
+	log.PanicIf(err)
+
+	if sl[1].MarkerId != MARKER_COM || bytes.Equal(sl[1].Data, comment) == false {
+		t.Fatalf("Update not written: %s", sl[1].MarkerName)
+	}
+
+	fi, err := os.Stat(filepath)
+	log.PanicIf(err)
+
+	if fi.Mode().Perm() != 0640 {
+		t.Fatalf("Permissions not kept: %o", fi.Mode().Perm())
+	}
+
+	files, err := ioutil.ReadDir(tempPath)
+	log.PanicIf(err)
+
+	if len(files) != 1 {
+		t.Fatalf("Temporary file left behind: (%d) files", len(files))
+	}
+}
+
+func TestUpdateFile_MutateError(t *testing.T) {
+	tempPath, filepath := getUpdateTestFilepath()
+	defer os.RemoveAll(tempPath)
+
+	original, err := ioutil.ReadFile(filepath)
+	log.PanicIf(err)
+
+	mutateErr := errors.New("mutation failed")
+
+	err = UpdateFile(filepath, func(sl *SegmentList) error {
+		*sl = (*sl)[:2]
+		return mutateErr
+	})
+
+	if err == nil {
+		t.Fatalf("Expected error.")
+	} else if log.Is(err, mutateErr) == false {
+		t.Fatalf("Error not correct: %v", err)
+	}
+
+	data, err := ioutil.ReadFile(filepath)
+	log.PanicIf(err)
+
+	if bytes.Equal(data, original) == false {
+		t.Fatalf("File changed after a failed mutation.")
+	}
+
+	files, err := ioutil.ReadDir(tempPath)
+	log.PanicIf(err)
+
+	if len(files) != 1 {
+		t.Fatalf("Temporary file left behind: (%d) files", len(files))
+	}
+}
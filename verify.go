@@ -0,0 +1,90 @@
+package jpegstructure
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/dsoprea/go-logging"
+)
+
+var (
+	// ErrRoundTripMismatch is returned by VerifyRoundTrip() when the written
+	// image isn't identical to the original.
+	ErrRoundTripMismatch = errors.New("written image does not match the original")
+)
+
+// RoundTripMismatch describes the first difference between an image and the
+// image written from its segments.
+type RoundTripMismatch struct {
+	// Offset is the position of the first byte that differs (or of the end of
+	// the shorter image).
+	Offset int
+
+	// SegmentIndex is the segment that was being written at the offset, or -1
+	// if the written image ended before it.
+	SegmentIndex int
+
+	OriginalSize int
+	WrittenSize int
+}
+
+func (rtm RoundTripMismatch) String() string {
+	return fmt.Sprintf("RoundTripMismatch<OFFSET=(0x%08x) SEGMENT=(%d) ORIGINAL-SIZE=(%d) WRITTEN-SIZE=(%d)>", rtm.Offset, rtm.SegmentIndex, rtm.OriginalSize, rtm.WrittenSize)
+}
+
+// VerifyRoundTrip writes the segments and compares the result with the image
+// that they were parsed from. If they differ, the first difference is
+// returned along with ErrRoundTripMismatch. Tools that overwrite images
+// should call this before replacing the original with a modified image, since
+// a mismatch means that something (e.g. fill bytes or data that the parser
+// dropped) wouldn't survive.
+func (sl SegmentList) VerifyRoundTrip(original []byte) (rtm *RoundTripMismatch, err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	b := new(bytes.Buffer)
+
+	// The position of each segment in the written image.
+	starts := make([]int, len(sl))
+
+	for i, s := range sl {
+		starts[i] = b.Len()
+
+		err = s.Write(b)
+		log.PanicIf(err)
+	}
+
+	written := b.Bytes()
+	if bytes.Equal(written, original) == true {
+		return nil, nil
+	}
+
+	offset := 0
+	for offset < len(written) && offset < len(original) && written[offset] == original[offset] {
+		offset++
+	}
+
+	rtm = &RoundTripMismatch{
+		Offset: offset,
+		SegmentIndex: -1,
+		OriginalSize: len(original),
+		WrittenSize: len(written),
+	}
+
+	if offset < len(written) {
+		for i := len(starts) - 1; i >= 0; i-- {
+			if starts[i] <= offset {
+				rtm.SegmentIndex = i
+				break
+			}
+		}
+	}
+
+	jpegLogger.Debugf(nil, "Round-trip mismatch: %s", rtm)
+
+	return rtm, ErrRoundTripMismatch
+}
@@ -0,0 +1,64 @@
+package jpegstructure
+
+import (
+	"path"
+	"testing"
+
+	"io/ioutil"
+
+	"github.com/dsoprea/go-logging"
+)
+
+func TestSegmentList_VerifyRoundTrip(t *testing.T) {
+	filepath := path.Join(assetsPath, testImageRelFilepath)
+
+	data, err := ioutil.ReadFile(filepath)
+	log.PanicIf(err)
+
+	sl, err := ParseBytesStructure(data)
+	log.PanicIf(err)
+
+	rtm, err := sl.VerifyRoundTrip(data)
+	log.PanicIf(err)
+
+	if rtm != nil {
+		t.Fatalf("Unexpected mismatch: %s", rtm)
+	}
+}
+
+func TestSegmentList_VerifyRoundTrip_Mismatch(t *testing.T) {
+	filepath := path.Join(assetsPath, testImageRelFilepath)
+
+	data, err := ioutil.ReadFile(filepath)
+	log.PanicIf(err)
+
+	sl, err := ParseBytesStructure(data)
+	log.PanicIf(err)
+
+	i := sl.Index(MARKER_DQT)
+
+	modified := make([]byte, len(data))
+	copy(modified, data)
+
+	offset := sl[i].Offset + sl[i].HeaderSize + 10
+	modified[offset]++
+
+	rtm, err := sl.VerifyRoundTrip(modified)
+	if err == nil {
+		t.Fatalf("Expected mismatch.")
+	} else if log.Is(err, ErrRoundTripMismatch) == false {
+		log.Panic(err)
+	}
+
+	if rtm.Offset != offset || rtm.SegmentIndex != i {
+		t.Fatalf("Mismatch not correct: %s", rtm)
+	}
+
+	// A truncated original differs at its end.
+	rtm, err = sl.VerifyRoundTrip(data[:100])
+	if log.Is(err, ErrRoundTripMismatch) == false {
+		t.Fatalf("Expected mismatch for truncated image.")
+	} else if rtm.Offset != 100 || rtm.WrittenSize != len(data) {
+		t.Fatalf("Truncation mismatch not correct: %s", rtm)
+	}
+}
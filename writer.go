@@ -0,0 +1,249 @@
+package jpegstructure
+
+import (
+	"errors"
+	"io"
+	"math"
+
+	"encoding/binary"
+
+	"github.com/dsoprea/go-logging"
+)
+
+const (
+	// maxSegmentPayloadSize is the largest payload that a two-byte length can
+	// describe (the length includes its own two bytes).
+	maxSegmentPayloadSize = 0xffff - 2
+)
+
+var (
+	// ErrSegmentTooLarge is returned when a segment's payload doesn't fit in
+	// its two-byte length and can't be split.
+	ErrSegmentTooLarge = errors.New("segment payload too large")
+)
+
+// Write serializes the segment into the stream exactly as it would appear in a
+// file.
+func (s Segment) Write(w io.Writer) (err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	// The scan-data isn't a segment and has no header.
+	if s.MarkerId == 0x0 {
+		_, err = w.Write(s.Data)
+		log.PanicIf(err)
+
+		return nil
+	}
+
+	_, err = w.Write([]byte{0xff, s.MarkerId})
+	log.PanicIf(err)
+
+	sizeLen, found := markerLen[s.MarkerId]
+	if found == false {
+		if len(s.Data) > maxSegmentPayloadSize {
+			jpegLogger.Debugf(nil, "Segment payload too large: MARKER=(0x%02x) SIZE=(%d)", s.MarkerId, len(s.Data))
+			log.Panic(ErrSegmentTooLarge)
+		}
+
+		err = binary.Write(w, binary.BigEndian, uint16(len(s.Data) + 2))
+		log.PanicIf(err)
+	} else if sizeLen == 4 {
+		err = binary.Write(w, binary.BigEndian, uint32(len(s.Data) + 4))
+		log.PanicIf(err)
+	}
+
+	_, err = w.Write(s.Data)
+	log.PanicIf(err)
+
+	return nil
+}
+
+// splitOversizedSegments returns the segments with any ICC profile or
+// standard XMP packet that's too large for one segment split the way their
+// specifications allow (into more ICC chunks, or into Extended XMP). The
+// list is returned as-is if nothing is too large. Other segments that are
+// too large produce ErrSegmentTooLarge.
+func (sl SegmentList) splitOversizedSegments() (split SegmentList, err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	oversizedIcc := false
+	var oversizedXmp []byte
+
+	for i, s := range sl {
+		if len(s.Data) <= maxSegmentPayloadSize {
+			continue
+		} else if _, found := markerLen[s.MarkerId]; found == true {
+			// The scan-data and the markers without a length can be any size.
+			continue
+		}
+
+		switch {
+		case s.MarkerId == MARKER_APP2 && isIccPayload(s.Data) == true:
+			oversizedIcc = true
+		case s.Kind() == SegmentKindXmpApp1 && oversizedXmp == nil:
+			oversizedXmp = s.Data[len(xmpPrefix):]
+		default:
+			jpegLogger.Debugf(nil, "Segment (%d) too large to split: MARKER=(0x%02x) SIZE=(%d)", i, s.MarkerId, len(s.Data))
+			log.Panic(ErrSegmentTooLarge)
+		}
+	}
+
+	if oversizedIcc == false && oversizedXmp == nil {
+		return sl, nil
+	}
+
+	split = make(SegmentList, len(sl))
+	copy(split, sl)
+
+	if oversizedIcc == true {
+		profile, err := split.IccProfile()
+		log.PanicIf(err)
+
+		err = split.SetIccProfile(profile)
+		log.PanicIf(err)
+	}
+
+	if oversizedXmp != nil {
+		err := split.SetXmp(oversizedXmp)
+		log.PanicIf(err)
+	}
+
+	return split, nil
+}
+
+// Write serializes every segment, reproducing the image. An ICC profile or
+// XMP packet that's too large for one segment is split (see SetIccProfile
+// and SetXmp); any other segment that's too large produces
+// ErrSegmentTooLarge before anything is written.
+func (sl SegmentList) Write(w io.Writer) (err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	split, err := sl.splitOversizedSegments()
+	log.PanicIf(err)
+
+	for i, s := range split {
+		err = s.Write(w)
+		if err != nil {
+			if log.Is(err, ErrSegmentTooLarge) == true {
+				log.Panic(err)
+			}
+
+			log.Panicf("could not write segment (%d): %s", i, err.Error())
+		}
+	}
+
+	return nil
+}
+
+// countingWriter tallies the bytes written through it.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countingWriter) Write(p []byte) (n int, err error) {
+	n, err = cw.w.Write(p)
+	cw.n += int64(n)
+
+	return n, err
+}
+
+// WriteTo serializes the image (see Write). It implements io.WriterTo so that
+// the list can be passed straight to io.Copy.
+func (sl SegmentList) WriteTo(w io.Writer) (n int64, err error) {
+	cw := &countingWriter{
+		w: w,
+	}
+
+	err = sl.Write(cw)
+	return cw.n, err
+}
+
+// countingReader tallies the bytes read through it.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (cr *countingReader) Read(p []byte) (n int, err error) {
+	n, err = cr.r.Read(p)
+	cr.n += int64(n)
+
+	return n, err
+}
+
+// ReadFrom parses an image from the stream, replacing the current segments.
+// It implements io.ReaderFrom. The resulting list holds every segment,
+// including the scan-data, so the whole image is buffered in memory. Use
+// RewriteStream() or ChunkScanData() to process an image without holding it.
+func (sl *SegmentList) ReadFrom(r io.Reader) (n int64, err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	cr := &countingReader{
+		r: r,
+	}
+
+	defer func() {
+		n = cr.n
+	}()
+
+	parsed, err := ParseSegments(cr, math.MaxInt32)
+	log.PanicIf(err)
+
+	*sl = parsed
+
+	return cr.n, nil
+}
+
+// segmentHeaderSize returns the number of bytes that precede the payload of
+// a segment with the given marker in the stream.
+func segmentHeaderSize(markerId byte) int {
+	// The scan-data has no header.
+	if markerId == 0x0 {
+		return 0
+	}
+
+	sizeLen, found := markerLen[markerId]
+	if found == false {
+		return 2 + 2
+	}
+
+	return 2 + sizeLen
+}
+
+// updateOffsets recalculates the offsets and extents of the segments as they
+// would be written.
+func (sl SegmentList) updateOffsets() {
+	offset := 0
+	for i, s := range sl {
+		headerSize := segmentHeaderSize(s.MarkerId)
+
+		markerLength := 0
+		if headerSize > 2 {
+			markerLength = headerSize - 2 + len(s.Data)
+		}
+
+		sl[i].Offset = offset
+		sl[i].HeaderSize = headerSize
+		sl[i].MarkerLength = markerLength
+		sl[i].TotalSize = headerSize + len(s.Data)
+
+		offset += sl[i].TotalSize
+	}
+}
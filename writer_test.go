@@ -0,0 +1,206 @@
+package jpegstructure
+
+import (
+	"bytes"
+	"path"
+	"strings"
+	"testing"
+
+	"io/ioutil"
+
+	"github.com/dsoprea/go-logging"
+)
+
+func TestSegmentList_Write(t *testing.T) {
+	filepath := path.Join(assetsPath, testImageRelFilepath)
+
+	data, err := ioutil.ReadFile(filepath)
+	log.PanicIf(err)
+
+	sl, err := ParseBytesStructure(data)
+	log.PanicIf(err)
+
+	b := new(bytes.Buffer)
+
+	err = sl.Write(b)
+	log.PanicIf(err)
+
+	if bytes.Compare(b.Bytes(), data) != 0 {
+		t.Fatalf("Written image does not match the original.")
+	}
+}
+
+func TestSegmentList_WriteTo_ReadFrom(t *testing.T) {
+	filepath := path.Join(assetsPath, testImageRelFilepath)
+
+	data, err := ioutil.ReadFile(filepath)
+	log.PanicIf(err)
+
+	var sl SegmentList
+
+	n, err := sl.ReadFrom(bytes.NewReader(data))
+	log.PanicIf(err)
+
+	if n != int64(len(data)) {
+		t.Fatalf("Bytes read not correct: (%d) != (%d)", n, len(data))
+	} else if len(sl) == 0 || sl[len(sl) - 1].MarkerId != MARKER_EOI {
+		t.Fatalf("Image not parsed.")
+	}
+
+	b := new(bytes.Buffer)
+
+	n, err = sl.WriteTo(b)
+	log.PanicIf(err)
+
+	if n != int64(len(data)) {
+		t.Fatalf("Bytes written not correct: (%d) != (%d)", n, len(data))
+	} else if bytes.Compare(b.Bytes(), data) != 0 {
+		t.Fatalf("Written image does not match the original.")
+	}
+}
+
+func TestSegmentList_StripMetadata(t *testing.T) {
+	filepath := path.Join(assetsPath, "20180428_212314.jpg")
+
+	sl, err := ParseFileStructure(filepath)
+	log.PanicIf(err)
+
+	stripped := sl.StripMetadata(false)
+
+	markers := make([]byte, len(stripped))
+	for i, s := range stripped {
+		markers[i] = s.MarkerId
+	}
+
+	// The JFIF APP0 survives but the EXIF APP1 doesn't.
+	expected := []byte { 0xd8, 0xe0, 0xdb, 0xc0, 0xc4, 0xc4, 0xc4, 0xc4, 0xda, 0x00, 0xd9 }
+	if bytes.Compare(markers, expected) != 0 {
+		t.Fatalf("Stripped markers not correct: %v", DumpBytesToString(markers))
+	}
+
+	b := new(bytes.Buffer)
+
+	err = stripped.Write(b)
+	log.PanicIf(err)
+
+	reparsed, err := ParseBytesStructure(b.Bytes())
+	log.PanicIf(err)
+
+	if len(reparsed) != len(stripped) {
+		t.Fatalf("Reparsed stripped image has wrong number of segments: (%d)", len(reparsed))
+	}
+}
+
+func TestSegmentList_ExifData(t *testing.T) {
+	filepath := path.Join(assetsPath, testImageRelFilepath)
+
+	sl, err := ParseFileStructure(filepath)
+	log.PanicIf(err)
+
+	exifData, err := sl.ExifData()
+	log.PanicIf(err)
+
+	if bytes.HasPrefix(exifData, []byte { 'I', 'I', 0x2a, 0x00 }) == false {
+		t.Fatalf("EXIF data does not start with a TIFF header.")
+	}
+
+	_, err = sl.IccProfile()
+	if err == nil {
+		t.Fatalf("Expected error for missing ICC profile.")
+	} else if log.Is(err, ErrSegmentNotFound) == false {
+		log.Panic(err)
+	}
+}
+
+func TestSegmentList_Write_SegmentTooLarge(t *testing.T) {
+	sl := SegmentList{
+		{MarkerId: MARKER_SOI},
+		{MarkerId: MARKER_COM, Data: make([]byte, maxSegmentPayloadSize + 1)},
+		{MarkerId: MARKER_EOI},
+	}
+
+	b := new(bytes.Buffer)
+
+	err := sl.Write(b)
+	if err == nil {
+		t.Fatalf("Expected error.")
+	} else if log.Is(err, ErrSegmentTooLarge) == false {
+		t.Fatalf("Error not correct: %v", err)
+	} else if b.Len() != 0 {
+		t.Fatalf("Nothing should have been written: (%d)", b.Len())
+	}
+}
+
+func TestSegmentList_Write_SplitsIcc(t *testing.T) {
+	sl, err := ParseBytesStructure(getTransformTestImage())
+	log.PanicIf(err)
+
+	profile, err := StandardIccProfile(STANDARD_PROFILE_SRGB)
+	log.PanicIf(err)
+
+	// Pad the profile (the padding isn't referenced by any tag) so that it
+	// needs three chunks.
+	padded := make([]byte, maxSegmentPayloadSize * 2 + 100)
+	copy(padded, profile)
+
+	payload := append(append([]byte{}, iccPrefix...), 1, 1)
+	payload = append(payload, padded...)
+
+	icc := Segment{
+		MarkerId: MARKER_APP2,
+		MarkerName: "APP2",
+		Data: payload,
+	}
+
+	sl = append(sl[:1], append(SegmentList{icc}, sl[1:]...)...)
+
+	b := new(bytes.Buffer)
+
+	err = sl.Write(b)
+	log.PanicIf(err)
+
+	parsed, err := ParseBytesStructure(b.Bytes())
+	log.PanicIf(err)
+
+	if len(parsed.FindAll(MARKER_APP2)) != 3 {
+		t.Fatalf("Profile not split: (%d)", len(parsed.FindAll(MARKER_APP2)))
+	}
+
+	recovered, err := parsed.IccProfile()
+	log.PanicIf(err)
+
+	if bytes.Equal(recovered, padded) == false {
+		t.Fatalf("Profile not reassembled correctly: (%d) != (%d)", len(recovered), len(padded))
+	}
+}
+
+func TestSegmentList_Write_SplitsXmp(t *testing.T) {
+	sl, err := ParseBytesStructure(getTransformTestImage())
+	log.PanicIf(err)
+
+	description := strings.Repeat("x", maxSegmentPayloadSize)
+	packet := `<x:xmpmeta xmlns:x="adobe:ns:meta/"><rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#"><rdf:Description rdf:about="" xmlns:dc="http://purl.org/dc/elements/1.1/" dc:format="` + description + `"/></rdf:RDF></x:xmpmeta>`
+
+	xmp := Segment{
+		MarkerId: MARKER_APP1,
+		MarkerName: "APP1",
+		Data: append(append([]byte{}, xmpPrefix...), packet...),
+	}
+
+	sl = append(sl[:1], append(SegmentList{xmp}, sl[1:]...)...)
+
+	b := new(bytes.Buffer)
+
+	err = sl.Write(b)
+	log.PanicIf(err)
+
+	parsed, err := ParseBytesStructure(b.Bytes())
+	log.PanicIf(err)
+
+	extended, err := parsed.ExtendedXmpData()
+	log.PanicIf(err)
+
+	if strings.Contains(string(extended), description) == false {
+		t.Fatalf("Extended XMP not written.")
+	}
+}
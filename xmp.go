@@ -0,0 +1,275 @@
+package jpegstructure
+
+import (
+	"bytes"
+	"io"
+	"regexp"
+	"strings"
+
+	"encoding/xml"
+
+	"github.com/dsoprea/go-logging"
+)
+
+const (
+	rdfNamespace = "http://www.w3.org/1999/02/22-rdf-syntax-ns#"
+)
+
+// XmpProperty is one simple (or flattened array) property from an XMP packet.
+type XmpProperty struct {
+	// Namespace is the namespace URI.
+	Namespace string
+
+	// Prefix is the prefix that the packet bound to the namespace.
+	Prefix string
+
+	Name string
+
+	// Value is the text of the property. The items of arrays are joined with
+	// ", ".
+	Value string
+}
+
+// QualifiedName returns "prefix:name".
+func (xp XmpProperty) QualifiedName() string {
+	return xp.Prefix + ":" + xp.Name
+}
+
+// ParseXmpProperties extracts the properties of every rdf:Description in the
+// packet. Both the attribute and element forms are supported. Arrays
+// (rdf:Seq, rdf:Bag, rdf:Alt) are flattened, and nested structures are
+// skipped.
+func ParseXmpProperties(packet []byte) (properties []XmpProperty, err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	decoder := xml.NewDecoder(bytes.NewReader(packet))
+
+	// The decoder resolves namespace URIs but not prefixes, so track the
+	// bindings ourselves.
+	prefixes := make(map[string]string)
+
+	properties = make([]XmpProperty, 0)
+
+	// The property element that we're currently inside of, if any.
+	var current *XmpProperty
+	var items []string
+	depth := 0
+	text := new(bytes.Buffer)
+
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+
+		log.PanicIf(err)
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			for _, attr := range t.Attr {
+				if attr.Name.Space == "xmlns" {
+					prefixes[attr.Value] = attr.Name.Local
+				}
+			}
+
+			if current == nil {
+				if t.Name.Space == rdfNamespace && t.Name.Local == "Description" {
+					for _, attr := range t.Attr {
+						if attr.Name.Space == "xmlns" || attr.Name.Space == rdfNamespace || attr.Name.Space == "" {
+							continue
+						}
+
+						xp := XmpProperty{
+							Namespace: attr.Name.Space,
+							Prefix: prefixes[attr.Name.Space],
+							Name: attr.Name.Local,
+							Value: attr.Value,
+						}
+
+						properties = append(properties, xp)
+					}
+				} else if t.Name.Space != rdfNamespace && t.Name.Space != "adobe:ns:meta/" {
+					current = &XmpProperty{
+						Namespace: t.Name.Space,
+						Prefix: prefixes[t.Name.Space],
+						Name: t.Name.Local,
+					}
+
+					items = nil
+					depth = 0
+					text.Reset()
+				}
+			} else {
+				depth++
+				text.Reset()
+			}
+		case xml.CharData:
+			if current != nil {
+				text.Write(t)
+			}
+		case xml.EndElement:
+			if current == nil {
+				continue
+			}
+
+			if depth > 0 {
+				if t.Name.Space == rdfNamespace && t.Name.Local == "li" {
+					if item := strings.TrimSpace(text.String()); item != "" {
+						items = append(items, item)
+					} else if items == nil {
+						items = make([]string, 0)
+					}
+				}
+
+				depth--
+				continue
+			}
+
+			if items != nil {
+				current.Value = strings.Join(items, ", ")
+				properties = append(properties, *current)
+			} else if value := strings.TrimSpace(text.String()); value != "" {
+				current.Value = value
+				properties = append(properties, *current)
+			}
+
+			current = nil
+		}
+	}
+
+	return properties, nil
+}
+
+// XmpPropertyName identifies a property by namespace URI and local name.
+type XmpPropertyName struct {
+	Namespace string
+	Name string
+}
+
+// xmpSpan is a range of bytes within a packet.
+type xmpSpan struct {
+	start, end int
+}
+
+// RemoveXmpProperties returns a copy of the packet without the given
+// properties, in either the attribute or the element form. Only the removed
+// bytes change; everything else (including formatting and padding) is
+// preserved.
+func RemoveXmpProperties(packet []byte, names []XmpPropertyName) (updated []byte, err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	isTarget := func(space, local string) bool {
+		for _, xpn := range names {
+			if xpn.Namespace == space && xpn.Name == local {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	decoder := xml.NewDecoder(bytes.NewReader(packet))
+	prefixes := make(map[string]string)
+
+	spans := make([]xmpSpan, 0)
+
+	// Depth of elements beneath the current rdf:Description, and the start of
+	// the element being removed (if any).
+	depth := 0
+	inDescription := false
+	removeAt := -1
+
+	for {
+		start := int(decoder.InputOffset())
+
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+
+		log.PanicIf(err)
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			for _, attr := range t.Attr {
+				if attr.Name.Space == "xmlns" {
+					prefixes[attr.Value] = attr.Name.Local
+				}
+			}
+
+			if inDescription == true {
+				depth++
+
+				if depth == 1 && isTarget(t.Name.Space, t.Name.Local) == true {
+					removeAt = start
+				}
+
+				continue
+			}
+
+			if t.Name.Space != rdfNamespace || t.Name.Local != "Description" {
+				continue
+			}
+
+			inDescription = true
+			depth = 0
+
+			end := int(decoder.InputOffset())
+			tag := packet[start:end]
+
+			for _, attr := range t.Attr {
+				if isTarget(attr.Name.Space, attr.Name.Local) == false {
+					continue
+				}
+
+				prefix, found := prefixes[attr.Name.Space]
+				if found == false {
+					continue
+				}
+
+				pattern := regexp.MustCompile(`\s+` + regexp.QuoteMeta(prefix + ":" + attr.Name.Local) + `\s*=\s*("[^"]*"|'[^']*')`)
+
+				location := pattern.FindIndex(tag)
+				if location != nil {
+					spans = append(spans, xmpSpan{start + location[0], start + location[1]})
+				}
+			}
+		case xml.EndElement:
+			if inDescription == false {
+				continue
+			}
+
+			if depth == 0 {
+				inDescription = false
+				continue
+			}
+
+			if depth == 1 && removeAt != -1 {
+				spans = append(spans, xmpSpan{removeAt, int(decoder.InputOffset())})
+				removeAt = -1
+			}
+
+			depth--
+		}
+	}
+
+	updated = make([]byte, 0, len(packet))
+
+	last := 0
+	for _, span := range spans {
+		updated = append(updated, packet[last:span.start]...)
+		last = span.end
+	}
+
+	updated = append(updated, packet[last:]...)
+
+	return updated, nil
+}
@@ -0,0 +1,210 @@
+package jpegstructure
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"crypto/md5"
+	"encoding/binary"
+
+	"github.com/dsoprea/go-logging"
+)
+
+const (
+	xmpNoteNamespace = "http://ns.adobe.com/xmp/note/"
+
+	// xmpPacketPadding is the amount of whitespace written in front of the
+	// trailer so that the packet can be edited in place later.
+	xmpPacketPadding = 2048
+
+	// xmpPaddingLineLength is the length of each line of padding (including
+	// the newline).
+	xmpPaddingLineLength = 100
+
+	// extendedXmpGuidSize is the length of the hex-encoded MD5 that ties the
+	// extended chunks to the standard packet.
+	extendedXmpGuidSize = 32
+)
+
+var (
+	xmpPacketHeader = []byte("<?xpacket begin=\"\xef\xbb\xbf\" id=\"W5M0MpCehiHzreSzNTczkc9d\"?>\n")
+	xmpPacketTrailer = []byte("<?xpacket end=\"w\"?>")
+
+	xmpPacketHeaderRegex = regexp.MustCompile(`^\s*<\?xpacket\s+begin[^>]*\?>`)
+	xmpPacketTrailerRegex = regexp.MustCompile(`<\?xpacket\s+end[^>]*\?>\s*$`)
+
+	// maxXmpPacketSize is the largest packet that fits in one APP1 segment.
+	maxXmpPacketSize = maxSegmentPayloadSize - len(xmpPrefix)
+
+	// extendedXmpHeaderSize is the size of the chunk header: prefix, GUID,
+	// full length, and chunk offset.
+	extendedXmpHeaderSize = len(extendedXmpPrefix) + extendedXmpGuidSize + 4 + 4
+)
+
+// unwrapXmpPacket removes any xpacket header, trailer, and padding from the
+// packet.
+func unwrapXmpPacket(packet []byte) []byte {
+	if location := xmpPacketHeaderRegex.FindIndex(packet); location != nil {
+		packet = packet[location[1]:]
+	}
+
+	if location := xmpPacketTrailerRegex.FindIndex(packet); location != nil {
+		packet = packet[:location[0]]
+	}
+
+	return bytes.TrimSpace(packet)
+}
+
+// wrapXmpPacket adds the xpacket header and trailer with up to `padding`
+// bytes of whitespace.
+func wrapXmpPacket(body []byte, padding int) []byte {
+	b := new(bytes.Buffer)
+
+	b.Write(xmpPacketHeader)
+	b.Write(body)
+	b.WriteByte('\n')
+
+	for padding > 0 {
+		lineLength := xmpPaddingLineLength
+		if lineLength > padding {
+			lineLength = padding
+		}
+
+		b.WriteString(strings.Repeat(" ", lineLength - 1))
+		b.WriteByte('\n')
+
+		padding -= lineLength
+	}
+
+	b.Write(xmpPacketTrailer)
+
+	return b.Bytes()
+}
+
+// standardXmpForExtension returns the standard packet that points readers at
+// the extended packet with the given GUID.
+func standardXmpForExtension(guid string) []byte {
+	return []byte(fmt.Sprintf(
+		`<x:xmpmeta xmlns:x="adobe:ns:meta/">`+
+			`<rdf:RDF xmlns:rdf="%s">`+
+			`<rdf:Description rdf:about="" xmlns:xmpNote="%s" xmpNote:HasExtendedXMP="%s"/>`+
+			`</rdf:RDF>`+
+			`</x:xmpmeta>`,
+		rdfNamespace, xmpNoteNamespace, guid))
+}
+
+// encodeExtendedXmp splits the extended packet into APP1 payloads.
+func encodeExtendedXmp(extended []byte, guid string) [][]byte {
+	chunkSize := maxSegmentPayloadSize - extendedXmpHeaderSize
+	payloads := make([][]byte, 0)
+
+	for offset := 0; offset < len(extended); offset += chunkSize {
+		end := offset + chunkSize
+		if end > len(extended) {
+			end = len(extended)
+		}
+
+		payload := make([]byte, 0, extendedXmpHeaderSize + end - offset)
+		payload = append(payload, extendedXmpPrefix...)
+		payload = append(payload, guid...)
+
+		var sizes [8]byte
+		binary.BigEndian.PutUint32(sizes[0:], uint32(len(extended)))
+		binary.BigEndian.PutUint32(sizes[4:], uint32(offset))
+
+		payload = append(payload, sizes[:]...)
+		payload = append(payload, extended[offset:end]...)
+
+		payloads = append(payloads, payload)
+	}
+
+	return payloads
+}
+
+// SetXmp replaces the XMP in the image with the given packet (an x:xmpmeta
+// document, with or without an xpacket wrapper). The packet is written with
+// a fresh wrapper and padding. If it doesn't fit in one segment, it is
+// written as Extended XMP: the whole packet goes into the extended chunks and
+// the standard packet only carries the xmpNote:HasExtendedXMP reference. Any
+// existing standard or extended XMP segments are removed.
+//
+// The new segments take the place of the old standard packet or, if there
+// wasn't one, follow the SOI, JFIF, and EXIF segments.
+func (sl *SegmentList) SetXmp(xmlBytes []byte) (err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	body := unwrapXmpPacket(xmlBytes)
+
+	_, err = ParseXmpProperties(body)
+	log.PanicIf(err)
+
+	// A reference to an earlier extension is stale.
+	body, err = RemoveXmpProperties(body, []XmpPropertyName{{xmpNoteNamespace, "HasExtendedXMP"}})
+	log.PanicIf(err)
+
+	payloads := make([][]byte, 0, 1)
+
+	if len(wrapXmpPacket(body, 0)) <= maxXmpPacketSize {
+		padding := maxXmpPacketSize - len(wrapXmpPacket(body, 0))
+		if padding > xmpPacketPadding {
+			padding = xmpPacketPadding
+		}
+
+		packet := wrapXmpPacket(body, padding)
+		payloads = append(payloads, append(append([]byte{}, xmpPrefix...), packet...))
+	} else {
+		guid := fmt.Sprintf("%X", md5.Sum(body))
+
+		packet := wrapXmpPacket(standardXmpForExtension(guid), xmpPacketPadding)
+		payloads = append(payloads, append(append([]byte{}, xmpPrefix...), packet...))
+
+		payloads = append(payloads, encodeExtendedXmp(body, guid)...)
+	}
+
+	updated := make(SegmentList, 0, len(*sl) + len(payloads))
+
+	position := -1
+	afterHeaders := 0
+
+	for _, s := range *sl {
+		if (s.Kind() == SegmentKindXmpApp1 || s.Kind() == SegmentKindExtendedXmpApp1) {
+			if position == -1 && isXmpPayload(s.Data) == true {
+				position = len(updated)
+			}
+
+			continue
+		}
+
+		updated = append(updated, s)
+
+		isHeader := s.MarkerId == MARKER_SOI || (s.MarkerId == MARKER_APP0 && isJfifPayload(s.Data) == true) || s.Kind() == SegmentKindExifApp1
+		if isHeader == true && afterHeaders == len(updated) - 1 {
+			afterHeaders = len(updated)
+		}
+	}
+
+	if position == -1 {
+		position = afterHeaders
+	}
+
+	segments := make(SegmentList, len(payloads))
+	for i, payload := range payloads {
+		segments[i] = Segment{
+			MarkerId: MARKER_APP1,
+			MarkerName: markerNames[MARKER_APP1],
+			Data: payload,
+		}
+	}
+
+	updated = append(updated[:position], append(segments, updated[position:]...)...)
+	updated.updateOffsets()
+
+	*sl = updated
+	return nil
+}
@@ -0,0 +1,164 @@
+package jpegstructure
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"strings"
+	"testing"
+
+	"crypto/md5"
+	"encoding/binary"
+
+	"github.com/dsoprea/go-logging"
+)
+
+func getXmpTestPacket(title string) []byte {
+	return []byte(`<x:xmpmeta xmlns:x="adobe:ns:meta/">
+<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">
+<rdf:Description rdf:about="" xmlns:dc="http://purl.org/dc/elements/1.1/">
+<dc:title>` + title + `</dc:title>
+</rdf:Description>
+</rdf:RDF>
+</x:xmpmeta>`)
+}
+
+func getXmpTestSegments() SegmentList {
+	filepath := path.Join(assetsPath, testImageRelFilepath)
+
+	sl, err := ParseFileStructure(filepath)
+	log.PanicIf(err)
+
+	return sl
+}
+
+func TestSegmentList_SetXmp(t *testing.T) {
+	sl := getXmpTestSegments()
+
+	original, err := sl.App1Xmp()
+	log.PanicIf(err)
+
+	position := sl.Index(MARKER_APP1)
+	for i, s := range sl {
+		if s.Offset == original.Offset {
+			position = i
+		}
+	}
+
+	packet := getXmpTestPacket("Replaced")
+
+	err = sl.SetXmp(packet)
+	log.PanicIf(err)
+
+	if sl[position].Offset != original.Offset || isXmpPayload(sl[position].Data) == false {
+		t.Fatalf("XMP not replaced in place.")
+	}
+
+	data, err := sl.XmpData()
+	log.PanicIf(err)
+
+	if bytes.HasPrefix(data, xmpPacketHeader) == false || bytes.HasSuffix(data, xmpPacketTrailer) == false {
+		t.Fatalf("Packet wrapper not correct.")
+	} else if bytes.Contains(data, packet) == false {
+		t.Fatalf("Packet body not found.")
+	} else if len(data) != len(xmpPacketHeader) + len(packet) + 1 + xmpPacketPadding + len(xmpPacketTrailer) {
+		t.Fatalf("Packet padding not correct: (%d)", len(data))
+	}
+
+	properties, err := ParseXmpProperties(data)
+	log.PanicIf(err)
+
+	if len(properties) != 1 || properties[0].Value != "Replaced" {
+		t.Fatalf("Properties not correct: %v", properties)
+	}
+
+	// Replacing it again (from the wrapped form) must not accumulate
+	// wrappers or padding.
+	err = sl.SetXmp(data)
+	log.PanicIf(err)
+
+	rewritten, err := sl.XmpData()
+	log.PanicIf(err)
+
+	if bytes.Equal(rewritten, data) == false {
+		t.Fatalf("Rewrapped packet not stable.")
+	}
+}
+
+func TestSegmentList_SetXmp_Extended(t *testing.T) {
+	sl := getXmpTestSegments()
+
+	packet := getXmpTestPacket(strings.Repeat("x", 100000))
+
+	err := sl.SetXmp(packet)
+	log.PanicIf(err)
+
+	data, err := sl.XmpData()
+	log.PanicIf(err)
+
+	guid := fmt.Sprintf("%X", md5.Sum(packet))
+
+	properties, err := ParseXmpProperties(data)
+	log.PanicIf(err)
+
+	if len(properties) != 1 || properties[0].Name != "HasExtendedXMP" || properties[0].Value != guid {
+		t.Fatalf("Extension reference not correct: %v", properties)
+	}
+
+	extended := sl.FindWithPrefix(MARKER_APP1, extendedXmpPrefix)
+	if len(extended) != 2 {
+		t.Fatalf("Extended chunk count not correct: (%d)", len(extended))
+	}
+
+	assembled := make([]byte, len(packet))
+	for _, s := range extended {
+		header := s.Data[len(extendedXmpPrefix):]
+
+		if string(header[:extendedXmpGuidSize]) != guid {
+			t.Fatalf("Chunk GUID not correct.")
+		} else if binary.BigEndian.Uint32(header[extendedXmpGuidSize:]) != uint32(len(packet)) {
+			t.Fatalf("Chunk full-length not correct.")
+		}
+
+		offset := binary.BigEndian.Uint32(header[extendedXmpGuidSize + 4:])
+		copy(assembled[offset:], header[extendedXmpGuidSize + 8:])
+	}
+
+	if bytes.Equal(assembled, packet) == false {
+		t.Fatalf("Extended packet not correct.")
+	}
+
+	reassembled, err := sl.ExtendedXmpData()
+	log.PanicIf(err)
+
+	if bytes.Equal(reassembled, packet) == false {
+		t.Fatalf("Reassembled extended packet not correct.")
+	}
+
+	// A small replacement removes the stale chunks.
+	err = sl.SetXmp(getXmpTestPacket("Small"))
+	log.PanicIf(err)
+
+	if len(sl.FindWithPrefix(MARKER_APP1, extendedXmpPrefix)) != 0 {
+		t.Fatalf("Stale extended chunks not removed.")
+	}
+}
+
+func TestSegmentList_SetXmp_Inserted(t *testing.T) {
+	exifData, err := NewExifDocument(binary.LittleEndian).Encode()
+	log.PanicIf(err)
+
+	sl := SegmentList {
+		{MarkerId: MARKER_SOI, MarkerName: "SOI"},
+		{MarkerId: MARKER_APP1, MarkerName: "APP1", Data: append(append([]byte{}, exifPrefix...), exifData...)},
+		{MarkerId: MARKER_COM, MarkerName: "COM", Data: []byte("comment")},
+		{MarkerId: MARKER_EOI, MarkerName: "EOI"},
+	}
+
+	err = sl.SetXmp(getXmpTestPacket("Inserted"))
+	log.PanicIf(err)
+
+	if len(sl) != 5 || isXmpPayload(sl[2].Data) == false {
+		t.Fatalf("XMP not inserted after the EXIF segment.")
+	}
+}
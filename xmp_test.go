@@ -0,0 +1,32 @@
+package jpegstructure
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/dsoprea/go-logging"
+)
+
+func TestParseXmpProperties(t *testing.T) {
+	packet := []byte(`<x:xmpmeta xmlns:x="adobe:ns:meta/">
+<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">
+<rdf:Description rdf:about="" xmlns:xmp="http://ns.adobe.com/xap/1.0/" xmlns:dc="http://purl.org/dc/elements/1.1/" xmp:Rating="3">
+<xmp:CreateDate>2018-04-28T21:23:14</xmp:CreateDate>
+<dc:creator><rdf:Seq><rdf:li>Alice</rdf:li><rdf:li>Bob</rdf:li></rdf:Seq></dc:creator>
+</rdf:Description>
+</rdf:RDF>
+</x:xmpmeta>`)
+
+	properties, err := ParseXmpProperties(packet)
+	log.PanicIf(err)
+
+	expected := []XmpProperty {
+		{"http://ns.adobe.com/xap/1.0/", "xmp", "Rating", "3"},
+		{"http://ns.adobe.com/xap/1.0/", "xmp", "CreateDate", "2018-04-28T21:23:14"},
+		{"http://purl.org/dc/elements/1.1/", "dc", "creator", "Alice, Bob"},
+	}
+
+	if reflect.DeepEqual(properties, expected) == false {
+		t.Fatalf("Properties not correct: %v", properties)
+	}
+}